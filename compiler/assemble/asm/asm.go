@@ -0,0 +1,83 @@
+// Package asm 汇编器的库接口, 在进程内把汇编源码转换为 ELF 可重定位目标文件, 不需要读写临时文件:
+//
+//	obj, err := asm.Assemble(src, asm.Options{Arch: "amd64", Syntax: "att"})
+//	if list, ok := err.(asm.ErrorList); ok {
+//		for _, e := range list { // 源码错误, 带行列号
+//			fmt.Println(e.Pos, e.Message)
+//		}
+//	}
+//	os.WriteFile("a.o", obj.Data, 0666)
+package asm
+
+import (
+	"fmt"
+	"github.com/facelang/face/compiler/assemble/internal"
+	"github.com/facelang/face/internal/os/elf"
+	"github.com/facelang/face/internal/prog"
+	"io"
+)
+
+// Error 一条源码错误, 包含文件名、行列号及错误信息
+type Error = prog.Error
+
+// ErrorList 源码错误列表, 按位置排序; Assemble 遇到源码错误时返回该类型
+type ErrorList = prog.ErrorList
+
+// Options 汇编选项, 零值表示以 Intel 语法汇编 x86-64 源码
+type Options struct {
+	Filename string    // 源文件名, 用于错误信息、调试信息及查找 .include 的相对路径, 默认为 <input>
+	Arch     string    // 目标架构: 386, amd64(默认), arm64, riscv64
+	Syntax   string    // 语法风格: att, intel, plan9; 默认 x86 为 intel, 其它架构为 att
+	Includes []string  // .include 的查找目录
+	Debug    bool      // 生成 DWARF 调试信息(行号表)
+	PIC      bool      // 生成位置无关代码(-fpic)
+	Listing  io.Writer // 不为 nil 时同时输出列表(地址、机器码及源码)
+}
+
+// Object 汇编结果
+type Object struct {
+	Data []byte    // ELF 可重定位目标文件的完整内容
+	File *elf.File // 目标文件的结构, 64 位目标为 ELF64 及 RELA 格式的重定位
+}
+
+// Assemble 汇编内存中的源码; 出错时返回 ErrorList 或其它错误(参数错误、读取包含文件失败等), 不会 panic
+func Assemble(src []byte, opts Options) (*Object, error) {
+	if opts.Filename == "" {
+		opts.Filename = "<input>"
+	}
+	if opts.Arch == "" {
+		opts.Arch = "amd64"
+	}
+	if opts.Syntax == "" {
+		opts.Syntax = "intel"
+		if opts.Arch != "386" && opts.Arch != "amd64" { // 非 x86 架构只有 GAS 语法
+			opts.Syntax = "att"
+		}
+	}
+	syntax, err := internal.ParseSyntax(opts.Syntax)
+	if err != nil {
+		return nil, err
+	}
+
+	data, file, err := internal.Assemble(opts.Filename, src, internal.Config{
+		Arch:     opts.Arch,
+		Syntax:   syntax,
+		Includes: opts.Includes,
+		Debug:    opts.Debug,
+		PIC:      opts.PIC,
+		Listing:  opts.Listing,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Data: data, File: file}, nil
+}
+
+// AssembleReader 读取并汇编 r 中的全部源码
+func AssembleReader(r io.Reader, opts Options) (*Object, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("读取源码失败: %w", err)
+	}
+	return Assemble(src, opts)
+}
@@ -0,0 +1,82 @@
+package asm
+
+import (
+	"bytes"
+	"github.com/facelang/face/internal/os/elf"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		opts    Options
+		src     string
+		machine elf.Machine
+		class   elf.Class
+	}{
+		{Options{Arch: "386"}, "mov eax, 1\nint 0x80\n", elf.EM_386, elf.ELFCLASS32},
+		{Options{}, "mov rax, 60\nret\n", elf.EM_X86_64, elf.ELFCLASS64},
+		{Options{Syntax: "att"}, "movq $60, %rax\nret\n", elf.EM_X86_64, elf.ELFCLASS64},
+		{Options{Arch: "arm64"}, "mov x8, #93\nsvc #0\n", elf.EM_AARCH64, elf.ELFCLASS64},
+		{Options{Arch: "riscv64"}, "li a7, 93\necall\n", elf.EM_RISCV, elf.ELFCLASS64},
+	}
+	for _, tt := range tests {
+		obj, err := Assemble([]byte(tt.src), tt.opts)
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		data := obj.Data
+		if !bytes.HasPrefix(data, []byte(elf.ELFMAG)) || elf.Class(data[elf.EI_CLASS]) != tt.class {
+			t.Errorf("%q: 不是 %s 目标文件", tt.src, tt.class)
+			continue
+		}
+		if machine := elf.Machine(uint16(data[18]) | uint16(data[19])<<8); machine != tt.machine {
+			t.Errorf("%q: machine got %s, want %s", tt.src, machine, tt.machine)
+		}
+		if obj.File == nil || obj.File.Bits() != int(tt.class) || !bytes.Equal(obj.File.Bytes(), data) {
+			t.Errorf("%q: File 与目标文件内容不一致", tt.src)
+		}
+	}
+
+	obj, err := AssembleReader(strings.NewReader("ret\n"), Options{Arch: "386", Listing: &bytes.Buffer{}})
+	if err != nil || obj.File.ShdrTab[".text"].Size != 1 {
+		t.Errorf("AssembleReader: %v", err)
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	_, err := Assemble([]byte("ret\nmov eax,\nmov ebx, 1 2\n"), Options{Filename: "bad.s"})
+	list, ok := err.(ErrorList)
+	if !ok || len(list) != 2 {
+		t.Fatalf("got %v, want 2 errors", err)
+	}
+	if list[0].Pos.Filename != "bad.s" || list[0].Pos.Line != list[1].Pos.Line-1 {
+		t.Errorf("错误位置: %v, %v", list[0].Pos, list[1].Pos)
+	}
+
+	for _, opts := range []Options{{Arch: "mips"}, {Syntax: "masm"}, {Arch: "arm64", Syntax: "intel"}} {
+		if _, err := Assemble([]byte("ret\n"), opts); err == nil {
+			t.Errorf("%+v: 期望出错", opts)
+		}
+	}
+}
+
+func FuzzAssemble(f *testing.F) {
+	for _, src := range []string{
+		"mov eax, [ebx+ecx*4+8]\n",
+		".macro m a\n.byte \\a\n.endm\nm 1\n",
+		"1: jmp 1b\n.align 16\n.fill 3, 2, 0x90\n",
+		".section .data\nx: .quad x - .\n.equ y, x+1\n",
+		".if 1\n.rept 3\nnop\n.endr\n.endif\n",
+	} {
+		f.Add([]byte(src), "amd64")
+	}
+	f.Add([]byte("ldr x0, [sp, #8]!\nb.ne 1f\n1:\n"), "arm64")
+	f.Add([]byte("la a0, x\nx: call x\n.option push\n"), "riscv64")
+	f.Fuzz(func(t *testing.T, src []byte, arch string) {
+		if _, err := Assemble(src, Options{Arch: arch}); err != nil && strings.Contains(err.Error(), "内部错误") {
+			t.Fatal(err)
+		}
+	})
+}
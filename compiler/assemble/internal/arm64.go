@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/facelang/face/internal/arch"
+	"github.com/facelang/face/internal/prog"
+	"strings"
+)
+
+// AArch64 汇编(GAS 语法), 指令在解析时直接编码为 32 位指令字:
+//
+//	add x0, x1, #4
+//	stp x29, x30, [sp, #-16]!
+//	ldr x1, [x0, x2, lsl #3]
+//	adrp x0, msg
+//	add x0, x0, :lo12:msg
+//	b.ne 1b
+//
+// 立即数必须是常量; 符号引用记录为 fixup, 代码生成时同一段内的 pc 相对引用直接回填, 其余生成 R_AARCH64_* 重定位.
+
+// a64Kind 操作数类型
+type a64Kind uint8
+
+const (
+	a64Reg   a64Kind = iota + 1 // 寄存器
+	a64Imm                      // 常量 #imm
+	a64Sym                      // 符号表达式: 跳转目标、标号, 可带 :lo12: 等重定位修饰
+	a64Mem                      // 内存引用 [xn, ...]
+	a64Shift                    // 移位及扩展: lsl #n, sxtw
+	a64Name                     // 条件码(eq, ne ...)及屏障选项(ish, sy ...)
+)
+
+var a64KindNames = [...]string{
+	a64Reg:   "寄存器",
+	a64Imm:   "立即数",
+	a64Sym:   "符号",
+	a64Mem:   "内存引用",
+	a64Shift: "移位",
+	a64Name:  "条件码或选项",
+}
+
+// 内存引用的寻址方式
+const (
+	a64Offset = iota // [xn, #imm]
+	a64Pre           // [xn, #imm]!
+	a64Post          // [xn], #imm
+)
+
+// a64Shifts 移位及扩展方式
+var a64Shifts = map[string]bool{
+	"lsl": true, "lsr": true, "asr": true, "ror": true,
+	"uxtb": true, "uxth": true, "uxtw": true, "uxtx": true,
+	"sxtb": true, "sxth": true, "sxtw": true, "sxtx": true,
+}
+
+// a64Mods 支持的重定位修饰
+var a64Mods = map[string]bool{"lo12": true, "got": true, "got_lo12": true}
+
+// a64Arg AArch64 操作数
+type a64Arg struct {
+	Kind     a64Kind
+	Name     string   // 寄存器、条件码等标识符的名称
+	Reg      int16    // 寄存器, 内存引用的基址寄存器
+	Index    int16    // 内存引用的变址寄存器, -1 表示没有
+	Imm      int64    // 立即数, 内存引用的偏移
+	Sym      *operand // 符号引用, 值为 符号 + Value
+	Mod      string   // 重定位修饰: lo12, got, got_lo12
+	Shift    string   // 移位或扩展方式, 内存引用中为变址寄存器的扩展方式
+	Amount   int64    // 移位量
+	Explicit bool     // 是否指定了移位量
+	Mode     int      // 内存引用的寻址方式
+}
+
+// arm64Stmt 解析并编码一条 AArch64 指令; 名称已读取
+func (p *parser) arm64Stmt(id string, pos prog.FilePos) {
+	name := strings.ToLower(id)
+	as, ok := p.arch.InstrTable[name]
+	if !ok {
+		p.errorAt(pos, fmt.Sprintf("不支持的指令: %s", id))
+	}
+	named := a64Named(as)
+	var args []*a64Arg
+	if !p.atEOL() {
+		args = append(args, p.a64Operand(named))
+		for p.got(COMMA) {
+			args = append(args, p.a64Operand(named))
+		}
+	}
+	e := &a64Encoder{name: name, args: args}
+	e.encode(as)
+	if e.err != nil {
+		p.errorAt(pos, fmt.Sprintf("%s: %s", name, e.err))
+	}
+	p.sec.Align = max(p.sec.Align, 4) // 指令所在的段按指令长度对齐
+	p.emit(&instr{Opcode: K_INSN, Times: 1, Pos: pos, Insn: &e.insn})
+}
+
+// a64Named 操作数中可以出现条件码或屏障选项的指令
+func a64Named(as arch.As) bool {
+	switch as {
+	case arch.A64_CSEL, arch.A64_CSINC, arch.A64_CSINV, arch.A64_CSNEG,
+		arch.A64_CSET, arch.A64_CSETM, arch.A64_CINC, arch.A64_CNEG,
+		arch.A64_DMB, arch.A64_DSB, arch.A64_ISB:
+		return true
+	}
+	return false
+}
+
+// a64Operand 解析一个操作数; named 为 true 时不是寄存器的标识符作为条件码或屏障选项
+func (p *parser) a64Operand(named bool) *a64Arg {
+	switch p.token {
+	case HASH:
+		p.next()
+		if p.token == COLON {
+			return p.a64Reloc()
+		}
+		return &a64Arg{Kind: a64Imm, Imm: p.number()}
+	case COLON:
+		return p.a64Reloc()
+	case LBRACK:
+		return p.a64Memory()
+	case IDENT:
+		name := strings.ToLower(p.id)
+		if reg, ok := p.arch.Register[name]; ok {
+			p.next()
+			return &a64Arg{Kind: a64Reg, Name: name, Reg: reg}
+		}
+		if a64Shifts[name] {
+			p.next()
+			arg := &a64Arg{Kind: a64Shift, Shift: name}
+			if p.got(HASH) {
+				arg.Amount, arg.Explicit = p.number(), true
+			}
+			return arg
+		}
+		if named {
+			p.next()
+			return &a64Arg{Kind: a64Name, Name: name}
+		}
+	}
+	arg := &a64Arg{Kind: a64Sym, Sym: &operand{Type: OPRTP_IMM}}
+	p.setExpr(arg.Sym, p.expr())
+	if !arg.Sym.Symbolic() { // 省略 # 的常量
+		return &a64Arg{Kind: a64Imm, Imm: arg.Sym.Value}
+	}
+	return arg
+}
+
+// a64Reloc 带重定位修饰的符号引用: :lo12:sym, :got:sym, :got_lo12:sym
+func (p *parser) a64Reloc() *a64Arg {
+	p.expect(COLON)
+	pos, mod := p.pos, strings.ToLower(p.ident())
+	if !a64Mods[mod] {
+		p.errorAt(pos, fmt.Sprintf("不支持的重定位修饰: %s", mod))
+	}
+	p.expect(COLON)
+	arg := &a64Arg{Kind: a64Sym, Mod: mod, Sym: &operand{Type: OPRTP_IMM}}
+	p.setExpr(arg.Sym, p.expr())
+	return arg
+}
+
+// a64Memory 解析内存引用:
+//
+//	[xn{, #imm}]  [xn, #imm]!  [xn], #imm  [xn, :lo12:sym]  [xn, xm{, lsl #s}]  [xn, wm, sxtw|uxtw {#s}]
+func (p *parser) a64Memory() *a64Arg {
+	p.expect(LBRACK)
+	arg := &a64Arg{Kind: a64Mem, Reg: p.a64Register(), Index: -1}
+	if p.got(COMMA) {
+		switch p.token {
+		case HASH, COLON:
+			off := p.a64Operand(false)
+			arg.Imm, arg.Sym, arg.Mod = off.Imm, off.Sym, off.Mod
+		default:
+			arg.Index = p.a64Register()
+			if p.got(COMMA) {
+				pos, ext := p.pos, p.a64Operand(false)
+				if ext.Kind != a64Shift {
+					p.errorAt(pos, "变址寄存器之后应为扩展方式")
+				}
+				arg.Shift, arg.Amount, arg.Explicit = ext.Shift, ext.Amount, ext.Explicit
+			}
+		}
+	}
+	p.expect(RBRACK)
+	switch {
+	case p.got(EXCL):
+		arg.Mode = a64Pre
+	case p.got(COMMA): // 内存引用总是最后一个操作数, 之后只能是后变址的偏移
+		p.expect(HASH)
+		arg.Mode, arg.Imm = a64Post, p.number()
+	}
+	return arg
+}
+
+// a64Register 读取一个寄存器
+func (p *parser) a64Register() int16 {
+	reg, ok := p.arch.Register[strings.ToLower(p.id)]
+	if p.token != IDENT || !ok {
+		p.unexpect("register")
+	}
+	p.next()
+	return reg
+}
+
+// arm64Directive AArch64 上含义与 x86 不同或特有的伪指令, 返回是否已处理
+func (p *parser) arm64Directive(name string) bool {
+	switch name {
+	case ".hword":
+		p.attData(K_DW, 2)
+	case ".word":
+		p.attData(K_DD, 4)
+	case ".xword", ".dword":
+		p.attData(K_DQ, 8)
+	case ".align": // 与 GAS 一致, AArch64 上 .align n 按 2^n 字节对齐
+		p.attAlign(true)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"encoding/binary"
+	"github.com/facelang/face/internal/arch"
+	"github.com/facelang/face/internal/os/elf"
+	"testing"
+)
+
+// words 代码段的指令字
+func words(p *parser) []uint32 {
+	data := p.findSection(".text").Data
+	var ws []uint32
+	for i := 0; i+4 <= len(data); i += 4 {
+		ws = append(ws, binary.LittleEndian.Uint32(data[i:]))
+	}
+	return ws
+}
+
+func TestArm64Encode(t *testing.T) {
+	// 期望结果来自 llvm-mc -triple=aarch64 的输出
+	tests := []struct {
+		src  string
+		want uint32
+	}{
+		{"add x0, x1, #4", 0x91001020},
+		{"add sp, sp, #0x1000", 0x914007FF},
+		{"add x0, x1, #-8", 0xD1002020},
+		{"sub x0, x1, x2, lsl #3", 0xCB020C20},
+		{"add x0, x1, w2, uxtw #2", 0x8B224820},
+		{"cmp sp, x1", 0xEB2163FF},
+		{"cmn x0, #1", 0xB100041F},
+		{"neg x0, x1", 0xCB0103E0},
+		{"and w0, w1, #0xf0f0f0f0", 0x1204CC20},
+		{"orr x0, x1, #0x5555555555555555", 0xB200F020},
+		{"eor x2, x3, x4, ror #7", 0xCAC41C62},
+		{"tst x0, #0x8000000000000000", 0xF241001F},
+		{"mvn x0, x1", 0xAA2103E0},
+		{"mov x29, sp", 0x910003FD},
+		{"mov x0, x1", 0xAA0103E0},
+		{"mov x0, #0x10000", 0xD2A00020},
+		{"mov w0, #-2", 0x12800020},
+		{"mov x0, #0xffffffff", 0xB2407FE0},
+		{"movk x0, #0x1234, lsl #16", 0xF2A24680},
+		{"lsl x0, x1, #3", 0xD37DF020},
+		{"asr w0, w1, w2", 0x1AC22820},
+		{"ror x0, x1, #4", 0x93C11020},
+		{"mul x0, x1, x2", 0x9B027C20},
+		{"umull x0, w1, w2", 0x9BA27C20},
+		{"udiv w0, w1, w2", 0x1AC20820},
+		{"cset w0, ne", 0x1A9F07E0},
+		{"cinc x0, x1, le", 0x9A81C420},
+		{"csel x0, x1, x2, eq", 0x9A820020},
+		{"sxtw x0, w1", 0x93407C20},
+		{"ubfx w0, w1, #3, #5", 0x53031C20},
+		{"rev x0, x1", 0xDAC00C20},
+		{"ret", 0xD65F03C0},
+		{"blr x1", 0xD63F0020},
+		{"svc #0", 0xD4000001},
+		{"dmb ish", 0xD5033BBF},
+		{"isb", 0xD5033FDF},
+		{"ldr x0, [sp, #8]", 0xF94007E0},
+		{"ldr x0, [x1, #-8]", 0xF85F8020},
+		{"str x0, [sp, #-16]!", 0xF81F0FE0},
+		{"ldr x0, [sp], #16", 0xF84107E0},
+		{"ldrsb w0, [x1]", 0x39C00020},
+		{"ldrsw x0, [x1, #4]", 0xB9800420},
+		{"ldr w0, [x1, w2, sxtw #2]", 0xB862D820},
+		{"ldrb w0, [x1, x2, lsl #0]", 0x38627820},
+		{"ldp x29, x30, [sp], #16", 0xA8C17BFD},
+		{"stp w0, w1, [x2, #8]", 0x29010440},
+		{"stxr w2, x0, [x1]", 0xC8027C20},
+		{"ldar x0, [x1]", 0xC8DFFC20},
+	}
+
+	for _, tt := range tests {
+		ws := words(assemble(t, "arm64", SyntaxATT, tt.src))
+		if len(ws) != 1 || ws[0] != tt.want {
+			t.Errorf("%q: got %08x, want %08x", tt.src, ws, tt.want)
+		}
+	}
+}
+
+func TestArm64Branch(t *testing.T) {
+	// 同一段内的局部标签直接回填, 对齐填充 nop
+	src := `
+f:	cbz x0, 1f
+	b.ne f
+	tbnz w1, #2, f
+	adr x2, 1f
+	ldr x3, 1f
+	bl f
+	.align 4
+1:	.xword 1
+`
+	p := assemble(t, "arm64", SyntaxATT, src)
+	want := []uint32{0xB4000100, 0x54FFFFE1, 0x3717FFC1, 0x100000A2, 0x58000083, 0x97FFFFFB, a64Nop, a64Nop, 1, 0}
+	ws := words(p)
+	if len(ws) != len(want) {
+		t.Fatalf("got %08x, want %08x", ws, want)
+	}
+	for i := range want {
+		if ws[i] != want[i] {
+			t.Errorf("第 %d 条: got %08x, want %08x", i, ws[i], want[i])
+		}
+	}
+	if len(p.relocateList) != 0 {
+		t.Errorf("局部跳转不应生成重定位, got %d", len(p.relocateList))
+	}
+}
+
+func TestArm64Reloc(t *testing.T) {
+	src := `
+	adrp x0, msg
+	add x0, x0, :lo12:msg
+	ldr w1, [x0, :lo12:msg]
+	adrp x1, :got:ext
+	ldr x1, [x1, #:got_lo12:ext]
+	bl ext
+	b.eq ext
+	.data
+msg:	.word 1
+	.xword ext
+`
+	p := assemble(t, "arm64", SyntaxATT, src)
+	want := []struct {
+		typ    elf.R_AARCH64
+		label  string
+		offset int
+	}{
+		{elf.R_AARCH64_ADR_PREL_PG_HI21, ".data", 0},
+		{elf.R_AARCH64_ADD_ABS_LO12_NC, ".data", 4},
+		{elf.R_AARCH64_LDST32_ABS_LO12_NC, ".data", 8},
+		{elf.R_AARCH64_ADR_GOT_PAGE, "ext", 12},
+		{elf.R_AARCH64_LD64_GOT_LO12_NC, "ext", 16},
+		{elf.R_AARCH64_CALL26, "ext", 20},
+		{elf.R_AARCH64_CONDBR19, "ext", 24},
+		{elf.R_AARCH64_ABS64, "ext", 4},
+	}
+	if len(p.relocateList) != len(want) {
+		t.Fatalf("重定位: got %d, want %d", len(p.relocateList), len(want))
+	}
+	for i, w := range want {
+		rel := p.relocateList[i]
+		if elf.R_AARCH64(rel.Type) != w.typ || rel.Label != w.label || rel.Offset != w.offset {
+			t.Errorf("第 %d 项: got %s %s %d, want %s %s %d", i,
+				elf.R_AARCH64(rel.Type), rel.Label, rel.Offset, w.typ, w.label, w.offset)
+		}
+	}
+
+	for _, src := range []string{
+		"add x0, x1",
+		"add w0, x1, #1",
+		"mov x0, #0x12345",
+		"and x0, x1, #0",
+		"ldr x0, [x1, #40000]",
+		"ldrb x0, [x1]",
+		"stp x0, x1, [sp, #12]",
+		"b.xx 1f",
+		"cset x0, al",
+		"adrp x0, :lo12:msg",
+		"add x0, xzr, #1",
+		"1: tbz x0, #64, 1b",
+	} {
+		lex := NewBytesLexer([]byte(src))
+		lex.syntax = SyntaxATT
+		p := NewParser(lex)
+		p.setArch(arch.Set("arm64"))
+		if err := p.ParseFile(); err == nil && p.Codegen() == nil {
+			t.Errorf("%q: 期望出错", src)
+		}
+	}
+}
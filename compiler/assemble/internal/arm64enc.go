@@ -0,0 +1,1007 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/facelang/face/internal/arch"
+	"github.com/facelang/face/internal/os/elf"
+	"math/bits"
+	"slices"
+)
+
+// a64Nop nop 指令, 也用于代码段的对齐填充
+const a64Nop = 0xD503201F
+
+// a64Encoder AArch64 指令编码, 记录第一个错误后继续, 由调用者统一报告
+type a64Encoder struct {
+	name string
+	args []*a64Arg
+	insn insn
+	err  error
+}
+
+func (e *a64Encoder) errorf(format string, args ...any) {
+	if e.err == nil {
+		e.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (e *a64Encoder) emit(word uint32) {
+	e.insn.Code = binary.LittleEndian.AppendUint32(e.insn.Code, word)
+}
+
+// fixup 下一个指令字中的符号引用, pcRel 表示同一段内的局部符号可以直接回填
+func (e *a64Encoder) fixup(sym *operand, reloc elf.R_AARCH64, pcRel bool) {
+	e.insn.Fixups = append(e.insn.Fixups, fixup{
+		Offset: len(e.insn.Code),
+		Size:   4,
+		Label:  sym.Label,
+		Expr:   sym.Expr,
+		Addend: sym.Value,
+		PCRel:  pcRel,
+		Reloc:  int(reloc),
+	})
+}
+
+// count 检查操作数个数
+func (e *a64Encoder) count(lo, hi int) {
+	switch n := len(e.args); {
+	case lo == hi && n != lo:
+		e.errorf("需要 %d 个操作数", lo)
+	case n < lo || n > hi:
+		e.errorf("需要 %d 至 %d 个操作数", lo, hi)
+	}
+}
+
+// arg 第 i 个操作数, 类型不符时报错
+func (e *a64Encoder) arg(i int, kind a64Kind) *a64Arg {
+	if i >= len(e.args) {
+		e.errorf("缺少第 %d 个操作数", i+1)
+		return &a64Arg{Kind: kind, Index: -1, Sym: &operand{}}
+	}
+	if a := e.args[i]; a.Kind != kind {
+		e.errorf("第 %d 个操作数应为%s", i+1, a64KindNames[kind])
+	}
+	return e.args[i]
+}
+
+// kind 第 i 个操作数的类型, 不存在时为 0
+func (e *a64Encoder) kind(i int) a64Kind {
+	if i < len(e.args) {
+		return e.args[i].Kind
+	}
+	return 0
+}
+
+// reg 第 i 个操作数的寄存器编号; sp 为 true 时编号 31 表示栈指针, 否则表示零寄存器
+func (e *a64Encoder) reg(i int, sp bool) uint32 {
+	a := e.arg(i, a64Reg)
+	if a.Kind != a64Reg {
+		return 0
+	}
+	return e.regNum(a.Reg, a.Name, sp)
+}
+
+func (e *a64Encoder) regNum(reg int16, name string, sp bool) uint32 {
+	if isSP := reg&arch.REG_A64_SP != 0; reg&31 == 31 && isSP != sp {
+		if isSP {
+			e.errorf("不能使用栈指针 %s", name)
+		} else {
+			e.errorf("不能使用零寄存器 %s", name)
+		}
+	}
+	return uint32(reg & 31)
+}
+
+// wide 第 i 个操作数是否为 64 位寄存器
+func (e *a64Encoder) wide(i int) bool {
+	return i < len(e.args) && e.args[i].Kind == a64Reg && e.args[i].Reg&arch.REG_A64_W == 0
+}
+
+// sf 第 i 个操作数决定的操作宽度: 64 位时为 1<<31
+func (e *a64Encoder) sf(i int) uint32 {
+	if e.wide(i) {
+		return 1 << 31
+	}
+	return 0
+}
+
+// width 第 i 个操作数的位数
+func (e *a64Encoder) width(i int) int64 {
+	if e.wide(i) {
+		return 64
+	}
+	return 32
+}
+
+// isSP 第 i 个操作数是否为栈指针
+func (e *a64Encoder) isSP(i int) bool {
+	return e.kind(i) == a64Reg && e.args[i].Reg&arch.REG_A64_SP != 0
+}
+
+// same 检查寄存器操作数的宽度一致
+func (e *a64Encoder) same(regs ...int) {
+	for _, i := range regs[1:] {
+		if e.kind(i) == a64Reg && e.kind(regs[0]) == a64Reg && e.wide(i) != e.wide(regs[0]) {
+			e.errorf("寄存器 %s 与 %s 的宽度不一致", e.args[regs[0]].Name, e.args[i].Name)
+		}
+	}
+}
+
+// wantWide 检查第 i 个操作数是 64 位(wide 为 true)或 32 位寄存器
+func (e *a64Encoder) wantWide(i int, wide bool) {
+	if e.kind(i) == a64Reg && e.wide(i) != wide {
+		if wide {
+			e.errorf("%s: 需要 64 位寄存器", e.args[i].Name)
+		} else {
+			e.errorf("%s: 需要 32 位寄存器", e.args[i].Name)
+		}
+	}
+}
+
+// imm 第 i 个操作数的立即数, 检查范围 [lo, hi]
+func (e *a64Encoder) imm(i int, lo, hi int64) uint32 {
+	a := e.arg(i, a64Imm)
+	if a.Kind == a64Imm && (a.Imm < lo || a.Imm > hi) {
+		e.errorf("立即数 %d 超出范围 [%d, %d]", a.Imm, lo, hi)
+	}
+	return uint32(a.Imm)
+}
+
+// insertZR 在第 i 个位置插入与第 ref 个操作数同宽的零寄存器, 用于 cmp、neg、mul 等别名
+func (e *a64Encoder) insertZR(i, ref int) {
+	zr := a64Arg{Kind: a64Reg, Name: "xzr", Reg: arch.REG_A64_ZR, Index: -1}
+	if e.kind(ref) == a64Reg && !e.wide(ref) {
+		zr.Name, zr.Reg = "wzr", zr.Reg|arch.REG_A64_W
+	}
+	if i > len(e.args) {
+		i = len(e.args)
+	}
+	e.args = slices.Insert(e.args, i, &zr)
+}
+
+// shift 第 i 个操作数的移位方式(可省略), names 的下标为编码, width 为移位量的上限
+func (e *a64Encoder) shift(i int, names []string, width int64) (typ, amount uint32) {
+	if i >= len(e.args) {
+		return 0, 0
+	}
+	a := e.arg(i, a64Shift)
+	n := slices.Index(names, a.Shift)
+	switch {
+	case a.Kind != a64Shift:
+	case n < 0:
+		e.errorf("不支持移位方式 %s", a.Shift)
+	case !a.Explicit:
+		e.errorf("%s 缺少移位量", a.Shift)
+	case a.Amount < 0 || a.Amount >= width:
+		e.errorf("移位量 %d 超出范围", a.Amount)
+	}
+	return uint32(max(n, 0)), uint32(a.Amount)
+}
+
+// cond 第 i 个操作数的条件码
+func (e *a64Encoder) cond(i int) uint32 {
+	a := e.arg(i, a64Name)
+	c, ok := arch.A64Conds[a.Name]
+	if a.Kind == a64Name && !ok {
+		e.errorf("无效的条件码 %s", a.Name)
+	}
+	return c
+}
+
+// invert 别名指令使用相反的条件码, al/nv 没有相反条件
+func (e *a64Encoder) invert(c uint32) uint32 {
+	if c >= 14 {
+		e.errorf("不能使用条件码 al/nv")
+	}
+	return c ^ 1
+}
+
+var (
+	a64ShiftNames  = []string{"lsl", "lsr", "asr"}
+	a64RotateNames = []string{"lsl", "lsr", "asr", "ror"}
+	a64ExtendNames = []string{"uxtb", "uxth", "uxtw", "uxtx", "sxtb", "sxth", "sxtw", "sxtx"}
+)
+
+// a64Barriers dmb/dsb 的屏障选项
+var a64Barriers = map[string]uint32{
+	"oshld": 1, "oshst": 2, "osh": 3, "nshld": 5, "nshst": 6, "nsh": 7,
+	"ishld": 9, "ishst": 10, "ish": 11, "ld": 13, "st": 14, "sy": 15,
+}
+
+// a64LoadStores 加载/存储指令的访问宽度(log2)及操作码; size 为 -1 时由 rt 的宽度决定,
+// opc 为 -1 时(有符号加载)按 rt 的宽度选择 2(64 位)或 3(32 位)
+var a64LoadStores = map[arch.As]struct {
+	size, opc int
+	unscaled  bool
+}{
+	arch.A64_LDR:   {-1, 1, false},
+	arch.A64_STR:   {-1, 0, false},
+	arch.A64_LDRB:  {0, 1, false},
+	arch.A64_STRB:  {0, 0, false},
+	arch.A64_LDRH:  {1, 1, false},
+	arch.A64_STRH:  {1, 0, false},
+	arch.A64_LDRSB: {0, -1, false},
+	arch.A64_LDRSH: {1, -1, false},
+	arch.A64_LDRSW: {2, 2, false},
+	arch.A64_LDUR:  {-1, 1, true},
+	arch.A64_STUR:  {-1, 0, true},
+	arch.A64_LDURB: {0, 1, true},
+	arch.A64_STURB: {0, 0, true},
+	arch.A64_LDURH: {1, 1, true},
+	arch.A64_STURH: {1, 0, true},
+}
+
+// a64Exclusives 独占及获取/释放语义的加载存储, 值为 64 位形式的编码, 32 位形式清除第 30 位
+var a64Exclusives = map[arch.As]uint32{
+	arch.A64_LDXR:  0xC85F7C00,
+	arch.A64_LDAXR: 0xC85FFC00,
+	arch.A64_LDAR:  0xC8DFFC00,
+	arch.A64_STLR:  0xC89FFC00,
+	arch.A64_STXR:  0xC8007C00,
+	arch.A64_STLXR: 0xC800FC00,
+}
+
+// encode 按指令编号编码, 别名指令转换为基本指令
+func (e *a64Encoder) encode(as arch.As) {
+	switch as {
+	case arch.A64_ADD, arch.A64_SUB:
+		e.addSub(uint32(as-arch.A64_ADD)/2, false)
+	case arch.A64_ADDS, arch.A64_SUBS:
+		e.addSub(uint32(as-arch.A64_ADDS)/2, true)
+	case arch.A64_CMP: // cmp rn, op2 -> subs zr, rn, op2
+		e.insertZR(0, 0)
+		e.addSub(1, true)
+	case arch.A64_CMN:
+		e.insertZR(0, 0)
+		e.addSub(0, true)
+	case arch.A64_NEG: // neg rd, op2 -> sub rd, zr, op2
+		e.insertZR(1, 0)
+		e.addSub(1, false)
+	case arch.A64_NEGS:
+		e.insertZR(1, 0)
+		e.addSub(1, true)
+	case arch.A64_AND:
+		e.logical(0 << 29)
+	case arch.A64_ORR:
+		e.logical(1 << 29)
+	case arch.A64_EOR:
+		e.logical(2 << 29)
+	case arch.A64_ANDS:
+		e.logical(3 << 29)
+	case arch.A64_BIC:
+		e.logical(0<<29 | 1<<21)
+	case arch.A64_ORN:
+		e.logical(1<<29 | 1<<21)
+	case arch.A64_EON:
+		e.logical(2<<29 | 1<<21)
+	case arch.A64_BICS:
+		e.logical(3<<29 | 1<<21)
+	case arch.A64_TST: // tst rn, op2 -> ands zr, rn, op2
+		e.insertZR(0, 0)
+		e.logical(3 << 29)
+	case arch.A64_MVN: // mvn rd, rm -> orn rd, zr, rm
+		e.insertZR(1, 0)
+		e.logical(1<<29 | 1<<21)
+	case arch.A64_MOV:
+		e.mov()
+	case arch.A64_MOVN:
+		e.movWide(0)
+	case arch.A64_MOVZ:
+		e.movWide(2)
+	case arch.A64_MOVK:
+		e.movWide(3)
+	case arch.A64_LSL, arch.A64_LSR, arch.A64_ASR, arch.A64_ROR:
+		e.shiftOp(uint32(as - arch.A64_LSL))
+	case arch.A64_MUL: // mul rd, rn, rm -> madd rd, rn, rm, zr
+		e.insertZR(3, 0)
+		e.multiply(0)
+	case arch.A64_MNEG:
+		e.insertZR(3, 0)
+		e.multiply(1 << 15)
+	case arch.A64_MADD:
+		e.multiply(0)
+	case arch.A64_MSUB:
+		e.multiply(1 << 15)
+	case arch.A64_SMULL, arch.A64_UMULL:
+		e.count(3, 3)
+		e.wantWide(0, true)
+		e.wantWide(1, false)
+		e.wantWide(2, false)
+		op := uint32(0x9B200000)
+		if as == arch.A64_UMULL {
+			op = 0x9BA00000
+		}
+		e.emit(op | e.reg(2, false)<<16 | 31<<10 | e.reg(1, false)<<5 | e.reg(0, false))
+	case arch.A64_SMULH, arch.A64_UMULH:
+		e.count(3, 3)
+		e.wantWide(0, true)
+		e.same(0, 1, 2)
+		op := uint32(0x9B400000)
+		if as == arch.A64_UMULH {
+			op = 0x9BC00000
+		}
+		e.emit(op | e.reg(2, false)<<16 | 31<<10 | e.reg(1, false)<<5 | e.reg(0, false))
+	case arch.A64_SDIV:
+		e.dataProc2(0x1AC00C00)
+	case arch.A64_UDIV:
+		e.dataProc2(0x1AC00800)
+	case arch.A64_CSEL:
+		e.condSelect(0x1A800000, 3)
+	case arch.A64_CSINC:
+		e.condSelect(0x1A800400, 3)
+	case arch.A64_CSINV:
+		e.condSelect(0x5A800000, 3)
+	case arch.A64_CSNEG:
+		e.condSelect(0x5A800400, 3)
+	case arch.A64_CSET: // cset rd, cond -> csinc rd, zr, zr, !cond
+		e.insertZR(1, 0)
+		e.insertZR(2, 0)
+		e.condSelect(0x1A800400, -1)
+	case arch.A64_CSETM:
+		e.insertZR(1, 0)
+		e.insertZR(2, 0)
+		e.condSelect(0x5A800000, -1)
+	case arch.A64_CINC: // cinc rd, rn, cond -> csinc rd, rn, rn, !cond
+		e.dupArg(1)
+		e.condSelect(0x1A800400, -1)
+	case arch.A64_CNEG:
+		e.dupArg(1)
+		e.condSelect(0x5A800400, -1)
+	case arch.A64_SBFM, arch.A64_UBFM:
+		e.count(4, 4)
+		e.same(0, 1)
+		w := e.width(0)
+		e.emit(a64Bitfield(a64BitfieldOps[as-arch.A64_SBFM], e.sf(0), e.imm(2, 0, w-1), e.imm(3, 0, w-1), e.reg(1, false), e.reg(0, false)))
+	case arch.A64_SBFX, arch.A64_UBFX: // sbfx rd, rn, #lsb, #width -> sbfm rd, rn, #lsb, #(lsb+width-1)
+		e.count(4, 4)
+		e.same(0, 1)
+		w := e.width(0)
+		lsb := e.imm(2, 0, w-1)
+		n := e.imm(3, 1, w-int64(lsb))
+		e.emit(a64Bitfield(a64BitfieldOps[as-arch.A64_SBFX], e.sf(0), lsb, lsb+n-1, e.reg(1, false), e.reg(0, false)))
+	case arch.A64_SXTB, arch.A64_SXTH, arch.A64_SXTW: // sxtb rd, wn -> sbfm rd, rn, #0, #7
+		e.count(2, 2)
+		e.wantWide(1, false)
+		if as == arch.A64_SXTW {
+			e.wantWide(0, true)
+		}
+		imms := uint32(8<<(as-arch.A64_SXTB) - 1)
+		e.emit(a64Bitfield(0x13000000, e.sf(0), 0, imms, e.reg(1, false), e.reg(0, false)))
+	case arch.A64_UXTB, arch.A64_UXTH: // uxtb wd, wn -> ubfm wd, wn, #0, #7
+		e.count(2, 2)
+		e.wantWide(0, false)
+		e.wantWide(1, false)
+		imms := uint32(8<<(as-arch.A64_UXTB) - 1)
+		e.emit(a64Bitfield(0x53000000, 0, 0, imms, e.reg(1, false), e.reg(0, false)))
+	case arch.A64_CLZ:
+		e.dataProc1(0x5AC01000)
+	case arch.A64_RBIT:
+		e.dataProc1(0x5AC00000)
+	case arch.A64_REV:
+		if e.wide(0) {
+			e.dataProc1(0x5AC00C00)
+		} else {
+			e.dataProc1(0x5AC00800)
+		}
+	case arch.A64_ADR, arch.A64_ADRP:
+		e.adr(as == arch.A64_ADRP)
+	case arch.A64_B:
+		e.branch(0x14000000, elf.R_AARCH64_JUMP26, 0)
+	case arch.A64_BL:
+		e.branch(0x94000000, elf.R_AARCH64_CALL26, 0)
+	case arch.A64_BCOND:
+		c, ok := arch.A64Conds[e.name[2:]]
+		if !ok {
+			e.errorf("无效的条件码")
+		}
+		e.branch(0x54000000|c, elf.R_AARCH64_CONDBR19, 0)
+	case arch.A64_CBZ, arch.A64_CBNZ:
+		e.count(2, 2)
+		op := 0x34000000 | uint32(as-arch.A64_CBZ)<<24 | e.sf(0) | e.reg(0, false)
+		e.branch(op, elf.R_AARCH64_CONDBR19, 1)
+	case arch.A64_TBZ, arch.A64_TBNZ:
+		e.count(3, 3)
+		bit := e.imm(1, 0, e.width(0)-1)
+		op := 0x36000000 | uint32(as-arch.A64_TBZ)<<24 | bit>>5<<31 | bit&31<<19 | e.reg(0, false)
+		e.branch(op, elf.R_AARCH64_TSTBR14, 2)
+	case arch.A64_BR, arch.A64_BLR:
+		e.count(1, 1)
+		e.wantWide(0, true)
+		e.emit(0xD61F0000 | uint32(as-arch.A64_BR)<<21 | e.reg(0, false)<<5)
+	case arch.A64_RET:
+		e.count(0, 1)
+		rn := uint32(arch.REG_A64_LR)
+		if len(e.args) > 0 {
+			e.wantWide(0, true)
+			rn = e.reg(0, false)
+		}
+		e.emit(0xD65F0000 | rn<<5)
+	case arch.A64_SVC:
+		e.count(1, 1)
+		e.emit(0xD4000001 | e.imm(0, 0, 0xFFFF)<<5)
+	case arch.A64_BRK:
+		e.count(1, 1)
+		e.emit(0xD4200000 | e.imm(0, 0, 0xFFFF)<<5)
+	case arch.A64_NOP:
+		e.count(0, 0)
+		e.emit(a64Nop)
+	case arch.A64_DMB:
+		e.count(1, 1)
+		e.emit(0xD50330BF | e.barrier(0)<<8)
+	case arch.A64_DSB:
+		e.count(1, 1)
+		e.emit(0xD503309F | e.barrier(0)<<8)
+	case arch.A64_ISB:
+		e.count(0, 1)
+		crm := uint32(15)
+		if len(e.args) > 0 {
+			crm = e.barrier(0)
+		}
+		e.emit(0xD50330DF | crm<<8)
+	case arch.A64_LDP, arch.A64_STP:
+		e.pair(as == arch.A64_LDP)
+	case arch.A64_LDXR, arch.A64_LDAXR, arch.A64_LDAR, arch.A64_STLR:
+		e.count(2, 2)
+		e.emit(e.exclusive(a64Exclusives[as], 0, 1) | e.reg(0, false))
+	case arch.A64_STXR, arch.A64_STLXR:
+		e.count(3, 3)
+		e.wantWide(0, false)
+		e.emit(e.exclusive(a64Exclusives[as], 1, 2) | e.reg(0, false)<<16 | e.reg(1, false))
+	default:
+		if ls, ok := a64LoadStores[as]; ok {
+			e.loadStore(as, ls.size, ls.opc, ls.unscaled)
+			return
+		}
+		e.errorf("不支持的指令")
+	}
+}
+
+// dupArg 复制第 i 个操作数, 用于 cinc/cneg 等别名
+func (e *a64Encoder) dupArg(i int) {
+	if i < len(e.args) {
+		e.args = slices.Insert(e.args, i, e.args[i])
+	}
+}
+
+// addSub 加减运算: op 为 0(add)或 1(sub), flags 表示设置标志位(adds/subs)
+//
+//	add rd, rn, #imm{, lsl #12}       rd、rn 可以是 sp
+//	add rd, rn, :lo12:sym
+//	add rd, rn, rm{, lsl|lsr|asr #s}
+//	add rd, rn, rm, uxtw|sxtx ... {#s} 扩展寄存器, 操作数包含 sp 时使用
+func (e *a64Encoder) addSub(op uint32, flags bool) {
+	e.count(3, 4)
+	e.same(0, 1)
+	sf, s := e.sf(0), uint32(0)
+	if flags {
+		s = 1
+	}
+	switch e.kind(2) {
+	case a64Imm, a64Sym:
+		a := e.args[2]
+		imm, sh := a.Imm, uint32(0)
+		if a.Kind == a64Sym {
+			if a.Mod != "lo12" || op != 0 {
+				e.errorf("符号只能以 :lo12: 修饰用于 add")
+			}
+			e.fixup(a.Sym, elf.R_AARCH64_ADD_ABS_LO12_NC, false)
+			imm = 0
+		} else if imm < 0 { // add rd, rn, #-n -> sub rd, rn, #n
+			imm, op = -imm, op^1
+		}
+		if len(e.args) == 4 {
+			if typ, amount := e.shift(3, a64ShiftNames, 13); typ != 0 || amount != 0 && amount != 12 {
+				e.errorf("立即数只能左移 0 或 12 位")
+			} else {
+				sh = amount / 12
+			}
+		} else if imm > 0xFFF && imm&0xFFF == 0 {
+			imm, sh = imm>>12, 1
+		}
+		if imm > 0xFFF {
+			e.errorf("立即数 %d 超出范围", a.Imm)
+		}
+		e.emit(sf | op<<30 | s<<29 | 0x11000000 | sh<<22 | uint32(imm)<<10 | e.reg(1, true)<<5 | e.reg(0, !flags))
+	case a64Reg:
+		extended := e.isSP(0) || e.isSP(1)
+		if len(e.args) == 4 && e.kind(3) == a64Shift {
+			extended = extended || slices.Contains(a64ExtendNames, e.args[3].Shift)
+		}
+		if !extended {
+			e.same(0, 2)
+			typ, amount := e.shift(3, a64ShiftNames, e.width(0))
+			e.emit(sf | op<<30 | s<<29 | 0x0B000000 | typ<<22 | e.reg(2, false)<<16 | amount<<10 | e.reg(1, false)<<5 | e.reg(0, false))
+			return
+		}
+		option, amount := uint32(2), uint32(0) // 默认 uxtw, 64 位为 uxtx
+		if sf != 0 {
+			option = 3
+		}
+		if len(e.args) == 4 {
+			a := e.arg(3, a64Shift)
+			if n := slices.Index(a64ExtendNames, a.Shift); n >= 0 {
+				option = uint32(n)
+			} else if a.Shift != "lsl" {
+				e.errorf("不支持扩展方式 %s", a.Shift)
+			}
+			if a.Amount < 0 || a.Amount > 4 {
+				e.errorf("扩展的移位量 %d 超出范围 [0, 4]", a.Amount)
+			}
+			amount = uint32(a.Amount)
+		}
+		e.wantWide(2, sf != 0 && option&3 == 3) // 64 位运算只有 uxtx/sxtx 使用 64 位寄存器
+		e.emit(sf | op<<30 | s<<29 | 0x0B200000 | e.reg(2, false)<<16 | option<<13 | amount<<10 | e.reg(1, true)<<5 | e.reg(0, !flags))
+	default:
+		e.errorf("第 3 个操作数应为寄存器或立即数")
+	}
+}
+
+// logical 逻辑运算, opc 包含操作码(29-30 位)及取反标志(21 位)
+//
+//	and rd, rn, #bitmask      rd 可以是 sp(ands 除外)
+//	and rd, rn, rm{, lsl|lsr|asr|ror #s}
+func (e *a64Encoder) logical(opc uint32) {
+	e.count(3, 4)
+	e.same(0, 1)
+	sf := e.sf(0)
+	switch e.kind(2) {
+	case a64Imm:
+		e.count(3, 3)
+		imm := e.args[2].Imm
+		if opc&(1<<21) != 0 { // bic rd, rn, #imm -> and rd, rn, #~imm
+			opc, imm = opc&^(1<<21), ^imm
+		}
+		n, immr, imms, ok := e.bitmask(imm, e.width(0))
+		if !ok {
+			e.errorf("立即数 %#x 不能编码为逻辑运算的位模式", e.args[2].Imm)
+		}
+		e.emit(sf | opc | 0x12000000 | n<<22 | immr<<16 | imms<<10 | e.reg(1, false)<<5 | e.reg(0, opc>>29 != 3))
+	case a64Reg:
+		e.same(0, 2)
+		typ, amount := e.shift(3, a64RotateNames, e.width(0))
+		e.emit(sf | opc | 0x0A000000 | typ<<22 | e.reg(2, false)<<16 | amount<<10 | e.reg(1, false)<<5 | e.reg(0, false))
+	default:
+		e.errorf("第 3 个操作数应为寄存器或立即数")
+	}
+}
+
+// bitmask 检查立即数在 width 位内的范围, 并编码为逻辑运算的位模式
+func (e *a64Encoder) bitmask(imm, width int64) (n, immr, imms uint32, ok bool) {
+	if width == 32 && (imm < -1<<31 || imm > 0xFFFFFFFF) {
+		e.errorf("立即数 %#x 超出 32 位范围", imm)
+	}
+	return a64Bitmask(uint64(imm), int(width))
+}
+
+// a64Bitmask 逻辑运算立即数的编码: 值由 size 位的元素重复组成, 元素为循环右移 immr 位的 imms+1 个连续的 1;
+// 全 0 及全 1 不能编码
+func a64Bitmask(v uint64, width int) (n, immr, imms uint32, ok bool) {
+	if width == 32 {
+		v = v&0xFFFFFFFF | v<<32
+	}
+	if v == 0 || v == ^uint64(0) {
+		return 0, 0, 0, false
+	}
+	size := 64
+	for size > 2 { // 最小的重复周期
+		half := size / 2
+		mask := uint64(1)<<half - 1
+		if v&mask != v>>half&mask {
+			break
+		}
+		size = half
+	}
+	mask := ^uint64(0) >> (64 - size)
+	elt := v & mask
+	ones := bits.OnesCount64(elt)
+	for r := 0; r < size; r++ {
+		if rot := (elt>>r | elt<<(size-r)) & mask; rot == 1<<ones-1 {
+			immr = uint32((size - r) % size)
+			imms = uint32((^(size-1))<<1|(ones-1)) & 0x3F
+			if size == 64 {
+				n = 1
+			}
+			return n, immr, imms, true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// a64Halfword 值是否只有一个 16 位的部分不为 0, 返回该部分的序号及值
+func a64Halfword(v uint64, width int64) (hw, imm uint32, ok bool) {
+	for i := int64(0); i < width/16; i++ {
+		if v&^(0xFFFF<<(16*i)) == 0 {
+			return uint32(i), uint32(v >> (16 * i)), true
+		}
+	}
+	return 0, 0, false
+}
+
+// mov 寄存器及立即数传送:
+//
+//	mov rd, rm     -> orr rd, zr, rm, 包含 sp 时为 add rd, rn, #0
+//	mov rd, #imm   -> movz, movn 或 orr rd, zr, #bitmask
+func (e *a64Encoder) mov() {
+	e.count(2, 2)
+	sf := e.sf(0)
+	switch e.kind(1) {
+	case a64Reg:
+		e.same(0, 1)
+		if e.isSP(0) || e.isSP(1) {
+			e.emit(sf | 0x11000000 | e.reg(1, true)<<5 | e.reg(0, true))
+			return
+		}
+		e.emit(sf | 0x2A000000 | e.reg(1, false)<<16 | 31<<5 | e.reg(0, false))
+	case a64Imm:
+		imm, width := e.args[1].Imm, e.width(0)
+		v, mask := uint64(imm), ^uint64(0)
+		if width == 32 {
+			if imm < -1<<31 || imm > 0xFFFFFFFF {
+				e.errorf("立即数 %#x 超出 32 位范围", imm)
+			}
+			v, mask = v&0xFFFFFFFF, 0xFFFFFFFF
+		}
+		if hw, imm16, ok := a64Halfword(v, width); ok {
+			e.emit(sf | 0x52800000 | hw<<21 | imm16<<5 | e.reg(0, false))
+		} else if hw, imm16, ok := a64Halfword(^v&mask, width); ok {
+			e.emit(sf | 0x12800000 | hw<<21 | imm16<<5 | e.reg(0, false))
+		} else if n, immr, imms, ok := a64Bitmask(v, int(width)); ok {
+			e.emit(sf | 0x32000000 | n<<22 | immr<<16 | imms<<10 | 31<<5 | e.reg(0, true))
+		} else {
+			e.errorf("立即数 %#x 不能用一条指令传送", imm)
+		}
+	default:
+		e.errorf("第 2 个操作数应为寄存器或立即数")
+	}
+}
+
+// movWide movn(0)、movz(2)、movk(3): rd, #imm16{, lsl #16*hw}
+func (e *a64Encoder) movWide(opc uint32) {
+	e.count(2, 3)
+	imm := e.imm(1, 0, 0xFFFF)
+	hw := uint32(0)
+	if len(e.args) == 3 {
+		typ, amount := e.shift(2, a64ShiftNames, e.width(0))
+		if typ != 0 || amount%16 != 0 {
+			e.errorf("只能左移 16 的倍数")
+		}
+		hw = amount / 16
+	}
+	e.emit(e.sf(0) | opc<<29 | 0x12800000 | hw<<21 | imm<<5 | e.reg(0, false))
+}
+
+// a64BitfieldOps sbfm、ubfm 的编码
+var a64BitfieldOps = [...]uint32{0x13000000, 0x53000000}
+
+// a64Bitfield 位域操作, N 与 sf 相同
+func a64Bitfield(op, sf, immr, imms, rn, rd uint32) uint32 {
+	return sf | op | sf>>31<<22 | immr<<16 | imms<<10 | rn<<5 | rd
+}
+
+// shiftOp 移位: typ 为 0(lsl)、1(lsr)、2(asr)、3(ror)
+//
+//	lsl rd, rn, rm      -> lslv
+//	lsl rd, rn, #s      -> ubfm rd, rn, #(-s mod size), #(size-1-s)
+//	lsr/asr rd, rn, #s  -> ubfm/sbfm rd, rn, #s, #(size-1)
+//	ror rd, rn, #s      -> extr rd, rn, rn, #s
+func (e *a64Encoder) shiftOp(typ uint32) {
+	e.count(3, 3)
+	e.same(0, 1)
+	sf, size := e.sf(0), e.width(0)
+	rd, rn := e.reg(0, false), e.reg(1, false)
+	if e.kind(2) == a64Reg {
+		e.same(0, 2)
+		e.emit(sf | 0x1AC02000 | e.reg(2, false)<<16 | typ<<10 | rn<<5 | rd)
+		return
+	}
+	s := e.imm(2, 0, size-1)
+	n := uint32(size)
+	switch typ {
+	case 0:
+		e.emit(a64Bitfield(0x53000000, sf, (n-s)%n, n-1-s, rn, rd))
+	case 1:
+		e.emit(a64Bitfield(0x53000000, sf, s, n-1, rn, rd))
+	case 2:
+		e.emit(a64Bitfield(0x13000000, sf, s, n-1, rn, rd))
+	default:
+		e.emit(sf | sf>>31<<22 | 0x13800000 | rn<<16 | s<<10 | rn<<5 | rd)
+	}
+}
+
+// multiply madd/msub rd, rn, rm, ra, o0 为 1<<15 时为减法
+func (e *a64Encoder) multiply(o0 uint32) {
+	e.count(4, 4)
+	e.same(0, 1, 2, 3)
+	e.emit(e.sf(0) | 0x1B000000 | e.reg(2, false)<<16 | o0 | e.reg(3, false)<<10 | e.reg(1, false)<<5 | e.reg(0, false))
+}
+
+// dataProc2 两个源寄存器的运算: sdiv/udiv rd, rn, rm
+func (e *a64Encoder) dataProc2(op uint32) {
+	e.count(3, 3)
+	e.same(0, 1, 2)
+	e.emit(e.sf(0) | op | e.reg(2, false)<<16 | e.reg(1, false)<<5 | e.reg(0, false))
+}
+
+// dataProc1 一个源寄存器的运算: clz/rbit/rev rd, rn
+func (e *a64Encoder) dataProc1(op uint32) {
+	e.count(2, 2)
+	e.same(0, 1)
+	e.emit(e.sf(0) | op | e.reg(1, false)<<5 | e.reg(0, false))
+}
+
+// condSelect 条件选择 csel rd, rn, rm, cond; condArg 为 -1 时条件码是第 4 个操作数并取反(cset/cinc 等别名)
+func (e *a64Encoder) condSelect(op uint32, condArg int) {
+	e.count(4, 4)
+	e.same(0, 1, 2)
+	var c uint32
+	if condArg < 0 {
+		c = e.invert(e.cond(3))
+	} else {
+		c = e.cond(condArg)
+	}
+	e.emit(e.sf(0) | op | e.reg(2, false)<<16 | c<<12 | e.reg(1, false)<<5 | e.reg(0, false))
+}
+
+// adr 计算地址: adr xd, label(±1MB), adrp xd, label 所在 4KB 页(:got: 为 GOT 表项所在页)
+func (e *a64Encoder) adr(page bool) {
+	e.count(2, 2)
+	e.wantWide(0, true)
+	rd := e.reg(0, false)
+	a := e.arg(1, a64Sym)
+	if a.Kind != a64Sym {
+		return
+	}
+	switch {
+	case !page && a.Mod == "":
+		e.fixup(a.Sym, elf.R_AARCH64_ADR_PREL_LO21, true)
+		e.emit(0x10000000 | rd)
+	case page && a.Mod == "":
+		e.fixup(a.Sym, elf.R_AARCH64_ADR_PREL_PG_HI21, false)
+		e.emit(0x90000000 | rd)
+	case page && a.Mod == "got":
+		e.fixup(a.Sym, elf.R_AARCH64_ADR_GOT_PAGE, false)
+		e.emit(0x90000000 | rd)
+	default:
+		e.errorf("不支持重定位修饰 :%s:", a.Mod)
+	}
+}
+
+// branch 跳转到第 i 个操作数的标号, op 为已编码的其余部分
+func (e *a64Encoder) branch(op uint32, reloc elf.R_AARCH64, i int) {
+	e.count(i+1, i+1)
+	a := e.arg(i, a64Sym)
+	if a.Kind == a64Sym && a.Mod != "" {
+		e.errorf("跳转目标不能使用重定位修饰")
+	}
+	if a.Kind == a64Sym {
+		e.fixup(a.Sym, reloc, true)
+	}
+	e.emit(op)
+}
+
+// barrier 屏障选项, 可以是名称或 #imm
+func (e *a64Encoder) barrier(i int) uint32 {
+	if e.kind(i) == a64Imm {
+		return e.imm(i, 0, 15)
+	}
+	a := e.arg(i, a64Name)
+	crm, ok := a64Barriers[a.Name]
+	if a.Kind == a64Name && !ok {
+		e.errorf("无效的屏障选项 %s", a.Name)
+	}
+	return crm
+}
+
+// base 内存引用的基址寄存器, 必须是 64 位寄存器或 sp
+func (e *a64Encoder) base(m *a64Arg) uint32 {
+	if m.Reg&arch.REG_A64_W != 0 {
+		e.errorf("基址寄存器 %s 必须是 64 位寄存器", arch.A64RegName(m.Reg))
+	}
+	return e.regNum(m.Reg, "xzr", true)
+}
+
+// loadStore 加载/存储单个寄存器
+//
+//	ldr rt, [xn{, #imm}]       无符号偏移按访问宽度缩放, 否则使用 9 位有符号的非缩放偏移(ldur)
+//	ldr rt, [xn, #imm]!        前变址
+//	ldr rt, [xn], #imm         后变址
+//	ldr rt, [xn, :lo12:sym]
+//	ldr rt, [xn, xm{, lsl #s}] 寄存器偏移, wm 需要 uxtw/sxtw 扩展
+//	ldr rt, label              pc 相对的字面量(ldr/ldrsw)
+func (e *a64Encoder) loadStore(as arch.As, size, opc int, unscaled bool) {
+	e.count(2, 2)
+	wide := e.wide(0)
+	switch {
+	case size < 0 && wide:
+		size = 3
+	case size < 0:
+		size = 2
+	case opc < 0 && wide: // ldrsb/ldrsh 扩展到 64 位
+		opc = 2
+	case opc < 0:
+		opc = 3
+	case as == arch.A64_LDRSW:
+		e.wantWide(0, true)
+	default:
+		e.wantWide(0, false)
+	}
+	rt := e.reg(0, false)
+
+	if e.kind(1) == a64Sym { // 字面量
+		a := e.args[1]
+		op, ok := map[arch.As]uint32{arch.A64_LDR: 0x18000000 | uint32(size-2)<<30, arch.A64_LDRSW: 0x98000000}[as]
+		if !ok || a.Mod != "" {
+			e.errorf("不支持 pc 相对的字面量寻址")
+		}
+		e.fixup(a.Sym, elf.R_AARCH64_LD_PREL_LO19, true)
+		e.emit(op | rt)
+		return
+	}
+
+	m := e.arg(1, a64Mem)
+	if m.Kind != a64Mem {
+		return
+	}
+	op := uint32(size)<<30 | 0x38000000 | uint32(opc)<<22 | e.base(m)<<5 | rt
+	switch {
+	case m.Index >= 0: // 寄存器偏移
+		if m.Mode != a64Offset || unscaled {
+			e.errorf("寄存器偏移只能使用基本寻址方式")
+		}
+		option, x := map[string]uint32{"": 3, "lsl": 3, "uxtw": 2, "sxtw": 6, "sxtx": 7}[m.Shift], true
+		if option == 0 {
+			e.errorf("不支持扩展方式 %s", m.Shift)
+		}
+		if option&1 == 0 { // uxtw/sxtw 使用 32 位变址寄存器
+			x = false
+		}
+		if (m.Index&arch.REG_A64_W == 0) != x {
+			e.errorf("变址寄存器 %s 与扩展方式不符", arch.A64RegName(m.Index))
+		}
+		s := uint32(0)
+		if m.Explicit {
+			if m.Amount != 0 && m.Amount != int64(size) {
+				e.errorf("移位量只能是 0 或 %d", size)
+			}
+			if m.Amount == int64(size) {
+				s = 1
+			}
+		}
+		e.emit(op | 0x00200800 | e.regNum(m.Index, arch.A64RegName(m.Index), false)<<16 | option<<13 | s<<12)
+	case m.Sym != nil: // :lo12:sym
+		relocs := [...]elf.R_AARCH64{elf.R_AARCH64_LDST8_ABS_LO12_NC, elf.R_AARCH64_LDST16_ABS_LO12_NC,
+			elf.R_AARCH64_LDST32_ABS_LO12_NC, elf.R_AARCH64_LDST64_ABS_LO12_NC}
+		reloc := relocs[size]
+		switch {
+		case m.Mode != a64Offset || unscaled:
+			e.errorf("符号偏移只能使用基本寻址方式")
+		case m.Mod == "got_lo12" && as == arch.A64_LDR && size == 3:
+			reloc = elf.R_AARCH64_LD64_GOT_LO12_NC
+		case m.Mod != "lo12":
+			e.errorf("符号偏移只能使用 :lo12: 修饰")
+		}
+		e.fixup(m.Sym, reloc, false)
+		e.emit(op | 0x01000000)
+	case m.Mode != a64Offset: // 前变址、后变址
+		if m.Imm < -256 || m.Imm > 255 {
+			e.errorf("偏移 %d 超出范围 [-256, 255]", m.Imm)
+		}
+		mode := uint32(1)
+		if m.Mode == a64Pre {
+			mode = 3
+		}
+		e.emit(op | uint32(m.Imm)&0x1FF<<12 | mode<<10)
+	case !unscaled && m.Imm >= 0 && m.Imm&(1<<size-1) == 0 && m.Imm>>size <= 0xFFF:
+		e.emit(op | 0x01000000 | uint32(m.Imm>>size)<<10)
+	case m.Imm >= -256 && m.Imm <= 255:
+		e.emit(op | uint32(m.Imm)&0x1FF<<12)
+	default:
+		e.errorf("偏移 %d 超出范围", m.Imm)
+	}
+}
+
+// pair 加载/存储一对寄存器: ldp/stp rt, rt2, [xn{, #imm}] | [xn, #imm]! | [xn], #imm
+func (e *a64Encoder) pair(load bool) {
+	e.count(3, 3)
+	e.same(0, 1)
+	m := e.arg(2, a64Mem)
+	if m.Kind != a64Mem {
+		return
+	}
+	if m.Index >= 0 || m.Sym != nil {
+		e.errorf("只能使用立即数偏移")
+	}
+	scale, opc := int64(4), uint32(0)
+	if e.wide(0) {
+		scale, opc = 8, 2
+	}
+	if m.Imm%scale != 0 || m.Imm/scale < -64 || m.Imm/scale > 63 {
+		e.errorf("偏移 %d 必须是 %d 的倍数, 且在 [%d, %d] 范围内", m.Imm, scale, -64*scale, 63*scale)
+	}
+	mode := [...]uint32{a64Offset: 2, a64Pre: 3, a64Post: 1}[m.Mode]
+	l := uint32(0)
+	if load {
+		l = 1
+	}
+	e.emit(opc<<30 | 0x28000000 | mode<<23 | l<<22 | uint32(m.Imm/scale)&0x7F<<15 | e.reg(1, false)<<10 | e.base(m)<<5 | e.reg(0, false))
+}
+
+// exclusive 独占加载/存储的编码: rt 为第 rt 个操作数, 内存引用为第 mem 个操作数, 只能是 [xn{, #0}]
+func (e *a64Encoder) exclusive(op uint32, rt, mem int) uint32 {
+	if !e.wide(rt) {
+		op &^= 1 << 30
+	}
+	m := e.arg(mem, a64Mem)
+	if m.Kind != a64Mem {
+		return op
+	}
+	if m.Index >= 0 || m.Sym != nil || m.Imm != 0 || m.Mode != a64Offset {
+		e.errorf("只能使用 [xn] 寻址")
+	}
+	return op | e.base(m)<<5
+}
+
+// a64Patch 将符号引用的值回填到指令字的字段中; 生成重定位时值为 0, 字段保持不变
+func a64Patch(reloc int, word uint32, val int64) (uint32, error) {
+	switch r := elf.R_AARCH64(reloc); r {
+	case elf.R_AARCH64_JUMP26, elf.R_AARCH64_CALL26:
+		return a64Field(word, val, 26, 0)
+	case elf.R_AARCH64_CONDBR19, elf.R_AARCH64_LD_PREL_LO19:
+		return a64Field(word, val, 19, 5)
+	case elf.R_AARCH64_TSTBR14:
+		return a64Field(word, val, 14, 5)
+	case elf.R_AARCH64_ADR_PREL_LO21:
+		return a64Adr(word, val)
+	case elf.R_AARCH64_ADR_PREL_PG_HI21, elf.R_AARCH64_ADR_GOT_PAGE:
+		return a64Adr(word, val>>12)
+	case elf.R_AARCH64_ADD_ABS_LO12_NC, elf.R_AARCH64_LDST8_ABS_LO12_NC:
+		return word | uint32(val&0xFFF)<<10, nil
+	case elf.R_AARCH64_LDST16_ABS_LO12_NC:
+		return word | uint32(val&0xFFF>>1)<<10, nil
+	case elf.R_AARCH64_LDST32_ABS_LO12_NC:
+		return word | uint32(val&0xFFF>>2)<<10, nil
+	case elf.R_AARCH64_LDST64_ABS_LO12_NC, elf.R_AARCH64_LD64_GOT_LO12_NC:
+		return word | uint32(val&0xFFF>>3)<<10, nil
+	default:
+		return word, fmt.Errorf("不支持的重定位类型 %s", r)
+	}
+}
+
+// a64Field 回填 pc 相对的跳转偏移: 偏移按 4 字节对齐, 除以 4 后写入从 pos 位开始的 n 位有符号字段
+func a64Field(word uint32, val int64, n, pos uint) (uint32, error) {
+	if val&3 != 0 {
+		return word, fmt.Errorf("偏移 %d 没有按 4 字节对齐", val)
+	}
+	if val >>= 2; val < -1<<(n-1) || val >= 1<<(n-1) {
+		return word, fmt.Errorf("偏移超出 ±%d 字节的范围", int64(1)<<(n+1))
+	}
+	return word | uint32(val)&(1<<n-1)<<pos, nil
+}
+
+// a64Adr 回填 adr/adrp 的 21 位偏移: 低 2 位在 29-30 位, 高 19 位在 5-23 位
+func a64Adr(word uint32, val int64) (uint32, error) {
+	if val < -1<<20 || val >= 1<<20 {
+		return word, fmt.Errorf("偏移超出 ±1MB 的范围")
+	}
+	return word | uint32(val)&3<<29 | uint32(val>>2)&0x7FFFF<<5, nil
+}
+
+// a64DataReloc 数据定义中的符号引用
+func a64DataReloc(f fixup) int {
+	switch {
+	case f.Size == 8 && f.PCRel:
+		return int(elf.R_AARCH64_PREL64)
+	case f.Size == 8:
+		return int(elf.R_AARCH64_ABS64)
+	case f.Size == 4 && f.PCRel:
+		return int(elf.R_AARCH64_PREL32)
+	case f.Size == 4:
+		return int(elf.R_AARCH64_ABS32)
+	case f.Size == 2 && f.PCRel:
+		return int(elf.R_AARCH64_PREL16)
+	case f.Size == 2:
+		return int(elf.R_AARCH64_ABS16)
+	}
+	return 0
+}
@@ -0,0 +1,336 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"strings"
+)
+
+// AT&T(GAS) 语法前端, 转换为与 Intel 语法相同的 instr/operand 结构:
+//
+//	movl $1, %eax           ->  mov eax, 1
+//	movq msg(%rip), %rsi    ->  mov rsi, [rip+msg]
+//	leal -8(%ebp,%ecx,4), %eax  ->  lea eax, [ebp+ecx*4-8]
+//	call *%rax              ->  call rax
+
+// attStrings AT&T 串操作指令的双字形式以 l 结尾, 与 Intel 的 d 结尾不同
+var attStrings = map[string]Token{
+	"movsl": I_MOVSD, "stosl": I_STOSD, "lodsl": I_LODSD, "cmpsl": I_CMPSD, "scasl": I_SCASD,
+}
+
+// attMnemonic 解析 AT&T 指令名称, 返回指令及后缀指定的操作数宽度(0 表示未指定);
+// 扩展传送指令同时返回源操作数的宽度
+func attMnemonic(name string) (op Token, size, src int) {
+	name = strings.ToLower(name)
+	if name == "movabs" { // movabs 即 64 位立即数的 mov
+		return I_MOV, 0, 0
+	}
+	if tok, ok := attStrings[name]; ok {
+		return tok, 0, 0
+	}
+	if tok := Lookup(name); tok.IsInstr() {
+		return tok, 0, 0
+	}
+	if op, src, size := attExtend(name); op != ILLEGAL {
+		return op, size, src
+	}
+	n := len(name) - 1
+	if n <= 0 {
+		return ILLEGAL, 0, 0
+	}
+	if size := suffixSize(name[n]); size != 0 {
+		if name[:n] == "movabs" {
+			return I_MOV, size, 0
+		}
+		if tok := Lookup(name[:n]); tok.IsInstr() {
+			return tok, size, 0
+		}
+	}
+	return ILLEGAL, 0, 0
+}
+
+// attExtend 扩展传送指令, 名称中依次为源操作数及目标操作数的宽度后缀:
+//
+//	movzbl %al, %eax        ->  movzx eax, al
+//	movswq (%rdi), %rax     ->  movsx rax, word [rdi]
+//	movslq %ecx, %rdx       ->  movsxd rdx, ecx
+func attExtend(name string) (op Token, src, dst int) {
+	if len(name) != 6 || name[:4] != "movz" && name[:4] != "movs" {
+		return ILLEGAL, 0, 0
+	}
+	src, dst = suffixSize(name[4]), suffixSize(name[5])
+	switch {
+	case src == 0 || dst <= src:
+		return ILLEGAL, 0, 0
+	case src == 4 && name[3] == 's':
+		return I_MOVSXD, src, dst
+	case src == 4: // 32 位操作数的 mov 已经零扩展到 64 位, 没有 movzlq
+		return ILLEGAL, 0, 0
+	case name[3] == 'z':
+		return I_MOVZX, src, dst
+	}
+	return I_MOVSX, src, dst
+}
+
+// attStmt 解析一条 AT&T 风格的语句: 标签、伪指令(以 . 开头)或机器指令
+func (p *parser) attStmt() {
+	id, pos := p.id, p.pos
+	if p.got(INT) { // 数字局部标签
+		p.expect(COLON)
+		p.defNumLabel(id, pos)
+		return
+	}
+	p.expect(IDENT)
+	if p.got(COLON) { // 标签, 同一行可以继续跟指令
+		p.defLabel(id, pos)
+		return
+	}
+	if strings.HasPrefix(id, ".") {
+		p.attDirective(id)
+		p.eol()
+		return
+	}
+	switch p.machine {
+	case elf.EM_AARCH64:
+		p.arm64Stmt(id, pos)
+		p.eol()
+		return
+	case elf.EM_RISCV:
+		p.riscvStmt(id, pos)
+		p.eol()
+		return
+	}
+
+	op, size, src := attMnemonic(id)
+	var prefix []Token
+	for op.IsPrefix() && p.token == IDENT { // lock addl ..., rep movsb
+		prefix = append(prefix, op)
+		id = p.id
+		p.next()
+		op, size, src = attMnemonic(id)
+	}
+	if op == ILLEGAL {
+		p.errorAt(pos, fmt.Sprintf("不支持的指令: %s", id))
+	}
+	var oprs []*operand
+	if !p.atEOL() {
+		oprs = append(oprs, p.attOperand(op))
+		for p.got(COMMA) {
+			oprs = append(oprs, p.attOperand(op))
+		}
+	}
+	for i, j := 0, len(oprs)-1; i < j; i, j = i+1, j-1 { // 源操作数在前, 转换为 Intel 顺序
+		oprs[i], oprs[j] = oprs[j], oprs[i]
+	}
+	if op == I_MOVQ && !hasXmm(oprs...) { // 没有 xmm 操作数时为 mov 加 q 后缀
+		op, size = I_MOV, 8
+	}
+	if src != 0 && len(oprs) == 2 { // 扩展传送的源操作数宽度由名称中的第一个后缀决定
+		switch opr := oprs[1]; opr.Type {
+		case OPRTP_REG:
+			if regSize(opr.Reg) != src {
+				p.errorAt(pos, fmt.Sprintf("寄存器 %s 与指令后缀宽度不一致", opr.Reg))
+			}
+		case OPRTP_MEM:
+			opr.Size = src
+		}
+	}
+	p.instruction(&instr{Opcode: op, Prefix: prefix, Times: 1, Pos: pos}, size, oprs)
+	p.eol()
+}
+
+// attOperand 解析 AT&T 操作数: %reg | $imm | [%seg:]内存引用 | 跳转目标 | *间接跳转
+func (p *parser) attOperand(op Token) *operand {
+	switch {
+	case p.token.IsSegment(): // %fs:0, %gs:8(%rax)
+		seg := p.token
+		p.next()
+		p.expect(COLON)
+		opr := p.attMemory()
+		opr.Seg = seg
+		return opr
+	case p.token.IsRegister():
+		opr := &operand{Type: OPRTP_REG, Reg: p.token}
+		p.next()
+		return opr
+	case p.got(DOLLAR):
+		opr := &operand{Type: OPRTP_IMM}
+		p.setExpr(opr, p.expr())
+		return opr
+	case p.token == MUL: // call *%rax, jmp *8(%rbx)
+		if !op.IsBranch() {
+			p.errorf("%s: 只有跳转指令可以使用 * 间接寻址", op)
+		}
+		p.next()
+		if p.token.IsRegister() {
+			opr := &operand{Type: OPRTP_REG, Reg: p.token}
+			p.next()
+			return opr
+		}
+		return p.attMemory()
+	case op.IsBranch() && p.token != LPAREN: // 直接跳转目标
+		opr := &operand{Type: OPRTP_IMM}
+		p.setExpr(opr, p.expr())
+		return opr
+	}
+	return p.attMemory()
+}
+
+// attMemory 解析内存引用: disp(base, index, scale), 各部分均可省略, 例如 (%eax), 8(,%ecx,4), msg
+func (p *parser) attMemory() *operand {
+	opr := &operand{Type: OPRTP_MEM}
+	if p.token != LPAREN {
+		p.setExpr(opr, p.expr())
+	}
+	if p.got(LPAREN) {
+		if p.token != COMMA {
+			opr.Base = p.attAddrReg()
+			if opr.Base == REG_RIP && p.token != RPAREN {
+				p.errorf("rip 只能作为基址寄存器")
+			}
+		}
+		if p.got(COMMA) {
+			opr.Index, opr.Scale = p.attAddrReg(), 1
+			if opr.Index == REG_RIP {
+				p.errorf("rip 只能作为基址寄存器")
+			}
+			if p.got(COMMA) {
+				opr.Scale = int(p.number())
+			}
+		}
+		p.expect(RPAREN)
+	}
+	return opr
+}
+
+// attAddrReg 读取用于寻址的寄存器
+func (p *parser) attAddrReg() Token {
+	reg := p.token
+	if reg != REG_RIP && !reg.IsRegister() {
+		p.unexpect("register")
+	}
+	if s := regSize(reg); reg != REG_RIP && s != 4 && s != 8 {
+		p.errorf("寄存器 %s 不能用于寻址", reg)
+	}
+	p.next()
+	return reg
+}
+
+// attDirective 处理 AT&T(GAS) 伪指令
+func (p *parser) attDirective(name string) {
+	switch {
+	case p.machine == elf.EM_AARCH64 && p.arm64Directive(name),
+		p.machine == elf.EM_RISCV && p.riscvDirective(name):
+		return
+	}
+	switch name {
+	case ".text", ".data", ".bss", ".section", ".pushsection", ".popsection", ".previous":
+		p.scope = "" // 切换段时结束 .type 开始的函数, 之后的标签在文件内可见
+	}
+	switch name {
+	case ".text", ".data", ".bss":
+		p._switch(name)
+	case ".section":
+		p.attSection()
+	case ".pushsection":
+		p.pushSection()
+	case ".popsection":
+		p.popSection()
+	case ".previous":
+		p.previous()
+	case ".globl", ".global":
+		p.attSymList(func(lb *label) { lb.Global = true })
+	case ".weak":
+		p.attSymList(func(lb *label) { lb.Global, lb.Weak = true, true })
+	case ".local":
+		p.attSymList(func(lb *label) { lb.Local = true })
+	case ".hidden", ".protected", ".internal":
+		vis := symVis[name]
+		p.attSymList(func(lb *label) { lb.Vis = vis })
+	case ".type":
+		p.attType()
+	case ".size":
+		p.attSize()
+	case ".file":
+		p.attFile()
+	case ".loc":
+		p.attLoc()
+	case ".comm":
+		p.attComm()
+	case ".lcomm":
+		p.attLcomm()
+	case ".equ", ".equiv": // .equ 与 .set 相同, 可以重新赋值; .equiv 不能重复定义
+		pos, id := p.pos, p.ident()
+		p.expect(COMMA)
+		p.equ(id, p.expr(), pos, name == ".equ")
+	case ".byte":
+		p.attData(K_DB, 1)
+	case ".short", ".word", ".value":
+		p.attData(K_DW, 2)
+	case ".long", ".int":
+		p.attData(K_DD, 4)
+	case ".quad":
+		p.attData(K_DQ, 8)
+	case ".ascii":
+		p.attString("")
+	case ".asciz", ".string":
+		p.attString("\x00")
+	case ".skip", ".space":
+		p.attSpace(false)
+	case ".zero":
+		p.attSpace(true)
+	case ".fill":
+		p.attFill()
+	case ".align", ".balign":
+		p.attAlign(false)
+	case ".p2align":
+		p.attAlign(true)
+	default:
+		p.errorf("不支持的伪指令: %s", name)
+	}
+}
+
+// attSectionName 读取段名, 段名可以加引号, 也可以包含 -, 例如 .note.GNU-stack
+func (p *parser) attSectionName() string {
+	if p.token == STRING {
+		name := p.id
+		p.next()
+		return name
+	}
+	name := p.ident()
+	for p.token == SUB || p.token == IDENT || p.token == INT {
+		if p.token == SUB {
+			name += "-"
+		} else {
+			name += p.id
+		}
+		p.next()
+	}
+	return name
+}
+
+// attData 数据定义: .byte/.short/.long/.quad 值 {, 值}
+func (p *parser) attData(op Token, size int) {
+	ins := &instr{Opcode: op, Size: size, Times: 1, Pos: p.pos}
+	ins.Values = append(ins.Values, p.value(size))
+	for p.got(COMMA) {
+		ins.Values = append(ins.Values, p.value(size))
+	}
+	p.emit(ins)
+}
+
+// attString 字符串定义: .ascii/.asciz "str" {, "str"}, suffix 为每个字符串的结尾
+func (p *parser) attString(suffix string) {
+	ins := &instr{Opcode: K_DB, Size: 1, Times: 1, Pos: p.pos}
+	for {
+		if p.token != STRING {
+			p.unexpect("string")
+		}
+		ins.Values = append(ins.Values, &operand{Type: OPRTP_STR, Text: p.id + suffix})
+		p.next()
+		if !p.got(COMMA) {
+			break
+		}
+	}
+	p.emit(ins)
+}
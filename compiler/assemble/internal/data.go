@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"github.com/facelang/face/internal/prog"
+)
+
+// 填充及对齐伪指令(AT&T):
+//
+//	.skip/.space n[, fill]      填充 n 个字节
+//	.zero n                     填充 n 个字节的 0
+//	.fill repeat[, size[, val]] 填充 repeat 个 size 字节的值, 与 GAS 一致只使用值的低 4 字节
+//	.align/.balign n[, fill[, max]]  按 n 字节对齐, 填充超过 max 个字节时不对齐
+//	.p2align n[, fill[, max]]        按 2^n 字节对齐
+//
+// 没有指定填充值时, 可执行段填充 nop, 其余段填充 0. .bss 段只记录长度, 不占用文件空间.
+// 数据按小端序存放.
+
+// nops64 64 位模式的多字节 nop, 下标为长度, 与 GNU as 的填充一致
+var nops64 = [][]byte{
+	nil,
+	{0x90},
+	{0x66, 0x90},
+	{0x0F, 0x1F, 0x00},
+	{0x0F, 0x1F, 0x40, 0x00},
+	{0x0F, 0x1F, 0x44, 0x00, 0x00},
+	{0x66, 0x0F, 0x1F, 0x44, 0x00, 0x00},
+	{0x0F, 0x1F, 0x80, 0x00, 0x00, 0x00, 0x00},
+	{0x0F, 0x1F, 0x84, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x66, 0x0F, 0x1F, 0x84, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x66, 0x2E, 0x0F, 0x1F, 0x84, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x66, 0x66, 0x2E, 0x0F, 0x1F, 0x84, 0x00, 0x00, 0x00, 0x00, 0x00},
+}
+
+// nops32 32 位模式的多字节 nop(lea 指令), 没有 5 字节的形式
+var nops32 = [][]byte{
+	nil,
+	{0x90},
+	{0x66, 0x90},
+	{0x8D, 0x76, 0x00},       // lea 0(%esi), %esi
+	{0x8D, 0x74, 0x26, 0x00}, // lea 0(%esi,%eiz,1), %esi
+	nil,
+	{0x8D, 0xB6, 0x00, 0x00, 0x00, 0x00}, // lea 0(%esi), %esi
+	{0x8D, 0xB4, 0x26, 0x00, 0x00, 0x00, 0x00}, // lea 0(%esi,%eiz,1), %esi
+}
+
+// nops 生成 n 个字节的 nop: 尽量使用最长的 nop, 填充较长时先跳过填充区域
+func nops(n, bits int) []byte {
+	table, limit := nops32, 21
+	if bits == 64 {
+		table, limit = nops64, 88
+	}
+	var code []byte
+	if n >= limit {
+		if n-2 <= 127 {
+			code = append(code, 0xEB, byte(n-2))
+		} else {
+			code = append(code, 0xE9, byte(n-5), byte((n-5)>>8), byte((n-5)>>16), byte((n-5)>>24))
+		}
+		n -= len(code)
+	}
+	for n > 0 {
+		size := min(n, len(table)-1)
+		for table[size] == nil {
+			size--
+		}
+		code = append(code, table[size]...)
+		n -= size
+	}
+	return code
+}
+
+// align 对齐填充, 长度取决于指令所在的偏移
+func (e *encoder) align(ins *instr) {
+	n := alignUp(ins.Offset, ins.Size) - ins.Offset
+	if ins.Max > 0 && n > ins.Max {
+		return
+	}
+	switch {
+	case len(ins.Values) > 0:
+		for i := 0; i < n; i++ {
+			e.byte(byte(ins.Values[0].Value))
+		}
+	case len(ins.Nops) > 0 && ins.Sec != nil && ins.Sec.exec(): // 不足最短 nop 的部分填 0, 其余由长到短填充 nop 指令
+		short := len(ins.Nops[len(ins.Nops)-1])
+		e.byte(make([]byte, n%short)...)
+		n -= n % short
+		for _, nop := range ins.Nops {
+			for ; n >= len(nop); n -= len(nop) {
+				e.byte(nop...)
+			}
+		}
+	case ins.Sec != nil && ins.Sec.exec():
+		e.byte(nops(n, e.bits)...)
+	default:
+		e.byte(make([]byte, n)...)
+	}
+}
+
+// fill 填充 Times 个 Size 字节的值
+func (e *encoder) fill(ins *instr) {
+	val := uint64(uint32(ins.Values[0].Value))
+	for i := 0; i < ins.Times; i++ {
+		for j := 0; j < ins.Size; j++ {
+			e.byte(byte(val >> (8 * j)))
+		}
+	}
+}
+
+// attSpace .skip/.space/.zero n[, fill], zero 表示不能指定填充值
+func (p *parser) attSpace(zero bool) {
+	pos := p.pos
+	n, fill := p.number(), int64(0)
+	if !zero && p.got(COMMA) {
+		fill = p.number()
+	}
+	p.space(n, 1, fill, pos)
+}
+
+// attFill .fill repeat[, size[, value]]
+func (p *parser) attFill() {
+	pos := p.pos
+	n, size, val := p.number(), int64(1), int64(0)
+	if p.got(COMMA) {
+		size = p.number()
+		if p.got(COMMA) {
+			val = p.number()
+		}
+	}
+	if size < 0 || size > 8 {
+		p.errorAt(pos, fmt.Sprintf(".fill: 无效的宽度 %d, 不能超过 8 字节", size))
+	}
+	p.space(n, int(size), val, pos)
+}
+
+// space 填充 n 个 size 字节的值
+func (p *parser) space(n int64, size int, val int64, pos prog.FilePos) {
+	if n < 0 {
+		p.errorAt(pos, fmt.Sprintf("填充长度不能为负数: %d", n))
+	}
+	p.emit(&instr{Opcode: K_SPACE, Size: size, Times: int(n), Values: []*operand{{Type: OPRTP_IMM, Value: val}}, Pos: pos})
+}
+
+// attAlign .align/.balign n[, fill[, max]] 按字节对齐, pow 为 true 时(.p2align)按 2^n 字节对齐
+func (p *parser) attAlign(pow bool) {
+	pos := p.pos
+	n := p.number()
+	if pow {
+		if n < 0 || n > 30 {
+			p.errorAt(pos, fmt.Sprintf("无效的对齐值: %d", n))
+		}
+		n = 1 << n
+	}
+	if n == 0 {
+		n = 1
+	}
+	if n < 0 || n&(n-1) != 0 {
+		p.errorAt(pos, fmt.Sprintf("对齐值必须是 2 的幂: %d", n))
+	}
+	ins := &instr{Opcode: K_ALIGN, Size: int(n), Times: 1, Pos: pos}
+	if p.got(COMMA) {
+		if p.token != COMMA { // 填充值可以省略: .p2align 4,,15
+			fill := p.number()
+			if !fitsSize(fill, 1) {
+				p.errorAt(pos, fmt.Sprintf("填充值 %d 超出 1 字节范围", fill))
+			}
+			ins.Values = []*operand{{Type: OPRTP_IMM, Value: fill}}
+		}
+		if p.got(COMMA) {
+			ins.Max = int(p.number())
+		}
+	}
+	p.sec.Align = max(p.sec.Align, ins.Size)
+	if len(ins.Values) == 0 {
+		switch p.machine {
+		case elf.EM_AARCH64:
+			ins.Nops = [][]byte{binary.LittleEndian.AppendUint32(nil, a64Nop)}
+		case elf.EM_RISCV:
+			ins = p.rvAlign(ins)
+		}
+	}
+	p.emit(ins)
+}
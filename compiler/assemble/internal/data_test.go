@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDataDirective(t *testing.T) {
+	// 期望结果来自 GNU as 的输出
+	tests := []struct {
+		src  string
+		want []byte
+	}{
+		{".ascii \"ab\", \"c\"", []byte("abc")},
+		{".asciz \"ab\", \"c\"", []byte("ab\x00c\x00")},
+		{".string \"x\"", []byte("x\x00")},
+		{".skip 3", []byte{0, 0, 0}},
+		{".space 2, 7", []byte{7, 7}},
+		{".zero 2", []byte{0, 0}},
+		{".fill 2, 3, 0x11223344", []byte{0x44, 0x33, 0x22, 0x44, 0x33, 0x22}},
+		{".fill 1, 8, -1", []byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0}},
+		{".fill 2", []byte{0, 0}},
+		{".byte 1\n.balign 4", []byte{1, 0, 0, 0}},
+		{".byte 1\n.align 4, 0xff", []byte{1, 0xFF, 0xFF, 0xFF}},
+		{".byte 1\n.p2align 3\n.byte 2", []byte{1, 0, 0, 0, 0, 0, 0, 0, 2}},
+		{".byte 1\n.balign 16, 0, 3\n.byte 2", []byte{1, 2}},
+		{".byte 1\n.p2align 2,,3\n.byte 2", []byte{1, 0, 0, 0, 2}},
+	}
+	for _, tt := range tests {
+		p := assemble(t, "amd64", SyntaxATT, ".data\n"+tt.src)
+		sec := p.secList[len(p.secList)-1]
+		if !bytes.Equal(sec.Data, tt.want) {
+			t.Errorf("%q: got % X, want % X", tt.src, sec.Data, tt.want)
+		}
+	}
+}
+
+func TestAlignNop(t *testing.T) {
+	// 代码段的对齐填充 nop, 期望结果来自 GNU as 的输出
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{1, []byte{0x90}},
+		{5, []byte{0x0F, 0x1F, 0x44, 0x00, 0x00}},
+		{12, []byte{0x66, 0x66, 0x2E, 0x0F, 0x1F, 0x84, 0x00, 0x00, 0x00, 0x00, 0x00, 0x90}},
+	}
+	for _, tt := range tests {
+		if got := nops(tt.n, 64); !bytes.Equal(got, tt.want) {
+			t.Errorf("nops(%d, 64): got % X, want % X", tt.n, got, tt.want)
+		}
+	}
+	if got, want := nops(5, 32), []byte{0x8D, 0x74, 0x26, 0x00, 0x90}; !bytes.Equal(got, want) {
+		t.Errorf("nops(5, 32): got % X, want % X", got, want)
+	}
+	if got := nops(100, 64); len(got) != 100 || got[0] != 0xEB || got[1] != 98 {
+		t.Errorf("nops(100, 64): 较长的填充应先跳过, got % X", got)
+	}
+
+	// 跳转指令缩短后对齐填充随之变化
+	src := `
+start:
+	jmp end
+	.p2align 4
+loop:
+	decl %ecx
+	jne loop
+	jmp start
+	.balign 8
+end:
+	ret
+`
+	p := assemble(t, "amd64", SyntaxATT, src)
+	want := []byte{
+		0xEB, 0x16, // jmp end
+		0x66, 0x66, 0x2E, 0x0F, 0x1F, 0x84, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0F, 0x1F, 0x00, // .p2align 4
+		0xFF, 0xC9, // loop: decl %ecx
+		0x75, 0xFC, // jne loop
+		0xEB, 0xEA, // jmp start
+		0x66, 0x90, // .balign 8
+		0xC3, // end: ret
+	}
+	if got := p.secList[0].Data; !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+	if p.secList[0].Align != 16 {
+		t.Errorf("段的对齐要求: got %d, want 16", p.secList[0].Align)
+	}
+}
+
+func TestBss(t *testing.T) {
+	p := assemble(t, "amd64", SyntaxATT, ".bss\n.skip 100\n.balign 32\nbuf: .zero 16\n")
+	if sec := p.secList[len(p.secList)-1]; sec.Name != ".bss" || sec.Length != 144 {
+		t.Errorf("%s: 长度 got %d, want 144", sec.Name, sec.Length)
+	}
+	if lb := p.GetLabel("buf"); lb.Addr != 128 {
+		t.Errorf("buf: got %#x, want 0x80", lb.Addr)
+	}
+
+	for _, src := range []string{".bss\n.byte 1", ".bss\n.skip 2, 1", ".bss\n.long buf\nbuf:"} {
+		lex := NewBytesLexer([]byte(src))
+		lex.syntax = SyntaxATT
+		if err := NewParser(lex).ParseFile(); err == nil {
+			t.Errorf("%q: .bss 段中定义非零数据应该出错", src)
+		}
+	}
+}
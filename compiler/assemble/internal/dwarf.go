@@ -0,0 +1,489 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"path/filepath"
+)
+
+// 调试信息: 生成 DWARF 4 格式的 .debug_line 行号表, .debug_aranges 地址表, 以及只包含一个编译单元的
+// .debug_info/.debug_abbrev, 使 gdb 可以按汇编源码单步执行. 多个代码段时编译单元的地址范围记录在 .debug_ranges 中;
+// RISC-V 的链接器松弛会移动代码, 代码段中的地址差由 R_RISCV_ADD/R_RISCV_SUB 重定位对计算. -g 时按指令在源文件中的位置生成行号, 宏展开的指令对应展开位置;
+// 也可以由 AT&T 风格的伪指令指定(编译器生成的汇编代码), 此时不需要 -g:
+//
+//	.file "name"                  源文件名, 生成 STT_FILE 符号
+//	.file n ["dir"] "name"        行号表中的第 n 个文件
+//	.loc n line [col] [...]       之后的指令对应第 n 个文件的第 line 行, 其余选项(is_stmt 等)忽略
+
+// 行号程序的参数, 与 GAS 一致
+const (
+	dwLineBase   = -5
+	dwLineRange  = 14
+	dwOpcodeBase = 13
+)
+
+// DWARF 常量
+const (
+	dwLnsCopy        = 0x01
+	dwLnsAdvancePc   = 0x02
+	dwLnsAdvanceLine = 0x03
+	dwLnsSetFile     = 0x04
+	dwLnsSetColumn   = 0x05
+	dwLnsFixedAdvPc  = 0x09
+	dwLneEndSeq      = 0x01
+	dwLneSetAddress  = 0x02
+
+	dwTagCompileUnit = 0x11
+	dwAtName         = 0x03
+	dwAtStmtList     = 0x10
+	dwAtLowPc        = 0x11
+	dwAtHighPc       = 0x12
+	dwAtLanguage     = 0x13
+	dwAtCompDir      = 0x1b
+	dwAtRanges       = 0x55
+	dwAtProducer     = 0x25
+	dwFormAddr       = 0x01
+	dwFormData2      = 0x05
+	dwFormData4      = 0x06
+	dwFormString     = 0x08
+	dwFormSecOffset  = 0x17
+
+	dwLangMipsAssembler = 0x8001
+)
+
+// dwarfProducer 编译单元的 DW_AT_producer
+const dwarfProducer = "face asm"
+
+// lineFile 行号表中的文件
+type lineFile struct {
+	Name string
+	Dir  string // 所在目录, 为空时相对于编译单元的工作目录
+}
+
+// lineLoc 行号表中的位置: 文件序号(从 1 开始, 0 表示没有行号信息)、行号及列号
+type lineLoc struct {
+	File, Line, Col int
+}
+
+// lineLoc 指令在行号表中的位置
+func (p *parser) lineLoc(ins *instr) lineLoc {
+	if p.locs {
+		return p.loc
+	}
+	if !p.debug || !p.sec.exec() {
+		return lineLoc{}
+	}
+	pos := ins.Pos
+	for i := len(p.frames) - 1; i >= 0 && !p.frames[i].file; i-- { // 宏展开及重复块使用展开位置
+		pos = p.frames[i].use
+	}
+	return lineLoc{File: p.fileIndex(pos.Filename), Line: pos.Line + 1}
+}
+
+// fileIndex 源文件在行号表中的序号, 不存在时添加
+func (p *parser) fileIndex(name string) int {
+	for i, f := range p.lineFiles {
+		if f.Name == name && f.Dir == "" {
+			return i + 1
+		}
+	}
+	p.lineFiles = append(p.lineFiles, lineFile{Name: name})
+	return len(p.lineFiles)
+}
+
+// attFile .file "name" 或 .file n ["dir"] "name"
+func (p *parser) attFile() {
+	pos := p.pos
+	if p.token == STRING {
+		p.srcName = p.id
+		p.next()
+		return
+	}
+	n := p.number()
+	if n <= 0 {
+		p.errorAt(pos, fmt.Sprintf(".file: 无效的文件序号 %d", n))
+	}
+	if !p.locs && len(p.lineFiles) > 0 {
+		p.errorAt(pos, ".file: -g 生成的行号不能与 .file/.loc 同时使用")
+	}
+	f := lineFile{Name: p.id}
+	p.expect(STRING)
+	if p.token == STRING { // .file n "dir" "name"
+		f = lineFile{Name: p.id, Dir: f.Name}
+		p.next()
+	}
+	for !p.atEOL() { // md5 等选项忽略
+		p.next()
+	}
+	for len(p.lineFiles) < int(n) {
+		p.lineFiles = append(p.lineFiles, lineFile{})
+	}
+	p.lineFiles[n-1] = f
+	p.locs = true
+}
+
+// attLoc .loc n line [col] [options]
+func (p *parser) attLoc() {
+	pos := p.pos
+	n := int(p.number())
+	if n <= 0 || n > len(p.lineFiles) || p.lineFiles[n-1].Name == "" {
+		p.errorAt(pos, fmt.Sprintf(".loc: 文件序号 %d 没有使用 .file 定义", n))
+	}
+	loc := lineLoc{File: n, Line: int(p.number())}
+	if p.token == INT {
+		loc.Col = int(p.number())
+	}
+	for !p.atEOL() {
+		p.next()
+	}
+	p.loc = loc
+}
+
+// dwarfBuf 调试信息段的内容, 以及其中引用段地址的位置
+type dwarfBuf struct {
+	data []byte
+	rels []dwarfRel
+}
+
+// dwarfRel 调试信息中的地址引用: 符号 Sym + Addend, Type 为 0 时按 Size 使用绝对地址重定位
+type dwarfRel struct {
+	Offset int
+	Size   int
+	Sym    string
+	Addend int64
+	Type   int
+}
+
+func (b *dwarfBuf) u8(v byte)    { b.data = append(b.data, v) }
+func (b *dwarfBuf) u16(v uint16) { b.data = binary.LittleEndian.AppendUint16(b.data, v) }
+func (b *dwarfBuf) u32(v uint32) { b.data = binary.LittleEndian.AppendUint32(b.data, v) }
+
+func (b *dwarfBuf) uleb(v uint64) {
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 {
+			b.u8(c)
+			return
+		}
+		b.u8(c | 0x80)
+	}
+}
+
+func (b *dwarfBuf) sleb(v int64) {
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0 {
+			b.u8(c)
+			return
+		}
+		b.u8(c | 0x80)
+	}
+}
+
+// str 以 0 结尾的字符串
+func (b *dwarfBuf) str(s string) {
+	b.data = append(b.data, s...)
+	b.u8(0)
+}
+
+// ref 引用段 sym 中偏移 addend 处的地址, 占用 size 字节, 由重定位填写
+func (b *dwarfBuf) ref(sym string, addend int64, size int) {
+	b.rels = append(b.rels, dwarfRel{Offset: len(b.data), Size: size, Sym: sym, Addend: addend})
+	b.data = append(b.data, make([]byte, size)...)
+}
+
+// diff 地址差 hi - lo, 占用 size 字节, 由 R_RISCV_ADDn/R_RISCV_SUBn 重定位对填写
+func (b *dwarfBuf) diff(hi, lo string, size int) {
+	add, sub := elf.R_RISCV_ADD16, elf.R_RISCV_SUB16
+	if size == 8 {
+		add, sub = elf.R_RISCV_ADD64, elf.R_RISCV_SUB64
+	}
+	b.rels = append(b.rels,
+		dwarfRel{Offset: len(b.data), Size: size, Sym: hi, Type: int(add)},
+		dwarfRel{Offset: len(b.data), Size: size, Sym: lo, Type: int(sub)})
+	b.data = append(b.data, make([]byte, size)...)
+}
+
+// patch32 回填长度字段, 长度为 at+4 之后到当前位置的字节数
+func (b *dwarfBuf) patch32(at int) {
+	binary.LittleEndian.PutUint32(b.data[at:], uint32(len(b.data)-at-4))
+}
+
+// dwarf 生成调试信息段, 在代码生成之后调用
+func (p *parser) dwarf() {
+	var code []*section // 有行号信息的段
+	for _, sec := range p.secList {
+		for _, ins := range p.instrList {
+			if ins.Sec == sec && ins.Loc.File != 0 && ins.Len > 0 {
+				code = append(code, sec)
+				break
+			}
+		}
+	}
+	if len(code) == 0 {
+		return
+	}
+	p.debugSection(".debug_info", p.debugInfo(code))
+	p.debugSection(".debug_abbrev", p.debugAbbrev(code))
+	p.debugSection(".debug_line", p.debugLine(code))
+	p.debugSection(".debug_aranges", p.debugAranges(code))
+	if p.useRanges(code) {
+		p.debugSection(".debug_ranges", p.debugRanges(code))
+	}
+}
+
+// useRanges 编译单元的地址范围是否记录在 .debug_ranges 中: 多个代码段, 或者代码段的长度在链接时才能确定
+func (p *parser) useRanges(code []*section) bool {
+	return len(code) > 1 || p.rvRelaxed(code[0].Name, 0, code[0].Length)
+}
+
+// addrLabel 代码段中偏移 off 处的临时符号, 松弛移动代码后由链接器修正地址
+func (p *parser) addrLabel(sec *section, off int) string {
+	key := fmt.Sprintf("%s+%d", sec.Name, off)
+	if name, ok := p.addrLabels[key]; ok {
+		return name
+	}
+	if p.addrLabels == nil {
+		p.addrLabels = make(map[string]string)
+	}
+	name := p.rvSymRef(&label{Type: LOCAL_LABEL, Addr: off, Section: sec.Name})
+	p.addrLabels[key] = name
+	return name
+}
+
+// secEnd 引用段的结束地址: 可松弛的段使用段末尾的临时符号
+func (p *parser) secEnd(sec *section) (string, int64) {
+	if p.rvRelaxed(sec.Name, 0, sec.Length) {
+		return p.addrLabel(sec, sec.Length), 0
+	}
+	return sec.Name, int64(sec.Length)
+}
+
+// debugRanges 编译单元的地址范围列表: 基地址选择项(基地址为 0), 每个段一项 [起始地址, 结束地址), 以 0, 0 结束
+func (p *parser) debugRanges(code []*section) *dwarfBuf {
+	b := &dwarfBuf{}
+	size := p.bits / 8
+	b.data = append(b.data, bytes.Repeat([]byte{0xFF}, size)...)
+	b.data = append(b.data, make([]byte, size)...)
+	for _, sec := range code {
+		end, addend := p.secEnd(sec)
+		b.ref(sec.Name, 0, size)
+		b.ref(end, addend, size)
+	}
+	b.data = append(b.data, make([]byte, 2*size)...)
+	return b
+}
+
+// debugAranges 地址表: 每个代码段一项 (起始地址, 长度), 可松弛的段的长度由重定位对计算
+func (p *parser) debugAranges(code []*section) *dwarfBuf {
+	b := &dwarfBuf{}
+	size := p.bits / 8
+	b.u32(0) // unit_length
+	b.u16(2) // version
+	b.ref(".debug_info", 0, 4)
+	b.u8(byte(size))
+	b.u8(0) // segment_selector_size
+	for len(b.data)%(2*size) != 0 {
+		b.u8(0)
+	}
+	for _, sec := range code {
+		b.ref(sec.Name, 0, size)
+		if p.rvRelaxed(sec.Name, 0, sec.Length) {
+			b.diff(p.addrLabel(sec, sec.Length), p.addrLabel(sec, 0), size)
+		} else if size == 8 {
+			b.data = binary.LittleEndian.AppendUint64(b.data, uint64(sec.Length))
+		} else {
+			b.u32(uint32(sec.Length))
+		}
+	}
+	b.data = append(b.data, make([]byte, 2*size)...)
+	b.patch32(0)
+	return b
+}
+
+// debugSection 添加调试信息段, 地址引用生成重定位:
+// 32 位目标文件(REL)加数写入引用位置, 64 位目标文件(RELA)加数记录在重定位项中
+func (p *parser) debugSection(name string, b *dwarfBuf) {
+	sec := p.newSection(name, elf.SHT_PROGBITS, 0, 0)
+	sec.Align, sec.Data, sec.Length, sec.Offset = 1, b.data, len(b.data), len(b.data)
+	for _, r := range b.rels {
+		typ, addend := r.Type, r.Addend
+		if typ == 0 {
+			typ = p.relType(fixup{Size: r.Size})
+		}
+		if p.bits == 32 {
+			addend = 0
+			binary.LittleEndian.PutUint32(sec.Data[r.Offset:], uint32(r.Addend))
+		}
+		p._addRel(sec, r.Offset, r.Sym, typ, addend)
+	}
+}
+
+// debugAbbrev 编译单元的缩写表, 只有一个代码段时以 low_pc/high_pc 记录其地址范围, 否则引用 .debug_ranges
+func (p *parser) debugAbbrev(code []*section) *dwarfBuf {
+	b := &dwarfBuf{}
+	b.uleb(1)
+	b.uleb(dwTagCompileUnit)
+	b.u8(0) // 没有子节点
+	attrs := []uint64{dwAtStmtList, dwFormSecOffset}
+	if p.useRanges(code) {
+		attrs = append(attrs, dwAtRanges, dwFormSecOffset)
+	} else {
+		attrs = append(attrs, dwAtLowPc, dwFormAddr, dwAtHighPc, dwFormData4)
+	}
+	attrs = append(attrs,
+		dwAtName, dwFormString,
+		dwAtCompDir, dwFormString,
+		dwAtProducer, dwFormString,
+		dwAtLanguage, dwFormData2,
+		0, 0)
+	for _, v := range attrs {
+		b.uleb(v)
+	}
+	b.u8(0)
+	return b
+}
+
+// debugInfo 编译单元, 属性顺序与 debugAbbrev 一致
+func (p *parser) debugInfo(code []*section) *dwarfBuf {
+	b := &dwarfBuf{}
+	b.u32(0) // unit_length
+	b.u16(4) // version
+	b.ref(".debug_abbrev", 0, 4)
+	b.u8(byte(p.bits / 8))
+	b.uleb(1)
+	b.ref(".debug_line", 0, 4)
+	if p.useRanges(code) {
+		b.ref(".debug_ranges", 0, 4)
+	} else {
+		b.ref(code[0].Name, 0, p.bits/8)
+		b.u32(uint32(code[0].Length))
+	}
+	name := p.srcName
+	if name == "" {
+		name = p.filename
+	}
+	b.str(name)
+	b.str(p.compDir)
+	b.str(dwarfProducer)
+	b.u16(dwLangMipsAssembler)
+	b.patch32(0)
+	return b
+}
+
+// debugLine 行号表: 每个段一个行号序列, 位置相同的连续指令只记录一行;
+// 可松弛的段与 GAS 一致, 以 DW_LNS_fixed_advance_pc 推进地址, 地址差由 R_RISCV_ADD16/R_RISCV_SUB16 重定位对填写
+func (p *parser) debugLine(code []*section) *dwarfBuf {
+	b := &dwarfBuf{}
+	b.u32(0) // unit_length
+	b.u16(4) // version
+	b.u32(0) // header_length
+	b.u8(1)  // minimum_instruction_length
+	b.u8(1)  // maximum_operations_per_instruction
+	b.u8(1)  // default_is_stmt
+	b.u8(dwLineBase & 0xFF)
+	b.u8(dwLineRange)
+	b.u8(dwOpcodeBase)
+	b.data = append(b.data, 0, 1, 1, 1, 1, 0, 0, 0, 1, 0, 0, 1) // standard_opcode_lengths
+
+	var dirs []string
+	dirIndex := make(map[string]int)
+	for _, f := range p.lineFiles {
+		if _, ok := dirIndex[f.Dir]; !ok && f.Dir != "" {
+			dirs = append(dirs, f.Dir)
+			dirIndex[f.Dir] = len(dirs)
+		}
+	}
+	for _, dir := range dirs {
+		b.str(dir)
+	}
+	b.u8(0)
+	for _, f := range p.lineFiles {
+		b.str(filepath.ToSlash(f.Name))
+		b.uleb(uint64(dirIndex[f.Dir]))
+		b.uleb(0) // 修改时间
+		b.uleb(0) // 文件大小
+	}
+	b.u8(0)
+	b.patch32(6)
+
+	for _, sec := range code {
+		b.u8(0) // DW_LNE_set_address
+		b.uleb(uint64(1 + p.bits/8))
+		b.u8(dwLneSetAddress)
+		b.ref(sec.Name, 0, p.bits/8)
+
+		relaxed := p.rvRelaxed(sec.Name, 0, sec.Length)
+		addr, cur := 0, lineLoc{File: 1, Line: 1}
+		first := true
+		for _, ins := range p.instrList {
+			if ins.Sec != sec || ins.Loc.File == 0 || ins.Len == 0 || !first && ins.Loc == cur {
+				continue
+			}
+			first = false
+			if ins.Loc.File != cur.File {
+				b.u8(dwLnsSetFile)
+				b.uleb(uint64(ins.Loc.File))
+			}
+			if ins.Loc.Col != cur.Col {
+				b.u8(dwLnsSetColumn)
+				b.uleb(uint64(ins.Loc.Col))
+			}
+			if relaxed {
+				p.fixedRow(b, sec, addr, ins.Offset, ins.Loc.Line-cur.Line)
+			} else {
+				b.row(ins.Offset-addr, ins.Loc.Line-cur.Line)
+			}
+			addr, cur = ins.Offset, ins.Loc
+		}
+		if sec.Length > addr && relaxed {
+			b.u8(dwLnsFixedAdvPc)
+			b.diff(p.addrLabel(sec, sec.Length), p.addrLabel(sec, addr), 2)
+		} else if sec.Length > addr {
+			b.u8(dwLnsAdvancePc)
+			b.uleb(uint64(sec.Length - addr))
+		}
+		b.u8(0) // DW_LNE_end_sequence
+		b.uleb(1)
+		b.u8(dwLneEndSeq)
+	}
+	b.patch32(0)
+	return b
+}
+
+// row 添加一行, 尽量使用特殊操作码同时推进地址及行号
+func (b *dwarfBuf) row(addrDelta, lineDelta int) {
+	if lineDelta >= dwLineBase && lineDelta < dwLineBase+dwLineRange {
+		op := lineDelta - dwLineBase + dwLineRange*addrDelta + dwOpcodeBase
+		if op <= 255 {
+			b.u8(byte(op))
+			return
+		}
+	}
+	if lineDelta != 0 {
+		b.u8(dwLnsAdvanceLine)
+		b.sleb(int64(lineDelta))
+	}
+	if addrDelta != 0 {
+		b.u8(dwLnsAdvancePc)
+		b.uleb(uint64(addrDelta))
+	}
+	b.u8(dwLnsCopy)
+}
+
+// fixedRow 添加一行, 地址从 from 推进到 to, 地址差在链接时由重定位对计算
+func (p *parser) fixedRow(b *dwarfBuf, sec *section, from, to, lineDelta int) {
+	if lineDelta != 0 {
+		b.u8(dwLnsAdvanceLine)
+		b.sleb(int64(lineDelta))
+	}
+	if to > from {
+		b.u8(dwLnsFixedAdvPc)
+		b.diff(p.addrLabel(sec, to), p.addrLabel(sec, from), 2)
+	}
+	b.u8(dwLnsCopy)
+}
@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"debug/dwarf"
+	"github.com/facelang/face/internal/arch"
+	"github.com/facelang/face/internal/os/elf"
+	"slices"
+	"testing"
+)
+
+// lineTable 使用标准库解析生成的调试信息, 返回行号表中的 地址:行号
+func lineTable(t *testing.T, p *parser) (files []string, rows [][2]int) {
+	t.Helper()
+	data := func(name string) []byte {
+		if sec := p.findSection(name); sec != nil {
+			return sec.Data
+		}
+		t.Fatalf("缺少 %s 段", name)
+		return nil
+	}
+	d, err := dwarf.New(data(".debug_abbrev"), nil, nil, data(".debug_info"), data(".debug_line"), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cu, err := d.Reader().Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lr, err := d.LineReader(cu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range lr.Files() {
+		if f != nil {
+			files = append(files, f.Name)
+		}
+	}
+	var e dwarf.LineEntry
+	for lr.Next(&e) == nil {
+		if !e.EndSequence {
+			rows = append(rows, [2]int{int(e.Address), e.Line})
+		}
+	}
+	return files, rows
+}
+
+func TestDebugLine(t *testing.T) {
+	src := `
+.macro sys n
+	movl $\n, %eax
+	.byte 0x0f, 0x05
+.endm
+_start:
+	movl $1, %edi
+	sys 1
+
+	movl $0, %edi
+	sys 60
+	.data
+	.long 1
+`
+	lex := NewBytesLexer([]byte(src))
+	lex.syntax = SyntaxATT
+	p := NewParser(lex)
+	p.bits, p.debug = 64, true
+	if err := p.ParseFile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Codegen(); err != nil {
+		t.Fatal(err)
+	}
+	_, rows := lineTable(t, p)
+	want := [][2]int{{0, 7}, {5, 8}, {12, 10}, {17, 11}}
+	if len(rows) != len(want) {
+		t.Fatalf("got %v, want %v", rows, want)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("第 %d 行: got %v, want %v", i, rows[i], want[i])
+		}
+	}
+	// 地址引用生成重定位: .debug_info 中的 abbrev/line 偏移及 low_pc, .debug_line 中的起始地址
+	n := 0
+	for _, rel := range p.relocateList {
+		if rel.Section == ".debug_info" || rel.Section == ".debug_line" {
+			n++
+		}
+	}
+	if n != 4 {
+		t.Errorf("调试信息的重定位: got %d, want 4", n)
+	}
+}
+
+func TestDebugLoc(t *testing.T) {
+	src := `
+	.file "x.c"
+	.file 1 "src" "x.c"
+	.file 2 "x.h"
+f:
+	.loc 1 3 5
+	movl $1, %eax
+	movl $2, %eax
+	.loc 2 40 1 is_stmt 0
+	ret
+	.loc 1 2
+	ret
+`
+	p := assemble(t, "amd64", SyntaxATT, src)
+	files, rows := lineTable(t, p)
+	if len(files) != 2 || files[0] != "src/x.c" || files[1] != "x.h" {
+		t.Errorf("files: got %q", files)
+	}
+	want := [][2]int{{0, 3}, {10, 40}, {11, 2}}
+	if len(rows) != len(want) {
+		t.Fatalf("got %v, want %v", rows, want)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("第 %d 行: got %v, want %v", i, rows[i], want[i])
+		}
+	}
+	if p.srcName != "x.c" {
+		t.Errorf(".file: got %q", p.srcName)
+	}
+
+	for _, src := range []string{".loc 1 2", ".file 0 \"a.s\"", ".file 2 \"a.s\"\n.loc 1 2"} {
+		lex := NewBytesLexer([]byte(src))
+		lex.syntax = SyntaxATT
+		if err := NewParser(lex).ParseFile(); err == nil {
+			t.Errorf("%q: 期望出错", src)
+		}
+	}
+}
+
+func TestDebugRanges(t *testing.T) {
+	// 多个代码段: 编译单元的地址范围记录在 .debug_ranges 中, 与 GAS 一致
+	src := `
+	.text
+	movl $1, %eax
+	ret
+	.section .text.startup,"ax",@progbits
+	ret
+`
+	lex := NewBytesLexer([]byte(src))
+	lex.syntax = SyntaxATT
+	p := NewParser(lex)
+	p.bits, p.debug = 64, true
+	if err := p.ParseFile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Codegen(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		".debug_info":    {".debug_abbrev", ".debug_line", ".debug_ranges"},
+		".debug_aranges": {".debug_info", ".text", ".text.startup"},
+		".debug_ranges":  {".text", ".text", ".text.startup", ".text.startup"},
+	}
+	got := make(map[string][]string)
+	for _, rel := range p.relocateList {
+		got[rel.Section] = append(got[rel.Section], rel.Label)
+	}
+	for sec, labels := range want {
+		if !slices.Equal(got[sec], labels) {
+			t.Errorf("%s 的重定位: got %v, want %v", sec, got[sec], labels)
+		}
+	}
+	// 基地址选择项之后, 每个段一项 [起始地址, 结束地址)
+	ranges := p.findSection(".debug_ranges")
+	if ranges == nil || len(ranges.Data) != 64 {
+		t.Fatalf(".debug_ranges: got %v", ranges)
+	}
+	var ends []int64
+	for _, rel := range p.relocateList {
+		if rel.Section == ".debug_ranges" {
+			ends = append(ends, rel.Addend)
+		}
+	}
+	if !slices.Equal(ends, []int64{0, 6, 0, 1}) {
+		t.Errorf(".debug_ranges 的地址: got %v", ends)
+	}
+}
+
+func TestDebugLineRelax(t *testing.T) {
+	// 启用松弛时地址差由重定位对计算: 每次推进地址一对 R_RISCV_ADD16/R_RISCV_SUB16
+	src := `
+_start:
+	call f
+	li a0, 1
+f:
+	ret
+`
+	lex := NewBytesLexer([]byte(src))
+	lex.syntax = SyntaxATT
+	p := NewParser(lex)
+	p.setArch(arch.Set("riscv64"))
+	p.debug = true
+	if err := p.ParseFile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Codegen(); err != nil {
+		t.Fatal(err)
+	}
+	var line []string
+	for _, rel := range p.relocateList {
+		if rel.Section == ".debug_line" {
+			line = append(line, elf.R_RISCV(rel.Type).String())
+		}
+	}
+	want := []string{"R_RISCV_64", "R_RISCV_ADD16", "R_RISCV_SUB16", "R_RISCV_ADD16", "R_RISCV_SUB16", "R_RISCV_ADD16", "R_RISCV_SUB16"}
+	if !slices.Equal(line, want) {
+		t.Errorf(".debug_line 的重定位: got %v, want %v", line, want)
+	}
+	if p.findSection(".debug_ranges") == nil {
+		t.Error("可松弛的代码段应使用 .debug_ranges")
+	}
+	_, rows := lineTable(t, p)
+	if len(rows) != 3 || rows[0] != [2]int{0, 3} || rows[2][1] != 6 {
+		t.Errorf("got %v", rows)
+	}
+}
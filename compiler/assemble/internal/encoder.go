@@ -0,0 +1,710 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+)
+
+// fixup 编码结果中需要回填的符号引用
+type fixup struct {
+	Offset int     // 回填位置(相对指令开始)
+	Size   int     // 回填宽度(字节)
+	Label  string  // 引用的符号
+	Expr   Express // 第二遍扫描时计算的表达式, 为 nil 时引用 Label
+	Addend int64   // 加数, pc 相对寻址时以回填位置为基准
+	PCRel  bool    // 是否 pc 相对寻址
+	Signed bool    // 64 位模式下作为有符号 32 位数扩展(R_X86_64_32S)
+	Branch bool    // 跳转目标(call/jmp/jcc), 64 位模式下使用 R_X86_64_PLT32
+	Reloc  int     // 精简指令架构(arm64, riscv64)指定的重定位类型, 回填指令字中的字段
+	Keep   bool    // 总是生成重定位, 同一段内的 pc 相对引用也不回填(RISC-V 链接器松弛会改变指令间距)
+	SymRef bool    // 重定位引用符号本身而不是所在的段, 局部符号也输出到符号表(R_RISCV_PCREL_LO12_*)
+	Relax  bool    // 所在的 x86 指令可以被链接器松弛, 其中的 GOT 引用使用 R_X86_64_GOTPCRELX, R_386_GOT32X
+	Rex    bool    // 所在的 x86 指令带有 REX 前缀(R_X86_64_REX_GOTPCRELX)
+	Mod    x86Mod  // x86 的重定位修饰(@PLT, @GOTPCREL 等), 第二遍扫描时由表达式确定
+}
+
+// REX 前缀及其标志位
+const (
+	rex  = 0x40
+	rexW = 0x08 // 64 位操作数
+	rexR = 0x04 // ModRM.reg 扩展
+	rexX = 0x02 // SIB.index 扩展
+	rexB = 0x01 // ModRM.rm, SIB.base 或操作码寄存器扩展
+)
+
+// encoder 机器码生成器
+type encoder struct {
+	bits   int // 32 或 64 位模式
+	code   []byte
+	fixups []fixup
+}
+
+func (e *encoder) byte(b ...byte) {
+	e.code = append(e.code, b...)
+}
+
+// value 按小端序写入 size 字节数据, 引用符号时先写 0 并记录回填信息
+func (e *encoder) value(opr *operand, size int, pcRel bool) {
+	val := opr.Value
+	if opr.Symbolic() {
+		e.fixups = append(e.fixups, fixup{
+			Offset: len(e.code),
+			Size:   size,
+			Label:  opr.Label,
+			Expr:   opr.Expr,
+			Addend: val,
+			PCRel:  pcRel,
+		})
+		val = 0
+	}
+	for i := 0; i < size; i++ {
+		e.code = append(e.code, byte(val>>(8*i)))
+	}
+}
+
+// regNum 寄存器编号, 低 3 位用于 ModRM/SIB/操作码, 第 4 位由 REX 前缀扩展
+func regNum(reg Token) byte {
+	switch {
+	case BR_AL <= reg && reg <= BR_R15B:
+		return byte(reg - BR_AL)
+	case BR_SPL <= reg && reg <= BR_DIL:
+		return byte(reg-BR_SPL) + 4
+	case WR_AX <= reg && reg <= WR_R15W:
+		return byte(reg - WR_AX)
+	case DR_EAX <= reg && reg <= DR_R15D:
+		return byte(reg - DR_EAX)
+	case QR_RAX <= reg && reg <= QR_R15:
+		return byte(reg - QR_RAX)
+	case reg.IsXmm():
+		return byte(reg - XR_XMM0)
+	}
+	panic(fmt.Errorf("无效的寄存器: %s", reg))
+}
+
+// regSize 寄存器宽度(字节)
+func regSize(reg Token) int {
+	switch {
+	case BR_AL <= reg && reg <= BR_DIL:
+		return 1
+	case WR_AX <= reg && reg <= WR_R15W:
+		return 2
+	case DR_EAX <= reg && reg <= DR_R15D:
+		return 4
+	case QR_RAX <= reg && reg <= QR_R15:
+		return 8
+	case reg.IsXmm():
+		return 16
+	}
+	return 0
+}
+
+// regOnly64 仅在 64 位模式下可用的寄存器
+func regOnly64(reg Token) bool {
+	return regSize(reg) == 8 || BR_SPL <= reg && reg <= BR_DIL || regNum(reg) >= 8
+}
+
+// checkReg 检查寄存器在当前模式下是否可用
+func (e *encoder) checkReg(reg Token) error {
+	if reg != 0 && reg != REG_RIP && e.bits != 64 && regOnly64(reg) {
+		return fmt.Errorf("寄存器 %s 仅在 64 位模式下可用", reg)
+	}
+	return nil
+}
+
+func fitsInt8(v int64) bool { return -128 <= v && v <= 127 }
+
+func fitsInt32(v int64) bool { return math.MinInt32 <= v && v <= math.MaxInt32 }
+
+// fitsSize 立即数是否可以用 size 字节表示(有符号或无符号)
+func fitsSize(v int64, size int) bool {
+	if size >= 8 {
+		return true
+	}
+	bits := uint(size * 8)
+	return -(1<<(bits-1)) <= v && v < 1<<bits
+}
+
+// immSize 立即数形式对应的编码宽度
+func immSize(arg argType, size int) int {
+	switch arg {
+	case argImm8, argImmB, argRel8:
+		return 1
+	case argImmW:
+		return 2
+	case argRel:
+		return 4
+	case argImm:
+		if size > 4 {
+			return 4
+		}
+		return size
+	case argImm64:
+		return 8
+	}
+	return 0
+}
+
+// fitsImm 立即数是否满足 size 宽度操作数的编码要求, 64 位操作数的立即数为符号扩展的 32 位数
+func fitsImm(v int64, size int) bool {
+	if size >= 8 {
+		return fitsInt32(v)
+	}
+	return fitsSize(v, size)
+}
+
+// operandSize 计算指令的操作数宽度
+func (e *encoder) operandSize(ins *instr) (int, error) {
+	if hasXmm(ins.Dst, ins.Src) {
+		return e.sseSize(ins)
+	}
+	size := max(stringSize[ins.Opcode], fixedSize[ins.Opcode]) // 串操作指令没有操作数, 宽度由名称决定
+	for _, opr := range []*operand{ins.Dst, ins.Src} {
+		if opr == nil || opr == ins.Src && sizedByDst[ins.Opcode] {
+			continue
+		}
+		s := opr.Size
+		if opr.Type == OPRTP_REG {
+			s = regSize(opr.Reg)
+		}
+		if s == 0 || ins.Opcode == I_LEA && opr.Type == OPRTP_MEM {
+			continue
+		}
+		if size != 0 && size != s {
+			return 0, fmt.Errorf("%s: 操作数宽度不一致(%d, %d)", ins.Opcode, size, s)
+		}
+		size = s
+	}
+	if size == 8 && e.bits != 64 {
+		return 0, fmt.Errorf("%s: 64 位操作数仅在 64 位模式下可用", ins.Opcode)
+	}
+	if size == 0 {
+		if defaultSize[ins.Opcode] {
+			return e.bits / 8, nil
+		}
+		for _, opr := range []*operand{ins.Dst, ins.Src} {
+			if opr != nil && opr.Type == OPRTP_MEM {
+				return 0, fmt.Errorf("%s: 未指定内存操作数宽度(byte/word/dword)", ins.Opcode)
+			}
+		}
+		return 4, nil
+	}
+	return size, nil
+}
+
+// hasXmm 操作数中是否有 xmm 寄存器
+func hasXmm(oprs ...*operand) bool {
+	for _, opr := range oprs {
+		if opr != nil && opr.Type == OPRTP_REG && opr.Reg.IsXmm() {
+			return true
+		}
+	}
+	return false
+}
+
+// sseSize SSE 指令的操作数宽度, 只由通用寄存器(或 cvtsi2sd 的整数内存操作数)决定, 用于选择 REX.W;
+// 浮点内存操作数的宽度由指令决定, 不参与计算
+func (e *encoder) sseSize(ins *instr) (int, error) {
+	size := 0
+	for _, opr := range []*operand{ins.Dst, ins.Src} {
+		switch {
+		case opr == nil:
+		case opr.Type == OPRTP_REG && !opr.Reg.IsXmm():
+			size = regSize(opr.Reg)
+		case opr.Type == OPRTP_MEM && sseIntMem[ins.Opcode]:
+			size = opr.Size
+		}
+	}
+	switch size {
+	case 0, 4:
+		return 4, nil
+	case 8:
+		if e.bits != 64 {
+			return 0, fmt.Errorf("%s: 64 位操作数仅在 64 位模式下可用", ins.Opcode)
+		}
+		return 8, nil
+	}
+	return 0, fmt.Errorf("%s: 通用寄存器操作数只能是 32 或 64 位", ins.Opcode)
+}
+
+// match 检查操作数是否满足编码形式的要求
+func (f *opForm) match(bits, size int, args [3]*operand) bool {
+	if bits == 64 && f.Flags&fNo64 != 0 || size == 8 && f.Flags&fNoQ != 0 {
+		return false
+	}
+	if bits == 64 && f.Flags&fDef64 != 0 && size == 4 { // 64 位模式下不支持 32 位操作数
+		return false
+	}
+	switch f.Width {
+	case szByte:
+		if size != 1 {
+			return false
+		}
+	case szFull:
+		if size == 1 {
+			return false
+		}
+	case szQuad:
+		if size != 8 {
+			return false
+		}
+	}
+	for i, arg := range f.Args {
+		opr := args[i]
+		if arg == argNone || opr == nil {
+			if arg != argNone || opr != nil {
+				return false
+			}
+			continue
+		}
+		switch arg {
+		case argReg:
+			if opr.Type != OPRTP_REG || opr.Reg.IsXmm() {
+				return false
+			}
+		case argAcc:
+			if opr.Type != OPRTP_REG || opr.Reg.IsXmm() || regNum(opr.Reg) != 0 {
+				return false
+			}
+		case argRM:
+			if opr.Type != OPRTP_REG && opr.Type != OPRTP_MEM || opr.Type == OPRTP_REG && opr.Reg.IsXmm() {
+				return false
+			}
+		case argXmm:
+			if opr.Type != OPRTP_REG || !opr.Reg.IsXmm() {
+				return false
+			}
+		case argXmmM:
+			if opr.Type != OPRTP_MEM && (opr.Type != OPRTP_REG || !opr.Reg.IsXmm()) {
+				return false
+			}
+		case argMem:
+			if opr.Type != OPRTP_MEM {
+				return false
+			}
+		case argMoffs:
+			if opr.Type != OPRTP_MEM || opr.Base != 0 || opr.Index != 0 {
+				return false
+			}
+		case argImm:
+			if opr.Type != OPRTP_IMM || opr.IsConst() && !fitsImm(opr.Value, size) {
+				return false
+			}
+		case argImm64:
+			if !opr.IsConst() {
+				return false
+			}
+		case argImm8:
+			if !opr.IsConst() || !fitsInt8(opr.Value) {
+				return false
+			}
+		case argImmB:
+			if !opr.IsConst() || !fitsSize(opr.Value, 1) {
+				return false
+			}
+		case argImmW:
+			if !opr.IsConst() || !fitsSize(opr.Value, 2) {
+				return false
+			}
+		case argRel, argRel8:
+			if opr.Type != OPRTP_IMM || !opr.Symbolic() {
+				return false
+			}
+		case argThree:
+			if !opr.IsConst() || opr.Value != 3 {
+				return false
+			}
+		case argOne:
+			if !opr.IsConst() || opr.Value != 1 {
+				return false
+			}
+		case argCL:
+			if opr.Type != OPRTP_REG || opr.Reg != BR_CL {
+				return false
+			}
+		case argRM8, argRM16, argRM32: // 宽度依次为 1, 2, 4 字节, 内存操作数需要指定宽度
+			s := opr.Size
+			if opr.Type == OPRTP_REG {
+				s = regSize(opr.Reg)
+			}
+			if opr.Type != OPRTP_REG && opr.Type != OPRTP_MEM || s != 1<<(arg-argRM8) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// addrSize 内存操作数的地址宽度, 由基址及变址寄存器决定
+func (e *encoder) addrSize(rm *operand) (int, error) {
+	size := 0
+	for _, reg := range []Token{rm.Base, rm.Index} {
+		if reg == 0 || reg == REG_RIP {
+			continue
+		}
+		if err := e.checkReg(reg); err != nil {
+			return 0, err
+		}
+		s := regSize(reg)
+		if s != 4 && s != 8 || s == 8 && e.bits != 64 {
+			return 0, fmt.Errorf("寄存器 %s 不能用于寻址", reg)
+		}
+		if size != 0 && size != s {
+			return 0, fmt.Errorf("基址与变址寄存器宽度不一致")
+		}
+		size = s
+	}
+	if rm.Base == REG_RIP && (e.bits != 64 || rm.Index != 0) {
+		return 0, fmt.Errorf("rip 相对寻址仅在 64 位模式下可用, 且不能使用变址寄存器")
+	}
+	return size, nil
+}
+
+// modrm 生成 ModRM 字节, 内存操作数同时生成 SIB 及偏移;
+// reg 仅使用低 3 位, 扩展位由调用者写入 REX 前缀
+func (e *encoder) modrm(reg byte, rm *operand) error {
+	reg &= 7
+	if rm.Type == OPRTP_REG {
+		e.byte(0xC0 | reg<<3 | regNum(rm.Reg)&7)
+		return nil
+	}
+
+	// 64 位模式下的偏移量为符号扩展的 32 位数
+	disp := func(size int) {
+		e.value(rm, size, false)
+		if rm.Symbolic() && e.bits == 64 {
+			e.fixups[len(e.fixups)-1].Signed = true
+		}
+	}
+
+	// rip 相对寻址 [rip + disp32]
+	if rm.Base == REG_RIP {
+		e.byte(0x05 | reg<<3)
+		e.value(rm, 4, rm.Symbolic())
+		return nil
+	}
+
+	// 仅偏移量 [disp32], 64 位模式下 mod=00 rm=101 表示 rip 相对寻址, 需要使用 SIB 形式
+	if rm.Base == 0 && rm.Index == 0 {
+		if e.bits == 64 {
+			e.byte(0x04|reg<<3, 0x25)
+		} else {
+			e.byte(0x05 | reg<<3)
+		}
+		disp(4)
+		return nil
+	}
+
+	// 偏移宽度: 0, 8 位, 32 位; ebp/r13 作为基址时无法省略偏移
+	mod, dispSize := byte(0x80), 4
+	if !rm.Symbolic() {
+		if rm.Value == 0 && (rm.Base == 0 || regNum(rm.Base)&7 != 5) {
+			mod, dispSize = 0x00, 0
+		} else if fitsInt8(rm.Value) {
+			mod, dispSize = 0x40, 1
+		}
+	}
+
+	if rm.Index == 0 && regNum(rm.Base)&7 != 4 { // esp/r12 作为基址必须使用 SIB
+		e.byte(mod | reg<<3 | regNum(rm.Base)&7)
+		disp(dispSize)
+		return nil
+	}
+
+	// SIB: scale | index | base
+	index := byte(4) // 100 表示无变址
+	if rm.Index != 0 {
+		if regNum(rm.Index) == 4 {
+			return fmt.Errorf("%s 不能作为变址寄存器", rm.Index)
+		}
+		index = regNum(rm.Index) & 7
+	}
+	var scale byte
+	switch rm.Scale {
+	case 0, 1:
+		scale = 0
+	case 2:
+		scale = 1
+	case 4:
+		scale = 2
+	case 8:
+		scale = 3
+	default:
+		return fmt.Errorf("无效的比例因子: %d", rm.Scale)
+	}
+
+	if rm.Base == 0 { // 无基址: mod=00, base=101, 固定 32 位偏移
+		e.byte(0x04|reg<<3, scale<<6|index<<3|0x05)
+		disp(4)
+		return nil
+	}
+	e.byte(mod|reg<<3|0x04, scale<<6|index<<3|regNum(rm.Base)&7)
+	disp(dispSize)
+	return nil
+}
+
+// rex 计算 REX 前缀, 0 表示不需要
+func (e *encoder) rex(form *opForm, size int, reg, rm *operand) (byte, error) {
+	var prefix byte
+	if size == 8 && (form.Width == szFull || form.Width == szQuad) && form.Flags&fDef64 == 0 {
+		prefix |= rexW
+	}
+	if reg != nil && regNum(reg.Reg) >= 8 {
+		prefix |= rexR
+	}
+	var high Token // ah/ch/dh/bh 无法与 REX 前缀同时使用
+	force := false // spl/bpl/sil/dil 必须使用 REX 前缀
+	for _, opr := range []*operand{reg, rm} {
+		if opr == nil || opr.Type != OPRTP_REG {
+			continue
+		}
+		if BR_AH <= opr.Reg && opr.Reg <= BR_BH {
+			high = opr.Reg
+		}
+		if BR_SPL <= opr.Reg && opr.Reg <= BR_DIL {
+			force = true
+		}
+	}
+	if rm != nil {
+		switch rm.Type {
+		case OPRTP_REG:
+			if regNum(rm.Reg) >= 8 {
+				prefix |= rexB
+			}
+		case OPRTP_MEM:
+			if rm.Base != 0 && rm.Base != REG_RIP && regNum(rm.Base) >= 8 {
+				prefix |= rexB
+			}
+			if rm.Index != 0 && regNum(rm.Index) >= 8 {
+				prefix |= rexX
+			}
+		}
+	}
+	if prefix == 0 && !force {
+		return 0, nil
+	}
+	if e.bits != 64 {
+		return 0, fmt.Errorf("64 位操作数或寄存器仅在 64 位模式下可用")
+	}
+	if high != 0 {
+		return 0, fmt.Errorf("寄存器 %s 不能与 REX 前缀同时使用", high)
+	}
+	return rex | prefix, nil
+}
+
+// encodeInstr 按照编码表生成机器指令
+func (e *encoder) encodeInstr(ins *instr) error {
+	forms, ok := optab[ins.Opcode]
+	if !ok {
+		return fmt.Errorf("不支持的指令: %s", ins.Opcode)
+	}
+	args := [3]*operand{ins.Dst, ins.Src, ins.Imm}
+	for _, opr := range args {
+		if opr != nil && opr.Type == OPRTP_REG {
+			if err := e.checkReg(opr.Reg); err != nil {
+				return err
+			}
+		}
+	}
+	size, err := e.operandSize(ins)
+	if err != nil {
+		return err
+	}
+
+	var form *opForm
+	for i := range forms {
+		if forms[i].Args[0] == argRel8 && !ins.Short {
+			continue
+		}
+		if forms[i].match(e.bits, size, args) {
+			form = &forms[i]
+			break
+		}
+	}
+	if form == nil {
+		return fmt.Errorf("%s: 无效的操作数组合", ins.Opcode)
+	}
+
+	// 确定 ModRM.reg 及 ModRM.rm 对应的操作数
+	var ext byte
+	var reg, rm *operand
+	switch form.Enc {
+	case encOpReg:
+		rm = args[0]
+	case encModRM:
+		switch {
+		case form.Ext >= 0: // /digit, r/m 为第一个非立即数操作数
+			ext, rm = byte(form.Ext), args[0]
+		case form.Args[0] == argReg && (form.Args[1] == argImm8 || form.Args[1] == argImm):
+			reg, rm = args[0], args[0] // imul r32, imm: 同一寄存器
+		case form.Args[0] == argReg, form.Args[0] == argXmm:
+			reg, rm = args[0], args[1]
+		default:
+			reg, rm = args[1], args[0]
+		}
+		if reg != nil {
+			ext = regNum(reg.Reg)
+		}
+	}
+
+	if err := checkPrefix(ins); err != nil {
+		return err
+	}
+
+	// 前缀顺序与 GAS 一致: 段超越, 地址宽度, 操作数宽度, lock/rep, 强制前缀, REX
+	start := len(e.code)
+	for _, opr := range args {
+		if opr != nil && opr.Type == OPRTP_MEM && opr.Seg != 0 {
+			e.byte(segPrefix[opr.Seg])
+		}
+	}
+	if rm != nil && rm.Type == OPRTP_MEM {
+		asize, err := e.addrSize(rm)
+		if err != nil {
+			return err
+		}
+		if e.bits == 64 && asize == 4 {
+			e.byte(0x67) // 地址宽度前缀
+		}
+	}
+	if size == 2 && form.Width == szFull {
+		e.byte(0x66) // 操作数宽度前缀
+	}
+	for _, pfx := range ins.Prefix {
+		e.byte(optab[pfx][0].Op...)
+	}
+	op := form.Op
+	if form.Flags&fPfx != 0 {
+		e.byte(op[0])
+		op = op[1:]
+	}
+	prefix, err := e.rex(form, size, reg, rm)
+	if err != nil {
+		return err
+	}
+	if prefix != 0 {
+		e.byte(prefix)
+	}
+
+	switch form.Enc {
+	case encOp:
+		e.byte(op...)
+	case encOpReg:
+		e.byte(op[:len(op)-1]...)
+		e.byte(op[len(op)-1] + regNum(args[0].Reg)&7)
+	case encModRM:
+		e.byte(op...)
+		if err := e.modrm(ext, rm); err != nil {
+			return err
+		}
+	}
+
+	// 立即数或跳转偏移
+	for i, arg := range form.Args {
+		n := immSize(arg, size)
+		if arg == argMoffs { // 偏移量宽度与地址宽度一致
+			n = e.bits / 8
+		}
+		if n == 0 || args[i] == nil {
+			continue
+		}
+		e.value(args[i], n, arg == argRel || arg == argRel8)
+		if args[i].Symbolic() {
+			f := &e.fixups[len(e.fixups)-1]
+			f.Branch = arg == argRel
+			f.Signed = size == 8 && n == 4 // 符号扩展为 64 位
+		}
+	}
+
+	// pc 相对寻址以指令结束位置为基准, 转换为以回填位置为基准
+	end, relax := len(e.code), form.Enc == encModRM && rm.Type == OPRTP_MEM && gotRelax(op, ext)
+	for i := range e.fixups {
+		if f := &e.fixups[i]; f.Offset >= start {
+			if f.PCRel {
+				f.Addend -= int64(end - f.Offset)
+			}
+			f.Relax, f.Rex = relax, prefix != 0
+		}
+	}
+	return nil
+}
+
+// segPrefix 段超越前缀
+var segPrefix = map[Token]byte{
+	SR_ES: 0x26, SR_CS: 0x2E, SR_SS: 0x36, SR_DS: 0x3E, SR_FS: 0x64, SR_GS: 0x65,
+}
+
+// checkPrefix 检查写在指令之前的前缀: lock 只能用于目标为内存的读-改-写指令,
+// rep/repe/repne 只能用于串操作指令及 ret(rep ret)
+func checkPrefix(ins *instr) error {
+	for _, pfx := range ins.Prefix {
+		switch pfx {
+		case I_LOCK:
+			if !lockable[ins.Opcode] || ins.Dst == nil || ins.Dst.Type != OPRTP_MEM {
+				return fmt.Errorf("lock 前缀不能用于 %s, 只能用于目标为内存的 add/sub/and/or/xor/inc/dec/neg", ins.Opcode)
+			}
+		default:
+			if stringSize[ins.Opcode] == 0 && ins.Opcode != I_RET || ins.Dst != nil {
+				return fmt.Errorf("%s 前缀只能用于串操作指令, 不能用于 %s", pfx, ins.Opcode)
+			}
+		}
+	}
+	return nil
+}
+
+// encodeData 数据定义 db/dw/dd/dq
+func (e *encoder) encodeData(ins *instr) error {
+	for _, v := range ins.Values {
+		switch v.Type {
+		case OPRTP_STR:
+			e.byte([]byte(v.Text)...)
+			for n := len(v.Text); n%ins.Size != 0; n++ {
+				e.byte(0) // 字符串补齐到数据宽度
+			}
+		case OPRTP_IMM:
+			if v.IsConst() && !fitsSize(v.Value, ins.Size) {
+				return fmt.Errorf("%s: 数值 %d 超出范围", ins.Opcode, v.Value)
+			}
+			e.value(v, ins.Size, false)
+		default:
+			return fmt.Errorf("%s: 无效的数据", ins.Opcode)
+		}
+	}
+	return nil
+}
+
+// encode 按 bits(32/64) 位模式编码指令, 返回机器码以及需要回填的符号引用(偏移相对于指令开始)
+func encode(ins *instr, bits int) ([]byte, []fixup, error) {
+	e := &encoder{bits: bits}
+	switch ins.Opcode {
+	case K_ALIGN:
+		e.align(ins)
+		return e.code, nil, nil
+	case K_SPACE:
+		e.fill(ins)
+		return e.code, nil, nil
+	case K_INSN:
+		code := ins.Insn
+		if ins.Short && code.Short != nil {
+			code = code.Short
+		}
+		e.code = append(e.code, code.Code...)
+		return e.code, code.Fixups, nil
+	}
+	times := ins.Times
+	if times <= 0 {
+		times = 1
+	}
+	for i := 0; i < times; i++ {
+		var err error
+		switch ins.Opcode {
+		case K_DB, K_DW, K_DD, K_DQ:
+			err = e.encodeData(ins)
+		default:
+			err = e.encodeInstr(ins)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return e.code, e.fixups, nil
+}
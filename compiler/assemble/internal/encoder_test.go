@@ -0,0 +1,361 @@
+package internal
+
+import (
+	"bytes"
+	"github.com/facelang/face/internal/os/elf"
+	"testing"
+)
+
+func TestEncodeInstr(t *testing.T) {
+	// 期望结果来自 GNU as --32 的输出
+	tests := []struct {
+		src  string
+		want []byte
+	}{
+		{"add eax, 1", []byte{0x83, 0xC0, 0x01}},
+		{"add eax, 1000", []byte{0x05, 0xE8, 0x03, 0x00, 0x00}},
+		{"add al, 1", []byte{0x04, 0x01}},
+		{"add eax, ebx", []byte{0x01, 0xD8}},
+		{"sub ecx, [ebx+4]", []byte{0x2B, 0x4B, 0x04}},
+		{"cmp byte [eax], 0", []byte{0x80, 0x38, 0x00}},
+		{"mov eax, ebx", []byte{0x89, 0xD8}},
+		{"mov eax, 10", []byte{0xB8, 0x0A, 0x00, 0x00, 0x00}},
+		{"mov al, 5", []byte{0xB0, 0x05}},
+		{"mov dword [esp+8], eax", []byte{0x89, 0x44, 0x24, 0x08}},
+		{"mov word [eax], 1", []byte{0x66, 0xC7, 0x00, 0x01, 0x00}},
+		{"mov ecx, [ebp]", []byte{0x8B, 0x4D, 0x00}},
+		{"lea eax, [ebx*2]", []byte{0x8D, 0x04, 0x5D, 0x00, 0x00, 0x00, 0x00}},
+		{"lea esi, [eax+ecx*4+16]", []byte{0x8D, 0x74, 0x88, 0x10}},
+		{"imul eax, 10", []byte{0x6B, 0xC0, 0x0A}},
+		{"imul eax, ebx", []byte{0x0F, 0xAF, 0xC3}},
+		{"imul eax, 1000", []byte{0x69, 0xC0, 0xE8, 0x03, 0x00, 0x00}},
+		{"idiv ecx", []byte{0xF7, 0xF9}},
+		{"neg edx", []byte{0xF7, 0xDA}},
+		{"inc eax", []byte{0x40}},
+		{"dec byte [ebx]", []byte{0xFE, 0x0B}},
+		{"push 1", []byte{0x6A, 0x01}},
+		{"push 1000", []byte{0x68, 0xE8, 0x03, 0x00, 0x00}},
+		{"push ebp", []byte{0x55}},
+		{"pop edi", []byte{0x5F}},
+		{"int 3", []byte{0xCC}},
+		{"int 0x80", []byte{0xCD, 0x80}},
+		{"ret", []byte{0xC3}},
+		{"ret 8", []byte{0xC2, 0x08, 0x00}},
+		{"rep movsd", []byte{0xF3, 0xA5}},
+		{"rep movsw", []byte{0x66, 0xF3, 0xA5}},
+		{"repne scasb", []byte{0xF2, 0xAE}},
+		{"lock add dword [ebx], 2", []byte{0xF0, 0x83, 0x03, 0x02}},
+		{"mov eax, gs:[0x14]", []byte{0x65, 0xA1, 0x14, 0x00, 0x00, 0x00}},
+		{"mov eax, [fs:ecx]", []byte{0x64, 0x8B, 0x01}},
+		{"movsd xmm0, qword [eax]", []byte{0xF2, 0x0F, 0x10, 0x00}},
+		{"movsd [esp+8], xmm7", []byte{0xF2, 0x0F, 0x11, 0x7C, 0x24, 0x08}},
+		{"addsd xmm1, xmm2", []byte{0xF2, 0x0F, 0x58, 0xCA}},
+		{"cvtsi2sd xmm0, eax", []byte{0xF2, 0x0F, 0x2A, 0xC0}},
+		{"cvttsd2si eax, xmm0", []byte{0xF2, 0x0F, 0x2C, 0xC0}},
+		{"movd xmm0, eax", []byte{0x66, 0x0F, 0x6E, 0xC0}},
+		{"movq xmm0, [eax]", []byte{0xF3, 0x0F, 0x7E, 0x00}},
+		{"pxor xmm1, xmm1", []byte{0x66, 0x0F, 0xEF, 0xC9}},
+	}
+
+	for _, tt := range tests {
+		p := assemble(t, "386", SyntaxIntel, tt.src)
+		if got := p.secList[0].Data; !bytes.Equal(got, tt.want) {
+			t.Errorf("%q: got % X, want % X", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeLabel(t *testing.T) {
+	src := `
+section .data
+msg db "hi", 10
+len equ 3
+
+section .text
+global _start
+_start:
+	mov ecx, msg
+	mov edx, len
+	call done
+	jmp _start
+done:
+	ret
+`
+	p := assemble(t, "386", SyntaxIntel, src)
+	want := []byte{
+		0xB9, 0x00, 0x00, 0x00, 0x00, // mov ecx, msg(.data 重定位)
+		0xBA, 0x03, 0x00, 0x00, 0x00, // mov edx, len
+		0xE8, 0x02, 0x00, 0x00, 0x00, // call done(段内直接计算)
+		0xEB, 0xEF, // jmp _start(同一段内的全局符号, 与 GAS 一致优化为 rel8, 不生成重定位)
+		0xC3,
+	}
+	text := p.secList[0]
+	if text.Name != ".text" || !bytes.Equal(text.Data, want) {
+		t.Fatalf("%s: got % X, want % X", text.Name, text.Data, want)
+	}
+
+	rels := []relocate{
+		{Label: ".data", Type: R_386_32, Offset: 1, Section: ".text"},
+	}
+	if len(p.relocateList) != len(rels) {
+		t.Fatalf("重定位数量: got %d, want %d", len(p.relocateList), len(rels))
+	}
+	for i, rel := range p.relocateList {
+		if *rel != rels[i] {
+			t.Errorf("重定位 %d: got %+v, want %+v", i, *rel, rels[i])
+		}
+	}
+}
+
+func TestEncodeMoffs(t *testing.T) {
+	// mov 的 moffs 形式(A0-A3)之后是与地址宽度一致的偏移量, 期望结果来自 GNU as --32 的输出
+	tests := []struct {
+		src  string
+		want []byte
+		rel  int // 重定位位置, 0 表示没有重定位
+	}{
+		{"mov eax, [0x1234]", []byte{0xA1, 0x34, 0x12, 0x00, 0x00}, 0},
+		{"mov al, [0x1234]", []byte{0xA0, 0x34, 0x12, 0x00, 0x00}, 0},
+		{"mov [8], al", []byte{0xA2, 0x08, 0x00, 0x00, 0x00}, 0},
+		{"mov [8], ax", []byte{0x66, 0xA3, 0x08, 0x00, 0x00, 0x00}, 0},
+		{"mov eax, [x]\nx dd 0", []byte{0xA1, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, 1},
+	}
+	for _, tt := range tests {
+		p := assemble(t, "386", SyntaxIntel, tt.src)
+		if got := p.secList[0].Data; !bytes.Equal(got, tt.want) {
+			t.Errorf("%q: got % X, want % X", tt.src, got, tt.want)
+		}
+		switch {
+		case tt.rel == 0 && len(p.relocateList) != 0:
+			t.Errorf("%q: 不应有重定位 %+v", tt.src, p.relocateList)
+		case tt.rel != 0 && (len(p.relocateList) != 1 || p.relocateList[0].Offset != tt.rel || p.relocateList[0].Type != R_386_32):
+			t.Errorf("%q: 重定位 got %+v, want R_386_32 at %d", tt.src, p.relocateList, tt.rel)
+		}
+	}
+}
+
+func TestEncodeInstr64(t *testing.T) {
+	// 期望结果来自 GNU as --64 的输出
+	tests := []struct {
+		src  string
+		want []byte
+	}{
+		{"mov rax, 10", []byte{0x48, 0xC7, 0xC0, 0x0A, 0x00, 0x00, 0x00}},
+		{"mov rax, 0x123456789", []byte{0x48, 0xB8, 0x89, 0x67, 0x45, 0x23, 0x01, 0x00, 0x00, 0x00}},
+		{"mov rax, -1", []byte{0x48, 0xC7, 0xC0, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{"mov eax, [0x10]", []byte{0x8B, 0x04, 0x25, 0x10, 0x00, 0x00, 0x00}},
+		{"mov rsi, [rip+8]", []byte{0x48, 0x8B, 0x35, 0x08, 0x00, 0x00, 0x00}},
+		{"mov r8, r9", []byte{0x4D, 0x89, 0xC8}},
+		{"mov r12d, [r13]", []byte{0x45, 0x8B, 0x65, 0x00}},
+		{"mov rcx, [rsp+8]", []byte{0x48, 0x8B, 0x4C, 0x24, 0x08}},
+		{"mov al, sil", []byte{0x40, 0x88, 0xF0}},
+		{"mov r10b, 1", []byte{0x41, 0xB2, 0x01}},
+		{"mov ax, r9w", []byte{0x66, 0x44, 0x89, 0xC8}},
+		{"add rax, 1", []byte{0x48, 0x83, 0xC0, 0x01}},
+		{"add rax, 1000", []byte{0x48, 0x05, 0xE8, 0x03, 0x00, 0x00}},
+		{"push rbp", []byte{0x55}},
+		{"push r12", []byte{0x41, 0x54}},
+		{"pop r15", []byte{0x41, 0x5F}},
+		{"inc rax", []byte{0x48, 0xFF, 0xC0}},
+		{"inc eax", []byte{0xFF, 0xC0}},
+		{"dec r8d", []byte{0x41, 0xFF, 0xC8}},
+		{"call rax", []byte{0xFF, 0xD0}},
+		{"push 1", []byte{0x6A, 0x01}},
+		{"lea rax, [rbx+r12*8+16]", []byte{0x4A, 0x8D, 0x44, 0xE3, 0x10}},
+		{"lea eax, [ebx+4]", []byte{0x67, 0x8D, 0x43, 0x04}},
+		{"imul r9, 100", []byte{0x4D, 0x6B, 0xC9, 0x64}},
+		{"dq -2", []byte{0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{"lock add word fs:[eax], 1", []byte{0x64, 0x67, 0x66, 0xF0, 0x83, 0x00, 0x01}},
+		{"mov rax, fs:[0]", []byte{0x64, 0x48, 0x8B, 0x04, 0x25, 0x00, 0x00, 0x00, 0x00}},
+		{"mov rcx, qword ptr gs:[rbx+8]", []byte{0x65, 0x48, 0x8B, 0x4B, 0x08}},
+		{"rep stosq", []byte{0xF3, 0x48, 0xAB}},
+		{"lock\ninc dword [rax]", []byte{0xF0, 0xFF, 0x00}},
+		{"movsd xmm8, xmm1", []byte{0xF2, 0x44, 0x0F, 0x10, 0xC1}},
+		{"cvtsi2sd xmm0, rax", []byte{0xF2, 0x48, 0x0F, 0x2A, 0xC0}},
+		{"cvtsi2ss xmm1, qword [rbx]", []byte{0xF3, 0x48, 0x0F, 0x2A, 0x0B}},
+		{"cvttsd2si r10, qword [rax]", []byte{0xF2, 0x4C, 0x0F, 0x2C, 0x10}},
+		{"movq rax, xmm0", []byte{0x66, 0x48, 0x0F, 0x7E, 0xC0}},
+		{"movq [rax], xmm1", []byte{0x66, 0x0F, 0xD6, 0x08}},
+		{"movd eax, xmm3", []byte{0x66, 0x0F, 0x7E, 0xD8}},
+		{"xorps xmm0, xmm0", []byte{0x0F, 0x57, 0xC0}},
+		{"ucomisd xmm9, [rip+8]", []byte{0x66, 0x44, 0x0F, 0x2E, 0x0D, 0x08, 0x00, 0x00, 0x00}},
+		{"syscall", []byte{0x0F, 0x05}},
+		{"nop", []byte{0x90}},
+		{"leave", []byte{0xC9}},
+		{"shl eax, 1", []byte{0xD1, 0xE0}},
+		{"shl rax, 3", []byte{0x48, 0xC1, 0xE0, 0x03}},
+		{"shr edx, cl", []byte{0xD3, 0xEA}},
+		{"sar r9, cl", []byte{0x49, 0xD3, 0xF9}},
+		{"sal r12d, 5", []byte{0x41, 0xC1, 0xE4, 0x05}},
+		{"shr word [rbx+8], 4", []byte{0x66, 0xC1, 0x6B, 0x08, 0x04}},
+		{"sete al", []byte{0x0F, 0x94, 0xC0}},
+		{"setnz sil", []byte{0x40, 0x0F, 0x95, 0xC6}},
+		{"setg byte [rdi]", []byte{0x0F, 0x9F, 0x07}},
+		{"cmovl eax, ecx", []byte{0x0F, 0x4C, 0xC1}},
+		{"cmovge r8, qword [rsi]", []byte{0x4C, 0x0F, 0x4D, 0x06}},
+		{"movzx eax, al", []byte{0x0F, 0xB6, 0xC0}},
+		{"movzx ecx, byte [rdi]", []byte{0x0F, 0xB6, 0x0F}},
+		{"movzx rax, word [rsi+2]", []byte{0x48, 0x0F, 0xB7, 0x46, 0x02}},
+		{"movsx r11, cl", []byte{0x4C, 0x0F, 0xBE, 0xD9}},
+		{"movsx eax, word [rax]", []byte{0x0F, 0xBF, 0x00}},
+		{"movsxd rdx, ecx", []byte{0x48, 0x63, 0xD1}},
+		{"movsxd rax, dword [rbp+4]", []byte{0x48, 0x63, 0x45, 0x04}},
+		{"cqo", []byte{0x48, 0x99}},
+		{"cdqe", []byte{0x48, 0x98}},
+		{"cdq", []byte{0x99}},
+	}
+
+	for _, tt := range tests {
+		p := assemble(t, "amd64", SyntaxIntel, tt.src)
+		if got := p.secList[0].Data; !bytes.Equal(got, tt.want) {
+			t.Errorf("%q: got % X, want % X", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeReloc64(t *testing.T) {
+	src := `
+section .data
+msg db "hi", 10
+
+section .text
+global _start
+extern foo
+_start:
+	mov rax, msg
+	mov eax, [msg]
+	lea rsi, [rip+msg]
+	mov dword [rip+msg], 5
+	call _start
+	jmp foo
+	dq msg
+`
+	p := assemble(t, "amd64", SyntaxIntel, src)
+	rels := []relocate{
+		{Label: ".data", Type: int(elf.R_X86_64_32S), Offset: 3, Section: ".text"},
+		{Label: ".data", Type: int(elf.R_X86_64_32S), Offset: 10, Section: ".text"},
+		{Label: ".data", Type: int(elf.R_X86_64_PC32), Offset: 17, Section: ".text", Addend: -4},
+		{Label: ".data", Type: int(elf.R_X86_64_PC32), Offset: 23, Section: ".text", Addend: -8},
+		{Label: "_start", Type: int(elf.R_X86_64_PLT32), Offset: 32, Section: ".text", Addend: -4},
+		{Label: "foo", Type: int(elf.R_X86_64_PLT32), Offset: 37, Section: ".text", Addend: -4},
+		{Label: ".data", Type: int(elf.R_X86_64_64), Offset: 41, Section: ".text"},
+	}
+	if len(p.relocateList) != len(rels) {
+		t.Fatalf("重定位数量: got %d, want %d", len(p.relocateList), len(rels))
+	}
+	for i, rel := range p.relocateList {
+		if *rel != rels[i] {
+			t.Errorf("重定位 %d: got %+v, want %+v", i, *rel, rels[i])
+		}
+	}
+}
+
+func TestEncodeModeError(t *testing.T) {
+	tests := []struct {
+		src  string
+		bits int
+	}{
+		{"mov rax, 1", 32},
+		{"mov r8d, eax", 32},
+		{"mov eax, [rip+8]", 32},
+		{"mov ah, sil", 64},
+		{"push eax", 64},
+		{"mov eax, [rax+ebx]", 64},
+		{"movsd xmm8, xmm0", 32},
+		{"cvtsi2sd xmm0, rax", 32},
+		{"movsq", 32},
+		{"rep add eax, 1", 64},
+		{"lock add eax, 1", 64},
+		{"lock mov [rax], eax", 64},
+		{"movq xmm0, eax", 64},
+		{"cvtsi2sd xmm0, ax", 64},
+		{"mov eax, [xmm0]", 64},
+		{"add eax, xmm0", 64},
+		{"rep movsd xmm0, xmm1", 64},
+		{"cqo", 32},
+		{"movsxd rax, ecx", 32},
+		{"sete eax", 64},
+		{"shl eax, ebx", 64},
+		{"movzx eax, [rbx]", 64},
+		{"movzx eax, eax", 64},
+		{"cmovl al, bl", 64},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(NewBytesLexer([]byte(tt.src)))
+		p.bits = tt.bits
+		if err := p.ParseFile(); err == nil {
+			t.Errorf("%q(%d 位): 期望编码错误", tt.src, tt.bits)
+		}
+	}
+}
+
+func TestEncodePIC(t *testing.T) {
+	src := `
+section .text
+extern foo, memcpy@GLIBC_2.0, _GLOBAL_OFFSET_TABLE_
+_start:
+	call foo
+	call foo@PLT
+	jmp _start
+	mov eax, [ebx+foo@GOT]
+	lea eax, [ebx+_start@GOTOFF]
+	call memcpy@GLIBC_2.0
+	add ebx, _GLOBAL_OFFSET_TABLE_
+`
+	p := NewParser(NewBytesLexer([]byte(src)))
+	p.pic = true
+	if err := p.ParseFile(); err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if err := p.Codegen(); err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	rels := []relocate{
+		{Label: "foo", Type: int(elf.R_386_PLT32), Offset: 1, Section: ".text"},
+		{Label: "foo", Type: int(elf.R_386_PLT32), Offset: 6, Section: ".text"},
+		{Label: "foo", Type: int(elf.R_386_GOT32X), Offset: 14, Section: ".text"},
+		{Label: ".text", Type: int(elf.R_386_GOTOFF), Offset: 20, Section: ".text"},
+		{Label: "memcpy@GLIBC_2.0", Type: int(elf.R_386_PLT32), Offset: 25, Section: ".text"},
+		{Label: "_GLOBAL_OFFSET_TABLE_", Type: int(elf.R_386_GOTPC), Offset: 31, Section: ".text"},
+	}
+	if len(p.relocateList) != len(rels) {
+		t.Fatalf("重定位数量: got %d, want %d", len(p.relocateList), len(rels))
+	}
+	for i, rel := range p.relocateList {
+		if *rel != rels[i] {
+			t.Errorf("重定位 %d: got %+v, want %+v", i, *rel, rels[i])
+		}
+	}
+	if got := p.sec.Data[31]; got != 2 { // GOT 地址的加数为回填位置相对指令开始的偏移
+		t.Errorf("_GLOBAL_OFFSET_TABLE_ 的加数: got %d, want 2", got)
+	}
+}
+
+func TestEncodePICError(t *testing.T) {
+	tests := []struct {
+		src  string
+		bits int
+	}{
+		{"mov eax, foo", 32},
+		{"mov eax, [foo]", 32},
+		{"mov rax, foo", 64},
+		{"lea rax, [foo]", 64},
+		{"section .data\ndd foo", 64},
+		{"call foo@GOTOFF", 64},
+		{"x equ 1\nmov eax, x@GOT", 32},
+		{"mov eax, foo@GOT - bar", 32},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(NewBytesLexer([]byte("extern foo, bar\n" + tt.src)))
+		p.bits, p.pic = tt.bits, true
+		err := p.ParseFile()
+		if err == nil {
+			err = p.Codegen()
+		}
+		if err == nil {
+			t.Errorf("%q(%d 位): 期望位置无关代码错误", tt.src, tt.bits)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/facelang/face/internal/prog"
+)
+
+// Express 常量表达式语法树, 用于 equ、数据定义、times 重复次数、立即数及内存偏移:
+//
+//	运算符优先级(从低到高, 与 C 一致): ||  &&  |  ^  &  == !=  < > <= >=  << >>  + -  * / %  一元运算(- + ~)
+//	比较运算的结果为 1 或 0, AT&T 语法与 GAS 一致, 结果为 -1 或 0
+//	操作数: 整数, 符号, 当前位置($ 或 .), 段起始位置($$), 括号
+type Express interface {
+	express()
+}
+
+// NumExpr 整数常量
+type NumExpr struct {
+	Value int64
+}
+
+// SymExpr 符号引用
+type SymExpr struct {
+	Name  string
+	Scope string // 引用所在的函数, 优先查找该函数内定义的标签
+	Mod   x86Mod // x86 的重定位修饰, 例如 foo@PLT
+}
+
+// DotExpr 当前位置($ .), 或段起始位置($$), 记录为不在符号表中的局部符号
+type DotExpr struct {
+	Sym *label
+}
+
+// RegExpr 寄存器, 仅出现在内存引用中
+type RegExpr struct {
+	Reg Token
+}
+
+// UnaryExpr 一元运算: - + ~
+type UnaryExpr struct {
+	Op Token
+	X  Express
+}
+
+// BinaryExpr 二元运算
+type BinaryExpr struct {
+	Op   Token
+	X, Y Express
+}
+
+func (*NumExpr) express()    {}
+func (*SymExpr) express()    {}
+func (*DotExpr) express()    {}
+func (*RegExpr) express()    {}
+func (*UnaryExpr) express()  {}
+func (*BinaryExpr) express() {}
+
+// exprValue 表达式的值: Sym 为 nil 时表示常量 Value, 否则为 符号地址 + Value;
+// Sub 不为 nil 时为 Sym - Sub + Value, 两个符号不在同一个段中, 回填时转换为 pc 相对的重定位
+type exprValue struct {
+	Sym   *label
+	Sub   *label
+	Value int64
+	Mod   x86Mod // 符号的重定位修饰
+}
+
+// errForward 第一遍扫描时引用了尚未定义的符号, 需要在第二遍扫描时计算
+var errForward = errors.New("引用了尚未定义的符号")
+
+// expr 解析表达式
+func (p *parser) expr() Express {
+	return p.binaryExpr(1, false)
+}
+
+// binaryExpr 按优先级解析二元运算, mem 表示内存引用中允许出现寄存器
+func (p *parser) binaryExpr(prec int, mem bool) Express {
+	x := p.unaryExpr(mem)
+	for p.token.Precedence() >= prec {
+		op := p.token
+		p.next()
+		x = &BinaryExpr{Op: op, X: x, Y: p.binaryExpr(op.Precedence()+1, mem)}
+	}
+	return x
+}
+
+// unaryExpr 解析一元运算及基本操作数
+func (p *parser) unaryExpr(mem bool) Express {
+	switch p.token {
+	case SUB, ADD, NOT:
+		op := p.token
+		p.next()
+		return &UnaryExpr{Op: op, X: p.unaryExpr(mem)}
+	case INT:
+		x := &NumExpr{Value: p.intLit()}
+		p.next()
+		return x
+	case IDENT:
+		name, mod := p.id, modNone
+		if p.x86() { // foo@PLT, foo@GOTPCREL
+			name, mod = splitMod(name)
+		}
+		sym := p.symRef(name)
+		sym.Mod = mod
+		var x Express = sym
+		if p.id == "." { // AT&T 当前位置
+			x = p.dot()
+		} else if lb := p.setLabel(name); lb != nil && lb.Expr != nil && mod == modNone {
+			x = lb.Expr // 与 GAS 一致, 引用 .set 常量当时的表达式, 之后重新赋值不影响此处
+		}
+		p.next()
+		return x
+	case DOLLAR: // $ 当前位置, $$ 段起始位置
+		p.next()
+		if p.got(DOLLAR) {
+			return &DotExpr{Sym: p.secLabel(p.sec)}
+		}
+		return p.dot()
+	case LPAREN:
+		p.next()
+		x := p.binaryExpr(1, mem)
+		p.expect(RPAREN)
+		return x
+	}
+	if mem && (p.token.IsRegister() || p.token == REG_RIP) {
+		x := &RegExpr{Reg: p.token}
+		p.next()
+		return x
+	}
+	p.unexpect("expression")
+	return nil
+}
+
+// dot 当前位置, 与标签一样记录所在的指令序号, 分支优化后重新计算地址
+func (p *parser) dot() *DotExpr {
+	lb := &label{Name: ".", Type: LOCAL_LABEL, Addr: p.sec.Offset, Section: p.sec.Name, Instr: len(p.instrList)}
+	p.dots = append(p.dots, lb)
+	return &DotExpr{Sym: lb}
+}
+
+// secLabel 段起始位置对应的符号, 不加入符号表, 引用时按段重定位
+func (p *parser) secLabel(sec *section) *label {
+	if sec.Sym == nil {
+		sec.Sym = &label{Name: sec.Name, Type: LOCAL_LABEL, Section: sec.Name}
+	}
+	return sec.Sym
+}
+
+// eval 计算表达式; final 为 false 时(第一遍扫描)引用尚未定义的符号返回 errForward,
+// 为 true 时(第二遍扫描)未定义的符号作为外部符号
+func (p *parser) eval(x Express, final bool) (exprValue, error) {
+	switch x := x.(type) {
+	case *NumExpr:
+		return exprValue{Value: x.Value}, nil
+	case *DotExpr:
+		return exprValue{Sym: x.Sym}, nil
+	case *RegExpr:
+		return exprValue{}, fmt.Errorf("寄存器 %s 不能用于表达式", x.Reg)
+	case *SymExpr:
+		lb, err := p.lookup(x, final)
+		if err != nil {
+			return exprValue{}, err
+		}
+		v, err := p.evalSym(lb, final)
+		if err == nil && x.Mod != modNone {
+			if v.Sym == nil || v.Mod != modNone {
+				return v, fmt.Errorf("重定位修饰 %s 只能用于符号, %s 不是符号", x.Mod, x.Name)
+			}
+			v.Mod = x.Mod
+		}
+		return v, err
+	case *UnaryExpr:
+		v, err := p.eval(x.X, final)
+		if err != nil {
+			return v, err
+		}
+		if x.Op == ADD {
+			return v, nil
+		}
+		if v.Sym != nil {
+			return v, fmt.Errorf("运算符 %s 不能用于符号 %s", x.Op, v.Sym.Name)
+		}
+		if x.Op == SUB {
+			return exprValue{Value: -v.Value}, nil
+		}
+		return exprValue{Value: ^v.Value}, nil
+	case *BinaryExpr:
+		l, err := p.eval(x.X, final)
+		if err != nil {
+			return l, err
+		}
+		r, err := p.eval(x.Y, final)
+		if err != nil {
+			return r, err
+		}
+		if x.Op == SUB && l.Sym != nil && r.Sym != nil && !final {
+			p.pin(l.Sym, r.Sym) // 第一遍扫描计算的差值不能因分支优化而改变
+		}
+		v, err := binaryValue(x.Op, l, r)
+		if err == nil && x.Op == SUB && v.Sym == nil && l.Sym != nil && p.dataDiffs && p.rvRelaxed(l.Sym.Section, l.Sym.Addr, r.Sym.Addr) {
+			v = exprValue{Sym: l.Sym, Sub: r.Sym, Value: l.Value - r.Value} // 链接器松弛会改变差值
+		}
+		if err == nil && x.Op.IsComparison() && v.Value != 0 && p.syntax == SyntaxATT {
+			v.Value = -1 // GAS 比较运算的结果
+		}
+		return v, err
+	}
+	return exprValue{}, fmt.Errorf("无效的表达式")
+}
+
+// evalSym 计算符号的值: equ 常量为其表达式的值, 已定义的符号为 符号地址 + 0
+func (p *parser) evalSym(lb *label, final bool) (exprValue, error) {
+	switch lb.Type {
+	case EQU_LABEL:
+		if lb.Expr == nil {
+			return exprValue{Value: int64(lb.Addr)}, nil
+		}
+		if lb.resolving {
+			return exprValue{}, fmt.Errorf("常量 %s 循环引用", lb.Name)
+		}
+		lb.resolving = true
+		v, err := p.eval(lb.Expr, final)
+		lb.resolving = false
+		if err == nil && v.Sym == nil { // 计算完成后记录为普通常量
+			lb.Expr, lb.Addr = nil, int(v.Value)
+		}
+		return v, err
+	case TEXT_LABEL, LOCAL_LABEL:
+		return exprValue{Sym: lb}, nil
+	}
+	if !final {
+		return exprValue{}, errForward
+	}
+	return exprValue{Sym: lb}, nil // 外部符号
+}
+
+// binaryValue 二元运算: 符号只能与常量相加减, 同一段内的两个符号可以相减得到常量;
+// 被减的符号定义在本文件中时, 与其它段的符号或外部符号的差值保留到回填时生成重定位
+func binaryValue(op Token, l, r exprValue) (exprValue, error) {
+	switch {
+	case op == ADD && l.Sym != nil && r.Sym != nil:
+		return l, fmt.Errorf("符号 %s 与 %s 不能相加", l.Sym.Name, r.Sym.Name)
+	case op == ADD && r.Sym != nil:
+		r.Value += l.Value
+		return r, nil
+	case op == ADD:
+		l.Value += r.Value
+		return l, nil
+	case op == SUB && r.Sym != nil:
+		if l.Mod != modNone || r.Mod != modNone {
+			return l, fmt.Errorf("带重定位修饰的符号不能相减")
+		}
+		if l.Sub != nil || r.Sub != nil {
+			return l, fmt.Errorf("不同段的符号差值不能再与符号相减")
+		}
+		if l.Sym == nil || !r.Sym.defined() {
+			return l, fmt.Errorf("符号 %s 不在当前文件的同一个段中, 无法计算差值", labelName(r.Sym.Name))
+		}
+		if l.Sym.defined() && l.Sym.Section == r.Sym.Section {
+			return exprValue{Value: int64(l.Sym.Addr-r.Sym.Addr) + l.Value - r.Value}, nil
+		}
+		return exprValue{Sym: l.Sym, Sub: r.Sym, Value: l.Value - r.Value}, nil
+	case op == SUB:
+		l.Value -= r.Value
+		return l, nil
+	case l.Sym != nil || r.Sym != nil:
+		sym := l.Sym
+		if sym == nil {
+			sym = r.Sym
+		}
+		return l, fmt.Errorf("运算符 %s 不能用于符号 %s", op, sym.Name)
+	}
+
+	a, b := l.Value, r.Value
+	switch op {
+	case MUL:
+		return exprValue{Value: a * b}, nil
+	case DIV, REM:
+		if b == 0 {
+			return l, fmt.Errorf("除数为 0")
+		}
+		if op == DIV {
+			return exprValue{Value: a / b}, nil
+		}
+		return exprValue{Value: a % b}, nil
+	case SHL:
+		return exprValue{Value: a << uint64(b)}, nil
+	case SHR:
+		return exprValue{Value: a >> uint64(b)}, nil
+	case AND:
+		return exprValue{Value: a & b}, nil
+	case OR:
+		return exprValue{Value: a | b}, nil
+	case XOR:
+		return exprValue{Value: a ^ b}, nil
+	case EQL:
+		return boolValue(a == b), nil
+	case NEQ:
+		return boolValue(a != b), nil
+	case LSS:
+		return boolValue(a < b), nil
+	case GTR:
+		return boolValue(a > b), nil
+	case LEQ:
+		return boolValue(a <= b), nil
+	case GEQ:
+		return boolValue(a >= b), nil
+	case LAND:
+		return boolValue(a != 0 && b != 0), nil
+	case LOR:
+		return boolValue(a != 0 || b != 0), nil
+	}
+	return l, fmt.Errorf("无效的运算符 %s", op)
+}
+
+// boolValue 比较及逻辑运算的结果
+func boolValue(b bool) exprValue {
+	if b {
+		return exprValue{Value: 1}
+	}
+	return exprValue{}
+}
+
+// constExpr 解析并计算常量表达式, 不能引用尚未定义的符号
+func (p *parser) constExpr() int64 {
+	pos := p.pos
+	v, err := p.eval(p.expr(), false)
+	if err == errForward {
+		p.errorAt(pos, "需要常量, 表达式引用了尚未定义的符号")
+	}
+	if err != nil {
+		p.errorAt(pos, err.Error())
+	}
+	if v.Sym != nil {
+		p.errorAt(pos, fmt.Sprintf("需要常量, 但符号 %s 不是常量", v.Sym.Name))
+	}
+	return v.Value
+}
+
+// setExpr 计算操作数的值: 常量及 符号 + 偏移 在第一遍扫描时直接记录,
+// 引用尚未定义的符号或当前位置的表达式保留到第二遍扫描时计算
+func (p *parser) setExpr(opr *operand, x Express) {
+	v, err := p.eval(x, false)
+	switch {
+	case err == errForward:
+		opr.Expr = x
+	case err != nil:
+		p.errorf("%s", err)
+	case v.Sym == nil:
+		opr.Value = v.Value
+	case v.Mod == modNone && v.Sub == nil && p.inTable(v.Sym):
+		opr.Label, opr.Value = v.Sym.Name, v.Value
+	default: // 当前位置等不在符号表中的符号, 带重定位修饰的符号
+		opr.Expr = x
+	}
+}
+
+// inTable 符号是否在符号表中, 段起始位置等内部符号不在符号表中
+func (p *parser) inTable(lb *label) bool {
+	i, ok := p.labelNames[lb.Name]
+	return ok && p.labelList[i] == lb
+}
+
+// equ 定义常量: 可以在第一遍扫描时计算的直接记录值, 否则在引用时计算.
+// redef 为 true 时(GAS 的 .equ/.set)可以重新赋值, 否则(NASM 的 equ 及 .equiv)重复定义时报错
+func (p *parser) equ(name string, x Express, pos prog.FilePos, redef bool) {
+	v, err := p.eval(x, false)
+	lb := &label{Type: EQU_LABEL, Expr: x, Redef: redef}
+	switch {
+	case err == nil && v.Sym == nil:
+		lb.Expr, lb.Addr = nil, int(v.Value)
+	case err != nil && err != errForward:
+		p.errorf("%s", err)
+	}
+	if old := p.setLabel(name); old != nil && redef {
+		old.Expr, old.Addr = lb.Expr, lb.Addr
+		return
+	}
+	p.AddLabel(name, lb, pos)
+}
+
+// setLabel 可以重新赋值的常量(.set/.equ)
+func (p *parser) setLabel(name string) *label {
+	if i, ok := p.labelNames[name]; ok && p.labelList[i].Type == EQU_LABEL && p.labelList[i].Redef {
+		return p.labelList[i]
+	}
+	return nil
+}
+
+// exprString 表达式的源码形式, 用于错误信息
+func exprString(x Express) string {
+	switch x := x.(type) {
+	case *NumExpr:
+		return fmt.Sprint(x.Value)
+	case *SymExpr:
+		return x.Name + x.Mod.String()
+	case *DotExpr:
+		return "$"
+	case *RegExpr:
+		return x.Reg.String()
+	case *UnaryExpr:
+		return x.Op.String() + exprString(x.X)
+	case *BinaryExpr:
+		return "(" + exprString(x.X) + " " + x.Op.String() + " " + exprString(x.Y) + ")"
+	}
+	return "?"
+}
@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"bytes"
+	"github.com/facelang/face/internal/arch"
+	"strings"
+	"testing"
+)
+
+func TestExprEqu(t *testing.T) {
+	src := `
+a equ 2*(3+4)
+b equ a<<2 | 1
+c equ ~0 & 0xff
+d equ 17 % 5 - 10 / 3
+e equ -8 >> 1
+f equ 1 + 2 * 3 ^ 4
+g equ h + 1
+h equ 0x10
+`
+	p := assemble(t, "386", SyntaxIntel, src)
+	want := map[string]int64{"a": 14, "b": 57, "c": 255, "d": -1, "e": -4, "f": 3, "g": 17, "h": 16}
+	for name, val := range want {
+		v, err := p.evalSym(p.GetLabel(name), true)
+		if err != nil || v.Sym != nil || v.Value != val {
+			t.Errorf("%s: got %+v(%v), want %d", name, v, err, val)
+		}
+	}
+}
+
+func TestExprData(t *testing.T) {
+	src := `
+section .data
+msg db "hello"
+len equ $ - msg
+	dd end - start, len * 2
+	times 16-($-$$) db 0x90
+
+section .text
+start:
+	mov eax, end - start
+	mov ecx, [ebx + 2*4 + len]
+	mov edx, [esi + (fwd - 1) * 4]
+	push size
+	jmp $
+end:
+fwd equ 3
+size equ end - start
+`
+	p := assemble(t, "386", SyntaxIntel, src)
+	data := []byte{
+		'h', 'e', 'l', 'l', 'o',
+		0x18, 0x00, 0x00, 0x00, // end - start
+		0x0A, 0x00, 0x00, 0x00, // len * 2
+		0x90, 0x90, 0x90,
+	}
+	text := []byte{
+		0xB8, 0x18, 0x00, 0x00, 0x00, // mov eax, end - start
+		0x8B, 0x4B, 0x0D, // mov ecx, [ebx+13]
+		0x8B, 0x96, 0x08, 0x00, 0x00, 0x00, // mov edx, [esi+8](后定义的常量使用 32 位偏移)
+		0x68, 0x18, 0x00, 0x00, 0x00, // push size
+		0xE9, 0xFB, 0xFF, 0xFF, 0xFF, // jmp $
+	}
+	for _, sec := range p.secList {
+		want := text
+		if sec.Name == ".data" {
+			want = data
+		}
+		if !bytes.Equal(sec.Data, want) {
+			t.Errorf("%s: got % X, want % X", sec.Name, sec.Data, want)
+		}
+	}
+	if len(p.relocateList) != 0 {
+		t.Errorf("不应产生重定位: %+v", *p.relocateList[0])
+	}
+}
+
+func TestExprLiteral(t *testing.T) {
+	// 各种进制的整数字面量; 最后一个字面量位于文件末尾, 之后没有结束字符
+	src := "\tdb 0x10,0b11,017,0o7,1_0, 2\n\tdq 0xFFFFFFFFFFFFFFFF\n\tdb 5"
+	p := assemble(t, "386", SyntaxIntel, src)
+	want := []byte{0x10, 3, 017, 7, 10, 2, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 5}
+	if got := p.secList[0].Data; !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+}
+
+func TestExprTimes(t *testing.T) {
+	p := assemble(t, "386", SyntaxIntel, "db 1, 2\ntimes 6-($-$$) db 0x90\nmsg equ $")
+	want := []byte{1, 2, 0x90, 0x90, 0x90, 0x90}
+	if got := p.secList[0].Data; !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+}
+
+func TestExprATT(t *testing.T) {
+	src := `
+.equ n, (1 << 4) - 1
+start:
+	movl $n*2, %eax
+	movl start+4(,%ecx,2*2), %ebx
+	.long . - start, n
+`
+	lex := NewBytesLexer([]byte(src))
+	lex.syntax = SyntaxATT
+	p := NewParser(lex)
+	if err := p.ParseFile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Codegen(); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0xB8, 0x1E, 0x00, 0x00, 0x00, // mov eax, 30
+		0x8B, 0x1C, 0x8D, 0x04, 0x00, 0x00, 0x00, // mov ebx, [ecx*4+start+4]
+		0x0C, 0x00, 0x00, 0x00, 0x0F, 0x00, 0x00, 0x00,
+	}
+	if got := p.secList[0].Data; !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+}
+
+func TestExprRedefine(t *testing.T) {
+	// .equ 与 .set 相同, 可以重新赋值, 引用时使用当前的值
+	src := `
+	.equ n, 1
+	.long n
+	.equ n, n + 1
+	.long n
+	.set n, 5
+	.long n
+	.equ n, end - start
+	.long n
+start:	.byte 0
+end:
+`
+	p := assemble(t, "amd64", SyntaxATT, src)
+	want := []byte{1, 0, 0, 0, 2, 0, 0, 0, 5, 0, 0, 0, 1, 0, 0, 0, 0}
+	if got := p.secList[0].Data; !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+
+	// NASM 的 equ 及 .equiv 不能重复定义
+	for _, tt := range []struct {
+		src    string
+		syntax Syntax
+	}{
+		{"x equ 1\nx equ 2", SyntaxIntel},
+		{".equiv x, 1\n.equiv x, 2", SyntaxATT},
+		{".equiv x, 1\n.equ x, 2", SyntaxATT},
+		{"x: .equ x, 1", SyntaxATT},
+	} {
+		lex := NewBytesLexer([]byte(tt.src))
+		lex.syntax = tt.syntax
+		if err := NewParser(lex).ParseFile(); err == nil || !strings.Contains(err.Error(), "重复定义") {
+			t.Errorf("%q: 期望重复定义错误, got %v", tt.src, err)
+		}
+	}
+}
+
+func TestExprError(t *testing.T) {
+	tests := []string{
+		"times n db 0\nn equ 2",
+		"a equ b\nb equ a\nmov eax, a",
+		"x: db 0\ny equ x + x",
+		"a equ 1 / 0",
+		"mov eax, [eax*ebx]",
+		"mov eax, [-ebx]",
+		"mov eax, [eax+ebx+ecx]",
+		"section .data\nx: db 0\nsection .text\ny: mov eax, y - x",
+		"mov eax, (1 + 2",
+		"jmp 5 * foo",
+		"dq 0x10000000000000000", // 超出 64 位
+	}
+
+	for _, src := range tests {
+		p := NewParser(NewBytesLexer([]byte(src)))
+		err := p.ParseFile()
+		if err == nil {
+			err = p.Codegen()
+		}
+		if err == nil {
+			t.Errorf("%q: 期望错误", src)
+		}
+	}
+}
+
+func TestExprDiffError(t *testing.T) {
+	// 被减的符号必须定义在本文件中, 且位于回填位置所在的段, 错误信息指出该符号
+	tests := []struct {
+		src, want string
+	}{
+		{".data\n.long . - ext", "符号 ext 不在当前文件的同一个段中"},
+		{".data\n.long 4 - d\nd:", "符号 d 不在当前文件的同一个段中"},
+		{".data\nd: .long 0\n.text\n.long ext - d", "符号 d 不在当前段中"},
+		{".data\nd: .long 0\n.text\n.long (ext - d) - (ext - d)", "不同段的符号差值不能再与符号相减"},
+	}
+	for _, tt := range tests {
+		lex := NewBytesLexer([]byte(tt.src))
+		lex.syntax = SyntaxATT
+		p := NewParser(lex)
+		p.setArch(arch.Set("amd64"))
+		err := p.ParseFile()
+		if err == nil {
+			err = p.Codegen()
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: got %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
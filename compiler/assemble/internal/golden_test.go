@@ -0,0 +1,301 @@
+package internal
+
+import (
+	"bytes"
+	stdelf "debug/elf"
+	"fmt"
+	"github.com/facelang/face/internal/arch"
+	"github.com/facelang/face/internal/prog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// 金标准测试: testdata/golden/<架构>/*.s 为 GAS 语法的源码, 同名 .o 为参考汇编器预先生成的目标文件,
+// 测试时不需要安装 binutils. 生成方法见 testdata/golden/README.md.
+// 逐段比较段内容、符号表及重定位表, 段内容不一致时报告第一条不同的指令及其源码行.
+
+// assembleGolden 汇编金标准源文件, 返回完成编码的解析器及目标文件内容
+func assembleGolden(t *testing.T, archName, file string) (*parser, []byte) {
+	t.Helper()
+	src, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := NewSourceLexer(file, src)
+	lex.syntax = SyntaxATT
+	p := NewParser(lex)
+	p.setArch(arch.Set(archName))
+	if err := p.ParseFile(); err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if err := p.Codegen(); err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	return p, p.Object().Bytes()
+}
+
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/golden/*/*.s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("没有找到金标准测试文件")
+	}
+	for _, file := range files {
+		archName := filepath.Base(filepath.Dir(file))
+		t.Run(archName+"/"+filepath.Base(file), func(t *testing.T) {
+			want, err := os.ReadFile(strings.TrimSuffix(file, ".s") + ".o")
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, got := assembleGolden(t, archName, file)
+			for _, diff := range p.diffObject(got, want) {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+// diffObject 比较汇编结果与参考目标文件, 返回差异列表
+func (p *parser) diffObject(got, want []byte) []string {
+	gf, err := stdelf.NewFile(bytes.NewReader(got))
+	if err != nil {
+		return []string{fmt.Sprintf("无法解析汇编结果: %v", err)}
+	}
+	wf, err := stdelf.NewFile(bytes.NewReader(want))
+	if err != nil {
+		return []string{fmt.Sprintf("无法解析参考文件: %v", err)}
+	}
+	var diffs []string
+	report := func(format string, args ...any) {
+		diffs = append(diffs, fmt.Sprintf(format, args...))
+	}
+	if gf.Class != wf.Class || gf.Machine != wf.Machine || eflags(gf, got) != eflags(wf, want) {
+		report("文件头: got %s %s flags=%#x, want %s %s flags=%#x",
+			gf.Class, gf.Machine, eflags(gf, got), wf.Class, wf.Machine, eflags(wf, want))
+	}
+
+	// 段: 只比较数据段, 段表、符号表及重定位表的布局由汇编器决定
+	for _, ws := range wf.Sections {
+		if !goldenSection(ws) {
+			continue
+		}
+		gs := gf.Section(ws.Name)
+		if gs == nil {
+			if ws.Size != 0 { // GAS 总是生成 .data 及 .bss, 空段不影响链接
+				report("缺少段 %s", ws.Name)
+			}
+			continue
+		}
+		if gs.Type != ws.Type || gs.Flags != ws.Flags || gs.Size != ws.Size {
+			report("段 %s: got %s %s size=%#x, want %s %s size=%#x",
+				ws.Name, gs.Type, gs.Flags, gs.Size, ws.Type, ws.Flags, ws.Size)
+		}
+		if gs.Type == stdelf.SHT_NOBITS || ws.Type == stdelf.SHT_NOBITS {
+			continue
+		}
+		gd, _ := gs.Data()
+		wd, _ := ws.Data()
+		if off := firstDiff(gd, wd); off >= 0 {
+			report("%s", p.diffInstr(ws.Name, off, gd, wd))
+		}
+	}
+	for _, gs := range gf.Sections {
+		if goldenSection(gs) && wf.Section(gs.Name) == nil {
+			report("多余的段 %s", gs.Name)
+		}
+	}
+
+	// 符号表: 按名称比较, 段符号及文件符号不参与比较
+	gsyms, wsyms := goldenSymbols(gf), goldenSymbols(wf)
+	for _, name := range sortedKeys(wsyms) {
+		if g, ok := gsyms[name]; !ok {
+			report("缺少符号 %s", name)
+		} else if g != wsyms[name] {
+			report("符号 %s: got %s, want %s", name, g, wsyms[name])
+		}
+	}
+	for _, name := range sortedKeys(gsyms) {
+		if _, ok := wsyms[name]; !ok {
+			report("多余的符号 %s", name)
+		}
+	}
+
+	// 重定位表: 按目标段比较, 表项按偏移排序
+	grels, wrels := goldenRelocs(gf), goldenRelocs(wf)
+	for _, sec := range sortedKeys(wrels) {
+		g, w := grels[sec], wrels[sec]
+		for i := 0; i < max(len(g), len(w)); i++ {
+			switch {
+			case i >= len(g):
+				report("%s 缺少重定位 %s", sec, w[i])
+			case i >= len(w):
+				report("%s 多余的重定位 %s", sec, g[i])
+			case g[i] != w[i]:
+				report("%s 的第 %d 项重定位: got %s, want %s", sec, i, g[i], w[i])
+			default:
+				continue
+			}
+			break
+		}
+	}
+	for _, sec := range sortedKeys(grels) {
+		if _, ok := wrels[sec]; !ok {
+			report("%s 多余的重定位 %s", sec, grels[sec][0])
+		}
+	}
+	return diffs
+}
+
+// eflags 文件头中的 e_flags, debug/elf 没有提供
+func eflags(f *stdelf.File, data []byte) uint32 {
+	if f.Class == stdelf.ELFCLASS64 {
+		return f.ByteOrder.Uint32(data[48:])
+	}
+	return f.ByteOrder.Uint32(data[36:])
+}
+
+// goldenSection 参与比较的数据段
+func goldenSection(s *stdelf.Section) bool {
+	switch s.Type {
+	case stdelf.SHT_NULL, stdelf.SHT_SYMTAB, stdelf.SHT_STRTAB, stdelf.SHT_REL, stdelf.SHT_RELA:
+		return false
+	}
+	return true
+}
+
+// firstDiff 第一个不同字节的偏移, 完全相同时返回 -1
+func firstDiff(a, b []byte) int {
+	for i := 0; i < min(len(a), len(b)); i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) != len(b) {
+		return min(len(a), len(b))
+	}
+	return -1
+}
+
+// diffInstr 描述段内偏移 off 处的差异: 所在的指令、源码行及两边的机器码
+func (p *parser) diffInstr(sec string, off int, got, want []byte) string {
+	begin, end, where := off, off+1, "(无对应指令)"
+	for _, ins := range p.instrList {
+		if ins.Sec.Name == sec && ins.Len > 0 && ins.Offset <= off && off < ins.Offset+ins.Len {
+			begin, end = ins.Offset, ins.Offset+ins.Len
+			where = fmt.Sprintf("第 %d 行 %q", ins.Use.Line+1, p.sourceLine(ins.Use))
+			break
+		}
+	}
+	return fmt.Sprintf("%s+%#x: %s: got % x, want % x",
+		sec, off, where, got[min(begin, len(got)):min(end, len(got))], want[min(begin, len(want)):min(end, len(want))])
+}
+
+// sourceLine 源码中 pos 所在的行
+func (p *parser) sourceLine(pos prog.FilePos) string {
+	lines := strings.Split(string(p.sources[pos.Filename]), "\n")
+	if pos.Line < len(lines) {
+		return strings.TrimSpace(lines[pos.Line])
+	}
+	return ""
+}
+
+// goldenSym 参与比较的符号属性
+type goldenSym struct {
+	Value, Size uint64
+	Info, Other byte
+	Section     string
+}
+
+func (s goldenSym) String() string {
+	return fmt.Sprintf("%s value=%#x size=%d info=%#x other=%#x", s.Section, s.Value, s.Size, s.Info, s.Other)
+}
+
+// goldenSymbols 名称 -> 符号属性; 所在段以段名表示, 特殊段以索引名表示
+func goldenSymbols(f *stdelf.File) map[string]goldenSym {
+	syms, _ := f.Symbols()
+	m := make(map[string]goldenSym)
+	for _, s := range syms {
+		if typ := stdelf.ST_TYPE(s.Info); typ == stdelf.STT_SECTION || typ == stdelf.STT_FILE {
+			continue
+		}
+		if strings.HasPrefix(s.Name, "$") { // AArch64 的映射符号 $x/$d 只用于反汇编
+			continue
+		}
+		m[s.Name] = goldenSym{s.Value, s.Size, s.Info, s.Other, sectionName(f, s.Section)}
+	}
+	return m
+}
+
+// sectionName 段索引对应的段名
+func sectionName(f *stdelf.File, i stdelf.SectionIndex) string {
+	if i > 0 && int(i) < len(f.Sections) {
+		return f.Sections[i].Name
+	}
+	return i.String()
+}
+
+// goldenReloc 参与比较的重定位属性
+type goldenReloc struct {
+	Offset uint64
+	Type   uint32
+	Sym    string // 段符号以段名表示
+	Addend int64
+}
+
+func (r goldenReloc) String() string {
+	return fmt.Sprintf("%#x type=%d %s%+d", r.Offset, r.Type, r.Sym, r.Addend)
+}
+
+// goldenRelocs 目标段名 -> 按偏移排序的重定位表项
+func goldenRelocs(f *stdelf.File) map[string][]goldenReloc {
+	syms, _ := f.Symbols()
+	symName := func(i uint32) string {
+		if i == 0 || int(i) > len(syms) {
+			return ""
+		}
+		s := syms[i-1]
+		if stdelf.ST_TYPE(s.Info) == stdelf.STT_SECTION {
+			return sectionName(f, s.Section)
+		}
+		return s.Name
+	}
+	m := make(map[string][]goldenReloc)
+	for _, s := range f.Sections {
+		if s.Type != stdelf.SHT_REL && s.Type != stdelf.SHT_RELA {
+			continue
+		}
+		data, _ := s.Data()
+		target := f.Sections[s.Info].Name
+		var rels []goldenReloc
+		switch {
+		case f.Class == stdelf.ELFCLASS64 && s.Type == stdelf.SHT_RELA:
+			for ; len(data) >= 24; data = data[24:] {
+				info := f.ByteOrder.Uint64(data[8:])
+				rels = append(rels, goldenReloc{f.ByteOrder.Uint64(data), uint32(info), symName(uint32(info >> 32)),
+					int64(f.ByteOrder.Uint64(data[16:]))})
+			}
+		case f.Class == stdelf.ELFCLASS32 && s.Type == stdelf.SHT_REL:
+			for ; len(data) >= 8; data = data[8:] {
+				info := f.ByteOrder.Uint32(data[4:])
+				rels = append(rels, goldenReloc{uint64(f.ByteOrder.Uint32(data)), info & 0xff, symName(info >> 8), 0})
+			}
+		}
+		sort.SliceStable(rels, func(i, j int) bool { return rels[i].Offset < rels[j].Offset })
+		m[target] = rels
+	}
+	return m
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
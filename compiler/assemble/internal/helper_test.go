@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"github.com/facelang/face/internal/arch"
+	"testing"
+)
+
+// assemble 按指定架构(386/amd64/arm64/riscv64)及语法风格汇编源码, 返回完成编码的解析器
+func assemble(t *testing.T, archName string, syntax Syntax, src string) *parser {
+	t.Helper()
+	lex := NewBytesLexer([]byte(src))
+	lex.syntax = syntax
+	p := NewParser(lex)
+	p.setArch(arch.Set(archName))
+	if err := p.ParseFile(); err != nil {
+		t.Fatalf("%s %s %q: 解析失败: %v", archName, syntax, src, err)
+	}
+	if err := p.Codegen(); err != nil {
+		t.Fatalf("%s %s %q: 编码失败: %v", archName, syntax, src, err)
+	}
+	return p
+}
@@ -1,9 +1,34 @@
 package internal
 
-// instr 表示一条汇编指令
+import "github.com/facelang/face/internal/prog"
+
+// instr 表示一条汇编指令, 数据定义(db/dw/dd)同样作为一条伪指令记录
 type instr struct {
-	Opcode string    // 操作码
-	Src    *operand  // 源操作数
-	Dst    *operand  // 目标操作数
-	Size   int       // 操作数大小(byte/word/dword/qword)
+	Opcode Token        // 操作码
+	Prefix []Token      // 写在指令之前的前缀 lock/rep/repe/repne
+	Src    *operand     // 源操作数
+	Dst    *operand     // 目标操作数
+	Imm    *operand     // 第三个操作数, 只用于三操作数的 imul
+	Size   int          // 操作数大小(byte/word/dword/qword)
+	Times  int          // 重复次数(times)
+	Values []*operand   // 数据定义的值列表
+	Sec    *section     // 所在段
+	Offset int          // 段内偏移
+	Len    int          // 编码长度
+	Pos    prog.FilePos // 源码位置
+	Trace  string       // 宏展开路径, 用于错误信息
+	Use    prog.FilePos // 在顶层源文件中的位置, 宏展开及包含文件中的指令为展开位置
+	Short  bool         // 跳转使用 rel8 编码(RISC-V 使用 Insn.Short), 由分支优化选择
+	Fixed  bool         // 长度已用于第一遍扫描时计算的常量, 不参与分支优化
+	Max    int          // 对齐时最多填充的字节数, 0 表示不限制
+	Loc    lineLoc      // 行号表中的源码位置, 用于调试信息
+	Insn   *insn        // 精简指令架构(arm64, riscv64)已编码的指令(K_INSN)
+	Nops   [][]byte     // 精简指令架构代码段对齐时填充的 nop 指令, 由长到短
+}
+
+// insn 精简指令架构(arm64, riscv64)的机器指令, 解析时已完成编码, 符号引用在代码生成时回填到指令字中
+type insn struct {
+	Code   []byte  // 机器码, 指令字按小端序排列
+	Fixups []fixup // 符号引用, Reloc 指定回填的字段及重定位类型
+	Short  *insn   // 跳转指令的压缩编码, 由分支优化选择
 }
@@ -1,6 +1,11 @@
 package internal
 
-import "fmt"
+import (
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"github.com/facelang/face/internal/prog"
+	"strings"
+)
 
 type LabelType uint8
 
@@ -9,42 +14,68 @@ const TEXT_LABEL LabelType = 1      // 代码段符号
 const EQU_LABEL LabelType = 2       // 常量
 const LOCAL_LABEL LabelType = 3     // 局部变量
 const EXTERNAL_LABEL LabelType = 4  // 外部变量, 提前申明的
+const COMMON_LABEL LabelType = 5    // 公共符号(.comm), 由链接器分配空间
 
 type label struct {
-	Name    string    // 标签名
-	Type    LabelType // 标签类型
-	Addr    int       // 地址
-	Index   int       // 添加顺序， 从1开始
-	Section string    // 段名
-	Times   int       // 重复次数
-	Size    int       // 字节长度
-	Cont    []int     // 内容
-	ContLen int       // 内容长度
-	RelInfo bool      // 记录重定位信息
+	Name      string       // 标签名
+	Type      LabelType    // 标签类型
+	Addr      int          // 地址, equ 常量记录常量值
+	Index     int          // 添加顺序， 从1开始
+	Section   string       // 段名
+	Instr     int          // 定义位置之后第一条指令的序号, 分支优化后据此重新计算地址
+	Global    bool         // 全局符号(global 申明)
+	Expr      Express      // equ 常量的表达式, 引用了尚未定义的符号时在使用时计算
+	Redef     bool         // .set/.equ 定义的常量, 可以重新赋值
+	Pos       prog.FilePos // 定义的位置, 未定义的符号记录第一次引用的位置
+	Weak      bool         // 弱符号(.weak), 同时视为全局符号
+	Local     bool         // .local 申明, 之后的 .comm 在 .bss 中分配空间
+	Kind      elf.SymType  // 符号类型(.type)
+	Size      int          // 符号大小(.size), 公共符号为申请的空间大小
+	Vis       elf.SymVis   // 可见性(.hidden/.protected/.internal)
+	Keep      bool         // 重定位直接引用了该符号, .L 开头的局部符号也输出到符号表
+	Orig      string       // 函数内标签的原名称, 以此名称作为局部符号输出到符号表
+	resolving bool         // 正在计算 Expr, 用于检测循环引用
 }
 
 // AddLabel 添加符号到符号表; 一共三处，equ 常量 仅数字 NewRecWithEqu， 变量 NewRecWithData,  代码段 TextLabel
-func (p *parser) AddLabel(name string, rec *label) {
+// 重复定义的符号记录错误, 保留第一次的定义
+func (p *parser) AddLabel(name string, rec *label, pos prog.FilePos) {
 	rec.Name = name // 缓存一次，减少后续查找名字
+	rec.Pos = pos
 	if rec.Type == TEXT_LABEL || rec.Type == LOCAL_LABEL {
-		rec.Addr = p.seg.Offset
-		rec.Section = p.seg.Name
+		rec.Addr = p.sec.Offset
+		rec.Section = p.sec.Name
+		rec.Instr = len(p.instrList)
 	}
 
-	// 更新地址, 除了具体的变量定义，这里都是 0， 没有变化
-	p.seg.Offset += rec.Times * rec.Size * rec.ContLen
-
 	if i, ok := p.labelNames[name]; ok {
 		labelRec := p.labelList[i]
-		if labelRec.Type == UNDEFINED_LABEL {
+		if labelRec.Type == UNDEFINED_LABEL || labelRec.Type == EXTERNAL_LABEL {
+			rec.Index = labelRec.Index
+			rec.Global = rec.Global || labelRec.Global // 先申明 global 再定义
+			rec.Weak, rec.Local, rec.Kind, rec.Size, rec.Vis = labelRec.Weak, labelRec.Local, labelRec.Kind, labelRec.Size, labelRec.Vis
 			p.labelList[i] = rec // 直接替换
 		} else {
-			_ = fmt.Errorf("符号: %s 重复定义！", name)
+			p.report(pos, fmt.Sprintf("符号 %s 重复定义, 上一次定义在 %s", labelName(name), labelRec.Pos.String()))
 		}
 	} else {
 		p.labelList = append(p.labelList, rec)
 		p.labelNames[name] = len(p.labelList) - 1
+		rec.Index = len(p.labelList)
+	}
+}
+
+// symName 符号表中的名称
+func (lb *label) symName() string {
+	if lb.Orig != "" {
+		return lb.Orig
 	}
+	return lb.Name
+}
+
+// defined 符号是否定义在本文件的某个段中
+func (lb *label) defined() bool {
+	return lb.Type == TEXT_LABEL || lb.Type == LOCAL_LABEL
 }
 
 // GetLabel 获取符号
@@ -57,11 +88,143 @@ func (p *parser) GetLabel(name string) *label {
 	// 未知符号，添加为外部符号(待重定位)
 	rec := NewLabel(UNDEFINED_LABEL)
 	rec.Name = name
+	rec.Pos = p.pos
 	p.labelList = append(p.labelList, rec)
 	p.labelNames[name] = len(p.labelList) - 1
+	rec.Index = len(p.labelList)
 	return rec
 }
 
+// extern 申明外部符号(Intel 风格), 已经定义的符号忽略申明
+func (p *parser) extern(name string) {
+	if lb := p.GetLabel(name); lb.Type == UNDEFINED_LABEL {
+		lb.Type = EXTERNAL_LABEL
+	}
+}
+
+// resolved 符号在文件结束时是否有确定的来源: 已定义、global/extern 申明, 或按 GAS 的规则视为外部符号;
+// .L 开头的局部标签(包括数字标签及函数内的标签)必须在本文件中定义
+func (p *parser) resolved(lb *label) bool {
+	switch {
+	case lb.Type != UNDEFINED_LABEL || lb.Global:
+		return true
+	case isLocal(lb.Name):
+		return false
+	}
+	return p.syntax != SyntaxIntel
+}
+
+// labelName 错误信息中的符号名称, 还原数字标签及函数内标签的源码形式
+func labelName(name string) string {
+	if !isLocal(name) {
+		return name
+	}
+	scope, name, ok := strings.Cut(name[2:], "$")
+	switch {
+	case !ok:
+		return ".L" + scope
+	case '0' <= scope[0] && scope[0] <= '9':
+		return "数字标签 " + scope
+	}
+	return fmt.Sprintf("%s(函数 %s 内)", name, scope)
+}
+
+// localName 数字局部标签 n 的第 k 次定义, 以 .L 开头的符号不导出到符号表
+func localName(n string, k int) string {
+	return fmt.Sprintf(".L%s$%d", n, k)
+}
+
+// scopedName 函数 fn 内定义的标签
+func scopedName(fn, name string) string {
+	return ".L" + fn + "$" + name
+}
+
+// isLocal 以 .L 开头的符号只在汇编时使用, 不导出到符号表
+func isLocal(name string) bool {
+	return strings.HasPrefix(name, ".L")
+}
+
+// defLabel 定义代码标签: 函数(TEXT 或 .type @function 之后)内的普通标签只在函数内可见,
+// 不同函数可以使用相同的标签名, 以原名称作为局部符号输出; 函数名、.L 标签及 global 申明的符号仍在文件内可见
+func (p *parser) defLabel(name string, pos prog.FilePos) {
+	if p.scope != "" && name != p.scope && !isLocal(name) {
+		if i, ok := p.labelNames[name]; !ok || !p.labelList[i].Global {
+			rec := &label{Type: TEXT_LABEL, Orig: name}
+			p.AddLabel(scopedName(p.scope, name), rec, pos)
+			p.scoped[name] = append(p.scoped[name], rec)
+			if ok { // 定义之前的 .type/.size/.hidden/.local 作用于同名的未定义符号, 转移到函数内的标签
+				p.moveAttrs(p.labelList[i], rec)
+			}
+			return
+		}
+	}
+	p.AddLabel(name, NewLabel(TEXT_LABEL), pos)
+}
+
+// moveAttrs 将未定义符号 from 上的属性转移到函数内定义的标签 to
+func (p *parser) moveAttrs(from, to *label) {
+	to.Weak, to.Local, to.Kind, to.Size, to.Vis = from.Weak, from.Local, from.Kind, from.Size, from.Vis
+	for _, s := range p.symSizes {
+		if s.Sym == from {
+			s.Sym = to
+		}
+	}
+}
+
+// defNumLabel 定义数字局部标签(AT&T), 同一数字可以多次定义, 每次定义生成新的实例
+func (p *parser) defNumLabel(n string, pos prog.FilePos) {
+	p.numLabels[n]++
+	p.AddLabel(localName(n, p.numLabels[n]), NewLabel(TEXT_LABEL), pos)
+}
+
+// symRef 符号引用: 数字标签 Nb/Nf 引用最近一次/下一次定义的实例, 其余引用记录所在函数
+func (p *parser) symRef(name string) *SymExpr {
+	if n := len(name) - 1; '0' <= name[0] && name[0] <= '9' && n > 0 {
+		k := p.numLabels[name[:n]]
+		if name[n] == 'f' {
+			k++
+		} else if k == 0 {
+			p.errorf("数字标签 %s 之前没有定义", name)
+		}
+		return &SymExpr{Name: localName(name[:n], k)}
+	}
+	return &SymExpr{Name: name, Scope: p.scope}
+}
+
+// lookup 查找符号引用: 函数内优先使用本函数定义的标签; 第一遍扫描时函数尚未结束,
+// 本函数的标签可能在后面定义, 除常量外都推迟到第二遍扫描.
+// 文件内没有定义的符号引用其它函数内唯一的同名标签, 多个函数定义了同名标签时报错
+func (p *parser) lookup(x *SymExpr, final bool) (*label, error) {
+	if x.Scope != "" {
+		if i, ok := p.labelNames[scopedName(x.Scope, x.Name)]; ok {
+			return p.labelList[i], nil
+		}
+		if !final && x.Scope == p.scope {
+			if i, ok := p.labelNames[x.Name]; !ok || p.labelList[i].Type != EQU_LABEL {
+				return nil, errForward
+			}
+		}
+	}
+	lb := p.GetLabel(x.Name)
+	if final && p.moved(lb) {
+		if defs := p.scoped[x.Name]; len(defs) > 1 {
+			return nil, fmt.Errorf("标签 %s 在多个函数内定义, 不能在函数外引用", x.Name)
+		}
+		return p.scoped[x.Name][0], nil
+	}
+	return lb, nil
+}
+
+// moved 未定义的符号 lb 是否引用函数内定义的同名标签, 这样的符号不输出到符号表
+func (p *parser) moved(lb *label) bool {
+	return lb.Type == UNDEFINED_LABEL && !lb.Global && len(p.scoped[lb.Name]) > 0
+}
+
 func NewLabel(lType LabelType) *label {
 	return &label{Type: lType}
 }
+
+// NewLabelEqu equ 常量符号
+func NewLabelEqu(value int64) *label {
+	return &label{Type: EQU_LABEL, Addr: int(value)}
+}
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/facelang/face/internal/prog"
+	"strings"
+	"testing"
+)
+
+func TestLocalLabel(t *testing.T) {
+	// 期望结果来自 GNU as 的输出
+	src := `
+	.globl f
+	.type f, @function
+f:
+	movl $3, %ecx
+1:	decl %ecx
+	jne 1b
+	jmp 1f
+	movl $1, %eax
+1:	jmp .Ldone
+.Ldone:
+	ret
+	.size f, .-f
+`
+	p := assemble(t, "amd64", SyntaxATT, src)
+	want := []byte{
+		0xB9, 0x03, 0x00, 0x00, 0x00, // movl $3, %ecx
+		0xFF, 0xC9, // 1: decl %ecx
+		0x75, 0xFC, // jne 1b
+		0xEB, 0x05, // jmp 1f
+		0xB8, 0x01, 0x00, 0x00, 0x00, // movl $1, %eax
+		0xEB, 0x00, // 1: jmp .Ldone
+		0xC3, // .Ldone: ret
+	}
+	if got := p.secList[0].Data; !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+	locals, globals := p.symbols()
+	if len(locals) != 0 || len(globals) != 1 || globals[0].Name != "f" {
+		t.Errorf("符号表中只应有 f: locals=%v globals=%v", locals, globals)
+	}
+}
+
+func TestScopedLabel(t *testing.T) {
+	tests := []struct {
+		src    string
+		syntax Syntax
+		want   []byte
+		locals string // 符号表中的局部符号
+	}{
+		{`
+	.type f, @function
+f:
+loop:
+	jmp done
+done:
+	jmp loop
+	.size f, .-f
+	.type g, @function
+g:
+loop:
+	jmp loop
+	call f
+`, SyntaxATT, []byte{0xEB, 0x00, 0xEB, 0xFC, 0xEB, 0xFE, 0xE8, 0xF5, 0xFF, 0xFF, 0xFF}, "f loop done g loop"},
+		{`
+TEXT ·f(SB), $0
+loop:
+	JMP loop
+TEXT ·g(SB), $0
+	JMP loop
+loop:
+	RET
+`, SyntaxPlan9, []byte{0xEB, 0xFE, 0xEB, 0x00, 0xC3}, "loop loop"},
+	}
+	for _, tt := range tests {
+		p := assemble(t, "amd64", tt.syntax, tt.src)
+		if got := p.secList[0].Data; !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got % X, want % X", tt.syntax, got, tt.want)
+		}
+		if _, ok := p.labelNames["loop"]; ok {
+			t.Errorf("%s: 函数内的标签 loop 不应在文件内可见", tt.syntax)
+		}
+		if got := symNames(p); got != tt.locals {
+			t.Errorf("%s: 局部符号 got %q, want %q", tt.syntax, got, tt.locals)
+		}
+	}
+}
+
+// symNames 符号表中局部符号的名称
+func symNames(p *parser) string {
+	locals, _ := p.symbols()
+	var names []string
+	for _, lb := range locals {
+		names = append(names, lb.symName())
+	}
+	return strings.Join(names, " ")
+}
+
+func TestScopedLabelRef(t *testing.T) {
+	// 期望结果来自 GNU as 的输出: 函数外引用其它函数内的标签, 缺少 .size 时切换段结束函数
+	src := `
+	.type f, @function
+f:
+loop:
+	ret
+	.size f, .-f
+	.type g, @function
+g:
+	jmp loop
+far:
+	.data
+d:	.quad loop
+	.quad far
+`
+	p := assemble(t, "amd64", SyntaxATT, src)
+	if got, want := p.secList[0].Data, []byte{0xC3, 0xEB, 0xFD}; !bytes.Equal(got, want) {
+		t.Errorf(".text got % X, want % X", got, want)
+	}
+	if got, want := symNames(p), "f loop g far d"; got != want {
+		t.Errorf("局部符号 got %q, want %q", got, want)
+	}
+	_, globals := p.symbols()
+	if len(globals) != 0 {
+		t.Errorf("不应有未定义的符号: %v", globals)
+	}
+	var rels []string
+	for _, rel := range p.relocateList {
+		rels = append(rels, fmt.Sprintf("%s+%d", rel.Label, rel.Addend))
+	}
+	if got, want := strings.Join(rels, " "), ".text+0 .text+3"; got != want {
+		t.Errorf("重定位 got %q, want %q", got, want)
+	}
+}
+
+func TestLabelError(t *testing.T) {
+	tests := []struct {
+		src    string
+		syntax Syntax
+		want   []string // 按位置排序的全部错误
+	}{
+		{`
+x equ 1
+x equ 2
+msg db 1
+msg db 2
+	mov eax, bx
+	call nowhere
+	bogus eax
+`, SyntaxIntel, []string{
+			"行: 3,", "符号 x 重复定义, 上一次定义在 行: 2,",
+			"行: 5,", "符号 msg 重复定义",
+			"行: 6,", "操作数宽度不一致",
+			"行: 7,", "符号 nowhere 未定义",
+			"行: 8,", "except :",
+		}},
+		{`
+extern exit
+	call exit
+	jmp .Lnone
+`, SyntaxIntel, []string{"行: 4,", "符号 .Lnone 未定义"}},
+		{`
+	call printf
+	jmp 1f
+	jne 2b
+`, SyntaxATT, []string{"行: 3,", "符号 数字标签 1 未定义", "行: 4,", "数字标签 2b 之前没有定义"}},
+		{`
+TEXT ·f(SB), $0
+	JMP done
+`, SyntaxPlan9, []string{"行: 3,", "符号 done(函数 f 内) 未定义"}},
+		{`
+	.type f, @function
+f:
+loop:	ret
+	.type g, @function
+g:
+loop:	ret
+	.text
+	jmp loop
+`, SyntaxATT, []string{"行: 9,", "标签 loop 在多个函数内定义, 不能在函数外引用"}},
+	}
+	for _, tt := range tests {
+		lex := NewBytesLexer([]byte(tt.src))
+		lex.syntax = tt.syntax
+		p := NewParser(lex)
+		p.ParseFile()
+		err := p.Codegen()
+		if err == nil {
+			t.Errorf("%q: 期望出错", tt.src)
+			continue
+		}
+		var got strings.Builder
+		prog.PrintError(&got, err)
+		msg := got.String()
+		for _, s := range tt.want {
+			i := strings.Index(msg, s)
+			if i < 0 {
+				t.Errorf("%q: 错误信息中缺少 %q:\n%s", tt.src, s, msg)
+				break
+			}
+			msg = msg[i+len(s):]
+		}
+	}
+}
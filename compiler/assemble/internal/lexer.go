@@ -1,13 +1,16 @@
 package internal
 
 import (
+	"github.com/facelang/face/internal/os/elf"
+	"github.com/facelang/face/internal/prog"
 	"github.com/facelang/face/internal/reader"
+	"strconv"
 	"unicode"
 	"unicode/utf8"
 )
 
-// Whitespace 对比 map, switch 位掩码 比较效率最高, 忽略 \n
-const Whitespace = 1<<'\t' | 1<<'\n' | 1<<'\r' | 1<<' '
+// Whitespace 对比 map, switch 位掩码 比较效率最高, 换行符作为语句结束标记单独返回
+const Whitespace = 1<<'\t' | 1<<'\r' | 1<<' '
 
 //type lexer struct {
 //	reader *reader.Reader
@@ -79,11 +82,13 @@ const Whitespace = 1<<'\t' | 1<<'\n' | 1<<'\r' | 1<<' '
 //}
 
 type lexer struct {
-	*reader.Reader        // 读取器
-	id             string // 暂存字符
-	pos            int    // 文件读取指针行列号
-	back           bool   // 回退标识
-	backToken      Token  // 回退Token
+	*reader.Reader              // 读取器
+	syntax         Syntax       // 语法风格, 决定注释符号及关键字识别方式
+	machine        elf.Machine  // 目标架构, AArch64 使用 # 作为立即数前缀, // 作为注释; RISC-V 中 % 不是寄存器前缀
+	id             string       // 暂存字符
+	pos            prog.FilePos // 当前 Token 的文件位置
+	back           bool         // 回退标识
+	backToken      Token        // 回退Token
 }
 
 func (lex *lexer) Back(token Token) {
@@ -102,17 +107,14 @@ func (lex *lexer) NextToken() Token {
 	}
 
 	ch, chw := lex.ReadRune()
-	if chw == 0 {
-		return EOF
-	}
-
-	lex.pos = lex.Pos()
 
 	// skip white space
-	for Whitespace&(1<<ch) != 0 {
+	for chw != 0 && Whitespace&(1<<ch) != 0 {
 		ch, chw = lex.ReadRune()
 	}
 
+	lex.pos = lex.FilePos()
+
 	if chw == 0 {
 		return EOF
 	}
@@ -122,31 +124,109 @@ func (lex *lexer) NextToken() Token {
 	lex.TextReady()
 
 	if '0' <= ch && ch <= '9' { // 数字
+		if lex.syntax == SyntaxATT && lex.localRef() {
+			return IDENT
+		}
 		return Number(lex, ch)
 	}
 
 	if CheckIdent(ch, 0) { // 符号
-		for i := 1; CheckIdent(ch, i); i++ {
-			ch, chw = lex.ReadRune()
+		lex.ident()
+		if lex.syntax != SyntaxIntel { // AT&T 及 Plan 9 的指令、寄存器由语法解析器识别
+			return IDENT
 		}
-		lex.id = lex.ReadText()
 		return Lookup(lex.id)
 	}
 
 	switch ch {
+	case '\n':
+		return NEWLINE
 	case '+':
 		return ADD
 	case '-':
 		return SUB
+	case '*':
+		return MUL
 	case ':':
 		return COLON
 	case ',':
 		return COMMA
+	case '|':
+		return lex.twoChar('|', OR, LOR)
+	case '&':
+		return lex.twoChar('&', AND, LAND)
+	case '^':
+		return XOR
+	case '~':
+		return NOT
+	case '(':
+		return LPAREN
+	case ')':
+		return RPAREN
+	case '$':
+		return DOLLAR
+	case '<':
+		if tok := lex.twoChar('<', LSS, SHL); tok != LSS {
+			return tok
+		}
+		return lex.twoChar('=', LSS, LEQ)
+	case '>':
+		if tok := lex.twoChar('>', GTR, SHR); tok != GTR {
+			return tok
+		}
+		return lex.twoChar('=', GTR, GEQ)
+	case '=':
+		return lex.twoChar('=', ILLEGAL, EQL)
 	case ';':
+		if lex.syntax != SyntaxIntel {
+			return SEMI
+		}
 		lex.id = reader.Comment(lex.Reader)
 		return COMMENT
-	case '"': // 查找字符串，到 " 结束
-		lex.id, _ = reader.String(lex.Reader, '"')
+	case '#':
+		if lex.machine == elf.EM_AARCH64 {
+			return HASH
+		}
+		if lex.syntax == SyntaxATT {
+			lex.id = reader.Comment(lex.Reader)
+			return COMMENT
+		}
+		return ILLEGAL
+	case '!':
+		return lex.twoChar('=', EXCL, NEQ)
+	case '%': // AT&T 寄存器, 其它风格为取余运算; RISC-V 的 %hi(sym) 等重定位修饰由语法解析器识别
+		if lex.syntax != SyntaxATT || lex.machine == elf.EM_AARCH64 || lex.machine == elf.EM_RISCV {
+			return REM
+		}
+		if ch, _ = lex.ReadRune(); CheckIdent(ch, 0) {
+			lex.ident()
+			if tok := Lookup(lex.id[1:]); tok.IsRegister() || tok.IsSegment() || tok == REG_RIP {
+				return tok
+			}
+		}
+		return ILLEGAL
+	case '/':
+		if lex.syntax == SyntaxIntel {
+			return DIV
+		}
+		next, eof := lex.ReadByte()
+		if !eof && next == '/' && (lex.syntax == SyntaxPlan9 || lex.machine == elf.EM_AARCH64) {
+			lex.id = reader.Comment(lex.Reader)
+			return COMMENT
+		}
+		if !eof && next == '*' {
+			return lex.blockComment()
+		}
+		lex.GoBack()
+		return DIV
+	case '"': // 查找字符串，到 " 结束, 转义规则与 Go 一致
+		text, _ := reader.String(lex.Reader, '"')
+		id, err := strconv.Unquote(text)
+		if err != nil {
+			lex.id = text
+			return ILLEGAL
+		}
+		lex.id = id
 		return STRING
 	case '[':
 		return LBRACK
@@ -157,6 +237,60 @@ func (lex *lexer) NextToken() Token {
 	}
 }
 
+// twoChar 两个字符的运算符: 下一个字符为 next 时返回 two, 否则返回 one
+func (lex *lexer) twoChar(next byte, one, two Token) Token {
+	if ch, eof := lex.ReadByte(); !eof && ch == next {
+		return two
+	}
+	lex.GoBack()
+	return one
+}
+
+// ident 读取符号的剩余部分, 首字符已读取
+func (lex *lexer) ident() {
+	ch, _ := lex.ReadRune()
+	for i := 1; CheckIdent(ch, i); i++ {
+		ch, _ = lex.ReadRune()
+	}
+	lex.GoBack() // 最后一个字符不属于符号，需要回退
+	lex.id = lex.ReadText()
+}
+
+// localRef 数字局部标签的引用 1b/1f (AT&T), 首个数字已读取
+func (lex *lexer) localRef() bool {
+	n := 0
+	for ch, eof := lex.Peek(n); !eof && '0' <= ch && ch <= '9'; ch, eof = lex.Peek(n) {
+		n++
+	}
+	if ch, _ := lex.Peek(n); ch != 'b' && ch != 'f' {
+		return false
+	}
+	if ch, eof := lex.Peek(n + 1); !eof && CheckIdent(rune(ch), 1) { // 0b1 等二进制数
+		return false
+	}
+	for i := 0; i <= n; i++ {
+		lex.ReadByte()
+	}
+	lex.id = lex.ReadText()
+	return true
+}
+
+// blockComment 块注释 /* ... */, 起始的 /* 已读取
+func (lex *lexer) blockComment() Token {
+	prev := byte(0)
+	for {
+		ch, eof := lex.ReadByte()
+		if eof {
+			return ILLEGAL // 注释未结束
+		}
+		if prev == '*' && ch == '/' {
+			lex.id = lex.ReadText()
+			return COMMENT
+		}
+		prev = ch
+	}
+}
+
 func CheckIdent(ch rune, i int) bool {
 	return ch == '.' || ch == '_' || ch == '@' || unicode.IsLetter(ch) ||
 		ch > utf8.RuneSelf || unicode.IsDigit(ch) && i > 0 // 第一个字符必须是字母或下划线
@@ -164,6 +298,9 @@ func CheckIdent(ch rune, i int) bool {
 
 func Number(lex *lexer, ch rune) Token {
 	typ, val := reader.Number(lex.Reader, ch)
+	if _, eof := lex.Peek(0); !eof { // reader.Number 返回的文本包含已回退的结束字符
+		val = val[:len(val)-1]
+	}
 	lex.id = val
 
 	if typ == reader.INT_TYPE {
@@ -175,3 +312,13 @@ func Number(lex *lexer, ch rune) Token {
 func NewLexer(file string) *lexer { // 封装后的读取器
 	return &lexer{Reader: reader.FileReader(file)}
 }
+
+// NewBytesLexer 从内存数据读取源码
+func NewBytesLexer(src []byte) *lexer {
+	return &lexer{Reader: reader.BytesReader(src)}
+}
+
+// NewSourceLexer 从内存数据读取源码, name 为错误信息及调试信息中使用的文件名
+func NewSourceLexer(name string, src []byte) *lexer {
+	return &lexer{Reader: reader.PosReader(src, prog.FilePos{Filename: name})}
+}
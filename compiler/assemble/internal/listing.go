@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"io"
+	"slices"
+	"strings"
+)
+
+// 汇编列表文件(-l): 按源码行输出 段、偏移、机器码及源码, 最后输出符号表及重定位表:
+//
+//	     3  .text    00000000  b8 01 00 00 00            mov eax, 1
+//	     4+ .text    00000005  50                        push \reg
+//
+// 宏展开及包含文件生成的指令列在展开位置之后, 以 + 标记, 源码为宏定义或包含文件中的对应行
+
+// listBytes 每行显示的机器码字节数, 超出部分续行显示
+const listBytes = 8
+
+// labelTypeNames 列表文件中的符号类型名称
+var labelTypeNames = map[LabelType]string{
+	UNDEFINED_LABEL: "UNDEF",
+	TEXT_LABEL:      "TEXT",
+	EQU_LABEL:       "EQU",
+	LOCAL_LABEL:     "DATA",
+	EXTERNAL_LABEL:  "EXTERN",
+	COMMON_LABEL:    "COMMON",
+}
+
+// listLine 输出一行, 去掉行尾空白
+func listLine(w io.Writer, format string, args ...any) {
+	fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf(format, args...), " "))
+}
+
+// listInstr 输出一条指令的机器码, mark 为行号后的标记, text 为源码;
+// .bss 等不占用文件空间的段没有写入文件的内容, 只显示大小
+func (p *parser) listInstr(w io.Writer, line int, mark string, ins *instr, text string) {
+	if ins.Sec.nobits() {
+		listLine(w, "%6d%-1s %-8s %08x  %-22s %s", line, mark, ins.Sec.Name, ins.Offset, fmt.Sprintf("<%d 字节>", ins.Len), text) // 汉字占两列
+		return
+	}
+	code := ins.Sec.Data[ins.Offset : ins.Offset+ins.Len]
+	for i := 0; i == 0 || i < len(code); i += listBytes {
+		chunk := code[i:min(i+listBytes, len(code))]
+		hex := fmt.Sprintf("% x", chunk)
+		if i == 0 {
+			listLine(w, "%6d%-1s %-8s %08x  %-24s %s", line, mark, ins.Sec.Name, ins.Offset, hex, text)
+		} else {
+			listLine(w, "%6s  %-8s %08x  %s", "", "", ins.Offset+i, hex)
+		}
+	}
+}
+
+// Listing 输出列表文件, 需要在代码生成之后调用
+func (p *parser) Listing(out io.Writer) error {
+	w := bufio.NewWriter(out)
+
+	lines := make(map[int][]*instr) // 顶层源文件的行号 -> 该行生成的指令
+	for _, ins := range p.instrList {
+		lines[ins.Use.Line] = append(lines[ins.Use.Line], ins)
+	}
+	for line, rows := range lines { // 不生成机器码的项(例如不需要填充的对齐)只在该行没有其它项时显示
+		if code := slices.DeleteFunc(slices.Clone(rows), func(ins *instr) bool { return ins.Len == 0 }); len(code) > 0 {
+			lines[line] = code
+		} else {
+			lines[line] = rows[:1]
+		}
+	}
+	files := make(map[string][]string) // 文件名 -> 按行拆分的源码
+	sourceLine := func(filename string, line int) string {
+		if files[filename] == nil {
+			files[filename] = strings.Split(string(p.sources[filename]), "\n")
+		}
+		if line < 0 || line >= len(files[filename]) {
+			return ""
+		}
+		return strings.TrimRight(files[filename][line], "\r")
+	}
+
+	src := strings.Split(string(p.sources[p.filename]), "\n")
+	for i, text := range src {
+		text = strings.TrimRight(text, "\r")
+		if i == len(src)-1 && text == "" {
+			break
+		}
+		rows := lines[i]
+		if len(rows) == 0 || rows[0].Trace != "" { // 没有生成指令, 或只有宏展开生成的指令
+			listLine(w, "%6d%-1s %-8s %8s  %-24s %s", i+1, "", "", "", "", text)
+			text = ""
+		}
+		for _, ins := range rows {
+			if ins.Trace != "" {
+				p.listInstr(w, i+1, "+", ins, sourceLine(ins.Pos.Filename, ins.Pos.Line))
+			} else {
+				p.listInstr(w, i+1, "", ins, text)
+				text = "" // 同一行的多条指令只显示一次源码
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "\n符号表:\n")
+	fmt.Fprintf(w, "  %-24s %-6s %-8s %-16s %s\n", "名称", "类型", "段", "值", "全局")
+	for _, lb := range p.labelList {
+		global := ""
+		if lb.Global {
+			global = "global"
+		}
+		listLine(w, "  %-24s %-6s %-8s %016x %s", lb.symName(), labelTypeNames[lb.Type], lb.Section, uint64(lb.Addr), global)
+	}
+
+	fmt.Fprintf(w, "\n重定位表:\n")
+	fmt.Fprintf(w, "  %-8s %-8s %-16s %-24s %s\n", "段", "偏移", "类型", "符号", "加数")
+	for _, rel := range p.relocateList {
+		var typ string
+		switch {
+		case p.machine == elf.EM_AARCH64:
+			typ = elf.R_AARCH64(rel.Type).String()
+		case p.machine == elf.EM_RISCV:
+			typ = elf.R_RISCV(rel.Type).String()
+		case p.bits == 64:
+			typ = elf.R_X86_64(rel.Type).String()
+		default:
+			typ = elf.R_386(rel.Type).String()
+		}
+		fmt.Fprintf(w, "  %-8s %08x %-16s %-24s %d\n", rel.Section, rel.Offset, typ, rel.Label, rel.Addend)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListing(t *testing.T) {
+	src := `.macro two r
+	push \r
+	pop \r
+.endm
+section .data
+msg db "hello, world", 10
+section .text
+global _start
+extern exit
+_start:
+	mov eax, msg
+	two ebx
+	call exit
+`
+	p := assemble(t, "386", SyntaxIntel, src)
+	var b strings.Builder
+	if err := p.Listing(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, line := range []string{
+		"     6  .data    00000000  68 65 6c 6c 6f 2c 20 77  msg db \"hello, world\", 10",
+		"                 00000008  6f 72 6c 64 0a",
+		"    11  .text    00000000  b8 00 00 00 00           \tmov eax, msg",
+		"    12                                              \ttwo ebx",
+		"    12+ .text    00000005  53                       \tpush \\r",
+		"    12+ .text    00000006  5b                       \tpop \\r",
+		"    13  .text    00000007  e8 fc ff ff ff           \tcall exit",
+		"  _start                   TEXT   .text    0000000000000000 global",
+		"  exit                     EXTERN          0000000000000000",
+		"  .text    00000001 R_386_32         .data                    0",
+		"  .text    00000008 R_386_PC32       exit                     0",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("列表文件中缺少: %q\n%s", line, out)
+		}
+	}
+}
+
+func TestListingATT(t *testing.T) {
+	src := `	.type f, @function
+f:	jmp 1f
+1:	ret
+loop:	ret
+	.size f, .-f
+	.bss
+buf:	.zero 16
+	.lcomm x, 16
+`
+	p := assemble(t, "amd64", SyntaxATT, src)
+	var b strings.Builder
+	if err := p.Listing(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, line := range []string{
+		"     7  .bss     00000000  <16 字节>                buf:\t.zero 16",
+		"     8  .bss     00000010  <16 字节>                \t.lcomm x, 16",
+		"  loop                     TEXT   .text    0000000000000003",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("列表文件中缺少: %q\n%s", line, out)
+		}
+	}
+	if n := strings.Count(out, "     8 "); n != 1 {
+		t.Errorf(".lcomm 显示了 %d 行\n%s", n, out)
+	}
+	if strings.Contains(out, ".Lf$") {
+		t.Errorf("符号表中出现了内部名称\n%s", out)
+	}
+}
@@ -0,0 +1,515 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/facelang/face/internal/prog"
+	"github.com/facelang/face/internal/reader"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 宏及条件汇编预处理, 在语法解析之前处理行首的以下伪指令, 对所有语法风格有效:
+//
+//	.macro name [p1[=默认值], p2:req, p3:vararg]   宏定义, 到 .endm 结束; 宏体中 \p1 引用参数, \@ 为展开序号, \() 用于分隔
+//	.rept n                                       重复 n 次, 到 .endr 结束
+//	.irp x, a, b, c                               依次使用 a, b, c 替换 \x, 到 .endr 结束
+//	.irpc x, abc                                  依次使用字符 a, b, c 替换 \x, 到 .endr 结束
+//	.if expr / .ifdef sym / .ifndef sym / .elseif expr / .else / .endif    expr 可以使用比较运算, 例如 .if \n > 2
+//	.set name, expr                               定义常量, 可以重新赋值, 表达式可以引用符号及尚未定义的符号
+//	.include "file"                               包含文件, 依次在当前文件所在目录及 -I 指定的目录中查找
+//
+// 宏展开后的文本重新进行词法分析, 行号与宏定义保持一致, 错误信息中同时给出展开位置
+
+// maxFrames 宏展开及文件包含的最大嵌套层数
+const maxFrames = 100
+
+// macroParam 宏参数
+type macroParam struct {
+	Name     string
+	Default  string // 默认值
+	Required bool   // :req 必须提供
+	Vararg   bool   // :vararg 接收剩余的全部参数
+}
+
+// macro .macro 定义的宏
+type macro struct {
+	Name   string
+	Params []macroParam
+	Body   []byte       // 宏体源码
+	Pos    prog.FilePos // 宏体起始位置
+}
+
+// frame 输入栈中的一层: 宏展开、重复块或包含的文件
+type frame struct {
+	prev   *lexer       // 展开结束后继续读取的源码
+	bodies [][]byte     // 尚未读取的展开文本, .rept/.irp 每次重复一段
+	pos    prog.FilePos // 展开文本的定义位置
+	desc   string       // 错误信息中的展开说明, 例如: 宏 push_all 展开于
+	use    prog.FilePos // 展开位置
+	file   bool         // 包含的文件, 调试信息中的行号使用文件中的位置
+}
+
+// cond 条件汇编状态
+type cond struct {
+	pos    prog.FilePos // .if 的位置
+	active bool         // 当前分支是否有效
+	taken  bool         // 是否已有分支有效
+	parent bool         // 外层是否有效
+	inElse bool         // 是否已经读取 .else
+}
+
+// active 当前是否处于有效的条件汇编分支中
+func (p *parser) active() bool {
+	return len(p.conds) == 0 || p.conds[len(p.conds)-1].active
+}
+
+// trace 宏展开及文件包含路径, 附加在错误信息之后
+func (p *parser) trace() string {
+	var b strings.Builder
+	for i := len(p.frames) - 1; i >= 0; i-- {
+		f := p.frames[i]
+		fmt.Fprintf(&b, "\n\t%s %s", f.desc, f.use.String())
+	}
+	return b.String()
+}
+
+// read 从输入栈读取下一个记号(跳过注释), 展开文本读完后返回上一层继续读取
+func (p *parser) read() Token {
+	for {
+		tok := p.lexer.NextToken()
+		for tok == COMMENT {
+			tok = p.lexer.NextToken()
+		}
+		if tok != EOF || len(p.frames) == 0 {
+			return tok
+		}
+		f := p.frames[len(p.frames)-1]
+		if len(f.bodies) > 0 {
+			p.lexer = p.newLexer(f.bodies[0], f.pos)
+			f.bodies = f.bodies[1:]
+		} else {
+			p.frames = p.frames[:len(p.frames)-1]
+			p.lexer = f.prev
+		}
+		p.id = ""
+		return NEWLINE // 每段展开文本都作为完整的语句结束
+	}
+}
+
+// newLexer 读取展开文本, 位置从 pos 开始计算
+func (p *parser) newLexer(src []byte, pos prog.FilePos) *lexer {
+	return &lexer{Reader: reader.PosReader(src, pos), syntax: p.syntax}
+}
+
+// push 开始读取展开文本
+func (p *parser) push(f *frame) {
+	if len(p.frames) >= maxFrames {
+		p.errorAt(f.use, "宏展开或文件包含嵌套过深")
+	}
+	f.prev = p.lexer
+	p.frames = append(p.frames, f)
+	if len(f.bodies) == 0 {
+		f.bodies = [][]byte{nil}
+	}
+	p.lexer = p.newLexer(f.bodies[0], f.pos)
+	f.bodies = f.bodies[1:]
+}
+
+// preprocess 处理行首的预处理伪指令及宏调用, 返回 false 表示不是预处理伪指令
+func (p *parser) preprocess() bool {
+	name, pos := p.id, p.pos
+	active := p.active()
+	switch name {
+	case ".if", ".ifdef", ".ifndef":
+		c := cond{pos: pos, parent: active}
+		if active {
+			p.bol = false
+			p.next()
+			if name == ".if" {
+				c.active = p.number() != 0
+			} else {
+				i, ok := p.labelNames[p.id]
+				defined := ok && p.labelList[i].Type != UNDEFINED_LABEL || p.macros[p.id] != nil
+				p.expect(IDENT)
+				c.active = defined == (name == ".ifdef")
+			}
+			if !p.atEOL() {
+				p.unexpect("newline")
+			}
+		} else {
+			p.skipLine()
+		}
+		c.taken = c.active
+		p.conds = append(p.conds, c)
+	case ".elseif":
+		c := p.topCond(name, pos)
+		if c.inElse {
+			p.errorAt(pos, ".elseif 不能出现在 .else 之后")
+		}
+		c.active = c.parent && !c.taken // 为 true 时 next 才返回条件表达式的记号
+		if c.active {
+			p.bol = false
+			p.next()
+			c.active = p.number() != 0
+			if !p.atEOL() {
+				p.unexpect("newline")
+			}
+		} else {
+			p.skipLine()
+		}
+		c.taken = c.taken || c.active
+	case ".else":
+		c := p.topCond(name, pos)
+		if c.inElse {
+			p.errorAt(pos, "重复的 .else")
+		}
+		c.inElse, c.active, c.taken = true, c.parent && !c.taken, true
+		p.endLine()
+	case ".endif":
+		p.topCond(name, pos)
+		p.conds = p.conds[:len(p.conds)-1]
+		p.endLine()
+	default:
+		if !active {
+			return false
+		}
+		switch name {
+		case ".macro":
+			p.defineMacro(pos)
+		case ".endm", ".endr":
+			p.errorAt(pos, fmt.Sprintf("%s 没有对应的开始伪指令", name))
+		case ".rept":
+			p.rept(pos)
+		case ".irp", ".irpc":
+			p.irp(name, pos)
+		case ".set":
+			p.set()
+		case ".include":
+			p.include(pos)
+		default:
+			m := p.macros[name]
+			if m == nil {
+				return false
+			}
+			p.expand(m, pos)
+		}
+	}
+	p.bol = true
+	return true
+}
+
+// topCond 最内层的条件汇编状态
+func (p *parser) topCond(name string, pos prog.FilePos) *cond {
+	if len(p.conds) == 0 {
+		p.errorAt(pos, fmt.Sprintf("%s 没有对应的 .if", name))
+	}
+	return &p.conds[len(p.conds)-1]
+}
+
+// skipLine 跳过当前行的剩余部分
+func (p *parser) skipLine() {
+	for tok := p.lexer.NextToken(); tok != NEWLINE && tok != SEMI && tok != EOF; tok = p.lexer.NextToken() {
+	}
+}
+
+// endLine 当前行必须已经结束
+func (p *parser) endLine() {
+	if tok := p.read(); tok != NEWLINE && tok != SEMI && tok != EOF {
+		p.token = tok
+		p.unexpect("newline")
+	}
+}
+
+// restText 读取当前行剩余部分的源码(不含注释), 返回文本以及行结束记号的位置
+func (p *parser) restText() (string, Token, prog.FilePos) {
+	src := p.lexer.Source()
+	start := p.pos.Offset + len(p.id)
+	end := -1
+	for {
+		tok := p.lexer.NextToken()
+		if tok == COMMENT && end < 0 {
+			end = p.pos.Offset
+		}
+		if tok == NEWLINE || tok == SEMI || tok == EOF {
+			if end < 0 {
+				end = p.pos.Offset
+			}
+			if end > len(src) {
+				end = len(src)
+			}
+			return strings.TrimSpace(string(src[start:end])), tok, p.pos
+		}
+	}
+}
+
+// block 读取块内容直到匹配的结束伪指令 end, begin 为可以嵌套的开始伪指令; term 为开始伪指令所在行的结束位置
+func (p *parser) block(begin []string, end string, tok Token, term, pos prog.FilePos) ([]byte, prog.FilePos) {
+	if tok == EOF {
+		p.errorAt(pos, fmt.Sprintf("缺少 %s", end))
+	}
+	from := term
+	from.Offset++
+	if tok == NEWLINE {
+		from.Line, from.Col = from.Line+1, 0
+	} else {
+		from.Col++
+	}
+
+	src, depth, bol := p.lexer.Source(), 1, true
+	for {
+		tok := p.lexer.NextToken()
+		switch {
+		case tok == EOF:
+			p.errorAt(pos, fmt.Sprintf("缺少 %s", end))
+		case bol && tok == IDENT && p.id == end:
+			if depth--; depth == 0 {
+				body := src[from.Offset:p.pos.Offset]
+				p.endLine()
+				return body, from
+			}
+		case bol && tok == IDENT:
+			for _, b := range begin {
+				if p.id == b {
+					depth++
+				}
+			}
+		}
+		bol = tok == NEWLINE || tok == SEMI
+	}
+}
+
+// defineMacro .macro name [param, ...] ... .endm
+func (p *parser) defineMacro(pos prog.FilePos) {
+	if tok := p.read(); tok != IDENT && !tok.IsInstr() {
+		p.token = tok
+		p.unexpect("macro name")
+	}
+	m := &macro{Name: p.id}
+	text, tok, term := p.restText()
+	for _, s := range splitArgs(text) {
+		param := macroParam{Name: s}
+		if i := strings.IndexByte(s, '='); i >= 0 {
+			param.Name, param.Default = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+		}
+		if i := strings.IndexByte(param.Name, ':'); i >= 0 {
+			switch strings.TrimSpace(param.Name[i+1:]) {
+			case "req":
+				param.Required = true
+			case "vararg":
+				param.Vararg = true
+			default:
+				p.errorAt(pos, fmt.Sprintf("宏 %s: 无效的参数修饰 %s", m.Name, param.Name[i:]))
+			}
+			param.Name = strings.TrimSpace(param.Name[:i])
+		}
+		if param.Name == "" {
+			p.errorAt(pos, fmt.Sprintf("宏 %s: 参数名称不能为空", m.Name))
+		}
+		m.Params = append(m.Params, param)
+	}
+	m.Body, m.Pos = p.block([]string{".macro"}, ".endm", tok, term, pos)
+	if p.macros[m.Name] != nil {
+		p.errorAt(pos, fmt.Sprintf("宏 %s 重复定义", m.Name))
+	}
+	p.macros[m.Name] = m
+}
+
+// expand 展开宏调用: name arg, name=arg ...
+func (p *parser) expand(m *macro, pos prog.FilePos) {
+	text, _, _ := p.restText()
+	values := make(map[string]string)
+	set := make(map[string]bool)
+	args := splitArgs(text)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if k := strings.IndexByte(arg, '='); k > 0 && m.param(strings.TrimSpace(arg[:k])) != nil { // 按名称传参
+			name := strings.TrimSpace(arg[:k])
+			values[name], set[name] = strings.TrimSpace(arg[k+1:]), true
+			continue
+		}
+		if i >= len(m.Params) {
+			p.errorAt(pos, fmt.Sprintf("宏 %s: 参数过多", m.Name))
+		}
+		param := m.Params[i]
+		if param.Vararg {
+			values[param.Name], set[param.Name] = strings.Join(args[i:], ", "), true
+			break
+		}
+		values[param.Name], set[param.Name] = arg, arg != ""
+	}
+	for _, param := range m.Params {
+		if !set[param.Name] {
+			if param.Required {
+				p.errorAt(pos, fmt.Sprintf("宏 %s: 缺少参数 %s", m.Name, param.Name))
+			}
+			values[param.Name] = param.Default
+		}
+	}
+
+	body := substitute(m.Body, values, p.expansions) // 与 GAS 一致, \@ 从 0 开始
+	p.expansions++
+	p.push(&frame{bodies: [][]byte{body}, pos: m.Pos, desc: "宏 " + m.Name + " 展开于", use: pos})
+}
+
+// param 按名称查找宏参数
+func (m *macro) param(name string) *macroParam {
+	for i := range m.Params {
+		if m.Params[i].Name == name {
+			return &m.Params[i]
+		}
+	}
+	return nil
+}
+
+// rept .rept n ... .endr
+func (p *parser) rept(pos prog.FilePos) {
+	p.bol = false
+	p.next()
+	n := p.number()
+	if !p.atEOL() {
+		p.unexpect("newline")
+	}
+	if n < 0 {
+		p.errorAt(pos, fmt.Sprintf("重复次数不能为负数: %d", n))
+	}
+	body, from := p.block([]string{".rept", ".irp", ".irpc"}, ".endr", p.token, p.pos, pos)
+	bodies := make([][]byte, n)
+	for i := range bodies {
+		bodies[i] = body
+	}
+	if n > 0 {
+		p.push(&frame{bodies: bodies, pos: from, desc: ".rept 展开于", use: pos})
+	}
+}
+
+// irp .irp x, a, b ... .endr 或 .irpc x, abc ... .endr
+func (p *parser) irp(name string, pos prog.FilePos) {
+	text, tok, term := p.restText()
+	args := splitArgs(text)
+	if len(args) == 0 || args[0] == "" {
+		p.errorAt(pos, fmt.Sprintf("%s: 缺少参数名称", name))
+	}
+	param, values := args[0], args[1:]
+	if name == ".irpc" {
+		values = nil
+		for _, arg := range args[1:] {
+			for _, ch := range arg {
+				values = append(values, string(ch))
+			}
+		}
+	}
+	body, from := p.block([]string{".rept", ".irp", ".irpc"}, ".endr", tok, term, pos)
+	var bodies [][]byte
+	for _, v := range values {
+		bodies = append(bodies, substitute(body, map[string]string{param: v}, p.expansions))
+	}
+	if len(bodies) > 0 {
+		p.push(&frame{bodies: bodies, pos: from, desc: name + " 展开于", use: pos})
+	}
+}
+
+// set .set name, expr 定义常量, 与 equ 不同, 可以重新赋值.
+// 表达式引用尚未定义的符号时推迟计算, 引用处使用当时的表达式, 之后重新赋值不影响已有的引用
+func (p *parser) set() {
+	p.bol = false
+	p.next()
+	pos, name := p.pos, p.ident()
+	p.expect(COMMA)
+	x := p.expr()
+	if !p.atEOL() {
+		p.unexpect("newline")
+	}
+	if i, ok := p.labelNames[name]; ok {
+		lb := p.labelList[i]
+		if lb.Type != UNDEFINED_LABEL && !(lb.Type == EQU_LABEL && lb.Redef) {
+			p.errorAt(pos, fmt.Sprintf("符号 %s 已经定义, 不能使用 .set 赋值", name))
+		}
+	}
+	p.equ(name, x, pos, true)
+}
+
+// include .include "file"
+func (p *parser) include(pos prog.FilePos) {
+	p.bol = false
+	p.next()
+	name := p.id
+	p.expect(STRING)
+	if !p.atEOL() {
+		p.unexpect("newline")
+	}
+
+	dirs := append([]string{filepath.Dir(pos.Filename)}, p.includes...)
+	if filepath.IsAbs(name) {
+		dirs = []string{""}
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		p.sources[path] = data
+		p.push(&frame{bodies: [][]byte{data}, pos: prog.FilePos{Filename: path}, desc: "文件 " + path + " 包含于", use: pos, file: true})
+		return
+	}
+	p.errorAt(pos, fmt.Sprintf("找不到包含文件: %s", name))
+}
+
+// splitArgs 按顶层的逗号拆分参数, 括号及字符串中的逗号不拆分
+func splitArgs(text string) []string {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	var args []string
+	depth, quote, start := 0, false, 0
+	for i := 0; i < len(text); i++ {
+		switch ch := text[i]; {
+		case quote && ch == '\\':
+			i++
+		case ch == '"':
+			quote = !quote
+		case quote:
+		case ch == '(' || ch == '[':
+			depth++
+		case ch == ')' || ch == ']':
+			depth--
+		case ch == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	return append(args, strings.TrimSpace(text[start:]))
+}
+
+// substitute 替换宏体中的参数引用: \name 替换为参数值, \@ 替换为展开序号 n, \() 删除
+func substitute(body []byte, values map[string]string, n int) []byte {
+	var out []byte
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' || i+1 == len(body) {
+			out = append(out, body[i])
+			continue
+		}
+		if body[i+1] == '@' {
+			out = append(out, strconv.Itoa(n)...)
+			i++
+			continue
+		}
+		if i+2 < len(body) && body[i+1] == '(' && body[i+2] == ')' {
+			i += 2
+			continue
+		}
+		j := i + 1
+		for j < len(body) && (body[j] == '_' || 'a' <= body[j]|0x20 && body[j]|0x20 <= 'z' || '0' <= body[j] && body[j] <= '9') {
+			j++
+		}
+		if v, ok := values[string(body[i+1:j])]; ok {
+			out = append(out, v...)
+			i = j - 1
+			continue
+		}
+		out = append(out, body[i])
+	}
+	return out
+}
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMacro(t *testing.T) {
+	src := `
+.macro push2 a, b=ebx
+	push \a
+	push \b
+.endm
+.macro load reg:req, val=0
+	mov \reg, \val
+.endm
+.macro data name, vals:vararg
+\name\()_\@: db \vals
+.endm
+
+	push2 eax
+	push2 ecx, edx
+	load eax
+	load val=7, reg=ecx
+	data tbl, 1, 2, 3
+`
+	p := assemble(t, "386", SyntaxIntel, src)
+	want := []byte{
+		0x50, 0x53, // push eax; push ebx
+		0x51, 0x52, // push ecx; push edx
+		0xB8, 0x00, 0x00, 0x00, 0x00, // mov eax, 0
+		0xB9, 0x07, 0x00, 0x00, 0x00, // mov ecx, 7
+		1, 2, 3,
+	}
+	if got := p.secList[0].Data; !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+	if lb := p.GetLabel("tbl_4"); !lb.defined() || lb.Addr != 14 { // 与 GAS 一致, \@ 从 0 开始, 第 5 次展开为 4
+		t.Errorf("tbl_4: %+v", *lb)
+	}
+}
+
+func TestMacroRepeat(t *testing.T) {
+	src := `
+.set n, 0
+.rept 3
+	db n
+	.set n, n + 1
+.endr
+.irp r, eax, ecx
+	push \r
+.endr
+.irpc c, 12
+	db \c
+.endr
+.rept 2
+.rept 2
+	ret
+.endr
+.endr
+`
+	p := assemble(t, "386", SyntaxIntel, src)
+	want := []byte{0, 1, 2, 0x50, 0x51, 1, 2, 0xC3, 0xC3, 0xC3, 0xC3}
+	if got := p.secList[0].Data; !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+}
+
+func TestMacroCond(t *testing.T) {
+	src := `
+debug equ 1
+.if debug
+	db 1
+.else
+	db 2
+.endif
+.if debug - 1
+	db 3
+	.if 1
+	db 4
+	.endif
+.else
+	db 5
+.endif
+.ifdef debug
+	db 6
+.endif
+.ifndef missing
+	db 7
+.endif
+.ifdef missing
+	.unknown directive is skipped
+.endif
+.macro sel n
+.if \n > 2
+	db 8
+.elseif \n == 2
+	db 9
+.elseif \n != 0 && \n >= 1
+	db 10
+.else
+	db 11
+.endif
+.endm
+	sel 3
+	sel 2
+	sel 1
+	sel 0
+.if 0
+.elseif 0
+	.unknown directive is skipped
+.elseif 1
+	db 12
+.elseif 1
+	db 13
+.endif
+`
+	p := assemble(t, "386", SyntaxIntel, src)
+	want := []byte{1, 5, 6, 7, 8, 9, 10, 11, 12}
+	if got := p.secList[0].Data; !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+}
+
+func TestMacroSet(t *testing.T) {
+	// .set 的表达式可以引用符号及尚未定义的符号, 重新赋值不影响之前的引用(与 GAS 一致)
+	src := `
+	.set base, start + 2
+	.set size, end - start
+	.long size, base
+	.set size, 3
+	.set size, size * 2
+	.long size
+start:	.byte 1, 2, 3
+end:
+	.byte 1 < 2, 2 == 3
+`
+	p := assemble(t, "amd64", SyntaxATT, src)
+	want := []byte{3, 0, 0, 0, 0, 0, 0, 0, 6, 0, 0, 0, 1, 2, 3, 0xFF, 0}
+	if got := p.secList[0].Data; !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+	if len(p.relocateList) != 1 || p.relocateList[0].Offset != 4 || p.relocateList[0].Addend != 14 {
+		t.Errorf("base 的重定位: %+v", p.relocateList)
+	}
+}
+
+func TestMacroInclude(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "defs.inc"), []byte(".macro two\n\tdb 2\n.endm\nthree equ 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := NewParser(NewBytesLexer([]byte(".include \"defs.inc\"\n\tdb 1\n\ttwo\n\tdb three\n")))
+	p.includes = []string{dir}
+	if err := p.ParseFile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Codegen(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.secList[0].Data, []byte{1, 2, 3}; !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+}
+
+func TestMacroError(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string // 错误信息需要包含的内容
+	}{
+		{".macro m\n\tret\n", []string{"缺少 .endm"}},
+		{".endif", []string{".endif 没有对应的 .if"}},
+		{".if 1\n\tret\n", []string{".if 缺少对应的 .endif"}},
+		{".macro m a:req\n.endm\n\tm", []string{"缺少参数 a"}},
+		{".macro m a\n.endm\n\tm 1, 2", []string{"参数过多"}},
+		{".macro m\n\tm\n.endm\n\tm", []string{"嵌套过深"}},
+		{".include \"missing.inc\"", []string{"找不到包含文件"}},
+		{"x equ 1\n.set x, 2", []string{"已经定义"}},
+		{".if 1\n.else\n.elseif 1\n.endif", []string{".elseif 不能出现在 .else 之后"}},
+		{".elseif 1", []string{".elseif 没有对应的 .if"}},
+		// 错误同时指向宏定义中的行及宏展开位置
+		{".macro m\n\tret\n\tbad eax\n.endm\n\n\tm", []string{"行: 3,", "宏 m 展开于 行: 6,"}},
+		{".macro m\n\tjmp 5 * foo\n.endm\n\tm", []string{"行: 2,", "宏 m 展开于 行: 4,"}},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(NewBytesLexer([]byte(tt.src)))
+		err := p.ParseFile()
+		if err == nil {
+			err = p.Codegen()
+		}
+		if err == nil {
+			t.Errorf("%q: 期望错误", tt.src)
+			continue
+		}
+		for _, s := range tt.want {
+			if !strings.Contains(err.Error(), s) {
+				t.Errorf("%q: 错误信息 %q 中缺少 %q", tt.src, err.Error(), s)
+			}
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package internal
+
+import "github.com/facelang/face/internal/os/elf"
+
+// strtab 字符串表, 第一个字节固定为 0(空字符串)
+type strtab struct {
+	data []byte
+}
+
+func newStrtab() *strtab {
+	return &strtab{data: []byte{0}}
+}
+
+// add 添加字符串, 返回其在表中的偏移
+func (t *strtab) add(s string) uint32 {
+	if s == "" {
+		return 0
+	}
+	off := len(t.data)
+	t.data = append(t.data, s...)
+	t.data = append(t.data, 0)
+	return uint32(off)
+}
+
+// align 尾部补 0, 保证表存放于文件 offset 处时, 后续数据按 n 字节对齐
+func (t *strtab) align(offset, n int) []byte {
+	for (offset+len(t.data))%n != 0 {
+		t.data = append(t.data, 0)
+	}
+	return t.data
+}
+
+func alignUp(n, align int) int {
+	return (n + align - 1) / align * align
+}
+
+// symbols 导出到符号表的符号, 局部符号在前, 全局及未定义符号在后; EQU 定义的符号及 .L 开头的局部标签不导出,
+// 函数内的标签以原名称导出为局部符号
+func (p *parser) symbols() (locals, globals []*label) {
+	for _, lb := range p.labelList {
+		if lb.Type == EQU_LABEL || lb.defined() && !lb.Global && !lb.Keep && lb.Orig == "" && isLocal(lb.Name) || p.moved(lb) {
+			continue
+		}
+		if lb.Global || !lb.defined() {
+			globals = append(globals, lb)
+			continue
+		}
+		locals = append(locals, lb)
+	}
+	return locals, globals
+}
+
+// Object 生成 ELF 可重定位目标文件, 32 位目标为 ELF32 及 REL 格式的重定位, 64 位目标为 ELF64 及 RELA 格式的重定位;
+// 文件布局与 elf.FileWrite 的输出顺序一致:
+//
+//	ELF 头 | 各段数据 | .shstrtab | 段表 | .symtab | .strtab | .rel.* 或 .rela.*
+func (p *parser) Object() *elf.File {
+	is64 := p.bits == 64
+	var magic elf.Elf_Magic
+	copy(magic[:], elf.ELFMAG)
+	magic[elf.EI_CLASS] = byte(elf.ELFCLASS32)
+	if is64 {
+		magic[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	}
+	magic[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	magic[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	machine, flags := elf.EM_386, uint32(0)
+	if is64 {
+		machine = elf.EM_X86_64
+	}
+	if p.arch != nil {
+		machine, flags = p.arch.Machine, p.arch.Flags
+	}
+	file := elf.NewElfFile(magic, elf.Elf32_Half(elf.ET_REL), elf.Elf32_Half(machine))
+	file.Ehdr.Flags = flags
+
+	// 32 位文件中的数据按 4 字节对齐; 64 位文件中段数据按段的对齐要求, 字符串表之后的表按 8 字节对齐
+	tabAlign, relPrefix := 4, ".rel"
+	if is64 {
+		tabAlign, relPrefix = 8, ".rela"
+	}
+
+	shstrtab, strtab := newStrtab(), newStrtab()
+	offset := int(file.Ehdr.Ehsize)
+
+	// 数据段
+	for _, sec := range p.secList {
+		align := max(1, sec.Align) // 与 GAS 一致, 没有对齐要求的段按 1 字节对齐
+		off := alignUp(offset, 4)  // 可重定位文件中段数据的文件偏移不需要满足段的对齐要求
+		if is64 {
+			off = alignUp(offset, align)
+		}
+		shdr := elf.NewShdr(sec.Type, sec.Flags, off, sec.Length)
+		shdr.Name, shdr.Addralign = shstrtab.add(sec.Name), uint64(align)
+		shdr.Entsize = uint64(sec.Entsize)
+		file.AddShdr(sec.Name, shdr)
+		if sec.nobits() { // .bss 不占用文件空间, 也不需要填充对齐
+			continue
+		}
+		file.ProgSegList = append(file.ProgSegList, &elf.ProgSeg{
+			Name:   sec.Name,
+			Offset: uint64(off),
+			Size:   uint64(sec.Length),
+			Blocks: []*elf.Block{{Data: sec.Data, Size: uint64(sec.Length)}},
+		})
+		offset = off + sec.Length
+	}
+
+	// 符号表: 空符号、段符号、局部符号在前，全局符号在后
+	for _, sec := range p.secList {
+		file.AddSym(sec.Name, &elf.Elf_Sym{
+			Info:  elf.ST_INFO(elf.STB_LOCAL, elf.STT_SECTION),
+			Shndx: uint16(file.GetSegIndex(sec.Name)),
+		})
+	}
+	if p.srcName != "" { // .file 指定的源文件名
+		file.AddSym(p.srcName, &elf.Elf_Sym{
+			Name:  strtab.add(p.srcName),
+			Info:  elf.ST_INFO(elf.STB_LOCAL, elf.STT_FILE),
+			Shndx: uint16(elf.SHN_ABS),
+		})
+	}
+	locals, globals := p.symbols()
+	firstGlobal := len(file.SymNames) + len(locals)
+	for _, lb := range append(locals, globals...) {
+		sym := &elf.Elf_Sym{Name: strtab.add(lb.symName()), Size: uint64(lb.Size)}
+		sym.Info, sym.Other = lb.symInfo()
+		switch {
+		case lb.defined():
+			sym.Value = uint64(lb.Addr)
+			sym.Shndx = uint16(file.GetSegIndex(lb.Section))
+		case lb.Type == COMMON_LABEL: // 公共符号的值为对齐要求
+			sym.Value = uint64(lb.Addr)
+			sym.Shndx = uint16(elf.SHN_COMMON)
+		}
+		file.AddSym(lb.Name, sym)
+	}
+
+	// 重定位表, 按段分组, 与段表顺序一致; 32 位目标的加数已经写入回填位置
+	var relSecs []*section
+	relCount := make(map[string]int)
+	for _, sec := range p.secList {
+		for _, rel := range p.relocateList {
+			if rel.Section != sec.Name {
+				continue
+			}
+			if relCount[sec.Name] == 0 {
+				relSecs = append(relSecs, sec)
+			}
+			relCount[sec.Name]++
+			info := &elf.Elf_RelInfo{
+				SegName: sec.Name,
+				Rel: &elf.Elf_Rel{
+					Offset: uint64(rel.Offset),
+					Sym:    uint32(file.GetSymIndex(rel.Label)),
+					Type:   uint32(rel.Type),
+				},
+				RelName: rel.Label,
+				Rela:    is64,
+			}
+			if is64 {
+				info.Rel.Addend = rel.Addend
+			}
+			file.AddRel(info)
+		}
+	}
+
+	// 辅助段: 段名需要先全部写入 .shstrtab
+	shstrtabName, symtabName, strtabName := shstrtab.add(".shstrtab"), shstrtab.add(".symtab"), shstrtab.add(".strtab")
+	relNames := make([]uint32, len(relSecs))
+	for i, sec := range relSecs {
+		relNames[i] = shstrtab.add(relPrefix + sec.Name)
+	}
+	shnum := len(file.ShdrNames) + 3 + len(relSecs)
+	file.Shstrtab = shstrtab.align(offset, tabAlign)
+	file.ShstrtabSize = len(file.Shstrtab)
+
+	shdr := elf.NewShdr(elf.SHT_STRTAB, 0, offset, file.ShstrtabSize)
+	shdr.Name, shdr.Addralign = shstrtabName, 1
+	file.AddShdr(".shstrtab", shdr)
+	offset += file.ShstrtabSize
+
+	file.Ehdr.Shoff = uint64(offset)
+	file.Ehdr.Shnum = uint16(shnum)
+	file.Ehdr.Shstrndx = uint16(file.GetSegIndex(".shstrtab"))
+	offset += shnum * int(file.Ehdr.Shentsize)
+
+	symtabSize := len(file.SymNames) * file.SymSize()
+	shdr = elf.NewShdr(elf.SHT_SYMTAB, 0, offset, symtabSize)
+	shdr.Name, shdr.Entsize, shdr.Addralign = symtabName, uint64(file.SymSize()), uint64(tabAlign)
+	shdr.Link, shdr.Info = uint32(shnum-len(relSecs)-1), uint32(firstGlobal) // 链接 .strtab, 第一个全局符号
+	file.AddShdr(".symtab", shdr)
+	offset += symtabSize
+
+	file.Strtab = strtab.align(offset, tabAlign)
+	file.StrtabSize = len(file.Strtab)
+	shdr = elf.NewShdr(elf.SHT_STRTAB, 0, offset, file.StrtabSize)
+	shdr.Name, shdr.Addralign = strtabName, 1
+	file.AddShdr(".strtab", shdr)
+	offset += file.StrtabSize
+
+	symtabIndex := file.GetSegIndex(".symtab")
+	relType, relSize := elf.SHT_REL, file.RelSize(is64)
+	if is64 {
+		relType = elf.SHT_RELA
+	}
+	for i, sec := range relSecs {
+		size := relCount[sec.Name] * relSize
+		shdr = elf.NewShdr(relType, elf.SHF_INFO_LINK, offset, size)
+		shdr.Name, shdr.Entsize, shdr.Addralign = relNames[i], uint64(relSize), uint64(tabAlign)
+		shdr.Link, shdr.Info = uint32(symtabIndex), uint32(file.GetSegIndex(sec.Name))
+		file.AddShdr(relPrefix+sec.Name, shdr)
+		offset += size
+	}
+
+	return file
+}
@@ -1,45 +1,45 @@
 package internal
 
-// 操作数类型
-const (
-	REGISTER  = iota // 寄存器
-	IMMEDIATE        // 立即数
-	MEMORY          // 内存引用
-	SYMBOL          // 符号/标签
-)
-
-type ExpType byte
-
-const EXP_ADD ExpType = 1
-const EXP_SUB ExpType = 1
-const EXP_ADD ExpType = 1
-const EXP_ADD ExpType = 1
-
 type OprType byte
 
 const OPRTP_IMM OprType = 1
 const OPRTP_REG OprType = 2
 const OPRTP_MEM OprType = 3 // 地址类型，需要寻址
 const OPRTP_REL OprType = 4 // 符号类型，需要重定位
+const OPRTP_STR OprType = 5 // 字符串常量，仅用于数据定义
 
 type Operand interface {
 	operand()
 }
 
-type Express interface {
-}
-
 // operand 表示一个操作数
+//
+//	mov eax, [ebx + esi*4 + msg + 8]
+//	    Reg   Base  Index Scale Label Value
 type operand struct {
-	Type  int    // 操作数类型
-	Value string // 操作数值
-	Base  string // 基址寄存器(用于内存引用)
-	Index string // 变址寄存器(用于内存引用)
-	Scale int    // 比例因子(用于内存引用)
+	Type  OprType // 操作数类型
+	Reg   Token   // 寄存器(OPRTP_REG)
+	Base  Token   // 基址寄存器(用于内存引用), 0 表示没有
+	Index Token   // 变址寄存器(用于内存引用), 0 表示没有
+	Scale int     // 比例因子(用于内存引用)
+	Value int64   // 立即数或地址偏移
+	Label string  // 引用的符号, 最终值为 符号地址 + Value
+	Expr  Express // 第一遍扫描无法计算的表达式(引用后定义的符号、当前位置等), 第二遍扫描时计算
+	Size  int     // 操作数宽度(字节), 0 表示未指定
+	Seg   Token   // 段超越前缀(用于内存引用), 0 表示没有, 例如 fs:[0]
+	Text  string  // 字符串内容(OPRTP_STR)
+}
+
+func (o *operand) operand() {}
+
+// IsConst 不依赖符号的立即数
+func (o *operand) IsConst() bool {
+	return o.Type == OPRTP_IMM && !o.Symbolic()
 }
 
-type ExpOpr struct {
-	ExpList []Express
+// Symbolic 操作数的值依赖符号或表达式, 编码时需要回填
+func (o *operand) Symbolic() bool {
+	return o.Label != "" || o.Expr != nil
 }
 
 type GenOpr struct {
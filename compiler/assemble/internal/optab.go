@@ -0,0 +1,393 @@
+package internal
+
+// argType 指令格式中的操作数类型, 用于匹配指令编码形式
+type argType uint8
+
+const (
+	argNone  argType = iota // 无操作数
+	argReg                  // 通用寄存器
+	argAcc                  // 累加器 al/eax
+	argRM                   // 寄存器或内存 r/m
+	argMem                  // 内存
+	argMoffs                // 仅有偏移量的内存(无基址、变址), 用于 mov 的 moffs 形式
+	argImm                  // 立即数, 宽度与操作数一致(最大 4 字节)
+	argImm8                 // 可符号扩展的 8 位立即数
+	argImmB                 // 8 位立即数(不扩展)
+	argImmW                 // 16 位立即数
+	argRel                  // 相对跳转目标 rel32
+	argRel8                 // 相对跳转目标 rel8, 仅用于分支优化选中的跳转
+	argThree                // 立即数 3 (int 3)
+	argImm64                // 64 位立即数, 仅用于 mov r64, imm64
+	argXmm                  // xmm 寄存器
+	argXmmM                 // xmm 寄存器或内存
+	argOne                  // 立即数 1 (移位一次)
+	argCL                   // 寄存器 cl (移位次数)
+	argRM8                  // 8 位寄存器或内存, 扩展传送的源操作数
+	argRM16                 // 16 位寄存器或内存
+	argRM32                 // 32 位寄存器或内存
+)
+
+// 操作数宽度约束
+const (
+	szAny  uint8 = iota // 与宽度无关
+	szByte              // 字节操作
+	szFull              // 字/双字操作, 16 位时需要 0x66 前缀
+	szQuad              // 仅 64 位操作数(movq r64, xmm)
+)
+
+// 编码方式
+const (
+	encOp    uint8 = iota // 仅操作码
+	encModRM              // 操作码 + ModRM (/r 或 /digit)
+	encOpReg              // 寄存器编码于操作码低三位 (+r)
+)
+
+// 编码限制及标志
+const (
+	fNo64  uint8 = 1 << iota // 64 位模式下不可用(该操作码被重新定义)
+	fDef64                   // 64 位模式下默认 64 位操作数, 无需 REX.W, 不支持 32 位操作数
+	fNoQ                     // 不支持 64 位操作数
+	fPfx                     // Op[0] 为 SSE 指令的强制前缀(66/F2/F3), REX 前缀位于其后
+)
+
+// opForm 指令的一种编码形式, Intel 操作数顺序(目标在前)
+type opForm struct {
+	Args  [3]argType // 操作数类型, 只有三操作数的 imul 使用第三个
+	Width uint8      // 宽度约束
+	Enc   uint8      // 编码方式
+	Op    []byte     // 操作码
+	Ext   int8       // ModRM.reg 扩展码 /digit, -1 表示 /r
+	Flags uint8      // 编码限制及标志
+}
+
+// aluForms 生成 add/sub/cmp/and/or/xor 等算术逻辑指令的编码形式, base 为 r/m8, r8 形式的操作码
+func aluForms(base byte, ext int8) []opForm {
+	return []opForm{
+		{[3]argType{argRM, argImm8}, szFull, encModRM, []byte{0x83}, ext, 0},
+		{[3]argType{argAcc, argImm}, szByte, encOp, []byte{base + 4}, -1, 0},
+		{[3]argType{argAcc, argImm}, szFull, encOp, []byte{base + 5}, -1, 0},
+		{[3]argType{argRM, argImm}, szByte, encModRM, []byte{0x80}, ext, 0},
+		{[3]argType{argRM, argImm}, szFull, encModRM, []byte{0x81}, ext, 0},
+		{[3]argType{argRM, argReg}, szByte, encModRM, []byte{base}, -1, 0},
+		{[3]argType{argRM, argReg}, szFull, encModRM, []byte{base + 1}, -1, 0},
+		{[3]argType{argReg, argRM}, szByte, encModRM, []byte{base + 2}, -1, 0},
+		{[3]argType{argReg, argRM}, szFull, encModRM, []byte{base + 3}, -1, 0},
+	}
+}
+
+// unaryForms 生成 F6/F7 /digit 组的单操作数指令
+func unaryForms(op byte, ext int8) []opForm {
+	return []opForm{
+		{[3]argType{argRM}, szByte, encModRM, []byte{op}, ext, 0},
+		{[3]argType{argRM}, szFull, encModRM, []byte{op + 1}, ext, 0},
+	}
+}
+
+// jccForms 条件跳转, cc 为条件码
+func jccForms(cc byte) []opForm {
+	return []opForm{
+		{[3]argType{argRel8}, szAny, encOp, []byte{0x70 | cc}, -1, 0},
+		{[3]argType{argRel}, szAny, encOp, []byte{0x0F, 0x80 | cc}, -1, 0},
+	}
+}
+
+// shiftForms 移位指令, 移位次数为 1、cl 或 8 位立即数; AT&T 语法省略移位次数时移位一次
+func shiftForms(ext int8) []opForm {
+	return []opForm{
+		{[3]argType{argRM, argOne}, szByte, encModRM, []byte{0xD0}, ext, 0},
+		{[3]argType{argRM, argOne}, szFull, encModRM, []byte{0xD1}, ext, 0},
+		{[3]argType{argRM, argCL}, szByte, encModRM, []byte{0xD2}, ext, 0},
+		{[3]argType{argRM, argCL}, szFull, encModRM, []byte{0xD3}, ext, 0},
+		{[3]argType{argRM, argImmB}, szByte, encModRM, []byte{0xC0}, ext, 0},
+		{[3]argType{argRM, argImmB}, szFull, encModRM, []byte{0xC1}, ext, 0},
+		{[3]argType{argRM}, szByte, encModRM, []byte{0xD0}, ext, 0},
+		{[3]argType{argRM}, szFull, encModRM, []byte{0xD1}, ext, 0},
+	}
+}
+
+// extendForms 扩展传送指令 movzx/movsx, op 为 8 位源操作数形式的操作码, op+1 为 16 位源操作数
+func extendForms(op byte) []opForm {
+	return []opForm{
+		{[3]argType{argReg, argRM8}, szFull, encModRM, []byte{0x0F, op}, -1, 0},
+		{[3]argType{argReg, argRM16}, szFull, encModRM, []byte{0x0F, op + 1}, -1, 0},
+	}
+}
+
+// setForms 按条件设置字节, cc 为条件码
+func setForms(cc byte) []opForm {
+	return []opForm{{[3]argType{argRM}, szByte, encModRM, []byte{0x0F, 0x90 | cc}, 0, 0}}
+}
+
+// cmovForms 条件传送, cc 为条件码
+func cmovForms(cc byte) []opForm {
+	return []opForm{{[3]argType{argReg, argRM}, szFull, encModRM, []byte{0x0F, 0x40 | cc}, -1, 0}}
+}
+
+// stringForms 串操作指令, op 为字节操作的操作码, 字/双字/四字操作为 op+1
+func stringForms(op byte) []opForm {
+	return []opForm{
+		{[3]argType{}, szByte, encOp, []byte{op}, -1, 0},
+		{[3]argType{}, szFull, encOp, []byte{op + 1}, -1, 0},
+	}
+}
+
+// sseForms SSE 指令 xmm, xmm/m 形式, pfx 为强制前缀, 0 表示没有
+func sseForms(pfx, op byte) []opForm {
+	if pfx == 0 {
+		return []opForm{{[3]argType{argXmm, argXmmM}, szAny, encModRM, []byte{0x0F, op}, -1, 0}}
+	}
+	return []opForm{{[3]argType{argXmm, argXmmM}, szAny, encModRM, []byte{pfx, 0x0F, op}, -1, fPfx}}
+}
+
+// sseMoveForms SSE 传送指令: load 为 xmm, xmm/m 形式的操作码, load+1 为 xmm/m, xmm 形式
+func sseMoveForms(pfx, load byte) []opForm {
+	store := []byte{0x0F, load + 1}
+	flags := uint8(0)
+	if pfx != 0 {
+		store, flags = append([]byte{pfx}, store...), fPfx
+	}
+	return append(sseForms(pfx, load), opForm{[3]argType{argMem, argXmm}, szAny, encModRM, store, -1, flags})
+}
+
+// cvtForms 整数与浮点数之间的转换, 通用寄存器为 32 或 64 位(REX.W)
+func cvtForms(pfx, op byte, toFloat bool) []opForm {
+	if toFloat {
+		return []opForm{{[3]argType{argXmm, argRM}, szFull, encModRM, []byte{pfx, 0x0F, op}, -1, fPfx}}
+	}
+	return []opForm{{[3]argType{argReg, argXmmM}, szFull, encModRM, []byte{pfx, 0x0F, op}, -1, fPfx}}
+}
+
+// optab 指令编码表, 按顺序匹配, 第一个满足条件的形式即为最终编码
+var optab = map[Token][]opForm{
+	I_MOV: {
+		{[3]argType{argAcc, argMoffs}, szByte, encOp, []byte{0xA0}, -1, fNo64},
+		{[3]argType{argAcc, argMoffs}, szFull, encOp, []byte{0xA1}, -1, fNo64},
+		{[3]argType{argMoffs, argAcc}, szByte, encOp, []byte{0xA2}, -1, fNo64},
+		{[3]argType{argMoffs, argAcc}, szFull, encOp, []byte{0xA3}, -1, fNo64},
+		{[3]argType{argRM, argReg}, szByte, encModRM, []byte{0x88}, -1, 0},
+		{[3]argType{argRM, argReg}, szFull, encModRM, []byte{0x89}, -1, 0},
+		{[3]argType{argReg, argRM}, szByte, encModRM, []byte{0x8A}, -1, 0},
+		{[3]argType{argReg, argRM}, szFull, encModRM, []byte{0x8B}, -1, 0},
+		{[3]argType{argReg, argImm}, szByte, encOpReg, []byte{0xB0}, -1, 0},
+		{[3]argType{argReg, argImm}, szFull, encOpReg, []byte{0xB8}, -1, fNoQ},
+		{[3]argType{argRM, argImm}, szByte, encModRM, []byte{0xC6}, 0, 0},
+		{[3]argType{argRM, argImm}, szFull, encModRM, []byte{0xC7}, 0, 0},
+		{[3]argType{argReg, argImm64}, szFull, encOpReg, []byte{0xB8}, -1, 0},
+	},
+	I_ADD: aluForms(0x00, 0),
+	I_SUB: aluForms(0x28, 5),
+	I_CMP: aluForms(0x38, 7),
+	I_AND: aluForms(0x20, 4),
+	I_OR:  aluForms(0x08, 1),
+	I_XOR: aluForms(0x30, 6),
+	I_TEST: {
+		{[3]argType{argAcc, argImm}, szByte, encOp, []byte{0xA8}, -1, 0},
+		{[3]argType{argAcc, argImm}, szFull, encOp, []byte{0xA9}, -1, 0},
+		{[3]argType{argRM, argImm}, szByte, encModRM, []byte{0xF6}, 0, 0},
+		{[3]argType{argRM, argImm}, szFull, encModRM, []byte{0xF7}, 0, 0},
+		{[3]argType{argRM, argReg}, szByte, encModRM, []byte{0x84}, -1, 0},
+		{[3]argType{argRM, argReg}, szFull, encModRM, []byte{0x85}, -1, 0},
+		{[3]argType{argReg, argMem}, szByte, encModRM, []byte{0x84}, -1, 0}, // test 的两个操作数可以交换
+		{[3]argType{argReg, argMem}, szFull, encModRM, []byte{0x85}, -1, 0},
+	},
+	I_LEA: {
+		{[3]argType{argReg, argMem}, szFull, encModRM, []byte{0x8D}, -1, 0},
+	},
+	I_CALL: {
+		{[3]argType{argRel}, szAny, encOp, []byte{0xE8}, -1, 0},
+		{[3]argType{argRM}, szFull, encModRM, []byte{0xFF}, 2, fDef64},
+	},
+	I_JMP: {
+		{[3]argType{argRel8}, szAny, encOp, []byte{0xEB}, -1, 0},
+		{[3]argType{argRel}, szAny, encOp, []byte{0xE9}, -1, 0},
+		{[3]argType{argRM}, szFull, encModRM, []byte{0xFF}, 4, fDef64},
+	},
+	I_JO:  jccForms(0x0),
+	I_JNO: jccForms(0x1),
+	I_JB:  jccForms(0x2),
+	I_JAE: jccForms(0x3),
+	I_JE:  jccForms(0x4),
+	I_JNE: jccForms(0x5),
+	I_JNA: jccForms(0x6),
+	I_JA:  jccForms(0x7),
+	I_JS:  jccForms(0x8),
+	I_JNS: jccForms(0x9),
+	I_JP:  jccForms(0xA),
+	I_JNP: jccForms(0xB),
+	I_JL:  jccForms(0xC),
+	I_JGE: jccForms(0xD),
+	I_JLE: jccForms(0xE),
+	I_JG:  jccForms(0xF),
+	I_INT: {
+		{[3]argType{argThree}, szAny, encOp, []byte{0xCC}, -1, 0},
+		{[3]argType{argImmB}, szAny, encOp, []byte{0xCD}, -1, 0},
+	},
+	I_IMUL: {
+		{[3]argType{argRM}, szByte, encModRM, []byte{0xF6}, 5, 0},
+		{[3]argType{argRM}, szFull, encModRM, []byte{0xF7}, 5, 0},
+		{[3]argType{argReg, argImm8}, szFull, encModRM, []byte{0x6B}, -1, 0},
+		{[3]argType{argReg, argImm}, szFull, encModRM, []byte{0x69}, -1, 0},
+		{[3]argType{argReg, argRM}, szFull, encModRM, []byte{0x0F, 0xAF}, -1, 0},
+		{[3]argType{argReg, argRM, argImm8}, szFull, encModRM, []byte{0x6B}, -1, 0},
+		{[3]argType{argReg, argRM, argImm}, szFull, encModRM, []byte{0x69}, -1, 0},
+	},
+	I_IDIV: unaryForms(0xF6, 7),
+	I_NEG:  unaryForms(0xF6, 3),
+	I_INC: {
+		{[3]argType{argReg}, szFull, encOpReg, []byte{0x40}, -1, fNo64},
+		{[3]argType{argRM}, szByte, encModRM, []byte{0xFE}, 0, 0},
+		{[3]argType{argRM}, szFull, encModRM, []byte{0xFF}, 0, 0},
+	},
+	I_DEC: {
+		{[3]argType{argReg}, szFull, encOpReg, []byte{0x48}, -1, fNo64},
+		{[3]argType{argRM}, szByte, encModRM, []byte{0xFE}, 1, 0},
+		{[3]argType{argRM}, szFull, encModRM, []byte{0xFF}, 1, 0},
+	},
+	I_PUSH: {
+		{[3]argType{argReg}, szFull, encOpReg, []byte{0x50}, -1, fDef64},
+		{[3]argType{argImm8}, szAny, encOp, []byte{0x6A}, -1, 0},
+		{[3]argType{argImm}, szAny, encOp, []byte{0x68}, -1, 0},
+		{[3]argType{argRM}, szFull, encModRM, []byte{0xFF}, 6, fDef64},
+	},
+	I_POP: {
+		{[3]argType{argReg}, szFull, encOpReg, []byte{0x58}, -1, fDef64},
+		{[3]argType{argRM}, szFull, encModRM, []byte{0x8F}, 0, fDef64},
+	},
+	I_RET: {
+		{[3]argType{}, szAny, encOp, []byte{0xC3}, -1, 0},
+		{[3]argType{argImmW}, szAny, encOp, []byte{0xC2}, -1, 0},
+	},
+	I_NOP: {
+		{[3]argType{}, szAny, encOp, []byte{0x90}, -1, 0},
+		{[3]argType{argRM}, szFull, encModRM, []byte{0x0F, 0x1F}, 0, 0}, // 多字节 nop, 例如 nopl 0(%rax)
+	},
+	I_SYSCALL: {{[3]argType{}, szAny, encOp, []byte{0x0F, 0x05}, -1, 0}},
+	I_LEAVE:   {{[3]argType{}, szAny, encOp, []byte{0xC9}, -1, 0}},
+	I_CDQ:     {{[3]argType{}, szFull, encOp, []byte{0x99}, -1, 0}},
+	I_CQO:     {{[3]argType{}, szFull, encOp, []byte{0x99}, -1, 0}},
+	I_CDQE:    {{[3]argType{}, szFull, encOp, []byte{0x98}, -1, 0}},
+
+	I_SHL:   shiftForms(4),
+	I_SHR:   shiftForms(5),
+	I_SAR:   shiftForms(7),
+	I_MOVZX: extendForms(0xB6),
+	I_MOVSX: extendForms(0xBE),
+	I_MOVSXD: {
+		{[3]argType{argReg, argRM32}, szQuad, encModRM, []byte{0x63}, -1, 0},
+	},
+	I_SETO:   setForms(0x0),
+	I_SETNO:  setForms(0x1),
+	I_SETB:   setForms(0x2),
+	I_SETAE:  setForms(0x3),
+	I_SETE:   setForms(0x4),
+	I_SETNE:  setForms(0x5),
+	I_SETNA:  setForms(0x6),
+	I_SETA:   setForms(0x7),
+	I_SETS:   setForms(0x8),
+	I_SETNS:  setForms(0x9),
+	I_SETP:   setForms(0xA),
+	I_SETNP:  setForms(0xB),
+	I_SETL:   setForms(0xC),
+	I_SETGE:  setForms(0xD),
+	I_SETLE:  setForms(0xE),
+	I_SETG:   setForms(0xF),
+	I_CMOVO:  cmovForms(0x0),
+	I_CMOVNO: cmovForms(0x1),
+	I_CMOVB:  cmovForms(0x2),
+	I_CMOVAE: cmovForms(0x3),
+	I_CMOVE:  cmovForms(0x4),
+	I_CMOVNE: cmovForms(0x5),
+	I_CMOVNA: cmovForms(0x6),
+	I_CMOVA:  cmovForms(0x7),
+	I_CMOVS:  cmovForms(0x8),
+	I_CMOVNS: cmovForms(0x9),
+	I_CMOVP:  cmovForms(0xA),
+	I_CMOVNP: cmovForms(0xB),
+	I_CMOVL:  cmovForms(0xC),
+	I_CMOVGE: cmovForms(0xD),
+	I_CMOVLE: cmovForms(0xE),
+	I_CMOVG:  cmovForms(0xF),
+
+	I_MOVSB: stringForms(0xA4), I_MOVSW: stringForms(0xA4), I_MOVSQ: stringForms(0xA4),
+	I_MOVSD: append(stringForms(0xA4), sseMoveForms(0xF2, 0x10)...),
+	I_STOSB: stringForms(0xAA), I_STOSW: stringForms(0xAA), I_STOSD: stringForms(0xAA), I_STOSQ: stringForms(0xAA),
+	I_LODSB: stringForms(0xAC), I_LODSW: stringForms(0xAC), I_LODSD: stringForms(0xAC), I_LODSQ: stringForms(0xAC),
+	I_CMPSB: stringForms(0xA6), I_CMPSW: stringForms(0xA6), I_CMPSD: stringForms(0xA6), I_CMPSQ: stringForms(0xA6),
+	I_SCASB: stringForms(0xAE), I_SCASW: stringForms(0xAE), I_SCASD: stringForms(0xAE), I_SCASQ: stringForms(0xAE),
+
+	I_MOVSS:  sseMoveForms(0xF3, 0x10),
+	I_MOVUPS: sseMoveForms(0, 0x10),
+	I_MOVUPD: sseMoveForms(0x66, 0x10),
+	I_MOVAPS: sseMoveForms(0, 0x28),
+	I_MOVAPD: sseMoveForms(0x66, 0x28),
+	I_MOVD: {
+		{[3]argType{argXmm, argRM}, szFull, encModRM, []byte{0x66, 0x0F, 0x6E}, -1, fPfx | fNoQ},
+		{[3]argType{argRM, argXmm}, szFull, encModRM, []byte{0x66, 0x0F, 0x7E}, -1, fPfx | fNoQ},
+	},
+	I_MOVQ: {
+		{[3]argType{argXmm, argXmmM}, szAny, encModRM, []byte{0xF3, 0x0F, 0x7E}, -1, fPfx},
+		{[3]argType{argMem, argXmm}, szAny, encModRM, []byte{0x66, 0x0F, 0xD6}, -1, fPfx},
+		{[3]argType{argXmm, argReg}, szQuad, encModRM, []byte{0x66, 0x0F, 0x6E}, -1, fPfx},
+		{[3]argType{argReg, argXmm}, szQuad, encModRM, []byte{0x66, 0x0F, 0x7E}, -1, fPfx},
+	},
+
+	I_ADDSS: sseForms(0xF3, 0x58), I_ADDSD: sseForms(0xF2, 0x58), I_ADDPS: sseForms(0, 0x58), I_ADDPD: sseForms(0x66, 0x58),
+	I_SUBSS: sseForms(0xF3, 0x5C), I_SUBSD: sseForms(0xF2, 0x5C), I_SUBPS: sseForms(0, 0x5C), I_SUBPD: sseForms(0x66, 0x5C),
+	I_MULSS: sseForms(0xF3, 0x59), I_MULSD: sseForms(0xF2, 0x59), I_MULPS: sseForms(0, 0x59), I_MULPD: sseForms(0x66, 0x59),
+	I_DIVSS: sseForms(0xF3, 0x5E), I_DIVSD: sseForms(0xF2, 0x5E), I_DIVPS: sseForms(0, 0x5E), I_DIVPD: sseForms(0x66, 0x5E),
+	I_SQRTSS: sseForms(0xF3, 0x51), I_SQRTSD: sseForms(0xF2, 0x51), I_SQRTPS: sseForms(0, 0x51), I_SQRTPD: sseForms(0x66, 0x51),
+	I_MINSS: sseForms(0xF3, 0x5D), I_MINSD: sseForms(0xF2, 0x5D), I_MAXSS: sseForms(0xF3, 0x5F), I_MAXSD: sseForms(0xF2, 0x5F),
+	I_UCOMISS: sseForms(0, 0x2E), I_UCOMISD: sseForms(0x66, 0x2E), I_COMISS: sseForms(0, 0x2F), I_COMISD: sseForms(0x66, 0x2F),
+	I_CVTSS2SD: sseForms(0xF3, 0x5A), I_CVTSD2SS: sseForms(0xF2, 0x5A),
+	I_CVTSI2SS: cvtForms(0xF3, 0x2A, true), I_CVTSI2SD: cvtForms(0xF2, 0x2A, true),
+	I_CVTTSS2SI: cvtForms(0xF3, 0x2C, false), I_CVTTSD2SI: cvtForms(0xF2, 0x2C, false),
+	I_CVTSS2SI: cvtForms(0xF3, 0x2D, false), I_CVTSD2SI: cvtForms(0xF2, 0x2D, false),
+	I_ANDPS: sseForms(0, 0x54), I_ANDPD: sseForms(0x66, 0x54), I_ANDNPS: sseForms(0, 0x55), I_ANDNPD: sseForms(0x66, 0x55),
+	I_ORPS: sseForms(0, 0x56), I_ORPD: sseForms(0x66, 0x56), I_XORPS: sseForms(0, 0x57), I_XORPD: sseForms(0x66, 0x57),
+	I_PXOR: sseForms(0x66, 0xEF),
+
+	// 单独成行的前缀作为一条单字节指令, 写在指令之前时由 encodeInstr 检查并输出
+	I_LOCK:  {{[3]argType{}, szAny, encOp, []byte{0xF0}, -1, 0}},
+	I_REP:   {{[3]argType{}, szAny, encOp, []byte{0xF3}, -1, 0}},
+	I_REPE:  {{[3]argType{}, szAny, encOp, []byte{0xF3}, -1, 0}},
+	I_REPNE: {{[3]argType{}, szAny, encOp, []byte{0xF2}, -1, 0}},
+}
+
+// defaultSize 未显式指定宽度时, 以下指令默认按地址宽度处理(32 位模式双字, 64 位模式四字)
+var defaultSize = map[Token]bool{
+	I_CALL: true, I_JMP: true, I_PUSH: true, I_POP: true,
+	I_JE: true, I_JNE: true, I_JNA: true, I_JL: true, I_JGE: true, I_JLE: true, I_JG: true,
+	I_JO: true, I_JNO: true, I_JB: true, I_JAE: true, I_JA: true, I_JS: true, I_JNS: true, I_JP: true, I_JNP: true,
+	I_INT: true, I_RET: true,
+}
+
+// stringSize 串操作指令的操作数宽度, 由指令名称的后缀决定
+var stringSize = map[Token]int{
+	I_MOVSB: 1, I_MOVSW: 2, I_MOVSD: 4, I_MOVSQ: 8,
+	I_STOSB: 1, I_STOSW: 2, I_STOSD: 4, I_STOSQ: 8,
+	I_LODSB: 1, I_LODSW: 2, I_LODSD: 4, I_LODSQ: 8,
+	I_CMPSB: 1, I_CMPSW: 2, I_CMPSD: 4, I_CMPSQ: 8,
+	I_SCASB: 1, I_SCASW: 2, I_SCASD: 4, I_SCASQ: 8,
+}
+
+// fixedSize 操作数宽度由指令决定: setcc 设置一个字节, cdq/cqo/cdqe 隐含使用 eax/rax
+var fixedSize = map[Token]int{
+	I_SETO: 1, I_SETNO: 1, I_SETB: 1, I_SETAE: 1, I_SETE: 1, I_SETNE: 1, I_SETNA: 1, I_SETA: 1,
+	I_SETS: 1, I_SETNS: 1, I_SETP: 1, I_SETNP: 1, I_SETL: 1, I_SETGE: 1, I_SETLE: 1, I_SETG: 1,
+	I_CDQ: 4, I_CQO: 8, I_CDQE: 8,
+}
+
+// sizedByDst 源操作数的宽度与操作数宽度无关的指令: 移位次数, 扩展传送的源操作数
+var sizedByDst = map[Token]bool{
+	I_SHL: true, I_SHR: true, I_SAR: true, I_MOVZX: true, I_MOVSX: true, I_MOVSXD: true,
+}
+
+// lockable 可以使用 lock 前缀的指令, 目标操作数必须是内存
+var lockable = map[Token]bool{
+	I_ADD: true, I_SUB: true, I_AND: true, I_OR: true, I_XOR: true, I_INC: true, I_DEC: true, I_NEG: true,
+}
+
+// sseIntMem 内存操作数为整数的 SSE 指令, 内存宽度决定是否需要 REX.W
+var sseIntMem = map[Token]bool{
+	I_CVTSI2SS: true, I_CVTSI2SD: true,
+}
@@ -1,49 +1,90 @@
 package internal
 
 import (
-	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
-	"github.com/facelang/face/compiler/compile/token"
+	"github.com/facelang/face/internal/arch"
 	"github.com/facelang/face/internal/os/elf"
-	"github.com/facelang/face/internal/utils"
-	"go/ast"
+	"github.com/facelang/face/internal/prog"
 	"io"
+	"math"
 	"os"
-	"sort"
+	"runtime"
+	"slices"
 	"strconv"
-	"strings"
-	"text/scanner"
 )
 
 // 重定位类型常量
 const (
-	R_386_32   = 1 // 绝对寻址
-	R_386_PC32 = 2 // 相对寻址
+	R_386_32   = 1  // 绝对寻址
+	R_386_PC32 = 2  // 相对寻址
+	R_386_16   = 20 // 16 位绝对寻址
+	R_386_PC16 = 21 // 16 位相对寻址
+	R_386_8    = 22 // 8 位绝对寻址
+	R_386_PC8  = 23 // 8 位相对寻址
 )
 
 type section struct {
 	Name           string
 	Offset, Length int
+	Data           []byte          // 段数据, 代码生成后填充
+	Sym            *label          // 段起始位置, 用于 $、$$ 等当前位置引用
+	Align          int             // 段内最大的对齐要求
+	Type           elf.SectionType // 段类型
+	Flags          elf.SectionFlag // 段标志
+	Entsize        int             // 表项大小(可合并的常量段、构造函数表)
 }
 
 type relocate struct {
 	Label   string // 重定位符号的名称
-	Type    int    // 重定位类型0-R_386_32；1-R_386_PC32
+	Type    int    // 重定位类型 R_386_32, R_386_PC32 ...
 	Offset  int    // 重定位位置的偏移
 	Section string // 重定位目标段
+	Addend  int64  // 加数, 仅 64 位目标文件(RELA)使用, 32 位目标文件的加数写入重定位位置
 }
 
 type parser struct {
-	*lexer                      // 词法解析器
-	token        Token          // 符号类型
-	error        error          // 错误信息
-	declList     []*ast.GenDecl // 语句列表
-	sec          *section       // 当前段
-	secList      []*section     // 所有段列表
-	instrList    []*instr       // 指令列表
-	labelList    []*label       // 符号表
-	labelNames   map[string]int // 符号表，名称映射
-	relocateList []*relocate    // 重定位表
+	*lexer                           // 词法解析器
+	bits         int                 // 32 或 64 位模式
+	arch         *arch.Arch          // 目标架构, 为 nil 时按 bits 选择 x86
+	token        Token               // 符号类型
+	errors       prog.ErrorList      // 错误列表, 出错的语句跳过后继续解析
+	sec          *section            // 当前段
+	secList      []*section          // 所有段列表
+	instrList    []*instr            // 指令列表
+	labelList    []*label            // 符号表
+	labelNames   map[string]int      // 符号表，名称映射
+	relocateList []*relocate         // 重定位表
+	plan9Globals []*plan9Global      // Plan 9 风格 DATA/GLOBL 定义的数据符号
+	includes     []string            // .include 的查找目录
+	macros       map[string]*macro   // .macro 定义的宏
+	frames       []*frame            // 宏展开及包含文件的输入栈
+	conds        []cond              // 条件汇编状态栈
+	expansions   int                 // 宏展开次数, 用于 \@
+	bol          bool                // 是否位于语句开始位置
+	dots         []*label            // 表达式中的当前位置($ .)
+	filename     string              // 顶层源文件名称
+	sources      map[string][]byte   // 源文件及包含文件的内容, 用于列表文件
+	scope        string              // 当前函数名, 函数内的标签只在函数内可见
+	scoped       map[string][]*label // 函数内定义的标签, 按原名称索引
+	prevSec      *section            // 上一个段, 用于 .previous
+	secStack     [][2]*section       // .pushsection 保存的 当前段、上一个段
+	symSizes     []*symSize          // .size 指定的符号大小, 代码生成时计算
+	numLabels    map[string]int      // 数字局部标签已定义的次数
+	debug        bool                // -g 按源码位置生成调试信息
+	pic          bool                // -fpic 生成位置无关代码
+	compDir      string              // 汇编时的工作目录, 写入调试信息
+	srcName      string              // .file "name" 指定的源文件名
+	lineFiles    []lineFile          // 行号表中的文件, .file n 指定第 n 个
+	locs         bool                // 使用 .file n/.loc 指定行号
+	addrLabels   map[string]string   // 调试信息引用的代码地址(段名+偏移)对应的临时符号
+	loc          lineLoc             // .loc 指定的当前位置
+	rv           rvOptions           // RISC-V 的 .option 选项
+	rvStack      []rvOptions         // .option push 保存的选项
+	rvLabels     int                 // 已生成的 .Lpcrel_hi 标签个数
+	dataDiffs    bool                // 正在计算数据的值, RISC-V 跨越可松弛代码的标签差值不折叠为常量
+	defaultRel   bool                // NASM 的 default rel, 64 位模式下引用符号的内存操作数使用 rip 相对寻址
 
 	//lineNum       int   // Line number in source file.
 	//errorLine     int   // Line number of last error.
@@ -85,44 +126,111 @@ type parser struct {
 //	return p.lex.ident, true
 //}
 
-func (p *parser) _addRel(label string, relType int) {
+func (p *parser) _addRel(sec *section, offset int, label string, relType int, addend int64) {
 	p.relocateList = append(
 		p.relocateList,
 		&relocate{
-			Label:   label,        // 重定位符号的名称
-			Type:    relType,      // 重定位类型0-R_386_32；1-R_386_PC32
-			Offset:  p.sec.Offset, // 重定位位置的偏移
-			Section: p.sec.Name,   // 重定位目标段
+			Label:   label,    // 重定位符号的名称
+			Type:    relType,  // 重定位类型
+			Offset:  offset,   // 重定位位置的偏移
+			Section: sec.Name, // 重定位目标段
+			Addend:  addend,   // 加数
 		},
 	)
 }
 
-// 段落切换
+// 段落切换, 再次切换到已有的段时，从该段的结束位置继续
 func (p *parser) _switch(id string) {
-	p.secList = append(
-		p.secList,
-		&section{
-			Name:   p.sec.Name,
-			Length: p.sec.Offset, // 结束位置，也代表大小, 先不记录偏移
-		},
-	)
+	if sec := p.findSection(id); sec != nil {
+		p.setSection(sec)
+		return
+	}
 
-	p.sec.Name = id  // 切换到下一个段
-	p.sec.Offset = 0 // 清0段偏移
+	typ, flags, _ := sectionAttr(id)
+	p.setSection(p.newSection(id, typ, flags, 0))
 }
 
 // ----------------------------------------------------------------------------------
 // -- parser start
 
 func (p *parser) errorf(format string, args ...interface{}) {
-	p.error = fmt.Errorf(format, args...)
-	panic(p.error)
+	p.errorAt(p.pos, fmt.Sprintf(format, args...))
+}
+
+// errorAt 记录错误并放弃当前语句(第二遍扫描时放弃当前指令)
+func (p *parser) errorAt(pos prog.FilePos, msg string) {
+	p.report(pos, msg+p.trace())
+	panic(errBailout)
+}
+
+// report 记录错误, 不影响当前语句的解析
+func (p *parser) report(pos prog.FilePos, msg string) {
+	p.errors.Add(pos, msg)
+}
+
+// instrError 第二遍扫描时的指令错误, 附带指令所在的宏展开路径
+func (p *parser) instrError(ins *instr, msg string) {
+	p.report(ins.Pos, msg+ins.Trace)
+	panic(errBailout)
+}
+
+// intLit 当前整数字面量的值, 超过 int64 的 64 位无符号数按补码解释
+func (p *parser) intLit() int64 {
+	v, err := strconv.ParseUint(p.id, 0, 64) // 前缀 0x/0o/0b/0 与词法分析器一致
+	if err != nil {
+		p.errorf("无效的整数: %s", p.id)
+	}
+	return int64(v)
+}
+
+// floatLit 当前浮点数字面量的值
+func (p *parser) floatLit() float64 {
+	v, err := strconv.ParseFloat(p.id, 64)
+	if err != nil {
+		p.errorf("无效的浮点数: %s", p.id)
+	}
+	return v
+}
+
+// errBailout 错误已记录到错误列表, 用于结束当前语句
+var errBailout = errors.New("bailout")
+
+// recover 将解析过程中的 panic 转换为错误返回, 返回收集到的全部错误
+func (p *parser) recover(err *error) {
+	if r := recover(); r != nil {
+		if r != errBailout {
+			if e, ok := r.(error); ok {
+				*err = e
+				return
+			}
+			panic(r)
+		}
+	}
+	p.errors.Sort()
+	*err = p.errors.Err()
+}
+
+// bailout 恢复 errorAt 引发的 panic, 返回是否发生了错误; 其它 panic 继续传递
+func bailout(r any) bool {
+	if r != nil && r != errBailout {
+		panic(r)
+	}
+	return r != nil
 }
 
+// next 读取下一个记号: 行首的预处理伪指令及宏调用在这里处理, 条件汇编无效分支中的记号直接跳过
 func (p *parser) next() {
-	p.token = p.lexer.NextToken()
-	for p.token == COMMENT {
-		p.token = p.lexer.NextToken()
+	for {
+		tok := p.read()
+		if p.bol && tok == IDENT && p.preprocess() {
+			continue
+		}
+		p.bol = tok == NEWLINE || tok == SEMI
+		if tok != EOF && !p.active() {
+			continue
+		}
+		p.token = tok
+		return
 	}
 }
 
@@ -134,7 +242,7 @@ func (p *parser) got(token Token) bool {
 	return false
 }
 
-func (p *parser) expect(tokens ...Token) Pos {
+func (p *parser) expect(tokens ...Token) prog.FilePos {
 	pos := p.pos
 	for _, tok := range tokens {
 		if p.token == tok {
@@ -148,164 +256,477 @@ func (p *parser) expect(tokens ...Token) Pos {
 }
 
 func (p *parser) unexpect(except string) {
-	found := token.TokenLabel(p.token, p.id)
+	found := p.token.Message(p.id)
 	p.errorf("except %s, found %s", except, found)
 }
 
-// defineType 处理数据定义, 同时计算符号长度
-func (p *parser) data(cont *[]int64, contLen *int64) {
+// atEOL 当前语句是否已经结束
+func (p *parser) atEOL() bool {
+	return p.token == NEWLINE || p.token == SEMI || p.token == EOF
+}
+
+// eol 语句结束: 换行或文件结束, AT&T 及 Plan 9 风格还可以使用 ; 分隔多条语句
+func (p *parser) eol() {
+	if !p.atEOL() {
+		p.unexpect("newline")
+	}
+	p.next()
+}
+
+// ident 读取一个标识符
+func (p *parser) ident() string {
+	id := p.id
+	p.expect(IDENT)
+	return id
+}
+
+// number 读取常量表达式的值, 表达式只能引用已定义的常量
+func (p *parser) number() int64 {
+	return p.constExpr()
+}
+
+// memory 解析内存寻址 [rel|abs base + index*scale + disp], 左括号已读取, seg 为方括号之前的段超越前缀;
+// 偏移可以是任意表达式, 例如 [ebx + esi*4 + (end - start) * 2]
+func (p *parser) memory(seg Token) *operand {
+	pos, mode := p.pos, p.token
+	if !p.got(K_REL) && !p.got(K_ABS) {
+		mode = 0
+	}
+	opr := &operand{Type: OPRTP_MEM, Seg: seg}
+	if s := p.segment(); s != 0 {
+		if seg != 0 {
+			p.errorf("重复的段超越前缀")
+		}
+		opr.Seg = s
+	}
+	var disp Express
+	p.address(opr, p.binaryExpr(1, true), false, &disp)
+	p.expect(RBRACK)
+	if disp != nil {
+		p.setExpr(opr, disp)
+	}
+	p.relative(opr, mode, pos)
+	return opr
+}
+
+// relative 按 rel/abs 及 default rel 选择寻址方式: 与 NASM 一致, 只有不含寄存器、没有 fs/gs 段超越前缀
+// 且引用符号的内存操作数可以使用 rip 相对寻址
+func (p *parser) relative(opr *operand, mode Token, pos prog.FilePos) {
+	if mode == K_ABS || mode == 0 && (!p.defaultRel || p.bits != 64) {
+		return
+	}
+	if opr.Base != 0 || opr.Index != 0 || opr.Seg == SR_FS || opr.Seg == SR_GS || !opr.Symbolic() {
+		if mode == K_REL {
+			p.errorAt(pos, "rel 只能用于不含寄存器、没有 fs/gs 段超越前缀的符号地址")
+		}
+		return
+	}
+	if p.bits != 64 {
+		p.errorAt(pos, "rel: rip 相对寻址仅在 64 位模式下可用")
+	}
+	opr.Base = REG_RIP
+}
+
+// segment 读取段超越前缀 fs: 等, 没有时返回 0
+func (p *parser) segment() Token {
+	seg := p.token
+	if !seg.IsSegment() {
+		return 0
+	}
+	p.next()
+	p.expect(COLON)
+	return seg
+}
+
+// address 拆分内存引用表达式: 寄存器项作为基址、变址, 其余各项相加作为偏移表达式
+func (p *parser) address(opr *operand, x Express, neg bool, disp *Express) {
+	switch e := x.(type) {
+	case *BinaryExpr:
+		switch e.Op {
+		case ADD, SUB:
+			if hasReg(e) {
+				p.address(opr, e.X, neg, disp)
+				p.address(opr, e.Y, neg != (e.Op == SUB), disp)
+				return
+			}
+		case MUL: // reg*scale 或 scale*reg
+			reg, scale := e.X, e.Y
+			if _, ok := scale.(*RegExpr); ok {
+				reg, scale = scale, reg
+			}
+			if r, ok := reg.(*RegExpr); ok {
+				v, err := p.eval(scale, false)
+				if err != nil || v.Sym != nil || hasReg(scale) {
+					p.errorf("比例因子必须是常量")
+				}
+				p.addrReg(opr, r.Reg, int(v.Value), neg)
+				return
+			}
+		}
+	case *RegExpr:
+		p.addrReg(opr, e.Reg, 0, neg)
+		return
+	}
+
+	if hasReg(x) {
+		p.errorf("寄存器只能作为基址或变址使用")
+	}
+	if neg {
+		x = &UnaryExpr{Op: SUB, X: x}
+	}
+	if *disp == nil {
+		*disp = x
+	} else {
+		*disp = &BinaryExpr{Op: ADD, X: *disp, Y: x}
+	}
+}
+
+// addrReg 记录内存引用中的寄存器, scale 为 0 表示未指定比例因子
+func (p *parser) addrReg(opr *operand, reg Token, scale int, neg bool) {
+	if s := regSize(reg); reg != REG_RIP && s != 4 && s != 8 {
+		p.errorf("寄存器 %s 不能用于寻址", reg)
+	}
+	if neg {
+		p.errorf("寄存器 %s 不能取负", reg)
+	}
+	if reg == REG_RIP && (opr.Base != 0 || scale != 0) {
+		p.errorf("rip 只能作为基址寄存器")
+	}
+	if scale != 0 {
+		if opr.Index != 0 {
+			p.errorf("内存寻址最多使用一个变址寄存器")
+		}
+		opr.Index, opr.Scale = reg, scale
+	} else if opr.Base == 0 {
+		opr.Base = reg
+	} else if opr.Index == 0 && opr.Base != REG_RIP {
+		opr.Index, opr.Scale = reg, 1
+	} else {
+		p.errorf("内存寻址最多使用两个寄存器")
+	}
+}
+
+// hasReg 表达式中是否包含寄存器
+func hasReg(x Express) bool {
+	switch e := x.(type) {
+	case *RegExpr:
+		return true
+	case *UnaryExpr:
+		return hasReg(e.X)
+	case *BinaryExpr:
+		return hasReg(e.X) || hasReg(e.Y)
+	}
+	return false
+}
+
+// operand 解析指令操作数: [byte|word|dword|qword [ptr]] 寄存器 | 立即数 | [段:][内存],
+// 段超越前缀也可以写在方括号内, 例如 fs:[rax], [fs:rax], fs:0x28
+func (p *parser) operand() *operand {
+	size := 0
 	switch p.token {
-	case IDENT: // 引用变量，变量必须已经被申明， 如果符号未定义，则记录重定位
-		lb := p.GetLabel(p.id)
-		if lb.Type == EQU_LABEL || lb.Type == LOCAL_LABEL {
-			(*cont)[*contLen] = lb.Addr
-		} else { // 未定义或非法符号, equ 做了单独处理！
-			p._addRel(p.id, R_386_32)
-		}
-		*contLen++
+	case K_SBYTE:
+		size = 1
+	case K_SWORD:
+		size = 2
+	case K_SDWORD:
+		size = 4
+	case K_SQWORD:
+		size = 8
+	}
+	if size != 0 {
 		p.next()
-	case INT:
-		(*cont)[*contLen] = utils.IntBytes(p.id)
-		*contLen++
+		p.got(K_PTR)
+	}
+
+	switch {
+	case p.token.IsRegister():
+		opr := &operand{Type: OPRTP_REG, Reg: p.token}
+		if size != 0 && size != regSize(p.token) {
+			p.errorf("寄存器 %s 与宽度修饰不一致", p.token)
+		}
 		p.next()
-	case FLOAT:
-		(*cont)[*contLen] = utils.FloatBytes(p.id)
-		*contLen++
+		return opr
+	case p.got(LBRACK):
+		opr := p.memory(0)
+		opr.Size = size
+		return opr
+	case p.token.IsSegment():
+		seg := p.segment()
+		var opr *operand
+		if p.got(LBRACK) {
+			opr = p.memory(seg)
+		} else { // fs:0x28, 仅有偏移量的内存引用
+			opr = &operand{Type: OPRTP_MEM, Seg: seg}
+			p.setExpr(opr, p.expr())
+		}
+		opr.Size = size
+		return opr
+	default:
+		opr := &operand{Type: OPRTP_IMM, Size: size}
+		p.setExpr(opr, p.expr())
+		return opr
+	}
+}
+
+// emit 记录一条指令, 并计算其编码长度(第一遍扫描)
+func (p *parser) emit(ins *instr) {
+	ins.Sec = p.sec
+	ins.Offset = p.sec.Offset
+	ins.Trace = p.trace()
+	ins.Use = ins.Pos
+	if len(p.frames) > 0 {
+		ins.Use = p.frames[0].use
+	}
+	code, fixups, err := encode(ins, p.bits)
+	if err != nil {
+		p.errorAt(ins.Pos, err.Error())
+	}
+	if p.sec.nobits() && (len(fixups) > 0 || slices.ContainsFunc(code, func(b byte) bool { return b != 0 })) {
+		p.errorAt(ins.Pos, fmt.Sprintf("%s 段只能保留空间, 不能定义非零数据", p.sec.Name))
+	}
+	ins.Len = len(code)
+	ins.Loc = p.lineLoc(ins)
+	p.sec.Offset += ins.Len
+	p.instrList = append(p.instrList, ins)
+}
+
+// inst 解析一条机器指令, Intel 语法, 目标操作数在前; 指令之前可以有 lock/rep 等前缀
+func (p *parser) inst(times int) {
+	ins := &instr{Opcode: p.token, Times: times, Pos: p.pos}
+	p.next()
+	for ins.Opcode.IsPrefix() && p.token.IsInstr() {
+		ins.Prefix = append(ins.Prefix, ins.Opcode)
+		ins.Opcode = p.token
 		p.next()
-	case STRING:
-		for _, ch := range []byte(p.id) {
-			(*cont)[*contLen] = int64(ch)
-			*contLen++
+	}
+	var oprs []*operand
+	if !p.atEOL() { // 操作数可选, 例如: ret, ret 8
+		oprs = append(oprs, p.operand())
+		for p.got(COMMA) {
+			oprs = append(oprs, p.operand())
+		}
+	}
+	p.instruction(ins, 0, oprs)
+}
+
+// instruction 记录一条指令, 操作数已按 Intel 顺序(目标在前)排列;
+// size 为 AT&T、Plan 9 指令后缀给出的操作数宽度, 0 表示未指定
+func (p *parser) instruction(ins *instr, size int, oprs []*operand) {
+	if len(oprs) > 2 && (ins.Opcode != I_IMUL || len(oprs) > 3) {
+		p.errorAt(ins.Pos, fmt.Sprintf("%s: 最多支持两个操作数(imul 为三个)", ins.Opcode))
+	}
+	for i, opr := range oprs {
+		if size == 0 {
+			break
+		}
+		if i == 1 && sizedByDst[ins.Opcode] { // 移位次数及扩展传送的源操作数宽度与后缀无关
+			continue
 		}
+		switch opr.Type {
+		case OPRTP_REG: // xmm 寄存器的宽度与后缀无关, 例如 cvtsi2sdq %rax, %xmm0
+			if !opr.Reg.IsXmm() && regSize(opr.Reg) != size {
+				p.errorAt(ins.Pos, fmt.Sprintf("寄存器 %s 与指令后缀宽度不一致", opr.Reg))
+			}
+		case OPRTP_MEM: // 立即数宽度由指令决定, 不需要指定
+			if opr.Size == 0 {
+				opr.Size = size
+			}
+		}
+	}
+	if len(oprs) > 0 {
+		ins.Dst = oprs[0]
+	}
+	if len(oprs) > 1 {
+		ins.Src = oprs[1]
+	}
+	if len(oprs) > 2 {
+		ins.Imm = oprs[2]
+	}
+	p.emit(ins)
+}
+
+// value 解析一个数据定义的值
+func (p *parser) value(size int) *operand {
+	switch p.token {
+	case STRING:
+		opr := &operand{Type: OPRTP_STR, Text: p.id}
 		p.next()
-	default:
-		// todo
-		//p.errorf("[valType](%d,%d): %s, %，数据类型获取异常！", p.token.Message(p.id))
+		return opr
+	case FLOAT:
+		val := p.floatLit()
+		p.next()
+		switch size {
+		case 4:
+			return &operand{Type: OPRTP_IMM, Value: int64(math.Float32bits(float32(val)))}
+		case 8:
+			return &operand{Type: OPRTP_IMM, Value: int64(math.Float64bits(val))}
+		}
+		p.errorf("浮点数只能使用 dd/dq 定义")
 	}
+	opr := &operand{Type: OPRTP_IMM}
+	p.dataDiffs = true
+	defer func() { p.dataDiffs = false }()
+	p.setExpr(opr, p.expr())
+	return opr
 }
 
-func (p *parser) define(id string, times, size int) {
-	lb := NewLabel(LOCAL_LABEL)
-	lb.Times = times
-	lb.Size = size
-	lb.Cont = make([]int, 255) // 数据缓存
-	lb.ContLen = 0
-	p.data(&lb.Cont, &lb.ContLen) // 这里获得的是值， 数字、字符串、引用名
+// define 处理数据定义: [times n] db|dw|dd|dq 值 {, 值}
+func (p *parser) define() {
+	pos, times := p.pos, 1
+	if p.got(K_TIMES) {
+		times = int(p.number())
+		if times < 0 {
+			p.errorf("重复次数不能为负数: %d", times)
+		}
+		if p.token.IsInstr() {
+			p.inst(times)
+			return
+		}
+		if resSize[p.token] != 0 {
+			p.reserve(times)
+			return
+		}
+	}
 
-	// 看是否有连续定义, 例如："hello world", 13, 10
-	token := p.NextToken()
-	for token == COMMA {
-		p.data(&lb.Cont, &lb.ContLen) // 这里获得的是值， 数字、字符串、引用名
-		token = p.NextToken()
+	ins := &instr{Opcode: p.token, Times: times, Pos: pos}
+	switch p.token {
+	case K_DB:
+		ins.Size = 1
+	case K_DW:
+		ins.Size = 2
+	case K_DD:
+		ins.Size = 4
+	case K_DQ:
+		ins.Size = 8
+	default:
+		p.unexpect("db")
 	}
+	p.next()
 
-	p.ProcTable.AddLabel(id, lb)
+	ins.Values = append(ins.Values, p.value(ins.Size))
+	for p.got(COMMA) { // 连续定义, 例如："hello world", 13, 10
+		ins.Values = append(ins.Values, p.value(ins.Size))
+	}
+	p.emit(ins)
 }
 
-// 以符号名称开始的语句， 数据定义，或代码段标记
-func (p *parser) labelDec(id string) {
+// resSize resb/resw/resd/resq 保留的单元宽度
+var resSize = map[Token]int{K_RESB: 1, K_RESW: 2, K_RESD: 4, K_RESQ: 8}
+
+// reserve 保留未初始化的空间: [times n] resb|resw|resd|resq count, 以 0 填充
+func (p *parser) reserve(times int) {
+	pos, size := p.pos, resSize[p.token]
 	p.next()
+	p.space(int64(times)*p.number(), size, 0, pos)
+}
+
+// 以符号名称开始的语句， 数据定义，或代码段标记
+func (p *parser) labelDec(id string, pos prog.FilePos) {
 	switch p.token {
-	case A_TIMES: // 需要重复
-		p.expect(INT)
-		repeat := utils.Int(p.id)
-		size := p.size()
-		p.define(id, utils.Int(p.id), p.size()) // size 代表数据类型 db dd 字符、字、双字
-	case A_EQU: // equ 常量？伪指令，所有使用到该符号的，全部替换为值，不存在地址。
-		// 这里需要被替换为值
+	case K_EQU: // equ 常量？伪指令，所有使用到该符号的，全部替换为值，不存在地址。
 		// 关于 equ 语法说明，equ 支持表达式：可以是数字、地址、其他符号、算术表达式等
 		// equ 定义的符号在汇编时就被替换为具体值，不会占用内存，也不会生成机器码。
 		// 不能对 equ 定义的符号赋新值（它不是变量）。
 		// equ 只能用于常量表达式，不能用于运行时可变的值。
-		// todo 完整的逻辑需要支持 数字，其他符号，表达式， 【最终获得运算后的值】
-		// todo equ 引用其它符号，必须提前申明！
-		p.require(NUMBER)                                 // todo 当前只支持数字
-		p.ProcTable.AddLabel(id, NewLabelEqu(p.number())) // 直接添加符号
-	case COLON: // 代码段（label）, main: 一般是函数名作为一个单独的记号
-		p.ProcTable.AddLabel(id, NewLabelText()) // 作为一个段符号
-	default: // 变量支持
-		p.Lexer.Back(token) // db, dd, dw // 退回去重新读 p.size()
-
-		p.values(id, 1, p.size()) // 单个变量定义，直接解析
-	}
-}
-
-func (p *parser) dataList() (*ast.File, error) {
-	p.next() // 跳过.data
-	// 解析数据段内容
-	for p.token > _literal {
-		switch p.token {
-		case ".byte", ".word", ".long", ".quad", ".float", ".double", A_STRING:
-			// 解析数据定义伪指令
-			decl := p.parseDataDirective()
-			if decl != nil {
-				p.declList = append(p.declList, decl)
-			}
-		case A_REPT:
-			// 解析重复定义
-			decl := p.parseReptDirective()
-			if decl != nil {
-				p.declList = append(p.declList, decl)
-			}
-		case IDENT:
-			// 解析标签定义
-			p.declList = append(p.declList, p.labelDec(p.id))
-		case A_GLB: // 全局符号定义
-			p.require(IDENT)
-			// 添加到全局符号表
-			p.ProcTable.AddLabel(p.id, NewLabelGlobal())
-		default:
-			p.errorf("unexpected token in data section: %s", p.token)
-			return nil, p.error
-		}
+		// 表达式引用了尚未定义的符号时, 在使用该常量时计算(第二遍扫描)
 		p.next()
+		p.equ(id, p.expr(), pos, false)
+		p.eol()
+	case COLON: // 代码段（label）, main: 一般是函数名作为一个单独的记号, 同一行可以继续跟指令
+		p.next()
+		p.AddLabel(id, NewLabel(TEXT_LABEL), pos)
+	case K_TIMES, K_DB, K_DW, K_DD, K_DQ: // 变量定义
+		p.AddLabel(id, NewLabel(LOCAL_LABEL), pos)
+		p.define()
+		p.eol()
+	case K_RESB, K_RESW, K_RESD, K_RESQ: // 未初始化的变量, 例如 buf resb 64
+		p.AddLabel(id, NewLabel(LOCAL_LABEL), pos)
+		p.reserve(1)
+		p.eol()
+	default:
+		p.unexpect(":")
 	}
 }
 
-func (p *parser) ParseFile() (*ast.File, error) {
-	if p.error != nil {
-		return nil, p.error
+// intelStmt 解析一条 Intel(NASM) 风格的语句
+func (p *parser) intelStmt() {
+	switch {
+	case p.token == IDENT: // 两种情况，段落定义，变量定义
+		id, pos := p.id, p.pos
+		p.next()
+		p.labelDec(id, pos)
+		return
+	case p.token == K_SEC: // 段定义
+		p.next()
+		p._switch(p.ident()) // 切换到新的段
+	case p.token == K_GLB: // 全局符号定义
+		p.next()
+		p.GetLabel(p.ident()).Global = true
+		for p.got(COMMA) {
+			p.GetLabel(p.ident()).Global = true
+		}
+	case p.token == K_EXT: // 外部符号申明, 未申明的符号引用视为错误
+		p.next()
+		p.extern(p.ident())
+		for p.got(COMMA) {
+			p.extern(p.ident())
+		}
+	case p.token == K_TIMES || p.token == K_DB || p.token == K_DW || p.token == K_DD || p.token == K_DQ:
+		p.define()
+	case resSize[p.token] != 0:
+		p.reserve(1)
+	case p.token == K_DEFAULT: // default rel|abs
+		p.next()
+		p.defaultRel = p.token == K_REL
+		p.expect(K_REL, K_ABS)
+	case p.token.IsInstr():
+		p.inst(1) // 解析指令
+	default:
+		p.unexpect("instruction")
 	}
+	p.eol()
+}
 
-	p.next()
-
-	for p.token > _literal {
-		switch p.token {
-		case A_DATA: // 数据段定义
-			p.dataList()
-		case A_TEXT: // 代码段定义
-			p.next() // 跳过.text
-			// 解析代码段内容
-			for p.token > _literal {
-				switch p.token {
-				case IDENT:
-					p.declList = append(p.declList, p.labelDec(p.id))
-				default:
-					p.inst(p.token) // 解析指令
-				}
+// stmt 解析一条语句, 出错时跳过当前行的剩余部分, 继续解析下一行
+func (p *parser) stmt() {
+	defer func() {
+		if bailout(recover()) {
+			for !p.atEOL() {
 				p.next()
 			}
-		case IDENT: // 两种情况，段落定义，变量定义
-			p.declList = append(p.declList, p.labelDec(p.id))
-		case A_SEC: // 段定义
-			p.require(IDENT)
-			p._switch(p.id) // 切换到新的段
-		case A_GLB: // 全局符号定义
-			p.require(IDENT)
-			// 添加到全局符号表
-			p.ProcTable.AddLabel(p.id, NewLabelGlobal())
-		default:
-			p.inst(p.token) // 解析指令
 		}
-
-		p.next()
+	}()
+	switch p.syntax {
+	case SyntaxATT:
+		p.attStmt()
+	case SyntaxPlan9:
+		p.plan9Stmt()
+	default:
+		p.intelStmt()
 	}
+}
+
+// ParseFile 解析源文件(第一遍扫描): 收集符号, 记录指令并计算每条指令的长度
+func (p *parser) ParseFile() (err error) {
+	defer p.recover(&err)
+
+	p.next()
 
-	p._switch("") // 结束最后一个段
+	for p.token != EOF {
+		if p.got(NEWLINE) || p.got(SEMI) { // 空语句
+			continue
+		}
+		p.stmt()
+	}
+	if len(p.conds) > 0 {
+		p.errorAt(p.conds[len(p.conds)-1].pos, ".if 缺少对应的 .endif")
+	}
+	if p.syntax == SyntaxPlan9 {
+		p.plan9Layout() // DATA/GLOBL 定义的数据在文件末尾统一布局
+	}
+	p.relax()
 
-	return &ast.File{
-		Decls: p.declList,
-	}, nil
+	return nil
 }
 
 // ExportLb 导出符号表
@@ -323,408 +744,677 @@ func (p *parser) ParseFile() (*ast.File, error) {
 //	}
 //}
 
-//// Codegen 代码生成, 生成代码，同时记录每个段的大小
-//func (proc *parser) Codegen() error {
-//	// 源码扫描完成，开始生成代码， 内部符号已存在
-//	instrBuffer := bytes.NewBuffer(nil)
-//	for _, instr := range proc.InstrList {
-//		instr.WriteOut(instrBuffer, &proc.seg.Offset)
-//	}
-//	instrBuffer.Len() // 代码段大小
-//
-//	// important 符号表[可能]存在符号嵌套引用
-//	//    但是所有嵌套引用，被引用的符号必须被声明
-//	//        如果引用外部符号，记录地址， 下一个引用符合也只引用所在地址信息
-//	//    逻辑上无需处理嵌套
-//	for _, label := range proc.labelList {
+// Codegen 代码生成(第二遍扫描): 生成每个段的数据，回填已知的符号地址，其余记录为重定位
 //
-//	}
-//	//for _, instr := range proc.InstrList {
-//	//	instr.WriteOut(instrBuffer, &proc.seg.Offset)
-//	//}
-//
-//}
+//	important 符号表[可能]存在符号嵌套引用
+//	   但是所有嵌套引用，被引用的符号必须被声明
+//	       如果引用外部符号，记录地址， 下一个引用符合也只引用所在地址信息
+func (p *parser) Codegen() (err error) {
+	defer p.recover(&err)
 
-//func Check(src, dest []byte, name string) {
-//	for i, ch := range src {
-//		if len(dest) <= i {
-//			fmt.Printf("错误：[0x%X],  两文件内容长度不一致: [%d, %d]", i, len(src), len(dest))
-//			return
-//		}
-//		if ch != dest[i] {
-//			fmt.Printf("错误:[0x%X, %d]（%X(%d) != %X(%d)）", i, i, ch, ch, dest[i], dest[i])
-//
-//			for i2, b := range src[i-4 : i+16] {
-//				fmt.Printf("%d: [%d, %d] \n", i+i2-4, b, dest[i+i2-4])
-//			}
-//			fmt.Printf("\n")
-//			return
-//		}
-//	}
-//
-//	fmt.Printf("校验完成，[%s]完全一致！\n", name)
-//}
+	for _, sec := range p.secList {
+		sec.Length = sec.Offset // 段大小
+		sec.Data = make([]byte, 0, sec.Length)
+	}
 
-/*
-*
-.section .name
-.global main               # 定义全局符号，使符号对其他文件可见
-.local  local_func         # 定义局部符号，仅在当前文件可见
-.type   main, @function    # 定义符号类型，@function表示这是一个函数
-.size   main, .-main       # 定义符号大小，.-main表示从当前位置到main标签的距离
-*/
-func (p *Parser) pseudo(word string, args []LineToken) *Program {
-	switch word {
-	case ".section": // 分段
-
-	case ".global":
-
-	case ".local":
-
-	case ".type":
-
-	case ".size":
-
-	case ".align":
-
-	case "DATA":
-		p.asmData(operands)
-	case "FUNCDATA":
-		p.asmFuncData(operands)
-	case "GLOBL":
-		p.asmGlobl(operands)
-	case "PCDATA":
-		p.asmPCData(operands)
-	case "PCALIGN":
-		p.asmPCAlign(operands)
-	case "TEXT":
-		p.asmText(operands) // 函数申明
-	default: // 处理符号声明
-		if len(args) > 0 && args[0].LiteralVal == ":" {
-			// 说明是符号
-		}
-		return false
-	}
-	return true
-}
-
-// asmText assembles a TEXT pseudo-op.
-// TEXT runtime·sigtramp(SB),4,$0-0
-func (p *Parser) asmText(operands [][]lex.Token) { // 记录一个函数到代码段
-	if len(operands) != 2 && len(operands) != 3 {  // 参数至少是,2个或者,,3个
-		p.errorf("expect two or three operands for TEXT")
-		return
+	for _, ins := range p.instrList {
+		p.gen(ins)
+	}
+	p.resolveSizes()
+	if p.debug || p.locs {
+		p.dwarf()
 	}
 
-	// Labels are function scoped. Patch existing labels and
-	// create a new label space for this TEXT.
-	p.patch()                             // todo， 多次被调用
-	p.labels = make(map[string]*obj.Prog) // 每次都初始化？
+	return nil
+}
 
-	// Operand 0 is the symbol name in the form foo(SB).
-	// That means symbol plus indirect on SB and no offset.
-	nameAddr := p.address(operands[0]) // 计算地址？
-	if !p.validSymbol("TEXT", &nameAddr, false) {
-		return
+// gen 生成一条指令的机器码, 出错时以 0 填充, 保持后续指令的偏移不变
+func (p *parser) gen(ins *instr) {
+	defer func() {
+		if bailout(recover()) {
+			ins.Sec.Data = append(ins.Sec.Data, make([]byte, ins.Len)...)
+		}
+	}()
+	code, fixups, err := encode(ins, p.bits)
+	if err != nil {
+		p.instrError(ins, err.Error())
 	}
-	name := symbolName(&nameAddr)
-	next := 1
-
-	// Next operand is the optional text flag, a literal integer.
-	var flag = int64(0)
-	if len(operands) == 3 {
-		flag = p.evalInteger("TEXT", operands[1])
-		next++
-	}
-
-	// Issue an error if we see a function defined as ABIInternal
-	// without NOSPLIT. In ABIInternal, obj needs to know the function
-	// signature in order to construct the morestack path, so this
-	// currently isn't supported for asm functions.
-	if nameAddr.Sym.ABI() == obj.ABIInternal && flag&obj.NOSPLIT == 0 {
-		p.errorf("TEXT %q: ABIInternal requires NOSPLIT", name)
-	}
-
-	// Next operand is the frame and arg size.
-	// Bizarre syntax: $frameSize-argSize is two words, not subtraction.
-	// Both frameSize and argSize must be simple integers; only frameSize
-	// can be negative.
-	// The "-argSize" may be missing; if so, set it to objabi.ArgsSizeUnknown.
-	// Parse left to right.
-	op := operands[next]
-	if len(op) < 2 || op[0].ScanToken != '$' {
-		p.errorf("TEXT %s: frame size must be an immediate constant", name)
+	if len(code) != ins.Len {
+		p.instrError(ins, fmt.Sprintf("%s: 指令长度发生变化(%d -> %d)", ins.Opcode, ins.Len, len(code)))
+	}
+	for _, f := range fixups {
+		p.fixup(ins, code, f)
+	}
+	ins.Sec.Data = append(ins.Sec.Data, code...)
+}
+
+// fixup 回填符号引用: 段内 pc 相对引用直接计算, 其余生成重定位项
+// 局部符号使用所在段重定位, 全局及外部符号使用符号本身重定位
+func (p *parser) fixup(ins *instr, code []byte, f fixup) {
+	if f.Label == "" && f.Expr == nil { // 不引用符号的标记重定位: R_RISCV_RELAX, R_RISCV_ALIGN
+		p._addRel(ins.Sec, ins.Offset+f.Offset, "", f.Reloc, f.Addend)
 		return
 	}
-	op = op[1:]
-	negative := false
-	if op[0].ScanToken == '-' {
-		negative = true
-		op = op[1:]
+	x := f.Expr
+	if x == nil {
+		x = &SymExpr{Name: f.Label}
+	}
+	p.dataDiffs = f.Reloc == 0 // 数据及 x86 指令中的值
+	v, err := p.eval(x, true)
+	p.dataDiffs = false
+	if err != nil {
+		p.instrError(ins, err.Error())
 	}
-	if len(op) == 0 || op[0].ScanToken != scanner.Int {
-		p.errorf("TEXT %s: frame size must be an immediate constant", name)
+	if v.Sub != nil && p.rvPair(ins, f, v) { // RISC-V 的标签差值由链接器在松弛之后计算
+		p.rvDiff(ins, f, x, v)
 		return
 	}
-	frameSize := p.positiveAtoi(op[0].String())
-	if negative {
-		frameSize = -frameSize
+	if v.Sub != nil { // sym - . 及 sym - 当前段中的标签, 转换为 pc 相对的重定位: sym - P + (P - sub)
+		if v.Sub.Section != ins.Sec.Name || f.PCRel || f.Reloc != 0 {
+			p.instrError(ins, fmt.Sprintf("符号 %s 不在当前段中, 无法计算 %s 的值", labelName(v.Sub.Name), exprString(x)))
+		}
+		f.PCRel = true
+		f.Addend += int64(ins.Offset + f.Offset - v.Sub.Addr)
 	}
-	op = op[1:]
-	argSize := int64(abi.ArgsSizeUnknown)
-	if len(op) > 0 {
-		// There is an argument size. It must be a minus sign followed by a non-negative integer literal.
-		if len(op) != 2 || op[0].ScanToken != '-' || op[1].ScanToken != scanner.Int {
-			p.errorf("TEXT %s: argument size must be of form -integer", name)
-			return
+	if v.Sym != nil && p.x86() {
+		p.x86Fixup(ins, &f, v.Mod, v.Sym)
+		p.checkPIC(ins, f, v.Sym)
+	}
+	lb, val := v.Sym, f.Addend+v.Value
+	switch {
+	case lb == nil: // 常量, 在引用之后定义
+		if f.PCRel {
+			p.instrError(ins, fmt.Sprintf("常量 %s 不能作为跳转目标", exprString(x)))
 		}
-		argSize = p.positiveAtoi(op[1].String())
-	}
-	p.ctxt.InitTextSym(nameAddr.Sym, int(flag), p.pos())
-	prog := &obj.Prog{
-		Ctxt: p.ctxt,
-		As:   obj.ATEXT,
-		Pos:  p.pos(),
-		From: nameAddr,
-		To: obj.Addr{
-			Type:   obj.TYPE_TEXTSIZE,
-			Offset: frameSize,
-			// Argsize set below.
-		},
+	case lb.defined():
+		if f.PCRel && !f.Keep && lb.Section == ins.Sec.Name && (!lb.Global || p.jumpLocal(ins, lb, f.Mod)) {
+			val += int64(lb.Addr - (ins.Offset + f.Offset))
+		} else if lb.Global || f.SymRef {
+			lb.Keep = lb.Keep || f.SymRef
+			val = p.relocate(ins, f, lb.Name, val)
+		} else {
+			val = p.relocate(ins, f, lb.Section, val+int64(lb.Addr))
+		}
+	case !p.resolved(lb):
+		p.instrError(ins, fmt.Sprintf("符号 %s 未定义", labelName(lb.Name)))
+	default: // 未定义符号, 作为外部符号重定位
+		val = p.relocate(ins, f, lb.Name, val)
 	}
-	nameAddr.Sym.Func().Text = prog
-	prog.To.Val = int32(argSize)
-	p.append(prog, "", true) // 添加一个代码段？
-}
 
-// asmData assembles a DATA pseudo-op.
-// DATA masks<>+0x00(SB)/4, $0x00000000
-func (p *Parser) asmData(operands [][]lex.Token) { // 记录一条数据到数据段
-	if len(operands) != 2 {
-		p.errorf("expect two operands for DATA")
+	if f.Reloc != 0 { // 回填到指令字的字段中
+		if err := p.patch(code[f.Offset:], f.Reloc, val); err != nil {
+			p.instrError(ins, fmt.Sprintf("%s: %s", exprString(x), err))
+		}
 		return
 	}
+	if !fitsSize(val, f.Size) {
+		p.instrError(ins, fmt.Sprintf("%s 的值超出 %d 字节范围", exprString(x), f.Size))
+	}
+	for i := 0; i < f.Size; i++ {
+		code[f.Offset+i] = byte(val >> (8 * i))
+	}
+}
 
-	// Operand 0 has the general form foo<>+0x04(SB)/4.
-	op := operands[0]
-	n := len(op)
-	if n < 3 || op[n-2].ScanToken != '/' || op[n-1].ScanToken != scanner.Int {
-		p.errorf("expect /size for DATA argument")
-		return
+// patch 按目标架构将值回填到 code 开始的指令字段中
+func (p *parser) patch(code []byte, reloc int, val int64) error {
+	if p.machine == elf.EM_RISCV {
+		return rvPatch(code, reloc, val)
 	}
-	szop := op[n-1].String()
-	sz, err := strconv.Atoi(szop)
-	if err != nil {
-		p.errorf("bad size for DATA argument: %q", szop)
+	word, err := a64Patch(reloc, binary.LittleEndian.Uint32(code), val)
+	binary.LittleEndian.PutUint32(code, word)
+	return err
+}
+
+// relocate 生成重定位项, 返回需要写入重定位位置的值:
+// 32 位目标文件(REL)加数写入原位置, 64 位目标文件(RELA)加数记录在重定位项中
+func (p *parser) relocate(ins *instr, f fixup, label string, addend int64) int64 {
+	relType := p.relType(f)
+	if relType == 0 {
+		p.instrError(ins, fmt.Sprintf("符号 %s%s: 不支持 %d 字节的重定位", label, f.Mod, f.Size))
 	}
-	op = op[:n-2]
-	nameAddr := p.address(op)
-	if !p.validSymbol("DATA", &nameAddr, true) {
-		return
+	if p.bits == 64 {
+		p._addRel(ins.Sec, ins.Offset+f.Offset, label, relType, addend)
+		return 0
 	}
-	name := symbolName(&nameAddr)
+	p._addRel(ins.Sec, ins.Offset+f.Offset, label, relType, 0)
+	return addend
+}
 
-	// Operand 1 is an immediate constant or address.
-	valueAddr := p.address(operands[1])
-	switch valueAddr.Type {
-	case obj.TYPE_CONST, obj.TYPE_FCONST, obj.TYPE_SCONST, obj.TYPE_ADDR:
-		// OK
-	default:
-		p.errorf("DATA value must be an immediate constant or address")
-		return
+// relType 根据回填宽度及寻址方式选择重定位类型, 0 表示不支持
+func (p *parser) relType(f fixup) int {
+	if f.Reloc != 0 {
+		return f.Reloc
+	}
+	if f.Mod != modNone {
+		return p.modReloc(f)
+	}
+	switch p.machine {
+	case elf.EM_AARCH64:
+		return a64DataReloc(f)
+	case elf.EM_RISCV:
+		return rvDataReloc(f)
+	}
+	if p.bits == 64 {
+		switch {
+		case f.Size == 1 && f.PCRel:
+			return int(elf.R_X86_64_PC8)
+		case f.Size == 1:
+			return int(elf.R_X86_64_8)
+		case f.Size == 2 && f.PCRel:
+			return int(elf.R_X86_64_PC16)
+		case f.Size == 2:
+			return int(elf.R_X86_64_16)
+		case f.Size == 4 && f.Branch:
+			return int(elf.R_X86_64_PLT32)
+		case f.Size == 4 && f.PCRel:
+			return int(elf.R_X86_64_PC32)
+		case f.Size == 4 && f.Signed:
+			return int(elf.R_X86_64_32S)
+		case f.Size == 4:
+			return int(elf.R_X86_64_32)
+		case f.PCRel:
+			return int(elf.R_X86_64_PC64)
+		}
+		return int(elf.R_X86_64_64)
 	}
 
-	// The addresses must not overlap. Easiest test: require monotonicity.
-	if lastAddr, ok := p.dataAddr[name]; ok && nameAddr.Offset < lastAddr {
-		p.errorf("overlapping DATA entry for %s", name)
-		return
+	switch {
+	case f.Size == 1 && f.PCRel:
+		return R_386_PC8
+	case f.Size == 1:
+		return R_386_8
+	case f.Size == 2 && f.PCRel:
+		return R_386_PC16
+	case f.Size == 2:
+		return R_386_16
+	case f.Size == 4 && f.PCRel:
+		return R_386_PC32
+	case f.Size == 4:
+		return R_386_32
 	}
-	p.dataAddr[name] = nameAddr.Offset + int64(sz)
+	return 0
+}
 
-	switch valueAddr.Type {
-	case obj.TYPE_CONST:
-		switch sz {
-		case 1, 2, 4, 8:
-			nameAddr.Sym.WriteInt(p.ctxt, nameAddr.Offset, int(sz), valueAddr.Offset)
-		default:
-			p.errorf("bad int size for DATA argument: %d", sz)
-		}
-	case obj.TYPE_FCONST:
-		switch sz {
-		case 4:
-			nameAddr.Sym.WriteFloat32(p.ctxt, nameAddr.Offset, float32(valueAddr.Val.(float64)))
-		case 8:
-			nameAddr.Sym.WriteFloat64(p.ctxt, nameAddr.Offset, valueAddr.Val.(float64))
-		default:
-			p.errorf("bad float size for DATA argument: %d", sz)
-		}
-	case obj.TYPE_SCONST:
-		nameAddr.Sym.WriteString(p.ctxt, nameAddr.Offset, int(sz), valueAddr.Val.(string))
-	case obj.TYPE_ADDR:
-		if sz == p.arch.PtrSize {
-			nameAddr.Sym.WriteAddr(p.ctxt, nameAddr.Offset, int(sz), valueAddr.Sym, valueAddr.Offset)
-		} else {
-			p.errorf("bad addr size for DATA argument: %d", sz)
-		}
+// setArch 设置目标架构, 同时决定位数及词法规则
+func (p *parser) setArch(a *arch.Arch) {
+	p.arch, p.bits, p.machine = a, a.PtrSize*8, a.Machine
+}
+
+// NewParser 创建语法解析器, 默认 32 位模式
+func NewParser(lex *lexer) *parser {
+	p := &parser{
+		lexer:      lex,
+		bits:       32,
+		labelNames: make(map[string]int),
+		macros:     make(map[string]*macro),
+		numLabels:  make(map[string]int),
+		scoped:     make(map[string][]*label),
+		bol:        true,
+		filename:   lex.FilePos().Filename,
+		sources:    map[string][]byte{lex.FilePos().Filename: lex.Source()},
 	}
+	p._switch(".text") // 默认代码段
+	return p
 }
 
-func (p *parser) pseudo() bool  {
-	
+// Config 汇编选项
+type Config struct {
+	Arch     string    // 目标架构: 386, amd64, arm64, riscv64
+	Syntax   Syntax    // 源码的语法风格
+	Includes []string  // .include 的查找目录
+	Debug    bool      // 生成调试信息
+	PIC      bool      // 生成位置无关代码: x86 跳转到外部符号时经过 PLT, 禁止代码段中的绝对地址; RISC-V 的 la 从 GOT 加载
+	Listing  io.Writer // 不为 nil 时同时输出列表(地址、机器码及源码)
 }
 
-func (p *Parser) Parse() *Program {
-	scratch := make([][]lex.Token, 0, 3)
-	for {
-		word, cond, operands, ok := p.line(scratch) // operands = scratch 一维数组为每个参数， 逗号分割, 二维数组是具体的符号和 ident 两种
-		if !ok {
-			break
+// Assemble 汇编源码, 返回 ELF 可重定位目标文件的内容及对应的 elf.File;
+// name 为源文件名, 用于错误信息、调试信息及查找 .include 的相对路径.
+// 源码中的错误以 prog.ErrorList 返回; 汇编过程中的其它 panic 也转换为错误, 不会传递给调用方
+func Assemble(name string, src []byte, cfg Config) (data []byte, file *elf.File, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			data, file, err = nil, nil, panicError(r)
 		}
-		scratch = operands
+	}()
 
-		if p.pseudo(word, operands) { // 处理伪指令，段落、符号定义 DATA TEXT
-			continue
-		}
-		i, present := p.arch.Instructions[word] // 这里取指令操作码
-		if present {
-			p.instruction(i, word, cond, operands) // 最重要！处理指令
-			continue
-		}
-		p.errorf("unrecognized instruction %q", word)
+	a := arch.Set(cfg.Arch)
+	if a == nil {
+		return nil, nil, fmt.Errorf("不支持的目标架构: %s", cfg.Arch)
 	}
-	if p.errorCount > 0 {
-		return nil, false
+	if a.Machine != elf.EM_386 && a.Machine != elf.EM_X86_64 && cfg.Syntax != SyntaxATT {
+		return nil, nil, fmt.Errorf("%s 只支持 AT&T(GAS) 语法", cfg.Arch)
 	}
-	p.patch() // todo 不知道用途 可能跟标签有关
-	return p.firstProg, true
-}
 
-func NewParser(lex *lexer) *Parser {
-	return &Parser{
-		lex:         lex,
-		labels:      make(map[string]*obj.Prog),
-		dataAddr:    make(map[string]int64),
-		errorWriter: os.Stderr,
-		allowABI:    ctxt != nil && objabi.LookupPkgSpecial(ctxt.Pkgpath).AllowAsmABI,
-		pkgPrefix:   pkgPrefix,
+	lex := NewSourceLexer(name, src)
+	lex.syntax = cfg.Syntax
+	p := NewParser(lex)
+	p.setArch(a)
+	p.includes = cfg.Includes
+	p.debug = cfg.Debug
+	p.pic, p.rv.PIC = cfg.PIC, cfg.PIC
+	p.compDir, _ = os.Getwd()
+	if err := p.ParseFile(); err != nil {
+		if _, ok := err.(prog.ErrorList); !ok { // 读取包含文件失败等无法继续的错误
+			return nil, nil, err
+		}
 	}
-}
-
-// parseDataDirective 解析数据定义伪指令
-func (p *parser) parseDataDirective() *ast.GenDecl {
-	switch p.token {
-	case ".byte":  // .byte
-		return p.parseByteDirective()
-	case ".word":  // .word
-		return p.parseWordDirective()
-	case ".long":  // .long
-		return p.parseLongDirective()
-	case ".quad":  // .quad
-		return p.parseQuadDirective()
-	case ".float", ".single":  // .float
-		return p.parseQuadDirective()
-	case ".double":  // .double
-		return p.parseQuadDirective()
-	case ".quad":  // .quad
-		return p.parseQuadDirective()
-	case ".ascii": // .ascii
-		return p.parseAsciiDirective()
-	case ".asciz": // .asciz
-		return p.parseAscizDirective()
-	case ".string": // .string
-		return p.parseStringDirective()
-	case ".rept":  // .rept
-		return p.parseReptDirective()
-	default:
-		p.errorf("unknown data directive: %s", p.token)
-		return nil
+	if err := p.Codegen(); err != nil { // 继续生成代码, 同时列出两遍扫描的全部错误
+		return nil, nil, err
 	}
+	if cfg.Listing != nil {
+		if err := p.Listing(cfg.Listing); err != nil {
+			return nil, nil, err
+		}
+	}
+	file = p.Object()
+	return file.Bytes(), file, nil
 }
 
-// parseByteDirective 解析.byte伪指令
-func (p *parser) parseByteDirective() *ast.GenDecl {
-	decl := &ast.GenDecl{
-		Tok: token.DATA,
-	}
-	
-	p.next() // 跳过.byte
-	
-	// 解析值列表
-	for {
-		switch p.token {
-		case INT:
-			// 解析整数值
-			val := utils.Int(p.id)
-			decl.Specs = append(decl.Specs, &ast.ValueSpec{
-				Type: &ast.Ident{Name: "byte"},
-				Values: []ast.Expr{&ast.BasicLit{
-					Kind:  token.INT,
-					Value: strconv.FormatInt(val, 10),
-				}},
-			})
-		case STRING:
-			// 解析字符串
-			for _, ch := range []byte(p.id) {
-				decl.Specs = append(decl.Specs, &ast.ValueSpec{
-					Type: &ast.Ident{Name: "byte"},
-					Values: []ast.Expr{&ast.BasicLit{
-						Kind:  token.INT,
-						Value: strconv.FormatInt(int64(ch), 10),
-					}},
-				})
-			}
-		case IDENT:
-			// 解析符号引用
-			decl.Specs = append(decl.Specs, &ast.ValueSpec{
-				Type: &ast.Ident{Name: "byte"},
-				Values: []ast.Expr{&ast.Ident{
-					Name: p.id,
-				}},
-			})
-		default:
-			p.errorf("invalid value in .byte directive")
-			return nil
-		}
-		
-		p.next()
-		if p.token != COMMA {
-			break
+// panicError 将 panic 的值转换为错误: 读取文件失败等错误原样返回, 其它情况(运行时错误)视为汇编器内部错误
+func panicError(r any) error {
+	if e, ok := r.(error); ok {
+		if _, ok := e.(runtime.Error); !ok {
+			return e
 		}
-		p.next()
 	}
-	
-	return decl
-}
-
-// parseAsciiDirective 解析.ascii伪指令
-func (p *parser) parseAsciiDirective() *ast.GenDecl {
-	decl := &ast.GenDecl{
-		Tok: token.DATA,
-	}
-	
-	p.next() // 跳过.ascii
-	
-	if p.token != STRING {
-		p.errorf("expected string literal after .ascii")
-		return nil
-	}
-	
-	// 将字符串转换为字节数组
-	for _, ch := range []byte(p.id) {
-		decl.Specs = append(decl.Specs, &ast.ValueSpec{
-			Type: &ast.Ident{Name: "byte"},
-			Values: []ast.Expr{&ast.BasicLit{
-				Kind:  token.INT,
-				Value: strconv.FormatInt(int64(ch), 10),
-			}},
-		})
-	}
-	
-	p.next()
-	return decl
+	return fmt.Errorf("汇编器内部错误: %v", r)
 }
 
-// parseAscizDirective 解析.asciz伪指令
-func (p *parser) parseAscizDirective() *ast.GenDecl {
-	decl := p.parseAsciiDirective()
-	if decl == nil {
\ No newline at end of file
+// dataList GAS 风格的数据段解析(尚未接入)
+//func (p *parser) dataList() (*ast.File, error) {
+//	p.next() // 跳过.data
+//	// 解析数据段内容
+//	for p.token > _literal {
+//		switch p.token {
+//		case ".byte", ".word", ".long", ".quad", ".float", ".double", A_STRING:
+//			// 解析数据定义伪指令
+//			decl := p.parseDataDirective()
+//			if decl != nil {
+//				p.declList = append(p.declList, decl)
+//			}
+//		case A_REPT:
+//			// 解析重复定义
+//			decl := p.parseReptDirective()
+//			if decl != nil {
+//				p.declList = append(p.declList, decl)
+//			}
+//		case IDENT:
+//			// 解析标签定义
+//			p.declList = append(p.declList, p.labelDec(p.id))
+//		case A_GLB: // 全局符号定义
+//			p.require(IDENT)
+//			// 添加到全局符号表
+//			p.ProcTable.AddLabel(p.id, NewLabelGlobal())
+//		default:
+//			p.errorf("unexpected token in data section: %s", p.token)
+//			return nil, p.error
+//		}
+//		p.next()
+//	}
+//}
+//
+
+// 以下为 Plan 9 风格伪指令(TEXT/DATA/GLOBL)及 GAS 数据伪指令的移植草稿, 尚未接入
+///*
+//*
+//.section .name
+//.global main               # 定义全局符号，使符号对其他文件可见
+//.local  local_func         # 定义局部符号，仅在当前文件可见
+//.type   main, @function    # 定义符号类型，@function表示这是一个函数
+//.size   main, .-main       # 定义符号大小，.-main表示从当前位置到main标签的距离
+//*/
+//func (p *Parser) pseudo(word string, args []LineToken) *Program {
+//	switch word {
+//	case ".section": // 分段
+//
+//	case ".global":
+//
+//	case ".local":
+//
+//	case ".type":
+//
+//	case ".size":
+//
+//	case ".align":
+//
+//	case "DATA":
+//		p.asmData(operands)
+//	case "FUNCDATA":
+//		p.asmFuncData(operands)
+//	case "GLOBL":
+//		p.asmGlobl(operands)
+//	case "PCDATA":
+//		p.asmPCData(operands)
+//	case "PCALIGN":
+//		p.asmPCAlign(operands)
+//	case "TEXT":
+//		p.asmText(operands) // 函数申明
+//	default: // 处理符号声明
+//		if len(args) > 0 && args[0].LiteralVal == ":" {
+//			// 说明是符号
+//		}
+//		return false
+//	}
+//	return true
+//}
+//
+//// asmText assembles a TEXT pseudo-op.
+//// TEXT runtime·sigtramp(SB),4,$0-0
+//func (p *Parser) asmText(operands [][]lex.Token) { // 记录一个函数到代码段
+//	if len(operands) != 2 && len(operands) != 3 {  // 参数至少是,2个或者,,3个
+//		p.errorf("expect two or three operands for TEXT")
+//		return
+//	}
+//
+//	// Labels are function scoped. Patch existing labels and
+//	// create a new label space for this TEXT.
+//	p.patch()                             // todo， 多次被调用
+//	p.labels = make(map[string]*obj.Prog) // 每次都初始化？
+//
+//	// Operand 0 is the symbol name in the form foo(SB).
+//	// That means symbol plus indirect on SB and no offset.
+//	nameAddr := p.address(operands[0]) // 计算地址？
+//	if !p.validSymbol("TEXT", &nameAddr, false) {
+//		return
+//	}
+//	name := symbolName(&nameAddr)
+//	next := 1
+//
+//	// Next operand is the optional text flag, a literal integer.
+//	var flag = int64(0)
+//	if len(operands) == 3 {
+//		flag = p.evalInteger("TEXT", operands[1])
+//		next++
+//	}
+//
+//	// Issue an error if we see a function defined as ABIInternal
+//	// without NOSPLIT. In ABIInternal, obj needs to know the function
+//	// signature in order to construct the morestack path, so this
+//	// currently isn't supported for asm functions.
+//	if nameAddr.Sym.ABI() == obj.ABIInternal && flag&obj.NOSPLIT == 0 {
+//		p.errorf("TEXT %q: ABIInternal requires NOSPLIT", name)
+//	}
+//
+//	// Next operand is the frame and arg size.
+//	// Bizarre syntax: $frameSize-argSize is two words, not subtraction.
+//	// Both frameSize and argSize must be simple integers; only frameSize
+//	// can be negative.
+//	// The "-argSize" may be missing; if so, set it to objabi.ArgsSizeUnknown.
+//	// Parse left to right.
+//	op := operands[next]
+//	if len(op) < 2 || op[0].ScanToken != '$' {
+//		p.errorf("TEXT %s: frame size must be an immediate constant", name)
+//		return
+//	}
+//	op = op[1:]
+//	negative := false
+//	if op[0].ScanToken == '-' {
+//		negative = true
+//		op = op[1:]
+//	}
+//	if len(op) == 0 || op[0].ScanToken != scanner.Int {
+//		p.errorf("TEXT %s: frame size must be an immediate constant", name)
+//		return
+//	}
+//	frameSize := p.positiveAtoi(op[0].String())
+//	if negative {
+//		frameSize = -frameSize
+//	}
+//	op = op[1:]
+//	argSize := int64(abi.ArgsSizeUnknown)
+//	if len(op) > 0 {
+//		// There is an argument size. It must be a minus sign followed by a non-negative integer literal.
+//		if len(op) != 2 || op[0].ScanToken != '-' || op[1].ScanToken != scanner.Int {
+//			p.errorf("TEXT %s: argument size must be of form -integer", name)
+//			return
+//		}
+//		argSize = p.positiveAtoi(op[1].String())
+//	}
+//	p.ctxt.InitTextSym(nameAddr.Sym, int(flag), p.pos())
+//	prog := &obj.Prog{
+//		Ctxt: p.ctxt,
+//		As:   obj.ATEXT,
+//		Pos:  p.pos(),
+//		From: nameAddr,
+//		To: obj.Addr{
+//			Type:   obj.TYPE_TEXTSIZE,
+//			Offset: frameSize,
+//			// Argsize set below.
+//		},
+//	}
+//	nameAddr.Sym.Func().Text = prog
+//	prog.To.Val = int32(argSize)
+//	p.append(prog, "", true) // 添加一个代码段？
+//}
+//
+//// asmData assembles a DATA pseudo-op.
+//// DATA masks<>+0x00(SB)/4, $0x00000000
+//func (p *Parser) asmData(operands [][]lex.Token) { // 记录一条数据到数据段
+//	if len(operands) != 2 {
+//		p.errorf("expect two operands for DATA")
+//		return
+//	}
+//
+//	// Operand 0 has the general form foo<>+0x04(SB)/4.
+//	op := operands[0]
+//	n := len(op)
+//	if n < 3 || op[n-2].ScanToken != '/' || op[n-1].ScanToken != scanner.Int {
+//		p.errorf("expect /size for DATA argument")
+//		return
+//	}
+//	szop := op[n-1].String()
+//	sz, err := strconv.Atoi(szop)
+//	if err != nil {
+//		p.errorf("bad size for DATA argument: %q", szop)
+//	}
+//	op = op[:n-2]
+//	nameAddr := p.address(op)
+//	if !p.validSymbol("DATA", &nameAddr, true) {
+//		return
+//	}
+//	name := symbolName(&nameAddr)
+//
+//	// Operand 1 is an immediate constant or address.
+//	valueAddr := p.address(operands[1])
+//	switch valueAddr.Type {
+//	case obj.TYPE_CONST, obj.TYPE_FCONST, obj.TYPE_SCONST, obj.TYPE_ADDR:
+//		// OK
+//	default:
+//		p.errorf("DATA value must be an immediate constant or address")
+//		return
+//	}
+//
+//	// The addresses must not overlap. Easiest test: require monotonicity.
+//	if lastAddr, ok := p.dataAddr[name]; ok && nameAddr.Offset < lastAddr {
+//		p.errorf("overlapping DATA entry for %s", name)
+//		return
+//	}
+//	p.dataAddr[name] = nameAddr.Offset + int64(sz)
+//
+//	switch valueAddr.Type {
+//	case obj.TYPE_CONST:
+//		switch sz {
+//		case 1, 2, 4, 8:
+//			nameAddr.Sym.WriteInt(p.ctxt, nameAddr.Offset, int(sz), valueAddr.Offset)
+//		default:
+//			p.errorf("bad int size for DATA argument: %d", sz)
+//		}
+//	case obj.TYPE_FCONST:
+//		switch sz {
+//		case 4:
+//			nameAddr.Sym.WriteFloat32(p.ctxt, nameAddr.Offset, float32(valueAddr.Val.(float64)))
+//		case 8:
+//			nameAddr.Sym.WriteFloat64(p.ctxt, nameAddr.Offset, valueAddr.Val.(float64))
+//		default:
+//			p.errorf("bad float size for DATA argument: %d", sz)
+//		}
+//	case obj.TYPE_SCONST:
+//		nameAddr.Sym.WriteString(p.ctxt, nameAddr.Offset, int(sz), valueAddr.Val.(string))
+//	case obj.TYPE_ADDR:
+//		if sz == p.arch.PtrSize {
+//			nameAddr.Sym.WriteAddr(p.ctxt, nameAddr.Offset, int(sz), valueAddr.Sym, valueAddr.Offset)
+//		} else {
+//			p.errorf("bad addr size for DATA argument: %d", sz)
+//		}
+//	}
+//}
+//
+//func (p *parser) pseudo() bool  {
+//
+//}
+//
+//func (p *Parser) Parse() *Program {
+//	scratch := make([][]lex.Token, 0, 3)
+//	for {
+//		word, cond, operands, ok := p.line(scratch) // operands = scratch 一维数组为每个参数， 逗号分割, 二维数组是具体的符号和 ident 两种
+//		if !ok {
+//			break
+//		}
+//		scratch = operands
+//
+//		if p.pseudo(word, operands) { // 处理伪指令，段落、符号定义 DATA TEXT
+//			continue
+//		}
+//		i, present := p.arch.Instructions[word] // 这里取指令操作码
+//		if present {
+//			p.instruction(i, word, cond, operands) // 最重要！处理指令
+//			continue
+//		}
+//		p.errorf("unrecognized instruction %q", word)
+//	}
+//	if p.errorCount > 0 {
+//		return nil, false
+//	}
+//	p.patch() // todo 不知道用途 可能跟标签有关
+//	return p.firstProg, true
+//}
+//
+//func NewParser(lex *lexer) *Parser {
+//	return &Parser{
+//		lex:         lex,
+//		labels:      make(map[string]*obj.Prog),
+//		dataAddr:    make(map[string]int64),
+//		errorWriter: os.Stderr,
+//		allowABI:    ctxt != nil && objabi.LookupPkgSpecial(ctxt.Pkgpath).AllowAsmABI,
+//		pkgPrefix:   pkgPrefix,
+//	}
+//}
+//
+//// parseDataDirective 解析数据定义伪指令
+//func (p *parser) parseDataDirective() *ast.GenDecl {
+//	switch p.token {
+//	case ".byte":  // .byte
+//		return p.parseByteDirective()
+//	case ".word":  // .word
+//		return p.parseWordDirective()
+//	case ".long":  // .long
+//		return p.parseLongDirective()
+//	case ".quad":  // .quad
+//		return p.parseQuadDirective()
+//	case ".float", ".single":  // .float
+//		return p.parseQuadDirective()
+//	case ".double":  // .double
+//		return p.parseQuadDirective()
+//	case ".quad":  // .quad
+//		return p.parseQuadDirective()
+//	case ".ascii": // .ascii
+//		return p.parseAsciiDirective()
+//	case ".asciz": // .asciz
+//		return p.parseAscizDirective()
+//	case ".string": // .string
+//		return p.parseStringDirective()
+//	case ".rept":  // .rept
+//		return p.parseReptDirective()
+//	default:
+//		p.errorf("unknown data directive: %s", p.token)
+//		return nil
+//	}
+//}
+//
+//// parseByteDirective 解析.byte伪指令
+//func (p *parser) parseByteDirective() *ast.GenDecl {
+//	decl := &ast.GenDecl{
+//		Tok: token.DATA,
+//	}
+//
+//	p.next() // 跳过.byte
+//
+//	// 解析值列表
+//	for {
+//		switch p.token {
+//		case INT:
+//			// 解析整数值
+//			val := utils.Int(p.id)
+//			decl.Specs = append(decl.Specs, &ast.ValueSpec{
+//				Type: &ast.Ident{Name: "byte"},
+//				Values: []ast.Expr{&ast.BasicLit{
+//					Kind:  token.INT,
+//					Value: strconv.FormatInt(val, 10),
+//				}},
+//			})
+//		case STRING:
+//			// 解析字符串
+//			for _, ch := range []byte(p.id) {
+//				decl.Specs = append(decl.Specs, &ast.ValueSpec{
+//					Type: &ast.Ident{Name: "byte"},
+//					Values: []ast.Expr{&ast.BasicLit{
+//						Kind:  token.INT,
+//						Value: strconv.FormatInt(int64(ch), 10),
+//					}},
+//				})
+//			}
+//		case IDENT:
+//			// 解析符号引用
+//			decl.Specs = append(decl.Specs, &ast.ValueSpec{
+//				Type: &ast.Ident{Name: "byte"},
+//				Values: []ast.Expr{&ast.Ident{
+//					Name: p.id,
+//				}},
+//			})
+//		default:
+//			p.errorf("invalid value in .byte directive")
+//			return nil
+//		}
+//
+//		p.next()
+//		if p.token != COMMA {
+//			break
+//		}
+//		p.next()
+//	}
+//
+//	return decl
+//}
+//
+//// parseAsciiDirective 解析.ascii伪指令
+//func (p *parser) parseAsciiDirective() *ast.GenDecl {
+//	decl := &ast.GenDecl{
+//		Tok: token.DATA,
+//	}
+//
+//	p.next() // 跳过.ascii
+//
+//	if p.token != STRING {
+//		p.errorf("expected string literal after .ascii")
+//		return nil
+//	}
+//
+//	// 将字符串转换为字节数组
+//	for _, ch := range []byte(p.id) {
+//		decl.Specs = append(decl.Specs, &ast.ValueSpec{
+//			Type: &ast.Ident{Name: "byte"},
+//			Values: []ast.Expr{&ast.BasicLit{
+//				Kind:  token.INT,
+//				Value: strconv.FormatInt(int64(ch), 10),
+//			}},
+//		})
+//	}
+//
+//	p.next()
+//	return decl
+//}
+//
+//// parseAscizDirective 解析.asciz伪指令
+//func (p *parser) parseAscizDirective() *ast.GenDecl {
+//	decl := p.parseAsciiDirective()
+//	if decl == nil {
@@ -0,0 +1,495 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/facelang/face/internal/prog"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Plan 9(Go 汇编)语法前端, 转换为与 Intel 语法相同的 instr/operand 结构:
+//
+//	MOVQ $1, AX             ->  mov rax, 1
+//	MOVL msg<>+4(SB), BX    ->  mov ebx, [rip+msg+4] (32 位模式为 [msg+4])
+//	LEAQ 8(SP)(CX*4), DI    ->  lea rdi, [rsp+rcx*4+8]
+//	CMPQ AX, $10            ->  cmp rax, 10 (CMP 与 Intel 顺序一致)
+//
+// 通用寄存器不区分宽度, 由指令后缀 B/W/L/Q 决定; 伪寄存器只支持 SB(静态基址),
+// FP 等依赖 Go 栈帧布局的写法不支持
+
+// plan9Regs Plan 9 通用寄存器的机器编码
+var plan9Regs = map[string]int{
+	"AX": 0, "CX": 1, "DX": 2, "BX": 3, "SP": 4, "BP": 5, "SI": 6, "DI": 7,
+	"R8": 8, "R9": 9, "R10": 10, "R11": 11, "R12": 12, "R13": 13, "R14": 14, "R15": 15,
+}
+
+// plan9ByteRegs 显式指定的 8 位寄存器
+var plan9ByteRegs = map[string]Token{
+	"AL": BR_AL, "CL": BR_CL, "DL": BR_DL, "BL": BR_BL,
+	"AH": BR_AH, "CH": BR_CH, "DH": BR_DH, "BH": BR_BH,
+	"SPB": BR_SPL, "BPB": BR_BPL, "SIB": BR_SIL, "DIB": BR_DIL,
+	"R8B": BR_R8B, "R9B": BR_R9B, "R10B": BR_R10B, "R11B": BR_R11B,
+	"R12B": BR_R12B, "R13B": BR_R13B, "R14B": BR_R14B, "R15B": BR_R15B,
+}
+
+// plan9Instrs Plan 9 指令名称(不含宽度后缀)
+var plan9Instrs = map[string]Token{
+	"MOV": I_MOV, "CMP": I_CMP, "SUB": I_SUB, "ADD": I_ADD, "LEA": I_LEA,
+	"AND": I_AND, "OR": I_OR, "XOR": I_XOR, "TEST": I_TEST,
+	"CALL": I_CALL, "INT": I_INT, "IMUL": I_IMUL, "IMUL3": I_IMUL, "IDIV": I_IDIV, "NEG": I_NEG,
+	"INC": I_INC, "DEC": I_DEC, "PUSH": I_PUSH, "POP": I_POP, "RET": I_RET,
+	"JMP": I_JMP, "JEQ": I_JE, "JZ": I_JE, "JNE": I_JNE, "JNZ": I_JNE, "JGT": I_JG, "JLT": I_JL,
+	"JGE": I_JGE, "JLE": I_JLE, "JLS": I_JNA, "JHI": I_JA, "JCS": I_JB, "JLO": I_JB,
+	"JCC": I_JAE, "JHS": I_JAE, "JMI": I_JS, "JPL": I_JNS, "JOS": I_JO, "JOC": I_JNO,
+	"JPS": I_JP, "JPE": I_JP, "JPC": I_JNP, "JPO": I_JNP,
+}
+
+// plan9Sized 指定宽度的通用寄存器
+func plan9Sized(num, size int) Token {
+	switch size {
+	case 1:
+		if num < 4 {
+			return BR_AL + Token(num)
+		}
+		if num < 8 {
+			return BR_SPL + Token(num-4)
+		}
+		return BR_R8B + Token(num-8)
+	case 2:
+		if num < 8 {
+			return WR_AX + Token(num)
+		}
+		return WR_R8W + Token(num-8)
+	case 4:
+		if num < 8 {
+			return DR_EAX + Token(num)
+		}
+		return DR_R8D + Token(num-8)
+	}
+	if num < 8 {
+		return QR_RAX + Token(num)
+	}
+	return QR_R8 + Token(num-8)
+}
+
+// plan9Name 符号名称转换: 中点 · 替换为 ., 当前包前缀(以 . 开头)去掉, 例如 ·add -> add
+func plan9Name(name string) string {
+	return strings.TrimPrefix(strings.ReplaceAll(name, "·", "."), ".")
+}
+
+// plan9Mnemonic 解析 Plan 9 指令名称, 返回指令及后缀指定的操作数宽度(0 表示未指定)
+func plan9Mnemonic(name string) (Token, int) {
+	if tok, ok := plan9Instrs[name]; ok {
+		return tok, 0
+	}
+	n := len(name) - 1
+	if n <= 0 {
+		return ILLEGAL, 0
+	}
+	if size := suffixSize(name[n]); size != 0 && name[n] >= 'A' && name[n] <= 'Z' {
+		if tok, ok := plan9Instrs[name[:n]]; ok {
+			return tok, size
+		}
+	}
+	return ILLEGAL, 0
+}
+
+// plan9Datum DATA 伪指令定义的一段初始值
+type plan9Datum struct {
+	Offset int          // 相对符号起始位置的偏移
+	Size   int          // 宽度(字节)
+	Value  *operand     // 初始值
+	Pos    prog.FilePos // 源码位置
+}
+
+// plan9Global GLOBL 伪指令定义的数据符号
+type plan9Global struct {
+	Name   string
+	Local  bool         // name<> 只在本文件可见
+	Flags  []string     // RODATA, NOPTR ...
+	Size   int          // 大小(字节)
+	Data   []plan9Datum // 初始值, 没有初始值的符号放入 .bss
+	Pos    prog.FilePos // 源码位置
+	Define bool         // 是否已经由 GLOBL 定义
+}
+
+// plan9Stmt 解析一条 Plan 9 风格的语句: 标签、TEXT/DATA/GLOBL 伪指令或机器指令
+func (p *parser) plan9Stmt() {
+	id, pos := p.id, p.pos
+	p.expect(IDENT)
+	if p.got(COLON) { // 标签, 同一行可以继续跟指令; TEXT 之后的标签只在函数内可见
+		p.defLabel(plan9Name(id), pos)
+		return
+	}
+
+	switch id {
+	case "TEXT":
+		p.plan9Text()
+	case "DATA":
+		p.plan9Data()
+	case "GLOBL":
+		p.plan9Globl()
+	case "BYTE", "WORD", "LONG", "QUAD": // 直接写入数据, 例如 BYTE $0x90
+		ins := &instr{Opcode: K_DB, Size: 1, Times: 1, Pos: pos}
+		switch id {
+		case "WORD":
+			ins.Opcode, ins.Size = K_DW, 2
+		case "LONG":
+			ins.Opcode, ins.Size = K_DD, 4
+		case "QUAD":
+			ins.Opcode, ins.Size = K_DQ, 8
+		}
+		p.expect(DOLLAR)
+		label, val := p.plan9Expr()
+		ins.Values = append(ins.Values, &operand{Type: OPRTP_IMM, Label: label, Value: val})
+		p.emit(ins)
+	case "PCDATA", "FUNCDATA", "NO_LOCAL_POINTERS":
+		p.errorAt(pos, fmt.Sprintf("不支持 Go 运行时伪指令: %s", id))
+	default:
+		p.plan9Inst(id, pos)
+	}
+	p.eol()
+}
+
+// plan9Inst 解析机器指令, 源操作数在前
+func (p *parser) plan9Inst(name string, pos prog.FilePos) {
+	op, size := plan9Mnemonic(name)
+	if op == ILLEGAL {
+		p.errorAt(pos, fmt.Sprintf("不支持的指令: %s", name))
+	}
+	width := size
+	if width == 0 {
+		width = p.bits / 8
+	}
+	var oprs []*operand
+	if !p.atEOL() {
+		oprs = append(oprs, p.plan9Operand(op, width))
+		for p.got(COMMA) {
+			oprs = append(oprs, p.plan9Operand(op, width))
+		}
+	}
+	if op != I_CMP { // CMP 的操作数顺序与 Intel 一致, 其它指令源操作数在前
+		for i, j := 0, len(oprs)-1; i < j; i, j = i+1, j-1 {
+			oprs[i], oprs[j] = oprs[j], oprs[i]
+		}
+	}
+	p.instruction(&instr{Opcode: op, Times: 1, Pos: pos}, size, oprs)
+}
+
+// plan9Reg 通用寄存器名称转换为指定宽度的寄存器, 不是寄存器时返回 ILLEGAL
+func plan9Reg(name string, size int) Token {
+	if reg, ok := plan9ByteRegs[name]; ok {
+		return reg
+	}
+	if num, ok := plan9Regs[name]; ok {
+		return plan9Sized(num, size)
+	}
+	return ILLEGAL
+}
+
+// plan9Operand 解析操作数: 寄存器 | $imm | $sym(SB) | 内存引用 | 跳转目标
+func (p *parser) plan9Operand(op Token, size int) *operand {
+	if p.got(DOLLAR) {
+		label, val := p.plan9Expr()
+		if p.token == LPAREN { // $sym(SB) 取符号地址
+			p.plan9Base(&operand{})
+		}
+		return &operand{Type: OPRTP_IMM, Label: label, Value: val}
+	}
+	if p.token == IDENT {
+		if reg := plan9Reg(p.id, size); reg != ILLEGAL {
+			p.next()
+			return &operand{Type: OPRTP_REG, Reg: reg}
+		}
+	}
+	label, val := "", int64(0)
+	if p.token != LPAREN {
+		label, val = p.plan9Expr()
+	}
+	if p.token != LPAREN {
+		if !op.IsBranch() {
+			p.unexpect("(")
+		}
+		if p.scope != "" && label != "" { // 跳转目标只能是本函数内的标签
+			label = scopedName(p.scope, label)
+		}
+		return &operand{Type: OPRTP_IMM, Label: label, Value: val} // 跳转目标
+	}
+	opr := &operand{Type: OPRTP_MEM, Label: label, Value: val}
+	p.plan9Base(opr)
+	if p.got(LPAREN) { // 变址寄存器 (CX*4)
+		opr.Index = p.plan9AddrReg()
+		p.expect(MUL)
+		opr.Scale = int(p.intLit())
+		p.expect(INT)
+		p.expect(RPAREN)
+	}
+	return opr
+}
+
+// plan9Base 解析基址部分 (REG) 或 (SB), 静态基址 SB 在 64 位模式下使用 rip 相对寻址
+func (p *parser) plan9Base(opr *operand) {
+	p.expect(LPAREN)
+	switch p.id {
+	case "SB":
+		p.next()
+		if p.bits == 64 {
+			opr.Base = REG_RIP
+		}
+	case "FP", "PC":
+		p.errorf("不支持伪寄存器 %s", p.id)
+	default:
+		if opr.Label != "" {
+			p.errorf("符号 %s 只能通过 SB 引用", opr.Label)
+		}
+		opr.Base = p.plan9AddrReg()
+	}
+	p.expect(RPAREN)
+}
+
+// plan9AddrReg 读取用于寻址的寄存器, 宽度与地址宽度一致
+func (p *parser) plan9AddrReg() Token {
+	num, ok := plan9Regs[p.id]
+	if p.token != IDENT || !ok {
+		p.unexpect("register")
+	}
+	p.next()
+	return plan9Sized(num, p.bits/8)
+}
+
+// plan9Expr 解析地址表达式: [符号[<>]] {+|- 整数}
+func (p *parser) plan9Expr() (string, int64) {
+	label, val, sign := "", int64(0), int64(1)
+	if p.got(SUB) {
+		sign = -1
+	}
+	if p.token == IDENT {
+		if sign < 0 {
+			p.errorf("不支持的符号表达式: -%s", p.id)
+		}
+		label = plan9Name(p.id)
+		p.next()
+		if p.got(LSS) { // name<> 文件内可见
+			p.expect(GTR)
+		}
+		if p.token != ADD && p.token != SUB {
+			return label, val
+		}
+	} else {
+		val = sign * p.plan9Int()
+	}
+	for {
+		if p.got(ADD) {
+			val += p.plan9Int()
+		} else if p.got(SUB) {
+			val -= p.plan9Int()
+		} else {
+			return label, val
+		}
+	}
+}
+
+// plan9Int 读取整数
+func (p *parser) plan9Int() int64 {
+	val := p.intLit()
+	p.expect(INT)
+	return val
+}
+
+// plan9Symbol 解析符号引用 name[<>][+off](SB)
+func (p *parser) plan9Symbol() (name string, local bool, off int64) {
+	name = plan9Name(p.ident())
+	if p.got(LSS) {
+		p.expect(GTR)
+		local = true
+	}
+	if p.got(ADD) {
+		off = p.plan9Int()
+	}
+	p.expect(LPAREN)
+	if p.id != "SB" {
+		p.unexpect("SB")
+	}
+	p.next()
+	p.expect(RPAREN)
+	return name, local, off
+}
+
+// plan9Flags 解析 TEXT/GLOBL 的标志: NOSPLIT|NOFRAME 或整数
+func (p *parser) plan9Flags() []string {
+	var flags []string
+	for {
+		if p.token != IDENT && p.token != INT {
+			p.unexpect("flag")
+		}
+		flags = append(flags, p.id)
+		p.next()
+		if !p.got(OR) {
+			return flags
+		}
+	}
+}
+
+// plan9Text TEXT sym(SB), [flags,] $frame[-args]: 在代码段定义函数, 不会自动生成函数序言, 因此栈帧大小必须为 0
+func (p *parser) plan9Text() {
+	pos := p.pos
+	name, local, off := p.plan9Symbol()
+	if off != 0 {
+		p.errorAt(pos, fmt.Sprintf("TEXT %s: 不能指定偏移", name))
+	}
+	p.expect(COMMA)
+	if p.token != DOLLAR {
+		p.plan9Flags()
+		p.expect(COMMA)
+	}
+	p.expect(DOLLAR)
+	frame := p.plan9Int()
+	if p.got(SUB) { // 参数大小, 仅用于 Go 的栈检查
+		p.plan9Int()
+	}
+	if frame != 0 {
+		p.errorAt(pos, fmt.Sprintf("TEXT %s: 不支持非零栈帧 $%d", name, frame))
+	}
+
+	p._switch(".text")
+	p.AddLabel(name, NewLabel(TEXT_LABEL), pos)
+	if !local {
+		p.GetLabel(name).Global = true
+	}
+	p.scope = name
+}
+
+// plan9Sym 获取 DATA/GLOBL 定义的数据符号
+func (p *parser) plan9Sym(name string, pos prog.FilePos) *plan9Global {
+	for _, g := range p.plan9Globals {
+		if g.Name == name {
+			return g
+		}
+	}
+	g := &plan9Global{Name: name, Pos: pos}
+	p.plan9Globals = append(p.plan9Globals, g)
+	return g
+}
+
+// plan9Data DATA sym+off(SB)/size, $value: 记录数据符号的初始值, 文件结束后统一布局
+func (p *parser) plan9Data() {
+	pos := p.pos
+	name, _, off := p.plan9Symbol()
+	p.expect(DIV)
+	size := int(p.plan9Int())
+	if size <= 0 {
+		p.errorAt(pos, fmt.Sprintf("DATA %s: 无效的数据宽度 %d", name, size))
+	}
+	p.expect(COMMA)
+	p.expect(DOLLAR)
+
+	var val *operand
+	switch p.token {
+	case STRING: // 字符串, 长度不能超过数据宽度, 不足部分补 0
+		if len(p.id) > size {
+			p.errorf("DATA %s: 字符串长度超过 %d 字节", name, size)
+		}
+		val = &operand{Type: OPRTP_STR, Text: p.id + strings.Repeat("\x00", size-len(p.id))}
+		p.next()
+	case FLOAT:
+		f := p.floatLit()
+		p.next()
+		switch size {
+		case 4:
+			val = &operand{Type: OPRTP_IMM, Value: int64(math.Float32bits(float32(f)))}
+		case 8:
+			val = &operand{Type: OPRTP_IMM, Value: int64(math.Float64bits(f))}
+		default:
+			p.errorAt(pos, fmt.Sprintf("DATA %s: 浮点数宽度只能是 4 或 8", name))
+		}
+	default:
+		if size != 1 && size != 2 && size != 4 && size != 8 {
+			p.errorAt(pos, fmt.Sprintf("DATA %s: 无效的数据宽度 %d", name, size))
+		}
+		label, v := p.plan9Expr()
+		if p.token == LPAREN { // $sym(SB) 符号地址
+			p.plan9Base(&operand{})
+		}
+		val = &operand{Type: OPRTP_IMM, Label: label, Value: v}
+	}
+
+	g := p.plan9Sym(name, pos)
+	g.Data = append(g.Data, plan9Datum{Offset: int(off), Size: size, Value: val, Pos: pos})
+}
+
+// plan9Globl GLOBL sym(SB), [flags,] $size: 定义数据符号
+func (p *parser) plan9Globl() {
+	pos := p.pos
+	name, local, off := p.plan9Symbol()
+	if off != 0 {
+		p.errorAt(pos, fmt.Sprintf("GLOBL %s: 不能指定偏移", name))
+	}
+	p.expect(COMMA)
+	var flags []string
+	if p.token != DOLLAR {
+		flags = p.plan9Flags()
+		p.expect(COMMA)
+	}
+	p.expect(DOLLAR)
+	size := p.plan9Int()
+
+	g := p.plan9Sym(name, pos)
+	if g.Define {
+		p.errorAt(pos, fmt.Sprintf("GLOBL %s: 重复定义", name))
+	}
+	g.Define, g.Local, g.Flags, g.Size, g.Pos = true, local, flags, int(size), pos
+}
+
+// plan9Layout 按 GLOBL 的顺序布局数据符号: 带 RODATA 标志的放入 .rodata,
+// 有初始值的放入 .data, 其余放入 .bss; 未初始化的部分补 0
+func (p *parser) plan9Layout() {
+	for _, g := range p.plan9Globals {
+		if !g.Define {
+			p.errorAt(g.Pos, fmt.Sprintf("DATA %s: 缺少 GLOBL 定义", g.Name))
+		}
+		sec := ".bss"
+		if len(g.Data) > 0 {
+			sec = ".data"
+		}
+		for _, flag := range g.Flags {
+			if flag == "RODATA" {
+				sec = ".rodata"
+			}
+		}
+		p._switch(sec)
+		p.AddLabel(g.Name, NewLabel(LOCAL_LABEL), g.Pos)
+		if !g.Local {
+			p.GetLabel(g.Name).Global = true
+		}
+
+		sort.SliceStable(g.Data, func(i, j int) bool { return g.Data[i].Offset < g.Data[j].Offset })
+		off := 0
+		for _, d := range g.Data {
+			if d.Offset < off || d.Offset+d.Size > g.Size {
+				p.errorAt(d.Pos, fmt.Sprintf("DATA %s+%d: 与其它数据重叠或超出符号大小 %d", g.Name, d.Offset, g.Size))
+			}
+			p.plan9Zero(d.Offset-off, d.Pos)
+			ins := &instr{Opcode: K_DB, Size: 1, Times: 1, Values: []*operand{d.Value}, Pos: d.Pos}
+			switch {
+			case d.Value.Type == OPRTP_STR:
+			case d.Size == 2:
+				ins.Opcode, ins.Size = K_DW, 2
+			case d.Size == 4:
+				ins.Opcode, ins.Size = K_DD, 4
+			case d.Size == 8:
+				ins.Opcode, ins.Size = K_DQ, 8
+			}
+			p.emit(ins)
+			off = d.Offset + d.Size
+		}
+		p.plan9Zero(g.Size-off, g.Pos)
+	}
+}
+
+// plan9Zero 填充 n 个字节的 0
+func (p *parser) plan9Zero(n int, pos prog.FilePos) {
+	if n > 0 {
+		p.emit(&instr{Opcode: K_DB, Size: 1, Times: n, Values: []*operand{{Type: OPRTP_IMM}}, Pos: pos})
+	}
+}
@@ -0,0 +1,140 @@
+package internal
+
+// 分支优化: 第一遍扫描时跳转指令都按 rel32 计算长度, 解析结束后目标为同一段内局部符号的 jmp/jcc
+// 先全部改为 rel8 编码, 重新布局后偏移超出 rel8 范围的改回 rel32, 重复直到长度不再变化.
+// RISC-V 的 beqz/bnez/j 同样先全部改为 16 位的压缩编码.
+// 只会由短变长, 因此一定收敛. 引用外部符号及弱符号的跳转仍然生成重定位; 与 GAS 一致,
+// x86 的 jmp/jcc 跳转到同一段内的全局符号时同样参与优化(见 jumpLocal), RISC-V 与 llvm-mc 一致不压缩跳转到全局符号的指令.
+//
+// 第一遍扫描时已经计算出的同一段内的地址差值(例如 times 次数、equ 常量), 其范围内的跳转不参与优化,
+// 保证这些常量在重新布局后仍然正确.
+
+// pin 第一遍扫描计算了两个位置的差值, 两者之间的跳转保持 rel32 编码
+func (p *parser) pin(a, b *label) {
+	if !a.defined() || !b.defined() || a.Section != b.Section {
+		return
+	}
+	lo, hi := a.Instr, b.Instr
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	for _, ins := range p.instrList[lo:hi] {
+		if ins.Opcode == K_ALIGN && ins.Sec.Name == a.Section { // 对齐填充取决于之前所有指令的长度
+			lo = 0
+			break
+		}
+	}
+	for _, ins := range p.instrList[lo:hi] {
+		if ins.Sec.Name == a.Section {
+			ins.Fixed = true
+		}
+	}
+}
+
+// branchTarget 跳转目标对应的符号及偏移, 只处理 符号 + 常量 及当前位置
+func (p *parser) branchTarget(ins *instr) (*label, int64) {
+	if ins.Fixed || ins.Times > 1 {
+		return nil, 0
+	}
+	var name string
+	var expr Express
+	var off int64
+	switch {
+	case ins.Opcode == K_INSN && ins.Insn.Short != nil: // RISC-V 可以压缩的跳转
+		f := ins.Insn.Short.Fixups[0]
+		name, expr, off = f.Label, f.Expr, f.Addend
+	case ins.Opcode != I_CALL && ins.Opcode.IsBranch() && ins.Dst != nil && ins.Dst.Type == OPRTP_IMM && ins.Dst.Symbolic():
+		name, expr, off = ins.Dst.Label, ins.Dst.Expr, ins.Dst.Value
+	default:
+		return nil, 0
+	}
+	var lb *label
+	mod := modNone
+	switch x := expr.(type) {
+	case nil:
+		lb = p.GetLabel(name)
+	case *SymExpr:
+		lb, _ = p.lookup(x, true)
+		mod = x.Mod
+	case *DotExpr:
+		lb = x.Sym
+	default:
+		return nil, 0
+	}
+	if !lb.defined() || lb.Section != ins.Sec.Name || lb.Global && !p.jumpLocal(ins, lb, mod) {
+		return nil, 0
+	}
+	return lb, off
+}
+
+// fitsShort 短跳转能否到达 target: x86 的 rel8 相对下一条指令, RISC-V 的压缩跳转相对指令开始
+func fitsShort(ins *instr, target int64) bool {
+	if ins.Opcode == K_INSN {
+		return rvRange(target-int64(ins.Offset), rvShortReach(ins.Insn.Short.Fixups[0].Reloc)) == nil
+	}
+	return fitsInt8(target - int64(ins.Offset+ins.Len))
+}
+
+// relax 选择跳转指令的编码长度, 并重新计算指令及符号的地址
+func (p *parser) relax() {
+	var jumps []*instr
+	for _, ins := range p.instrList {
+		if lb, _ := p.branchTarget(ins); lb != nil {
+			ins.Short = true
+			jumps = append(jumps, ins)
+		}
+	}
+	if len(jumps) == 0 {
+		return
+	}
+
+	for changed := true; changed; {
+		p.layout(jumps)
+		changed = false
+		for _, ins := range jumps {
+			lb, off := p.branchTarget(ins)
+			if ins.Short && !fitsShort(ins, int64(lb.Addr)+off) {
+				ins.Short, changed = false, true
+			}
+		}
+	}
+}
+
+// layout 按当前的跳转长度重新计算指令偏移、段大小以及符号地址
+func (p *parser) layout(jumps []*instr) {
+	for _, ins := range jumps {
+		code, _, err := encode(ins, p.bits)
+		if err != nil {
+			p.instrError(ins, err.Error())
+		}
+		ins.Len = len(code)
+	}
+
+	// 按定义位置分组: 符号地址为其后第一条同段指令的偏移
+	anchors := make([][]*label, len(p.instrList)+1)
+	for _, list := range [][]*label{p.labelList, p.dots} {
+		for _, lb := range list {
+			if lb.defined() {
+				anchors[lb.Instr] = append(anchors[lb.Instr], lb)
+			}
+		}
+	}
+	offset := make(map[string]int)
+	for i := 0; i <= len(p.instrList); i++ {
+		for _, lb := range anchors[i] {
+			lb.Addr = offset[lb.Section]
+		}
+		if i < len(p.instrList) {
+			ins := p.instrList[i]
+			ins.Offset = offset[ins.Sec.Name]
+			if ins.Opcode == K_ALIGN { // 填充长度取决于偏移
+				code, _, _ := encode(ins, p.bits)
+				ins.Len = len(code)
+			}
+			offset[ins.Sec.Name] += ins.Len
+		}
+	}
+	for _, sec := range p.secList {
+		sec.Offset = offset[sec.Name]
+	}
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRelax(t *testing.T) {
+	src := `
+section .data
+	dd end - start ; 引用之后定义的符号, 在分支优化之后计算
+
+section .text
+start:
+	jmp fwd
+	je start
+	jmp $
+fwd:
+	jne far
+` + strings.Repeat("\tpush eax\n", 130) + `
+far:
+	jmp start
+end:
+`
+	p := assemble(t, "386", SyntaxIntel, src)
+	code := p.secList[0].Data
+	want := []byte{
+		0xEB, 0x04, // jmp fwd
+		0x74, 0xFC, // je start
+		0xEB, 0xFE, // jmp $
+		0x0F, 0x85, 0x82, 0x00, 0x00, 0x00, // jne far(超出 rel8 范围)
+	}
+	if !bytes.HasPrefix(code, want) {
+		t.Errorf("got % X, want % X...", code[:len(want)], want)
+	}
+	tail := []byte{
+		0xE9, 0x6D, 0xFF, 0xFF, 0xFF, // jmp start(距离 -147)
+	}
+	if !bytes.HasSuffix(code, tail) {
+		t.Errorf("got % X, want ...% X", code[len(code)-len(tail):], tail)
+	}
+	if got, want := p.secList[1].Data, []byte{0x93, 0, 0, 0}; !bytes.Equal(got, want) {
+		t.Errorf("end - start: got % X, want % X", got, want)
+	}
+	if lb := p.GetLabel("far"); lb.Addr != 12+130 {
+		t.Errorf("far: got %d, want %d", lb.Addr, 12+130)
+	}
+}
+
+func TestRelaxPinned(t *testing.T) {
+	src := `
+global glob
+extern ext
+start:
+	jmp next
+next:
+	jmp glob
+	jmp ext
+glob:
+	times 16-($-$$) db 0x90
+after:
+	jmp start
+`
+	p := assemble(t, "386", SyntaxIntel, src)
+	want := []byte{
+		0xE9, 0x00, 0x00, 0x00, 0x00, // jmp next(已用于计算 times 次数, 保持 rel32)
+		0xE9, 0x05, 0x00, 0x00, 0x00, // jmp glob(同一段内的全局符号, 保持 rel32 时同样直接计算)
+		0xE9, 0xFC, 0xFF, 0xFF, 0xFF, // jmp ext(外部符号)
+		0x90,
+		0xEB, 0xEE, // jmp start
+	}
+	if got := p.secList[0].Data; !bytes.Equal(got, want) {
+		t.Errorf("got % X, want % X", got, want)
+	}
+	if len(p.relocateList) != 1 || p.relocateList[0].Label != "ext" {
+		t.Errorf("got %d relocations, want 1(ext)", len(p.relocateList))
+	}
+}
@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"strings"
+)
+
+// x86 符号的重定位修饰, 写在符号名之后:
+//
+//	call foo@PLT                    ->  R_X86_64_PLT32 / R_386_PLT32
+//	movq foo@GOTPCREL(%rip), %rax   ->  R_X86_64_REX_GOTPCRELX
+//	movl foo@GOT(%ebx), %eax        ->  R_386_GOT32X
+//	leal foo@GOTOFF(%ebx), %eax     ->  R_386_GOTOFF
+//	movq %fs:0, %rax; movq foo@TPOFF(%rax) ...
+type x86Mod uint8
+
+const (
+	modNone      x86Mod = iota
+	modPLT              // 过程链接表
+	modGOT              // GOT 表项相对 GOT 的偏移
+	modGOTOFF           // 符号相对 GOT 的偏移
+	modGOTPCREL         // GOT 表项的 pc 相对地址(amd64)
+	modGOTPC            // GOT 的地址, 引用 _GLOBAL_OFFSET_TABLE_ 时自动使用
+	modTPOFF            // 静态 TLS 块中的偏移, 之后均为 TLS 模型的修饰
+	modNTPOFF           // 静态 TLS 块中的负偏移(386)
+	modGOTTPOFF         // 保存 TLS 偏移的 GOT 表项, pc 相对(amd64)
+	modGOTNTPOFF        // 保存 TLS 负偏移的 GOT 表项, 相对 GOT(386)
+	modINDNTPOFF        // 保存 TLS 负偏移的 GOT 表项的绝对地址(386)
+	modTLSGD            // 通用动态模型的 GOT 表项
+	modTLSLD            // 局部动态模型的 GOT 表项(amd64)
+	modTLSLDM           // 局部动态模型的 GOT 表项(386)
+	modDTPOFF           // 模块 TLS 块中的偏移
+)
+
+// x86Mods 重定位修饰的名称, 不区分大小写
+var x86Mods = map[string]x86Mod{
+	"PLT":       modPLT,
+	"GOT":       modGOT,
+	"GOTOFF":    modGOTOFF,
+	"GOTPCREL":  modGOTPCREL,
+	"TPOFF":     modTPOFF,
+	"NTPOFF":    modNTPOFF,
+	"GOTTPOFF":  modGOTTPOFF,
+	"GOTNTPOFF": modGOTNTPOFF,
+	"INDNTPOFF": modINDNTPOFF,
+	"TLSGD":     modTLSGD,
+	"TLSLD":     modTLSLD,
+	"TLSLDM":    modTLSLDM,
+	"DTPOFF":    modDTPOFF,
+}
+
+// gotSymbol 引用 GOT 地址的特殊符号
+const gotSymbol = "_GLOBAL_OFFSET_TABLE_"
+
+func (m x86Mod) String() string {
+	for name, mod := range x86Mods {
+		if mod == m {
+			return "@" + name
+		}
+	}
+	return ""
+}
+
+// splitMod 拆分 name@MOD 形式的符号引用; 不是已知修饰时原样返回, 例如带版本的符号 memcpy@GLIBC_2.2.5
+func splitMod(name string) (string, x86Mod) {
+	i := strings.LastIndexByte(name, '@')
+	if i <= 0 {
+		return name, modNone
+	}
+	if mod, ok := x86Mods[strings.ToUpper(name[i+1:])]; ok {
+		return name[:i], mod
+	}
+	return name, modNone
+}
+
+// tls 是否为 TLS 模型的修饰, 引用的符号类型为 STT_TLS
+func (m x86Mod) tls() bool {
+	return m >= modTPOFF
+}
+
+// symRef 重定位是否总是引用符号本身: GOT 表项及 TLS 偏移按符号分配, 不能改为 段 + 偏移
+func (m x86Mod) symRef() bool {
+	return m != modNone && m != modPLT && m != modGOTOFF && m != modGOTPC
+}
+
+// x86Relocs 重定位修饰对应的重定位类型, 按回填宽度 4, 8 字节区分, 0 表示不支持
+var x86Relocs = map[x86Mod][2][2]int{ // [32/64 位][4/8 字节]
+	modPLT:       {{int(elf.R_386_PLT32), 0}, {int(elf.R_X86_64_PLT32), 0}},
+	modGOT:       {{int(elf.R_386_GOT32), 0}, {int(elf.R_X86_64_GOT32), int(elf.R_X86_64_GOT64)}},
+	modGOTOFF:    {{int(elf.R_386_GOTOFF), 0}, {0, int(elf.R_X86_64_GOTOFF64)}},
+	modGOTPCREL:  {{0, 0}, {int(elf.R_X86_64_GOTPCREL), int(elf.R_X86_64_GOTPCREL64)}},
+	modGOTPC:     {{int(elf.R_386_GOTPC), 0}, {int(elf.R_X86_64_GOTPC32), int(elf.R_X86_64_GOTPC64)}},
+	modTPOFF:     {{int(elf.R_386_TLS_LE_32), 0}, {int(elf.R_X86_64_TPOFF32), int(elf.R_X86_64_TPOFF64)}},
+	modNTPOFF:    {{int(elf.R_386_TLS_LE), 0}, {0, 0}},
+	modGOTTPOFF:  {{0, 0}, {int(elf.R_X86_64_GOTTPOFF), 0}},
+	modGOTNTPOFF: {{int(elf.R_386_TLS_GOTIE), 0}, {0, 0}},
+	modINDNTPOFF: {{int(elf.R_386_TLS_IE), 0}, {0, 0}},
+	modTLSGD:     {{int(elf.R_386_TLS_GD), 0}, {int(elf.R_X86_64_TLSGD), 0}},
+	modTLSLD:     {{0, 0}, {int(elf.R_X86_64_TLSLD), 0}},
+	modTLSLDM:    {{int(elf.R_386_TLS_LDM), 0}, {0, 0}},
+	modDTPOFF:    {{int(elf.R_386_TLS_LDO_32), 0}, {int(elf.R_X86_64_DTPOFF32), int(elf.R_X86_64_DTPOFF64)}},
+}
+
+// modReloc 带重定位修饰的引用的重定位类型, 0 表示不支持;
+// 可以被链接器松弛的指令中的 GOT 引用与 GAS 一致使用 R_X86_64_[REX_]GOTPCRELX 及 R_386_GOT32X
+func (p *parser) modReloc(f fixup) int {
+	if f.Size != 4 && f.Size != 8 {
+		return 0
+	}
+	if f.Mod == modPLT && !f.PCRel {
+		return 0
+	}
+	b := 0
+	if p.bits == 64 {
+		b = 1
+	}
+	typ := x86Relocs[f.Mod][b][f.Size/8]
+	switch {
+	case b == 1 && typ == int(elf.R_X86_64_GOTPCREL) && f.Relax && f.Rex:
+		return int(elf.R_X86_64_REX_GOTPCRELX)
+	case b == 1 && typ == int(elf.R_X86_64_GOTPCREL) && f.Relax:
+		return int(elf.R_X86_64_GOTPCRELX)
+	case b == 0 && typ == int(elf.R_386_GOT32) && f.Relax:
+		return int(elf.R_386_GOT32X)
+	}
+	return typ
+}
+
+// gotRelax 指令是否可以由链接器把 GOT 中的内存引用改为直接引用:
+// mov r, r/m; call/jmp *r/m; test r/m, r; add/or/adc/sbb/and/sub/xor/cmp r, r/m
+func gotRelax(op []byte, ext byte) bool {
+	if len(op) != 1 {
+		return false
+	}
+	switch c := op[0]; {
+	case c == 0x8B, c == 0x85:
+		return true
+	case c == 0xFF:
+		return ext == 2 || ext == 4
+	default:
+		return c < 0x40 && c&0xC7 == 0x03
+	}
+}
+
+// x86 目标架构是否为 x86(386, amd64)
+func (p *parser) x86() bool {
+	return p.machine != elf.EM_AARCH64 && p.machine != elf.EM_RISCV
+}
+
+// x86Fixup 确定 x86 符号引用的重定位修饰: 显式写出的 @MOD, 引用 _GLOBAL_OFFSET_TABLE_ 时为 GOT 地址,
+// -fpic 模式下跳转到全局及外部符号时经过 PLT
+func (p *parser) x86Fixup(ins *instr, f *fixup, mod x86Mod, lb *label) {
+	switch {
+	case mod == modNone && lb.Name == gotSymbol && p.inTable(lb):
+		mod = modGOTPC
+		if !f.PCRel && ins.Opcode.IsInstr() { // 加数为回填位置相对指令开始的偏移, 与 GAS 一致
+			f.Addend += int64(f.Offset)
+		}
+	case mod == modNone && p.pic && f.Branch && (lb.Global || !lb.defined()):
+		mod = modPLT
+	}
+	f.Mod = mod
+	if mod.symRef() {
+		f.Keep, f.SymRef = true, true
+	}
+	if mod.tls() && lb.Kind == elf.STT_NOTYPE {
+		lb.Kind = elf.STT_TLS
+	}
+}
+
+// jumpLocal x86 的 jmp/jcc 跳转到同一段内的全局符号时是否在汇编时确定偏移: 与 GAS 一致, 非弱符号不生成重定位;
+// call、@PLT 及 -fpic 模式下的跳转仍然引用符号本身, 链接时可以被其它模块中的定义覆盖
+func (p *parser) jumpLocal(ins *instr, lb *label, mod x86Mod) bool {
+	return p.x86() && !p.pic && ins.Opcode != I_CALL && ins.Opcode.IsBranch() && mod == modNone && !lb.Weak
+}
+
+// checkPIC -fpic 模式下检查 x86 的绝对地址重定位: 代码段中的绝对地址需要运行时修改代码,
+// 64 位模式下 32 位的绝对地址无法表示任意加载位置
+func (p *parser) checkPIC(ins *instr, f fixup, lb *label) {
+	if !p.pic || f.Mod != modNone || f.PCRel || !p.x86() {
+		return
+	}
+	if ins.Sec.exec() || f.Size < p.bits/8 {
+		p.instrError(ins, fmt.Sprintf("-fpic: 不能使用符号 %s 的绝对地址, 请使用 pc 相对寻址或 GOT 引用", labelName(lb.Name)))
+	}
+}
@@ -0,0 +1,316 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/facelang/face/internal/arch"
+	"github.com/facelang/face/internal/os/elf"
+	"github.com/facelang/face/internal/prog"
+	"strings"
+)
+
+// RISC-V 汇编(GAS 语法), 支持 RV64I/M/A/F/D/C、Zicsr、Zifencei 及常用伪指令, 指令在解析时直接编码:
+//
+//	addi sp, sp, -16
+//	sd ra, 8(sp)
+//	lui a0, %hi(msg)
+//	addi a0, a0, %lo(msg)
+//	la a1, buf
+//	fld fa0, 8(sp)
+//	fadd.d fa0, fa0, fa1
+//	call puts
+//	bnez a0, 1b
+//
+// 与 GAS 的默认行为一致: 可以压缩的指令自动使用 C 扩展的 16 位编码(.option norvc 关闭);
+// 允许链接器松弛, 符号引用总是生成重定位并附加 R_RISCV_RELAX(.option norelax 关闭, 此时同一段内的跳转直接回填);
+// 数据中跨越可松弛指令的标签差值生成 R_RISCV_ADD/R_RISCV_SUB 重定位对, 由链接器在松弛之后计算.
+// 跳转到同一段内局部符号的 beqz/bnez/j 由分支优化选择压缩指令, 其余跳转由链接器松弛缩短.
+
+// rvKind 操作数类型
+type rvKind uint8
+
+const (
+	rvReg  rvKind = iota + 1 // 寄存器
+	rvImm                    // 常量
+	rvSym                    // 符号表达式: 跳转目标、标号, 可带 %hi 等重定位修饰
+	rvMem                    // 内存引用 offset(reg)
+	rvName                   // 控制状态寄存器名称(cycle ...)、fence 的访问类型(iorw)及浮点舍入模式(rtz ...)
+)
+
+var rvKindNames = [...]string{
+	rvReg:  "寄存器",
+	rvImm:  "立即数",
+	rvSym:  "符号",
+	rvMem:  "内存引用",
+	rvName: "名称",
+}
+
+// rvMods 支持的重定位修饰, 值为 I 型指令使用的重定位类型, 存储指令的 %lo/%pcrel_lo 使用对应的 S 型重定位
+var rvMods = map[string]elf.R_RISCV{
+	"hi":           elf.R_RISCV_HI20,
+	"lo":           elf.R_RISCV_LO12_I,
+	"pcrel_hi":     elf.R_RISCV_PCREL_HI20,
+	"pcrel_lo":     elf.R_RISCV_PCREL_LO12_I,
+	"got_pcrel_hi": elf.R_RISCV_GOT_HI20,
+}
+
+// rvArg RISC-V 操作数
+type rvArg struct {
+	Kind rvKind
+	Name string   // 寄存器及名称操作数的名称
+	Reg  int16    // 寄存器, 内存引用的基址寄存器
+	Imm  int64    // 常量, 内存引用的偏移
+	Sym  *operand // 符号引用, 值为 符号 + Value
+	Mod  string   // 重定位修饰: hi, lo, pcrel_hi, pcrel_lo, got_pcrel_hi; sym@plt 为 plt
+}
+
+// rvOptions RISC-V 汇编选项(.option), 零值为默认选项
+type rvOptions struct {
+	NoRVC   bool // 不使用压缩指令
+	NoRelax bool // 不允许链接器松弛
+	PIC     bool // 位置无关代码(.option pic), la 从 GOT 加载符号地址
+}
+
+// riscvStmt 解析并编码一条 RISC-V 指令; 名称已读取
+func (p *parser) riscvStmt(id string, pos prog.FilePos) {
+	name := strings.ToLower(id)
+	as, ok := p.arch.InstrTable[name]
+	if !ok {
+		p.errorAt(pos, fmt.Sprintf("不支持的指令: %s", id))
+	}
+	named := rvNamed(as)
+	var args []*rvArg
+	if !p.atEOL() {
+		args = append(args, p.rvOperand(named))
+		for p.got(COMMA) {
+			args = append(args, p.rvOperand(named))
+		}
+	}
+	e := &rvEncoder{
+		name:  name,
+		args:  args,
+		rvc:   !p.rv.NoRVC,
+		relax: !p.rv.NoRelax,
+		pic:   p.rv.PIC,
+		hi:    fmt.Sprintf(".Lpcrel_hi%d", p.rvLabels),
+	}
+	e.encode(as)
+	if e.err != nil {
+		p.errorAt(pos, fmt.Sprintf("%s: %s", name, e.err))
+	}
+	if e.hiUsed { // la 等伪指令中 auipc 的位置, 由 %pcrel_lo 引用
+		p.AddLabel(e.hi, NewLabel(TEXT_LABEL), pos)
+		p.rvLabels++
+	}
+	p.sec.Align = max(p.sec.Align, 2) // 与 llvm-mc 一致, 启用 C 扩展时指令所在的段按 2 字节对齐
+	p.emit(&instr{Opcode: K_INSN, Times: 1, Pos: pos, Insn: &e.insn})
+}
+
+// rvNamed 操作数中可以出现名称的指令
+func rvNamed(as arch.As) bool {
+	if f, ok := rvFloats[as]; ok {
+		return f.RM // 舍入模式
+	}
+	switch as {
+	case arch.RV_FENCE, arch.RV_CSRRW, arch.RV_CSRRS, arch.RV_CSRRC,
+		arch.RV_CSRRWI, arch.RV_CSRRSI, arch.RV_CSRRCI, arch.RV_CSRR, arch.RV_CSRW:
+		return true
+	}
+	return false
+}
+
+// rvOperand 解析一个操作数; named 为 true 时不是寄存器的标识符作为名称
+func (p *parser) rvOperand(named bool) *rvArg {
+	switch p.token {
+	case IDENT:
+		name := strings.ToLower(p.id)
+		if reg, ok := p.arch.Register[name]; ok {
+			p.next()
+			return &rvArg{Kind: rvReg, Name: name, Reg: reg}
+		}
+		if named {
+			p.next()
+			return &rvArg{Kind: rvName, Name: name}
+		}
+	case REM:
+		arg := p.rvReloc()
+		if p.token == LPAREN {
+			return p.rvMemory(arg)
+		}
+		return arg
+	case LPAREN:
+		return p.rvMemory(&rvArg{Kind: rvImm})
+	}
+	var mod string
+	if p.token == IDENT && strings.HasSuffix(p.id, "@plt") { // call sym@plt
+		p.id, mod = strings.TrimSuffix(p.id, "@plt"), "plt"
+	}
+	arg := &rvArg{Kind: rvSym, Sym: &operand{Type: OPRTP_IMM}, Mod: mod}
+	p.setExpr(arg.Sym, p.expr())
+	if !arg.Sym.Symbolic() {
+		arg = &rvArg{Kind: rvImm, Imm: arg.Sym.Value}
+	}
+	if p.token == LPAREN {
+		return p.rvMemory(arg)
+	}
+	return arg
+}
+
+// rvReloc 带重定位修饰的符号引用: %hi(sym), %lo(sym), %pcrel_hi(sym), %pcrel_lo(label), %got_pcrel_hi(sym)
+func (p *parser) rvReloc() *rvArg {
+	p.expect(REM)
+	pos, mod := p.pos, strings.ToLower(p.ident())
+	if _, ok := rvMods[mod]; !ok {
+		p.errorAt(pos, fmt.Sprintf("不支持的重定位修饰: %%%s", mod))
+	}
+	p.expect(LPAREN)
+	arg := &rvArg{Kind: rvSym, Mod: mod, Sym: &operand{Type: OPRTP_IMM}}
+	p.setExpr(arg.Sym, p.expr())
+	p.expect(RPAREN)
+	return arg
+}
+
+// rvMemory 解析内存引用 offset(reg), 偏移已读取: 8(sp), (a0), %lo(msg)(a1)
+func (p *parser) rvMemory(off *rvArg) *rvArg {
+	p.expect(LPAREN)
+	reg, ok := p.arch.Register[strings.ToLower(p.id)]
+	if p.token != IDENT || !ok || reg >= arch.REG_RV_F0 {
+		p.unexpect("register")
+	}
+	p.next()
+	p.expect(RPAREN)
+	return &rvArg{Kind: rvMem, Reg: reg, Imm: off.Imm, Sym: off.Sym, Mod: off.Mod}
+}
+
+// riscvDirective RISC-V 上含义与 x86 不同或特有的伪指令, 返回是否已处理
+func (p *parser) riscvDirective(name string) bool {
+	switch name {
+	case ".half":
+		p.attData(K_DW, 2)
+	case ".word":
+		p.attData(K_DD, 4)
+	case ".dword":
+		p.attData(K_DQ, 8)
+	case ".align": // 与 GAS 一致, RISC-V 上 .align n 按 2^n 字节对齐
+		p.attAlign(true)
+	case ".option":
+		p.rvOption()
+	default:
+		return false
+	}
+	return true
+}
+
+// rvOption .option rvc|norvc|relax|norelax|pic|nopic|push|pop
+func (p *parser) rvOption() {
+	pos, opt := p.pos, strings.ToLower(p.ident())
+	switch opt {
+	case "rvc", "norvc":
+		p.rv.NoRVC = opt == "norvc"
+	case "relax", "norelax":
+		p.rv.NoRelax = opt == "norelax"
+	case "pic", "nopic":
+		p.rv.PIC = opt == "pic"
+	case "push":
+		p.rvStack = append(p.rvStack, p.rv)
+	case "pop":
+		if len(p.rvStack) == 0 {
+			p.errorAt(pos, ".option pop 之前没有 .option push")
+		}
+		p.rv = p.rvStack[len(p.rvStack)-1]
+		p.rvStack = p.rvStack[:len(p.rvStack)-1]
+	default:
+		p.errorAt(pos, fmt.Sprintf("不支持的选项: %s", opt))
+	}
+}
+
+// rvAlign 代码段的对齐填充: 允许链接器松弛时与 GAS 一致, 预留最大长度的 nop 并生成 R_RISCV_ALIGN,
+// 由链接器在松弛之后删除多余的部分; 否则按偏移填充; 不足 4 字节的部分使用 c.nop
+func (p *parser) rvAlign(ins *instr) *instr {
+	nop, cnop := []byte{rvNop, 0, 0, 0}, []byte{rvCNop, 0}
+	least := 4
+	ins.Nops = [][]byte{nop}
+	if !p.rv.NoRVC {
+		ins.Nops, least = [][]byte{nop, cnop}, 2
+	}
+	if p.rv.NoRelax || !p.sec.exec() || ins.Size <= least {
+		return ins
+	}
+	n := ins.Size - least
+	var code []byte
+	for len(code)+4 <= n {
+		code = append(code, nop...)
+	}
+	if len(code) < n {
+		code = append(code, cnop...)
+	}
+	return &instr{Opcode: K_INSN, Times: 1, Pos: ins.Pos, Insn: &insn{
+		Code:   code,
+		Fixups: []fixup{{Reloc: int(elf.R_RISCV_ALIGN), Addend: int64(n)}},
+	}}
+}
+
+// rvRelaxed 段 sec 中 [lo, hi) 之间是否有链接器可以松弛的指令(R_RISCV_RELAX 及 R_RISCV_ALIGN), 两端的差值在链接时可能改变
+func (p *parser) rvRelaxed(sec string, lo, hi int) bool {
+	if p.machine != elf.EM_RISCV {
+		return false
+	}
+	lo, hi = min(lo, hi), max(lo, hi)
+	for _, ins := range p.instrList {
+		if ins.Insn == nil || ins.Sec.Name != sec || ins.Offset < lo || ins.Offset >= hi {
+			continue
+		}
+		for _, f := range ins.Insn.Fixups {
+			if f.Reloc == int(elf.R_RISCV_RELAX) || f.Reloc == int(elf.R_RISCV_ALIGN) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rvPair 数据中的标签差值 Sym - Sub 是否需要生成 R_RISCV_ADD/R_RISCV_SUB 重定位对: 与 llvm-mc 一致,
+// 只有被减的符号在当前段中、与回填位置之间没有可松弛的指令时, 4 字节的值才使用 R_RISCV_32_PCREL
+func (p *parser) rvPair(ins *instr, f fixup, v exprValue) bool {
+	if p.machine != elf.EM_RISCV || f.Reloc != 0 {
+		return false
+	}
+	return f.Size != 4 || v.Sub.Section != ins.Sec.Name || p.rvRelaxed(ins.Sec.Name, v.Sub.Addr, ins.Offset+f.Offset)
+}
+
+// rvDiff 生成标签差值的重定位对: R_RISCV_ADDn 引用 Sym, R_RISCV_SUBn 引用 Sub, 回填位置的值为 0
+func (p *parser) rvDiff(ins *instr, f fixup, x Express, v exprValue) {
+	var add, sub elf.R_RISCV
+	switch f.Size {
+	case 1:
+		add, sub = elf.R_RISCV_ADD8, elf.R_RISCV_SUB8
+	case 2:
+		add, sub = elf.R_RISCV_ADD16, elf.R_RISCV_SUB16
+	case 4:
+		add, sub = elf.R_RISCV_ADD32, elf.R_RISCV_SUB32
+	case 8:
+		add, sub = elf.R_RISCV_ADD64, elf.R_RISCV_SUB64
+	default:
+		p.instrError(ins, fmt.Sprintf("%s: 不支持 %d 字节的标签差值", exprString(x), f.Size))
+	}
+	if !p.resolved(v.Sym) {
+		p.instrError(ins, fmt.Sprintf("符号 %s 未定义", labelName(v.Sym.Name)))
+	}
+	p._addRel(ins.Sec, ins.Offset+f.Offset, p.rvSymRef(v.Sym), int(add), f.Addend+v.Value)
+	p._addRel(ins.Sec, ins.Offset+f.Offset, p.rvSymRef(v.Sub), int(sub), 0)
+}
+
+// rvSymRef 重定位对引用的符号名称: 松弛会移动代码, 本文件中定义的符号以符号本身引用并输出到符号表;
+// 当前位置等不在符号表中的位置登记为临时符号
+func (p *parser) rvSymRef(lb *label) string {
+	if !lb.defined() {
+		return lb.Name
+	}
+	if !p.inTable(lb) {
+		lb.Name = fmt.Sprintf(".Ltmp$%d", len(p.labelList))
+		p.labelList = append(p.labelList, lb)
+		p.labelNames[lb.Name] = len(p.labelList) - 1
+		lb.Index = len(p.labelList)
+	}
+	lb.Keep = true
+	return lb.Name
+}
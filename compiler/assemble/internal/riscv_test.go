@@ -0,0 +1,290 @@
+package internal
+
+import (
+	"bytes"
+	"github.com/facelang/face/internal/arch"
+	"github.com/facelang/face/internal/os/elf"
+	"strings"
+	"testing"
+)
+
+func TestRiscv64Encode(t *testing.T) {
+	// 期望结果来自 llvm-mc -triple=riscv64 -mattr=+m,+a,+f,+d,+c 的输出
+	tests := []struct {
+		src  string
+		want []byte
+	}{
+		{"addi a0, a1, 100", []byte{0x13, 0x85, 0x45, 0x06}},
+		{"add a0, a0, a1", []byte{0x2e, 0x95}},
+		{"add a0, a1, a0", []byte{0x2e, 0x95}},
+		{"lui a0, 0x12345", []byte{0x37, 0x55, 0x34, 0x12}},
+		{"ld ra, 8(sp)", []byte{0xa2, 0x60}},
+		{"sd s0, 0(sp)", []byte{0x22, 0xe0}},
+		{"sw a0, 4(a1)", []byte{0xc8, 0xc1}},
+		{"addi sp, sp, -16", []byte{0x41, 0x11}},
+		{"slli a0, a1, 3", []byte{0x13, 0x95, 0x35, 0x00}},
+		{"sub a0, a1, a2", []byte{0x33, 0x85, 0xc5, 0x40}},
+		{"mul a0, a1, a2", []byte{0x33, 0x85, 0xc5, 0x02}},
+		{"divuw a0, a1, a2", []byte{0x3b, 0xd5, 0xc5, 0x02}},
+		{"amoadd.w.aqrl a0, a1, (a2)", []byte{0x2f, 0x25, 0xb6, 0x06}},
+		{"lr.d a0, (a1)", []byte{0x2f, 0xb5, 0x05, 0x10}},
+		{"csrr a0, cycle", []byte{0x73, 0x25, 0x00, 0xc0}},
+		{"csrrwi a0, mstatus, 5", []byte{0x73, 0xd5, 0x02, 0x30}},
+		{"fence rw, w", []byte{0x0f, 0x00, 0x10, 0x03}},
+		{"fence.i", []byte{0x0f, 0x10, 0x00, 0x00}},
+		{"ecall", []byte{0x73, 0x00, 0x00, 0x00}},
+		{"nop", []byte{0x01, 0x00}},
+		{"ret", []byte{0x82, 0x80}},
+		{"mv a0, a1", []byte{0x2e, 0x85}},
+		{"neg a0, a1", []byte{0x33, 0x05, 0xb0, 0x40}},
+		{"seqz a0, a1", []byte{0x13, 0xb5, 0x15, 0x00}},
+		{"sext.w a0, a1", []byte{0x1b, 0x85, 0x05, 0x00}},
+		{"li a0, -1", []byte{0x7d, 0x55}},
+		{"li a0, 0x12345678", []byte{0x37, 0x55, 0x34, 0x12, 0x1b, 0x05, 0x85, 0x67}},
+		{".option norvc\n add a0, a0, a1", []byte{0x33, 0x05, 0xb5, 0x00}},
+		{"flw fa0, 4(a1)", []byte{0x07, 0xa5, 0x45, 0x00}},
+		{"fld fa0, 8(sp)", []byte{0x22, 0x25}},
+		{"fld fa5, 8(a0)", []byte{0x1c, 0x25}},
+		{"fsd ft11, 504(sp)", []byte{0xfe, 0xbf}},
+		{"fld ft0, 512(sp)", []byte{0x07, 0x30, 0x01, 0x20}},
+		{"fadd.d fa0, fa1, fa2", []byte{0x53, 0xf5, 0xc5, 0x02}},
+		{"fadd.s fa0, fa1, fa2, rtz", []byte{0x53, 0x95, 0xc5, 0x00}},
+		{"fsqrt.d fa0, fa1", []byte{0x53, 0xf5, 0x05, 0x5a}},
+		{"fmadd.d fa0, fa1, fa2, fa3", []byte{0x43, 0xf5, 0xc5, 0x6a}},
+		{"fnmsub.s fa0, fa1, fa2, fa3, rne", []byte{0x4b, 0x85, 0xc5, 0x68}},
+		{"fmv.d fa0, fa1", []byte{0x53, 0x85, 0xb5, 0x22}},
+		{"fneg.s fa0, fa1", []byte{0x53, 0x95, 0xb5, 0x20}},
+		{"fcvt.d.l fa0, a0", []byte{0x53, 0x75, 0x25, 0xd2}},
+		{"fcvt.d.w fa0, a0", []byte{0x53, 0x05, 0x05, 0xd2}},
+		{"fcvt.w.d a0, fa0, rtz", []byte{0x53, 0x15, 0x05, 0xc2}},
+		{"fcvt.d.s fa0, fa1", []byte{0x53, 0x85, 0x05, 0x42}},
+		{"fcvt.s.d fa0, fa1", []byte{0x53, 0xf5, 0x15, 0x40}},
+		{"fmv.x.d a0, fa0", []byte{0x53, 0x05, 0x05, 0xe2}},
+		{"fmv.w.x fa0, a0", []byte{0x53, 0x05, 0x05, 0xf0}},
+		{"feq.d a0, fa0, fa1", []byte{0x53, 0x25, 0xb5, 0xa2}},
+		{"fclass.d a0, f10", []byte{0x53, 0x15, 0x05, 0xe2}},
+	}
+
+	for _, tt := range tests {
+		got := assemble(t, "riscv64", SyntaxATT, tt.src).findSection(".text").Data
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%q: got % x, want % x", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestRiscv64Branch(t *testing.T) {
+	// 不允许松弛时同一段内的跳转直接回填, beqz/j 使用压缩指令, 对齐填充 nop
+	src := `
+	.option norelax
+f:	beqz a0, 1f
+	bne a0, a1, f
+	j 1f
+	jal f
+	ret
+	.align 3
+1:	ret
+`
+	p := assemble(t, "riscv64", SyntaxATT, src)
+	want := []byte{
+		0x01, 0xc9, 0xe3, 0x1f, 0xb5, 0xfe, 0x29, 0xa0, 0xef, 0xf0, 0x9f, 0xff, 0x82, 0x80, 0x01, 0x00,
+		0x82, 0x80,
+	}
+	if got := p.findSection(".text").Data; !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+	if len(p.relocateList) != 0 {
+		t.Errorf("局部跳转不应生成重定位, got %d", len(p.relocateList))
+	}
+}
+
+func TestRiscv64Compress(t *testing.T) {
+	// 与 llvm-mc 一致: 目标为同一段内的局部符号且在范围内时使用 c.bnez/c.beqz/c.j,
+	// 允许松弛时生成 R_RISCV_RVC_BRANCH/R_RISCV_RVC_JUMP; t0 不能使用压缩指令, 全局符号及超出范围的目标保持 32 位编码
+	src := `
+f:	bnez a0, 1f
+	bnez t0, 1f
+	j 1f
+	j g
+	beqz a0, 2f
+	.space 256
+1:	ret
+	.globl g
+g:	j f
+2:	ret
+`
+	p := assemble(t, "riscv64", SyntaxATT, src)
+	code := p.findSection(".text").Data
+	if want := []byte{0x63, 0x10, 0x05, 0x00, 0x63, 0x90, 0x02, 0x00, 0x01, 0xa0, 0x6f, 0x00, 0x00, 0x00}; !bytes.HasPrefix(code, want) {
+		t.Errorf("got % x, want % x", code[:len(want)], want)
+	}
+	if len(code) != 0x118 {
+		t.Errorf("代码长度 got %#x, want 0x118", len(code))
+	}
+	want := []struct {
+		typ    elf.R_RISCV
+		offset int
+	}{
+		{elf.R_RISCV_BRANCH, 0},
+		{elf.R_RISCV_BRANCH, 4},
+		{elf.R_RISCV_RVC_JUMP, 8},
+		{elf.R_RISCV_JAL, 0xa},
+		{elf.R_RISCV_BRANCH, 0xe},
+		{elf.R_RISCV_RVC_JUMP, 0x114},
+	}
+	if len(p.relocateList) != len(want) {
+		t.Fatalf("重定位: got %d, want %d", len(p.relocateList), len(want))
+	}
+	for i, w := range want {
+		if rel := p.relocateList[i]; elf.R_RISCV(rel.Type) != w.typ || rel.Offset != w.offset {
+			t.Errorf("第 %d 项: got %s %d, want %s %d", i, elf.R_RISCV(rel.Type), rel.Offset, w.typ, w.offset)
+		}
+	}
+}
+
+func TestRiscv64Reloc(t *testing.T) {
+	src := `
+	lui a0, %hi(msg)
+	addi a0, a0, %lo(msg)
+	sd a1, %lo(msg)(a0)
+	la a1, ext
+	call puts
+	.align 3
+	ret
+	.data
+msg:	.word 1
+	.dword ext
+`
+	p := assemble(t, "riscv64", SyntaxATT, src)
+	want := []struct {
+		typ    elf.R_RISCV
+		label  string
+		offset int
+	}{
+		{elf.R_RISCV_HI20, "msg", 0},
+		{elf.R_RISCV_RELAX, "", 0},
+		{elf.R_RISCV_LO12_I, "msg", 4},
+		{elf.R_RISCV_RELAX, "", 4},
+		{elf.R_RISCV_LO12_S, "msg", 8},
+		{elf.R_RISCV_RELAX, "", 8},
+		{elf.R_RISCV_PCREL_HI20, "ext", 12},
+		{elf.R_RISCV_RELAX, "", 12},
+		{elf.R_RISCV_PCREL_LO12_I, ".Lpcrel_hi0", 16},
+		{elf.R_RISCV_RELAX, "", 16},
+		{elf.R_RISCV_CALL, "puts", 20},
+		{elf.R_RISCV_RELAX, "", 20},
+		{elf.R_RISCV_ALIGN, "", 28},
+		{elf.R_RISCV_64, "ext", 4},
+	}
+	if len(p.relocateList) != len(want) {
+		t.Fatalf("重定位: got %d, want %d", len(p.relocateList), len(want))
+	}
+	for i, w := range want {
+		rel := p.relocateList[i]
+		if elf.R_RISCV(rel.Type) != w.typ || rel.Label != w.label || rel.Offset != w.offset {
+			t.Errorf("第 %d 项: got %s %s %d, want %s %s %d", i,
+				elf.R_RISCV(rel.Type), rel.Label, rel.Offset, w.typ, w.label, w.offset)
+		}
+	}
+
+	for _, src := range []string{
+		"add a0, a1",
+		"addi a0, a1, 4096",
+		"slli a0, a1, 64",
+		"ld a0, 2048(a1)",
+		"lui a0, 0x100000",
+		"csrr a0, nosuch",
+		"fence rw, x",
+		"amoadd.w a0, a1, 4(a2)",
+		"beqz a0, 5",
+		".option pop",
+		".option norelax\n1: beq a0, a1, 1b+1",
+		"fadd.d fa0, a1, fa2",
+		"fmv.x.d fa0, fa1",
+		"fadd.d fa0, fa1, fa2, up",
+		"fsgnj.d fa0, fa1, fa2, rtz",
+		"fld fa0, sym",
+		"ld a0, 0(fa0)",
+	} {
+		lex := NewBytesLexer([]byte(src))
+		lex.syntax = SyntaxATT
+		p := NewParser(lex)
+		p.setArch(arch.Set("riscv64"))
+		if err := p.ParseFile(); err == nil && p.Codegen() == nil {
+			t.Errorf("%q: 期望出错", src)
+		}
+	}
+}
+
+func TestRiscv64Diff(t *testing.T) {
+	// 与 llvm-mc 一致: 跨越可松弛指令的标签差值及被减符号不在当前段中的差值生成 R_RISCV_ADD/R_RISCV_SUB 重定位对,
+	// 不跨越可松弛指令的差值直接计算; norelax 时同一段内的差值不受影响
+	src := `
+1:	call puts
+2:	ret
+3:	.word 2b-1b
+	.data
+d:	.word 2b-1b
+	.half 2b-1b
+	.quad 2b-1b + 4
+	.byte 3b-2b
+	.quad ext-.
+	.word .-f
+	.word f-.
+	.section .rodata
+f:	.word 0
+	.text
+	.option norelax
+4:	call puts
+5:	.word 5b-4b
+`
+	p := assemble(t, "riscv64", SyntaxATT, src)
+	type rel struct {
+		typ    elf.R_RISCV
+		label  string
+		offset int
+		addend int64
+	}
+	var got []rel
+	for _, r := range p.relocateList {
+		if r.Section == ".data" || r.Section == ".text" && r.Offset == 10 {
+			got = append(got, rel{elf.R_RISCV(r.Type), r.Label, r.Offset, r.Addend})
+		}
+	}
+	l1, l2 := localName("1", 1), localName("2", 1)
+	want := []rel{
+		{elf.R_RISCV_ADD32, l2, 10, 0},
+		{elf.R_RISCV_SUB32, l1, 10, 0},
+		{elf.R_RISCV_ADD32, l2, 0, 0},
+		{elf.R_RISCV_SUB32, l1, 0, 0},
+		{elf.R_RISCV_ADD16, l2, 4, 0},
+		{elf.R_RISCV_SUB16, l1, 4, 0},
+		{elf.R_RISCV_ADD64, l2, 6, 4},
+		{elf.R_RISCV_SUB64, l1, 6, 0},
+		{elf.R_RISCV_ADD64, "ext", 15, 0},
+		{elf.R_RISCV_SUB64, "", 15, 0}, // 当前位置登记为临时符号
+		{elf.R_RISCV_ADD32, "", 23, 0},
+		{elf.R_RISCV_SUB32, "f", 23, 0},
+		{elf.R_RISCV_32_PCREL, ".rodata", 27, 0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("重定位: got %+v, want %+v", got, want)
+	}
+	for i, w := range want {
+		if w.label == "" && strings.HasPrefix(got[i].label, ".Ltmp$") {
+			w.label = got[i].label
+		}
+		if got[i] != w {
+			t.Errorf("第 %d 项: got %+v, want %+v", i, got[i], w)
+		}
+	}
+	if data := p.findSection(".data").Data; data[14] != 2 { // 3b-2b 之间只有 ret
+		t.Errorf(".byte 3b-2b: got %d, want 2", data[14])
+	}
+	if text := p.findSection(".text").Data; !bytes.Equal(text[len(text)-4:], []byte{8, 0, 0, 0}) {
+		t.Errorf("norelax: got % x, want 08 00 00 00", text[len(text)-4:])
+	}
+	if lb := p.GetLabel(l1); !lb.Keep {
+		t.Errorf("%s 应输出到符号表", l1)
+	}
+}
@@ -0,0 +1,988 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/facelang/face/internal/arch"
+	"github.com/facelang/face/internal/os/elf"
+	"math/bits"
+	"slices"
+	"strings"
+)
+
+// nop 指令: addi x0, x0, 0 及压缩指令 c.nop, 也用于代码段的对齐填充
+const (
+	rvNop  = 0x13
+	rvCNop = 0x01
+)
+
+// 指令格式的主操作码
+const (
+	rvLoad   = 0x03
+	rvFence  = 0x0F
+	rvOpImm  = 0x13
+	rvAuipc  = 0x17
+	rvOpImmW = 0x1B
+	rvStore  = 0x23
+	rvAmo    = 0x2F
+	rvOp     = 0x33
+	rvLui    = 0x37
+	rvOpW    = 0x3B
+	rvBranch = 0x63
+	rvJalr   = 0x67
+	rvJal    = 0x6F
+	rvSystem = 0x73
+
+	rvLoadFP  = 0x07
+	rvStoreFP = 0x27
+	rvMadd    = 0x43
+	rvMsub    = 0x47
+	rvNmsub   = 0x4B
+	rvNmadd   = 0x4F
+	rvOpFP    = 0x53
+)
+
+// rvOpcode 基本指令的编码: 主操作码、funct3 及 funct7(移位指令为 imm[11:5], 原子指令为 funct5)
+type rvOpcode struct{ Op, F3, F7 uint32 }
+
+var rvOpcodes = map[arch.As]rvOpcode{
+	arch.RV_BEQ: {rvBranch, 0, 0}, arch.RV_BNE: {rvBranch, 1, 0}, arch.RV_BLT: {rvBranch, 4, 0},
+	arch.RV_BGE: {rvBranch, 5, 0}, arch.RV_BLTU: {rvBranch, 6, 0}, arch.RV_BGEU: {rvBranch, 7, 0},
+
+	arch.RV_LB: {rvLoad, 0, 0}, arch.RV_LH: {rvLoad, 1, 0}, arch.RV_LW: {rvLoad, 2, 0}, arch.RV_LD: {rvLoad, 3, 0},
+	arch.RV_LBU: {rvLoad, 4, 0}, arch.RV_LHU: {rvLoad, 5, 0}, arch.RV_LWU: {rvLoad, 6, 0},
+	arch.RV_SB: {rvStore, 0, 0}, arch.RV_SH: {rvStore, 1, 0}, arch.RV_SW: {rvStore, 2, 0}, arch.RV_SD: {rvStore, 3, 0},
+	arch.RV_FLW: {rvLoadFP, 2, 0}, arch.RV_FLD: {rvLoadFP, 3, 0}, arch.RV_FSW: {rvStoreFP, 2, 0}, arch.RV_FSD: {rvStoreFP, 3, 0},
+
+	arch.RV_ADDI: {rvOpImm, 0, 0}, arch.RV_SLTI: {rvOpImm, 2, 0}, arch.RV_SLTIU: {rvOpImm, 3, 0},
+	arch.RV_XORI: {rvOpImm, 4, 0}, arch.RV_ORI: {rvOpImm, 6, 0}, arch.RV_ANDI: {rvOpImm, 7, 0},
+	arch.RV_ADDIW: {rvOpImmW, 0, 0},
+	arch.RV_SLLI:  {rvOpImm, 1, 0}, arch.RV_SRLI: {rvOpImm, 5, 0}, arch.RV_SRAI: {rvOpImm, 5, 0x20},
+	arch.RV_SLLIW: {rvOpImmW, 1, 0}, arch.RV_SRLIW: {rvOpImmW, 5, 0}, arch.RV_SRAIW: {rvOpImmW, 5, 0x20},
+
+	arch.RV_ADD: {rvOp, 0, 0}, arch.RV_SUB: {rvOp, 0, 0x20}, arch.RV_SLL: {rvOp, 1, 0}, arch.RV_SLT: {rvOp, 2, 0},
+	arch.RV_SLTU: {rvOp, 3, 0}, arch.RV_XOR: {rvOp, 4, 0}, arch.RV_SRL: {rvOp, 5, 0}, arch.RV_SRA: {rvOp, 5, 0x20},
+	arch.RV_OR: {rvOp, 6, 0}, arch.RV_AND: {rvOp, 7, 0},
+	arch.RV_ADDW: {rvOpW, 0, 0}, arch.RV_SUBW: {rvOpW, 0, 0x20}, arch.RV_SLLW: {rvOpW, 1, 0},
+	arch.RV_SRLW: {rvOpW, 5, 0}, arch.RV_SRAW: {rvOpW, 5, 0x20},
+
+	arch.RV_MUL: {rvOp, 0, 1}, arch.RV_MULH: {rvOp, 1, 1}, arch.RV_MULHSU: {rvOp, 2, 1}, arch.RV_MULHU: {rvOp, 3, 1},
+	arch.RV_DIV: {rvOp, 4, 1}, arch.RV_DIVU: {rvOp, 5, 1}, arch.RV_REM: {rvOp, 6, 1}, arch.RV_REMU: {rvOp, 7, 1},
+	arch.RV_MULW: {rvOpW, 0, 1}, arch.RV_DIVW: {rvOpW, 4, 1}, arch.RV_DIVUW: {rvOpW, 5, 1},
+	arch.RV_REMW: {rvOpW, 6, 1}, arch.RV_REMUW: {rvOpW, 7, 1},
+
+	arch.RV_LRW: {rvAmo, 2, 0x02}, arch.RV_SCW: {rvAmo, 2, 0x03}, arch.RV_AMOSWAPW: {rvAmo, 2, 0x01},
+	arch.RV_AMOADDW: {rvAmo, 2, 0x00}, arch.RV_AMOXORW: {rvAmo, 2, 0x04}, arch.RV_AMOANDW: {rvAmo, 2, 0x0C},
+	arch.RV_AMOORW: {rvAmo, 2, 0x08}, arch.RV_AMOMINW: {rvAmo, 2, 0x10}, arch.RV_AMOMAXW: {rvAmo, 2, 0x14},
+	arch.RV_AMOMINUW: {rvAmo, 2, 0x18}, arch.RV_AMOMAXUW: {rvAmo, 2, 0x1C},
+	arch.RV_LRD: {rvAmo, 3, 0x02}, arch.RV_SCD: {rvAmo, 3, 0x03}, arch.RV_AMOSWAPD: {rvAmo, 3, 0x01},
+	arch.RV_AMOADDD: {rvAmo, 3, 0x00}, arch.RV_AMOXORD: {rvAmo, 3, 0x04}, arch.RV_AMOANDD: {rvAmo, 3, 0x0C},
+	arch.RV_AMOORD: {rvAmo, 3, 0x08}, arch.RV_AMOMIND: {rvAmo, 3, 0x10}, arch.RV_AMOMAXD: {rvAmo, 3, 0x14},
+	arch.RV_AMOMINUD: {rvAmo, 3, 0x18}, arch.RV_AMOMAXUD: {rvAmo, 3, 0x1C},
+
+	arch.RV_CSRRW: {rvSystem, 1, 0}, arch.RV_CSRRS: {rvSystem, 2, 0}, arch.RV_CSRRC: {rvSystem, 3, 0},
+	arch.RV_CSRRWI: {rvSystem, 5, 0}, arch.RV_CSRRSI: {rvSystem, 6, 0}, arch.RV_CSRRCI: {rvSystem, 7, 0},
+}
+
+// rvFloat 浮点指令的编码: funct7(funct5 及精度, 单精度为 0, 双精度为 1), 固定的 funct3 或默认的舍入模式,
+// 单个源操作数的指令中 rs2 字段的值(转换指令为整数类型); R4 格式的乘加指令 Op 为主操作码, F7 只有精度.
+// 结果精确的转换(fcvt.d.s, fcvt.d.w, fcvt.d.wu)与 GAS 一致, 默认舍入模式为 rne
+type rvFloat struct {
+	Op, F7, F3, Rs2 uint32
+	Args            string // 操作数依次为浮点(f)或整数(x)寄存器
+	RM              bool   // 最后可以指定舍入模式
+}
+
+const rvDyn = 7 // 使用 frm 寄存器中的舍入模式
+
+var rvFloats = map[arch.As]rvFloat{
+	arch.RV_FMADDS: {rvMadd, 0, rvDyn, 0, "ffff", true}, arch.RV_FMSUBS: {rvMsub, 0, rvDyn, 0, "ffff", true},
+	arch.RV_FNMSUBS: {rvNmsub, 0, rvDyn, 0, "ffff", true}, arch.RV_FNMADDS: {rvNmadd, 0, rvDyn, 0, "ffff", true},
+	arch.RV_FADDS: {rvOpFP, 0x00, rvDyn, 0, "fff", true}, arch.RV_FSUBS: {rvOpFP, 0x04, rvDyn, 0, "fff", true},
+	arch.RV_FMULS: {rvOpFP, 0x08, rvDyn, 0, "fff", true}, arch.RV_FDIVS: {rvOpFP, 0x0C, rvDyn, 0, "fff", true},
+	arch.RV_FSQRTS: {rvOpFP, 0x2C, rvDyn, 0, "ff", true},
+	arch.RV_FSGNJS: {rvOpFP, 0x10, 0, 0, "fff", false}, arch.RV_FSGNJNS: {rvOpFP, 0x10, 1, 0, "fff", false},
+	arch.RV_FSGNJXS: {rvOpFP, 0x10, 2, 0, "fff", false},
+	arch.RV_FMINS:   {rvOpFP, 0x14, 0, 0, "fff", false}, arch.RV_FMAXS: {rvOpFP, 0x14, 1, 0, "fff", false},
+	arch.RV_FCVTWS: {rvOpFP, 0x60, rvDyn, 0, "xf", true}, arch.RV_FCVTWUS: {rvOpFP, 0x60, rvDyn, 1, "xf", true},
+	arch.RV_FCVTLS: {rvOpFP, 0x60, rvDyn, 2, "xf", true}, arch.RV_FCVTLUS: {rvOpFP, 0x60, rvDyn, 3, "xf", true},
+	arch.RV_FMVXW: {rvOpFP, 0x70, 0, 0, "xf", false}, arch.RV_FCLASSS: {rvOpFP, 0x70, 1, 0, "xf", false},
+	arch.RV_FEQS: {rvOpFP, 0x50, 2, 0, "xff", false}, arch.RV_FLTS: {rvOpFP, 0x50, 1, 0, "xff", false},
+	arch.RV_FLES:   {rvOpFP, 0x50, 0, 0, "xff", false},
+	arch.RV_FCVTSW: {rvOpFP, 0x68, rvDyn, 0, "fx", true}, arch.RV_FCVTSWU: {rvOpFP, 0x68, rvDyn, 1, "fx", true},
+	arch.RV_FCVTSL: {rvOpFP, 0x68, rvDyn, 2, "fx", true}, arch.RV_FCVTSLU: {rvOpFP, 0x68, rvDyn, 3, "fx", true},
+	arch.RV_FMVWX: {rvOpFP, 0x78, 0, 0, "fx", false},
+
+	arch.RV_FMADDD: {rvMadd, 1, rvDyn, 0, "ffff", true}, arch.RV_FMSUBD: {rvMsub, 1, rvDyn, 0, "ffff", true},
+	arch.RV_FNMSUBD: {rvNmsub, 1, rvDyn, 0, "ffff", true}, arch.RV_FNMADDD: {rvNmadd, 1, rvDyn, 0, "ffff", true},
+	arch.RV_FADDD: {rvOpFP, 0x01, rvDyn, 0, "fff", true}, arch.RV_FSUBD: {rvOpFP, 0x05, rvDyn, 0, "fff", true},
+	arch.RV_FMULD: {rvOpFP, 0x09, rvDyn, 0, "fff", true}, arch.RV_FDIVD: {rvOpFP, 0x0D, rvDyn, 0, "fff", true},
+	arch.RV_FSQRTD: {rvOpFP, 0x2D, rvDyn, 0, "ff", true},
+	arch.RV_FSGNJD: {rvOpFP, 0x11, 0, 0, "fff", false}, arch.RV_FSGNJND: {rvOpFP, 0x11, 1, 0, "fff", false},
+	arch.RV_FSGNJXD: {rvOpFP, 0x11, 2, 0, "fff", false},
+	arch.RV_FMIND:   {rvOpFP, 0x15, 0, 0, "fff", false}, arch.RV_FMAXD: {rvOpFP, 0x15, 1, 0, "fff", false},
+	arch.RV_FCVTSD: {rvOpFP, 0x20, rvDyn, 1, "ff", true}, arch.RV_FCVTDS: {rvOpFP, 0x21, 0, 0, "ff", true},
+	arch.RV_FCVTWD: {rvOpFP, 0x61, rvDyn, 0, "xf", true}, arch.RV_FCVTWUD: {rvOpFP, 0x61, rvDyn, 1, "xf", true},
+	arch.RV_FCVTLD: {rvOpFP, 0x61, rvDyn, 2, "xf", true}, arch.RV_FCVTLUD: {rvOpFP, 0x61, rvDyn, 3, "xf", true},
+	arch.RV_FMVXD: {rvOpFP, 0x71, 0, 0, "xf", false}, arch.RV_FCLASSD: {rvOpFP, 0x71, 1, 0, "xf", false},
+	arch.RV_FEQD: {rvOpFP, 0x51, 2, 0, "xff", false}, arch.RV_FLTD: {rvOpFP, 0x51, 1, 0, "xff", false},
+	arch.RV_FLED:   {rvOpFP, 0x51, 0, 0, "xff", false},
+	arch.RV_FCVTDW: {rvOpFP, 0x69, 0, 0, "fx", true}, arch.RV_FCVTDWU: {rvOpFP, 0x69, 0, 1, "fx", true},
+	arch.RV_FCVTDL: {rvOpFP, 0x69, rvDyn, 2, "fx", true}, arch.RV_FCVTDLU: {rvOpFP, 0x69, rvDyn, 3, "fx", true},
+	arch.RV_FMVDX: {rvOpFP, 0x79, 0, 0, "fx", false},
+}
+
+// rvRoundingModes 浮点指令的舍入模式
+var rvRoundingModes = map[string]uint32{"rne": 0, "rtz": 1, "rdn": 2, "rup": 3, "rmm": 4, "dyn": rvDyn}
+
+// rvEncoder RISC-V 指令编码, 记录第一个错误后继续, 由调用者统一报告
+type rvEncoder struct {
+	name   string
+	args   []*rvArg
+	rvc    bool   // 可以压缩的指令使用 16 位编码
+	relax  bool   // 允许链接器松弛
+	pic    bool   // 位置无关代码, la 从 GOT 加载符号地址
+	hi     string // 伪指令中 auipc 所在位置的标签名称, %pcrel_lo 引用该标签
+	hiUsed bool   // 是否使用了 hi 标签
+	insn   insn
+	err    error
+}
+
+func (e *rvEncoder) errorf(format string, args ...any) {
+	if e.err == nil {
+		e.err = fmt.Errorf(format, args...)
+	}
+}
+
+// emit 输出一条指令, 没有符号引用且可以压缩时使用 16 位编码
+func (e *rvEncoder) emit(word uint32) {
+	if e.rvc && !e.fixed() {
+		if c, ok := rvCompress(word); ok {
+			e.insn.Code = binary.LittleEndian.AppendUint16(e.insn.Code, c)
+			return
+		}
+	}
+	e.insn.Code = binary.LittleEndian.AppendUint32(e.insn.Code, word)
+}
+
+// fixed 下一条指令是否有符号引用, 回填的字段只存在于 32 位编码中
+func (e *rvEncoder) fixed() bool {
+	n := len(e.insn.Fixups)
+	return n > 0 && e.insn.Fixups[n-1].Offset == len(e.insn.Code)
+}
+
+// fixup 下一条指令中的符号引用; 跳转目标在禁止链接器松弛时可以直接回填同一段内的局部符号,
+// 其余重定位在允许松弛时附加 R_RISCV_RELAX
+func (e *rvEncoder) fixup(sym *operand, reloc elf.R_RISCV, pcRel bool) {
+	if !sym.Symbolic() {
+		e.errorf("%s 需要引用符号", reloc)
+		return
+	}
+	jump := reloc == elf.R_RISCV_BRANCH || reloc == elf.R_RISCV_JAL || reloc == elf.R_RISCV_CALL || reloc == elf.R_RISCV_CALL_PLT
+	e.insn.Fixups = append(e.insn.Fixups, fixup{
+		Offset: len(e.insn.Code),
+		Size:   4,
+		Label:  sym.Label,
+		Expr:   sym.Expr,
+		Addend: sym.Value,
+		PCRel:  pcRel,
+		Reloc:  int(reloc),
+		Keep:   e.relax || !jump,
+		SymRef: e.relax || reloc == elf.R_RISCV_PCREL_LO12_I || reloc == elf.R_RISCV_PCREL_LO12_S, // 松弛会移动代码, 不能使用 段 + 偏移 引用局部符号
+	})
+	if e.relax && reloc != elf.R_RISCV_BRANCH && reloc != elf.R_RISCV_JAL && reloc != elf.R_RISCV_GOT_HI20 { // GOT 引用不松弛, 与 llvm-mc 一致
+		e.insn.Fixups = append(e.insn.Fixups, fixup{Offset: len(e.insn.Code), Reloc: int(elf.R_RISCV_RELAX)})
+	}
+}
+
+// count 检查操作数个数
+func (e *rvEncoder) count(lo, hi int) {
+	switch n := len(e.args); {
+	case lo == hi && n != lo:
+		e.errorf("需要 %d 个操作数", lo)
+	case n < lo || n > hi:
+		e.errorf("需要 %d 至 %d 个操作数", lo, hi)
+	}
+}
+
+// arg 第 i 个操作数, 类型不符时报错
+func (e *rvEncoder) arg(i int, kind rvKind) *rvArg {
+	if i >= len(e.args) {
+		e.errorf("缺少第 %d 个操作数", i+1)
+		return &rvArg{Kind: kind, Sym: &operand{}}
+	}
+	if a := e.args[i]; a.Kind != kind {
+		e.errorf("第 %d 个操作数应为%s", i+1, rvKindNames[kind])
+	}
+	return e.args[i]
+}
+
+// kind 第 i 个操作数的类型, 不存在时为 0
+func (e *rvEncoder) kind(i int) rvKind {
+	if i < len(e.args) {
+		return e.args[i].Kind
+	}
+	return 0
+}
+
+// reg 第 i 个操作数, 整数寄存器的编号
+func (e *rvEncoder) reg(i int) uint32 {
+	a := e.arg(i, rvReg)
+	if a.Reg >= arch.REG_RV_F0 {
+		e.errorf("第 %d 个操作数应为整数寄存器", i+1)
+	}
+	return uint32(a.Reg)
+}
+
+// freg 第 i 个操作数, 浮点寄存器的编号
+func (e *rvEncoder) freg(i int) uint32 {
+	a := e.arg(i, rvReg)
+	if a.Kind == rvReg && a.Reg < arch.REG_RV_F0 {
+		e.errorf("第 %d 个操作数应为浮点寄存器", i+1)
+	}
+	return uint32(a.Reg) & 31
+}
+
+// rm 第 i 个操作数, 舍入模式
+func (e *rvEncoder) rm(i int) uint32 {
+	mode, ok := rvRoundingModes[e.arg(i, rvName).Name]
+	if !ok {
+		e.errorf("无效的舍入模式: %s", e.args[i].Name)
+	}
+	return mode
+}
+
+// imm 第 i 个操作数, 有符号 n 位常量
+func (e *rvEncoder) imm(i int, n uint) int64 {
+	v := e.arg(i, rvImm).Imm
+	if v < -1<<(n-1) || v >= 1<<(n-1) {
+		e.errorf("立即数 %d 超出 %d 位有符号数的范围", v, n)
+	}
+	return v
+}
+
+// uimm 第 i 个操作数, 无符号 n 位常量
+func (e *rvEncoder) uimm(i int, n uint) int64 {
+	v := e.arg(i, rvImm).Imm
+	if v < 0 || v >= 1<<n {
+		e.errorf("立即数 %d 超出 %d 位无符号数的范围", v, n)
+	}
+	return v
+}
+
+// lo12 12 位立即数: 常量、%lo(sym) 或 %pcrel_lo(label), 符号引用记录为下一条指令的 fixup
+func (e *rvEncoder) lo12(a *rvArg, store bool) int64 {
+	switch {
+	case a.Kind == rvImm || a.Kind == rvMem && a.Sym == nil:
+		if a.Imm < -2048 || a.Imm > 2047 {
+			e.errorf("立即数 %d 超出 12 位有符号数的范围", a.Imm)
+		}
+		return a.Imm
+	case a.Mod == "lo" && !a.Sym.Symbolic():
+		return a.Sym.Value << 52 >> 52
+	case a.Mod == "lo" || a.Mod == "pcrel_lo":
+		reloc := rvMods[a.Mod]
+		if store {
+			reloc++ // LO12_S 紧跟在 LO12_I 之后
+		}
+		e.fixup(a.Sym, reloc, false)
+	default:
+		e.errorf("需要 12 位立即数、%%lo 或 %%pcrel_lo")
+	}
+	return 0
+}
+
+// hi20 lui/auipc 的 20 位立即数: 常量或 %hi/%pcrel_hi/%got_pcrel_hi 修饰的符号, mods 为指令接受的修饰
+func (e *rvEncoder) hi20(a *rvArg, mods ...string) int64 {
+	switch {
+	case a.Kind == rvImm:
+		if a.Imm < 0 || a.Imm > 0xFFFFF {
+			e.errorf("立即数 %d 超出 20 位无符号数的范围", a.Imm)
+		}
+		return a.Imm
+	case a.Kind != rvSym || !slices.Contains(mods, a.Mod):
+		e.errorf("需要 20 位立即数或 %%%s", strings.Join(mods, ", %"))
+	case a.Mod == "hi" && !a.Sym.Symbolic():
+		return (a.Sym.Value + 0x800) >> 12 & 0xFFFFF
+	default:
+		e.fixup(a.Sym, rvMods[a.Mod], a.Mod != "hi")
+	}
+	return 0
+}
+
+// target 跳转目标: 符号记录为下一条指令的 fixup, 常量作为相对当前指令的偏移
+func (e *rvEncoder) target(i int, reloc elf.R_RISCV) int64 {
+	if i >= len(e.args) {
+		e.errorf("缺少第 %d 个操作数", i+1)
+		return 0
+	}
+	switch a := e.args[i]; a.Kind {
+	case rvSym:
+		if a.Mod != "" && a.Mod != "plt" {
+			e.errorf("跳转目标不能使用 %%%s", a.Mod)
+		}
+		e.fixup(a.Sym, reloc, true)
+	case rvImm:
+		return a.Imm
+	default:
+		e.errorf("第 %d 个操作数应为跳转目标", i+1)
+	}
+	return 0
+}
+
+// mem 第 i 个操作数为内存引用, 返回基址寄存器, 偏移为 12 位立即数
+func (e *rvEncoder) mem(i int, store bool) (uint32, int64) {
+	a := e.arg(i, rvMem)
+	if a.Kind != rvMem {
+		return 0, 0
+	}
+	return uint32(a.Reg), e.lo12(a, store)
+}
+
+// 各类指令格式
+func rvR(op rvOpcode, rd, rs1, rs2 uint32) uint32 {
+	return op.F7<<25 | rs2<<20 | rs1<<15 | op.F3<<12 | rd<<7 | op.Op
+}
+
+func rvI(op, f3, rd, rs1 uint32, imm int64) uint32 {
+	return uint32(imm)&0xFFF<<20 | rs1<<15 | f3<<12 | rd<<7 | op
+}
+
+func rvS(op, f3, rs1, rs2 uint32, imm int64) uint32 {
+	v := uint32(imm)
+	return v>>5&0x7F<<25 | rs2<<20 | rs1<<15 | f3<<12 | v&0x1F<<7 | op
+}
+
+func rvU(op, rd uint32, imm int64) uint32 {
+	return uint32(imm)&0xFFFFF<<12 | rd<<7 | op
+}
+
+// rvBImm B 型指令的 13 位偏移字段
+func rvBImm(off int64) uint32 {
+	v := uint32(off)
+	return v>>12&1<<31 | v>>5&0x3F<<25 | v>>1&0xF<<8 | v>>11&1<<7
+}
+
+// rvCBImm 压缩条件跳转(CB 型)的 9 位偏移字段
+func rvCBImm(off int64) uint16 {
+	v := uint16(off)
+	return v>>8&1<<12 | v>>3&3<<10 | v>>6&3<<5 | v>>1&3<<3 | v>>5&1<<2
+}
+
+// rvCJImm 压缩无条件跳转(CJ 型)的 12 位偏移字段
+func rvCJImm(off int64) uint16 {
+	v := uint16(off)
+	return v>>11&1<<12 | v>>4&1<<11 | v>>8&3<<9 | v>>10&1<<8 | v>>6&1<<7 | v>>7&1<<6 | v>>1&7<<3 | v>>5&1<<2
+}
+
+// rvShortReach 压缩跳转指令偏移的有符号位数
+func rvShortReach(reloc int) uint {
+	if elf.R_RISCV(reloc) == elf.R_RISCV_RVC_JUMP {
+		return 12
+	}
+	return 9
+}
+
+// rvJImm J 型指令的 21 位偏移字段
+func rvJImm(off int64) uint32 {
+	v := uint32(off)
+	return v>>20&1<<31 | v>>1&0x3FF<<21 | v>>11&1<<20 | v>>12&0xFF<<12
+}
+
+// rvHiLo 32 位值拆分为 lui/auipc 的高 20 位及 addi 等指令的有符号低 12 位
+func rvHiLo(v int64) (int64, int64) {
+	return (v + 0x800) >> 12 & 0xFFFFF, v << 52 >> 52
+}
+
+func (e *rvEncoder) encode(as arch.As) {
+	if op, ok := rvOpcodes[as]; ok {
+		e.base(as, op)
+		return
+	}
+	if f, ok := rvFloats[as]; ok {
+		e.float(f)
+		return
+	}
+	zero, ra := uint32(arch.REG_RV_ZERO), uint32(arch.REG_RV_RA)
+	switch as {
+	case arch.RV_LUI, arch.RV_AUIPC:
+		e.count(2, 2)
+		rd, op, mods := e.reg(0), uint32(rvLui), []string{"hi"}
+		if as == arch.RV_AUIPC {
+			op, mods = rvAuipc, []string{"pcrel_hi", "got_pcrel_hi"}
+		}
+		e.emit(rvU(op, rd, e.hi20(e.arg(1, e.kind(1)), mods...)))
+	case arch.RV_JAL, arch.RV_J: // jal [rd,] target; j target
+		rd, i := ra, 0
+		if as == arch.RV_J {
+			e.count(1, 1)
+			rd = zero
+		} else if e.count(1, 2); len(e.args) == 2 {
+			rd, i = e.reg(0), 1
+		}
+		e.jal(rd, e.target(i, elf.R_RISCV_JAL))
+	case arch.RV_JALR: // jalr rs; jalr rd, rs[, imm]; jalr rd, imm(rs)
+		e.count(1, 3)
+		switch {
+		case len(e.args) == 1:
+			e.emit(rvI(rvJalr, 0, ra, e.reg(0), 0))
+		case e.kind(1) == rvMem:
+			e.count(2, 2)
+			rs, off := e.mem(1, false)
+			e.emit(rvI(rvJalr, 0, e.reg(0), rs, off))
+		case len(e.args) == 3:
+			e.emit(rvI(rvJalr, 0, e.reg(0), e.reg(1), e.lo12(e.args[2], false)))
+		default:
+			e.emit(rvI(rvJalr, 0, e.reg(0), e.reg(1), 0))
+		}
+	case arch.RV_JR:
+		e.count(1, 1)
+		e.emit(rvI(rvJalr, 0, zero, e.reg(0), 0))
+	case arch.RV_RET:
+		e.count(0, 0)
+		e.emit(rvI(rvJalr, 0, zero, ra, 0))
+	case arch.RV_CALL, arch.RV_TAIL: // auipc + jalr, 由 R_RISCV_CALL 同时回填两条指令
+		e.count(1, 1)
+		tmp, rd := ra, ra
+		if as == arch.RV_TAIL {
+			tmp, rd = arch.REG_RV_T1, zero
+		}
+		reloc := elf.R_RISCV_CALL
+		if e.kind(0) == rvSym && e.args[0].Mod == "plt" {
+			reloc = elf.R_RISCV_CALL_PLT
+		}
+		if e.target(0, reloc); e.kind(0) != rvSym {
+			e.errorf("调用目标应为符号")
+		}
+		e.insn.Code = binary.LittleEndian.AppendUint32(e.insn.Code, rvU(rvAuipc, tmp, 0))
+		e.insn.Code = binary.LittleEndian.AppendUint32(e.insn.Code, rvI(rvJalr, 0, rd, tmp, 0))
+	case arch.RV_BEQZ, arch.RV_BNEZ, arch.RV_BLTZ, arch.RV_BGEZ: // rs 与 x0 比较
+		e.count(2, 2)
+		op := map[arch.As]arch.As{arch.RV_BEQZ: arch.RV_BEQ, arch.RV_BNEZ: arch.RV_BNE, arch.RV_BLTZ: arch.RV_BLT, arch.RV_BGEZ: arch.RV_BGE}[as]
+		e.branch(rvOpcodes[op], e.reg(0), zero, 1)
+	case arch.RV_BGTZ, arch.RV_BLEZ: // x0 与 rs 比较
+		e.count(2, 2)
+		op := map[arch.As]arch.As{arch.RV_BGTZ: arch.RV_BLT, arch.RV_BLEZ: arch.RV_BGE}[as]
+		e.branch(rvOpcodes[op], zero, e.reg(0), 1)
+	case arch.RV_BGT, arch.RV_BLE, arch.RV_BGTU, arch.RV_BLEU: // 交换操作数
+		e.count(3, 3)
+		op := map[arch.As]arch.As{arch.RV_BGT: arch.RV_BLT, arch.RV_BLE: arch.RV_BGE, arch.RV_BGTU: arch.RV_BLTU, arch.RV_BLEU: arch.RV_BGEU}[as]
+		e.branch(rvOpcodes[op], e.reg(1), e.reg(0), 2)
+	case arch.RV_FENCE:
+		e.count(0, 2)
+		pred, succ := uint32(0xF), uint32(0xF)
+		if len(e.args) > 0 {
+			e.count(2, 2)
+			pred, succ = e.fence(0), e.fence(1)
+		}
+		e.emit(pred<<24 | succ<<20 | rvFence)
+	case arch.RV_FENCEI:
+		e.count(0, 0)
+		e.emit(1<<12 | rvFence)
+	case arch.RV_ECALL:
+		e.count(0, 0)
+		e.emit(rvSystem)
+	case arch.RV_EBREAK:
+		e.count(0, 0)
+		e.emit(1<<20 | rvSystem)
+	case arch.RV_CSRR: // csrrs rd, csr, x0
+		e.count(2, 2)
+		e.emit(e.csr(1)<<20 | 2<<12 | e.reg(0)<<7 | rvSystem)
+	case arch.RV_CSRW: // csrrw x0, csr, rs
+		e.count(2, 2)
+		e.emit(e.csr(0)<<20 | e.reg(1)<<15 | 1<<12 | rvSystem)
+	case arch.RV_NOP:
+		e.count(0, 0)
+		e.emit(rvNop)
+	case arch.RV_LI:
+		e.count(2, 2)
+		rd := e.reg(0)
+		if e.kind(1) == rvSym && e.args[1].Mod == "" { // 符号地址: lui + addi
+			sym := e.args[1]
+			e.emit(rvU(rvLui, rd, e.hi20(&rvArg{Kind: rvSym, Mod: "hi", Sym: sym.Sym}, "hi")))
+			e.emit(rvI(rvOpImm, 0, rd, rd, e.lo12(&rvArg{Kind: rvSym, Mod: "lo", Sym: sym.Sym}, false)))
+			break
+		}
+		e.li(rd, e.arg(1, rvImm).Imm)
+	case arch.RV_LA, arch.RV_LLA: // auipc + addi; 位置无关代码中的 la 为 auipc + ld, 从 GOT 加载
+		e.count(2, 2)
+		rd := e.reg(0)
+		if as == arch.RV_LA && e.pic {
+			e.pcrel(e.arg(1, rvSym), rd, elf.R_RISCV_GOT_HI20)
+			e.emit(rvI(rvLoad, 3, rd, rd, e.lo12(&rvArg{Kind: rvSym, Mod: "pcrel_lo", Sym: &operand{Label: e.hi}}, false)))
+			break
+		}
+		e.pcrel(e.arg(1, rvSym), rd, elf.R_RISCV_PCREL_HI20)
+		e.emit(rvI(rvOpImm, 0, rd, rd, e.lo12(&rvArg{Kind: rvSym, Mod: "pcrel_lo", Sym: &operand{Label: e.hi}}, false)))
+	case arch.RV_MV:
+		e.count(2, 2)
+		e.emit(rvI(rvOpImm, 0, e.reg(0), e.reg(1), 0))
+	case arch.RV_NOT:
+		e.count(2, 2)
+		e.emit(rvI(rvOpImm, 4, e.reg(0), e.reg(1), -1))
+	case arch.RV_NEG, arch.RV_NEGW:
+		e.count(2, 2)
+		op := rvOpcodes[arch.RV_SUB]
+		if as == arch.RV_NEGW {
+			op = rvOpcodes[arch.RV_SUBW]
+		}
+		e.emit(rvR(op, e.reg(0), zero, e.reg(1)))
+	case arch.RV_SEXTW:
+		e.count(2, 2)
+		e.emit(rvI(rvOpImmW, 0, e.reg(0), e.reg(1), 0))
+	case arch.RV_SEQZ:
+		e.count(2, 2)
+		e.emit(rvI(rvOpImm, 3, e.reg(0), e.reg(1), 1))
+	case arch.RV_SNEZ:
+		e.count(2, 2)
+		e.emit(rvR(rvOpcodes[arch.RV_SLTU], e.reg(0), zero, e.reg(1)))
+	case arch.RV_SLTZ:
+		e.count(2, 2)
+		e.emit(rvR(rvOpcodes[arch.RV_SLT], e.reg(0), e.reg(1), zero))
+	case arch.RV_SGTZ:
+		e.count(2, 2)
+		e.emit(rvR(rvOpcodes[arch.RV_SLT], e.reg(0), zero, e.reg(1)))
+	case arch.RV_FMVS, arch.RV_FNEGS, arch.RV_FABSS, arch.RV_FMVD, arch.RV_FNEGD, arch.RV_FABSD: // fsgnj* rd, rs, rs
+		e.count(2, 2)
+		f := rvFloats[map[arch.As]arch.As{
+			arch.RV_FMVS: arch.RV_FSGNJS, arch.RV_FNEGS: arch.RV_FSGNJNS, arch.RV_FABSS: arch.RV_FSGNJXS,
+			arch.RV_FMVD: arch.RV_FSGNJD, arch.RV_FNEGD: arch.RV_FSGNJND, arch.RV_FABSD: arch.RV_FSGNJXD,
+		}[as]]
+		rs := e.freg(1)
+		e.emit(rvR(rvOpcode{rvOpFP, f.F3, f.F7}, e.freg(0), rs, rs))
+	default:
+		e.errorf("不支持的指令")
+	}
+}
+
+// base 按 rvOpcodes 编码的基本指令
+func (e *rvEncoder) base(as arch.As, op rvOpcode) {
+	switch op.Op {
+	case rvOp, rvOpW:
+		e.count(3, 3)
+		e.emit(rvR(op, e.reg(0), e.reg(1), e.reg(2)))
+	case rvOpImm, rvOpImmW:
+		e.count(3, 3)
+		if op.F3 == 1 || op.F3 == 5 { // 移位
+			n := uint(6)
+			if op.Op == rvOpImmW {
+				n = 5
+			}
+			e.emit(rvI(op.Op, op.F3, e.reg(0), e.reg(1), int64(op.F7)<<5|e.uimm(2, n)))
+			break
+		}
+		e.emit(rvI(op.Op, op.F3, e.reg(0), e.reg(1), e.lo12(e.arg(2, e.kind(2)), false)))
+	case rvBranch:
+		e.count(3, 3)
+		e.branch(op, e.reg(0), e.reg(1), 2)
+	case rvLoad, rvLoadFP: // ld rd, off(rs); ld rd, sym; fld rd, sym, tmp
+		reg := e.reg
+		if op.Op == rvLoadFP {
+			reg = e.freg
+		}
+		rd := reg(0)
+		if e.kind(1) == rvSym && e.args[1].Mod == "" {
+			tmp := rd
+			if op.Op == rvLoadFP { // 浮点寄存器不能作为地址, 需要临时寄存器
+				e.count(3, 3)
+				tmp = e.reg(2)
+			} else {
+				e.count(2, 2)
+			}
+			e.pcrel(e.args[1], tmp, elf.R_RISCV_PCREL_HI20)
+			e.emit(rvI(op.Op, op.F3, rd, tmp, e.lo12(&rvArg{Kind: rvSym, Mod: "pcrel_lo", Sym: &operand{Label: e.hi}}, false)))
+			break
+		}
+		e.count(2, 2)
+		rs, off := e.mem(1, false)
+		e.emit(rvI(op.Op, op.F3, rd, rs, off))
+	case rvStore, rvStoreFP: // sd rs, off(rd); sd rs, sym, tmp
+		e.count(2, 3)
+		rs2 := e.reg
+		if op.Op == rvStoreFP {
+			rs2 = e.freg
+		}
+		if e.kind(1) == rvSym && e.args[1].Mod == "" {
+			if len(e.args) != 3 {
+				e.errorf("存储到符号地址需要临时寄存器")
+			}
+			tmp := e.reg(2)
+			e.pcrel(e.args[1], tmp, elf.R_RISCV_PCREL_HI20)
+			e.emit(rvS(op.Op, op.F3, tmp, rs2(0), e.lo12(&rvArg{Kind: rvSym, Mod: "pcrel_lo", Sym: &operand{Label: e.hi}}, true)))
+			break
+		}
+		e.count(2, 2)
+		rs1, off := e.mem(1, true)
+		e.emit(rvS(op.Op, op.F3, rs1, rs2(0), off))
+	case rvAmo: // lr.w rd, (rs1); sc.w/amo*.w rd, rs2, (rs1)
+		var rs2 uint32
+		i := 1
+		if as != arch.RV_LRW && as != arch.RV_LRD {
+			e.count(3, 3)
+			rs2, i = e.reg(1), 2
+		} else {
+			e.count(2, 2)
+		}
+		mem := e.arg(i, rvMem)
+		if mem.Imm != 0 || mem.Sym != nil {
+			e.errorf("原子指令的内存引用不能有偏移")
+		}
+		var order uint32 // aq, rl 位
+		switch {
+		case strings.HasSuffix(e.name, ".aqrl"):
+			order = 3
+		case strings.HasSuffix(e.name, ".aq"):
+			order = 2
+		case strings.HasSuffix(e.name, ".rl"):
+			order = 1
+		}
+		e.emit(rvR(rvOpcode{rvAmo, op.F3, op.F7<<2 | order}, e.reg(0), uint32(mem.Reg), rs2))
+	case rvSystem: // csrrw rd, csr, rs1; csrrwi rd, csr, uimm5
+		e.count(3, 3)
+		var src uint32
+		if op.F3 >= 5 {
+			src = uint32(e.uimm(2, 5))
+		} else {
+			src = e.reg(2)
+		}
+		e.emit(e.csr(1)<<20 | src<<15 | op.F3<<12 | e.reg(0)<<7 | rvSystem)
+	}
+}
+
+// float 按 rvFloats 编码的浮点指令
+func (e *rvEncoder) float(f rvFloat) {
+	n, f3 := len(f.Args), f.F3
+	if !f.RM {
+		e.count(n, n)
+	} else if e.count(n, n+1); len(e.args) == n+1 {
+		f3 = e.rm(n)
+	}
+	var regs [4]uint32 // rd, rs1, rs2, rs3
+	regs[2] = f.Rs2
+	for i, c := range f.Args {
+		if c == 'f' {
+			regs[i] = e.freg(i)
+		} else {
+			regs[i] = e.reg(i)
+		}
+	}
+	op := rvOpcode{f.Op, f3, f.F7}
+	if n == 4 {
+		op.F7 |= regs[3] << 2
+	}
+	e.emit(rvR(op, regs[0], regs[1], regs[2]))
+}
+
+// branch 条件跳转, 目标为第 i 个操作数
+func (e *rvEncoder) branch(op rvOpcode, rs1, rs2 uint32, i int) {
+	off := e.target(i, elf.R_RISCV_BRANCH)
+	if err := rvRange(off, 13); err != nil {
+		e.errorf("%s", err)
+	}
+	e.emit(rvBImm(off) | rs2<<20 | rs1<<15 | op.F3<<12 | rvBranch)
+	if rs2 == 0 && rs1 >= 8 && rs1 <= 15 && op.F3 <= 1 { // c.beqz, c.bnez
+		e.short(uint16(6+op.F3)<<13|uint16(rs1-8)<<7|1, elf.R_RISCV_RVC_BRANCH)
+	}
+}
+
+// jal 无条件跳转, off 为常量偏移, 符号目标已记录 fixup
+func (e *rvEncoder) jal(rd uint32, off int64) {
+	if err := rvRange(off, 21); err != nil {
+		e.errorf("%s", err)
+	}
+	e.emit(rvJImm(off) | rd<<7 | rvJal)
+	if rd == 0 { // c.j
+		e.short(5<<13|1, elf.R_RISCV_RVC_JUMP)
+	}
+}
+
+// short 跳转到符号的指令可以使用的 16 位压缩编码, 与 llvm-mc 一致, 由分支优化在目标为同一段内的局部符号
+// 且偏移在压缩指令的范围内时选用; 目标为全局或外部符号时保持 32 位编码, 由链接器松弛缩短
+func (e *rvEncoder) short(half uint16, reloc elf.R_RISCV) {
+	if !e.rvc || e.err != nil || len(e.insn.Code) != 4 || len(e.insn.Fixups) != 1 {
+		return
+	}
+	f := e.insn.Fixups[0]
+	f.Size, f.Reloc = 2, int(reloc)
+	e.insn.Short = &insn{Code: binary.LittleEndian.AppendUint16(nil, half), Fixups: []fixup{f}}
+}
+
+// pcrel 伪指令中 auipc rd, %pcrel_hi(sym) 或 %got_pcrel_hi(sym), 之后的指令通过 hi 标签引用
+func (e *rvEncoder) pcrel(a *rvArg, rd uint32, reloc elf.R_RISCV) {
+	if a.Kind != rvSym || a.Mod != "" {
+		e.errorf("需要符号地址")
+		return
+	}
+	e.hiUsed = true
+	e.fixup(a.Sym, reloc, true)
+	e.emit(rvU(rvAuipc, rd, 0))
+}
+
+// li 加载常量
+func (e *rvEncoder) li(rd uint32, val int64) {
+	seq := rvLi(rd, val)
+	if val > 0 && len(seq) > 2 { // 正数可以先生成左移后的值, 再逻辑右移恢复高位的 0
+		lz := bits.LeadingZeros64(uint64(val))
+		ones := uint64(1)<<lz - 1
+		for _, v := range []uint64{uint64(val)<<lz | ones, uint64(val) << lz} {
+			if s := append(rvLi(rd, int64(v)), rvI(rvOpImm, 5, rd, rd, int64(lz))); len(s) < len(seq) {
+				seq = s
+			}
+		}
+	}
+	for _, w := range seq {
+		e.emit(w)
+	}
+}
+
+// rvLi 加载常量的指令序列, 与 LLVM 的生成方式一致: 32 位以内为 lui + addiw(或单条 addi),
+// 否则去掉低 12 位及末尾的 0 后递归生成, 再左移并加上低 12 位
+func rvLi(rd uint32, val int64) []uint32 {
+	if val == int64(int32(val)) {
+		var seq []uint32
+		hi, lo := rvHiLo(val)
+		if hi != 0 {
+			seq = append(seq, rvU(rvLui, rd, hi))
+		}
+		switch {
+		case hi != 0 && lo != 0:
+			seq = append(seq, rvI(rvOpImmW, 0, rd, rd, lo))
+		case hi == 0:
+			seq = append(seq, rvI(rvOpImm, 0, rd, arch.REG_RV_ZERO, lo))
+		}
+		return seq
+	}
+	lo := val << 52 >> 52
+	val -= lo
+	shift := bits.TrailingZeros64(uint64(val))
+	val >>= shift
+	if shift > 12 && (val < -2048 || val > 2047) && val<<12 == int64(int32(val<<12)) { // 使用 lui 生成低 12 位的 0
+		shift -= 12
+		val <<= 12
+	}
+	seq := append(rvLi(rd, val), rvI(rvOpImm, 1, rd, rd, int64(shift)))
+	if lo != 0 {
+		seq = append(seq, rvI(rvOpImm, 0, rd, rd, lo))
+	}
+	return seq
+}
+
+// fence 第 i 个操作数的访问类型: i(输入), o(输出), r(读), w(写) 的组合
+func (e *rvEncoder) fence(i int) uint32 {
+	a := e.arg(i, rvName)
+	var set uint32
+	for _, c := range a.Name {
+		bit := strings.IndexRune("wroi", c)
+		if bit < 0 || set&(1<<bit) != 0 {
+			e.errorf("无效的访问类型: %s", a.Name)
+		}
+		set |= 1 << bit
+	}
+	return set
+}
+
+// csr 第 i 个操作数, 控制状态寄存器的名称或编号
+func (e *rvEncoder) csr(i int) uint32 {
+	switch e.kind(i) {
+	case rvName:
+		csr, ok := arch.RVCSRs[e.args[i].Name]
+		if !ok {
+			e.errorf("未知的控制状态寄存器: %s", e.args[i].Name)
+		}
+		return csr
+	case rvImm:
+		return uint32(e.uimm(i, 12))
+	}
+	e.errorf("第 %d 个操作数应为控制状态寄存器", i+1)
+	return 0
+}
+
+// rvRange 检查跳转偏移: 按 2 字节对齐, 在 n 位有符号数的范围内
+func rvRange(off int64, n uint) error {
+	if off&1 != 0 {
+		return fmt.Errorf("偏移 %d 没有按 2 字节对齐", off)
+	}
+	if off < -1<<(n-1) || off >= 1<<(n-1) {
+		return fmt.Errorf("偏移超出 ±%d 字节的范围", int64(1)<<(n-1))
+	}
+	return nil
+}
+
+// rvPatch 将符号引用的值回填到 code 开始的指令中; 生成重定位时值为 0, 指令保持不变
+func rvPatch(code []byte, reloc int, val int64) error {
+	switch r := elf.R_RISCV(reloc); r {
+	case elf.R_RISCV_RVC_BRANCH, elf.R_RISCV_RVC_JUMP: // 16 位指令
+		if err := rvRange(val, rvShortReach(reloc)); err != nil {
+			return err
+		}
+		half := binary.LittleEndian.Uint16(code)
+		if r == elf.R_RISCV_RVC_BRANCH {
+			half |= rvCBImm(val)
+		} else {
+			half |= rvCJImm(val)
+		}
+		binary.LittleEndian.PutUint16(code, half)
+		return nil
+	}
+	word := binary.LittleEndian.Uint32(code)
+	switch r := elf.R_RISCV(reloc); r {
+	case elf.R_RISCV_BRANCH:
+		if err := rvRange(val, 13); err != nil {
+			return err
+		}
+		word |= rvBImm(val)
+	case elf.R_RISCV_JAL:
+		if err := rvRange(val, 21); err != nil {
+			return err
+		}
+		word |= rvJImm(val)
+	case elf.R_RISCV_CALL, elf.R_RISCV_CALL_PLT: // auipc + jalr
+		if val != int64(int32(val)) {
+			return fmt.Errorf("偏移超出 ±2GB 的范围")
+		}
+		hi, lo := rvHiLo(val)
+		binary.LittleEndian.PutUint32(code[4:], binary.LittleEndian.Uint32(code[4:])|uint32(lo)&0xFFF<<20)
+		word |= uint32(hi) << 12
+	case elf.R_RISCV_HI20:
+		hi, _ := rvHiLo(val)
+		word |= uint32(hi) << 12
+	case elf.R_RISCV_LO12_I:
+		word |= uint32(val) & 0xFFF << 20
+	case elf.R_RISCV_LO12_S:
+		word |= rvS(0, 0, 0, 0, val)
+	default:
+		if val != 0 {
+			return fmt.Errorf("不支持回填 %s", r)
+		}
+	}
+	binary.LittleEndian.PutUint32(code, word)
+	return nil
+}
+
+// rvDataReloc 数据定义中的符号引用
+func rvDataReloc(f fixup) int {
+	switch {
+	case f.Size == 8 && !f.PCRel:
+		return int(elf.R_RISCV_64)
+	case f.Size == 4 && f.PCRel:
+		return int(elf.R_RISCV_32_PCREL)
+	case f.Size == 4:
+		return int(elf.R_RISCV_32)
+	}
+	return 0
+}
+
+// rvCompress 将 32 位指令转换为等价的 C 扩展 16 位指令, 不能压缩时返回 false
+func rvCompress(w uint32) (uint16, bool) {
+	op, rd, f3, rs1, rs2 := w&0x7F, w>>7&31, w>>12&7, w>>15&31, w>>20&31
+	imm := int64(int32(w) >> 20)
+	// x8-x15 可以使用 3 位寄存器字段
+	prime := func(r uint32) bool { return r >= 8 && r <= 15 }
+	fits6 := imm >= -32 && imm < 32
+	ci := func(f3, op uint32, rd uint32, v int64) uint16 { // CI 格式: imm[5] 在 12 位, imm[4:0] 在 2-6 位
+		return uint16(f3<<13 | uint32(v>>5&1)<<12 | rd<<7 | uint32(v&31)<<2 | op)
+	}
+	cb := func(f2, rd uint32, v int64) uint16 { // c.srli, c.srai, c.andi
+		return uint16(4<<13 | uint32(v>>5&1)<<12 | f2<<10 | (rd-8)<<7 | uint32(v&31)<<2 | 1)
+	}
+	u := uint32(imm)
+	switch op {
+	case rvOpImm:
+		switch {
+		case f3 == 0 && rd == 0 && rs1 == 0 && imm == 0:
+			return rvCNop, true
+		case f3 == 0 && rd != 0 && rs1 == 0 && fits6: // c.li
+			return ci(2, 1, rd, imm), true
+		case f3 == 0 && rd != 0 && rd == rs1 && imm != 0 && fits6: // c.addi
+			return ci(0, 1, rd, imm), true
+		case f3 == 0 && rd == 2 && rs1 == 2 && imm != 0 && imm&15 == 0 && imm >= -512 && imm < 512: // c.addi16sp
+			return uint16(3<<13 | u>>9&1<<12 | 2<<7 | u>>4&1<<6 | u>>6&1<<5 | u>>7&3<<3 | u>>5&1<<2 | 1), true
+		case f3 == 0 && prime(rd) && rs1 == 2 && imm > 0 && imm&3 == 0 && imm < 1024: // c.addi4spn
+			return uint16(u>>4&3<<11 | u>>6&15<<7 | u>>2&1<<6 | u>>3&1<<5 | (rd-8)<<2), true
+		case f3 == 0 && rd != 0 && rs1 != 0 && imm == 0: // c.mv
+			return uint16(8<<12 | rd<<7 | rs1<<2 | 2), true
+		case f3 == 1 && rd != 0 && rd == rs1 && imm > 0 && imm < 64: // c.slli
+			return ci(0, 2, rd, imm), true
+		case f3 == 5 && prime(rd) && rd == rs1 && imm&63 != 0 && (imm>>6 == 0 || imm>>6 == 0x10): // c.srli, c.srai
+			return cb(uint32(imm>>10&1), rd, imm&63), true
+		case f3 == 7 && prime(rd) && rd == rs1 && fits6: // c.andi
+			return cb(2, rd, imm), true
+		}
+	case rvOpImmW:
+		if f3 == 0 && rd != 0 && rd == rs1 && fits6 { // c.addiw
+			return ci(1, 1, rd, imm), true
+		}
+	case rvLui:
+		v := int64(int32(w) >> 12)
+		if rd != 0 && rd != 2 && v != 0 && v >= -32 && v < 32 { // c.lui
+			return ci(3, 1, rd, v), true
+		}
+	case rvOp, rvOpW:
+		f7 := w >> 25
+		if rd == rs2 && f7 == 0 && (f3 == 0 || op == rvOp && f3 >= 6 || op == rvOp && f3 == 4) { // 可交换的运算
+			rs1, rs2 = rs2, rs1
+		}
+		switch {
+		case op == rvOp && f3 == 0 && f7 == 0 && rd != 0 && rs2 != 0 && rd == rs1: // c.add
+			return uint16(9<<12 | rd<<7 | rs2<<2 | 2), true
+		case op == rvOp && f3 == 0 && f7 == 0 && rd != 0 && rs2 != 0 && rs1 == 0: // c.mv
+			return uint16(8<<12 | rd<<7 | rs2<<2 | 2), true
+		case !prime(rd) || rd != rs1 || !prime(rs2):
+		case op == rvOp && f7 == 0x20 && f3 == 0: // c.sub
+			return uint16(0x23<<10 | (rd-8)<<7 | (rs2-8)<<2 | 1), true
+		case op == rvOp && f7 == 0 && (f3 == 4 || f3 == 6 || f3 == 7): // c.xor, c.or, c.and
+			return uint16(0x23<<10 | (rd-8)<<7 | [8]uint32{4: 1, 6: 2, 7: 3}[f3]<<5 | (rs2-8)<<2 | 1), true
+		case op == rvOpW && f3 == 0 && (f7 == 0 || f7 == 0x20): // c.addw, c.subw
+			return uint16(0x27<<10 | (rd-8)<<7 | (1-f7>>5)<<5 | (rs2-8)<<2 | 1), true
+		}
+	case rvLoad:
+		switch {
+		case f3 == 2 && rs1 == 2 && rd != 0 && imm >= 0 && imm < 256 && imm&3 == 0: // c.lwsp
+			return uint16(2<<13 | u>>5&1<<12 | rd<<7 | u>>2&7<<4 | u>>6&3<<2 | 2), true
+		case f3 == 3 && rs1 == 2 && rd != 0 && imm >= 0 && imm < 512 && imm&7 == 0: // c.ldsp
+			return uint16(3<<13 | u>>5&1<<12 | rd<<7 | u>>3&3<<5 | u>>6&7<<2 | 2), true
+		case f3 == 2 && prime(rd) && prime(rs1) && imm >= 0 && imm < 128 && imm&3 == 0: // c.lw
+			return uint16(2<<13 | u>>3&7<<10 | (rs1-8)<<7 | u>>2&1<<6 | u>>6&1<<5 | (rd-8)<<2), true
+		case f3 == 3 && prime(rd) && prime(rs1) && imm >= 0 && imm < 256 && imm&7 == 0: // c.ld
+			return uint16(3<<13 | u>>3&7<<10 | (rs1-8)<<7 | u>>6&3<<5 | (rd-8)<<2), true
+		}
+	case rvLoadFP:
+		switch {
+		case f3 == 3 && rs1 == 2 && imm >= 0 && imm < 512 && imm&7 == 0: // c.fldsp
+			return uint16(1<<13 | u>>5&1<<12 | rd<<7 | u>>3&3<<5 | u>>6&7<<2 | 2), true
+		case f3 == 3 && prime(rd) && prime(rs1) && imm >= 0 && imm < 256 && imm&7 == 0: // c.fld
+			return uint16(1<<13 | u>>3&7<<10 | (rs1-8)<<7 | u>>6&3<<5 | (rd-8)<<2), true
+		}
+	case rvStoreFP:
+		s := int64(int32(w)>>25<<5) | int64(rd)
+		u = uint32(s)
+		switch {
+		case f3 == 3 && rs1 == 2 && s >= 0 && s < 512 && s&7 == 0: // c.fsdsp
+			return uint16(5<<13 | u>>3&7<<10 | u>>6&7<<7 | rs2<<2 | 2), true
+		case f3 == 3 && prime(rs2) && prime(rs1) && s >= 0 && s < 256 && s&7 == 0: // c.fsd
+			return uint16(5<<13 | u>>3&7<<10 | (rs1-8)<<7 | u>>6&3<<5 | (rs2-8)<<2), true
+		}
+	case rvStore:
+		s := int64(int32(w)>>25<<5) | int64(rd)
+		u = uint32(s)
+		switch {
+		case f3 == 2 && rs1 == 2 && s >= 0 && s < 256 && s&3 == 0: // c.swsp
+			return uint16(6<<13 | u>>2&15<<9 | u>>6&3<<7 | rs2<<2 | 2), true
+		case f3 == 3 && rs1 == 2 && s >= 0 && s < 512 && s&7 == 0: // c.sdsp
+			return uint16(7<<13 | u>>3&7<<10 | u>>6&7<<7 | rs2<<2 | 2), true
+		case f3 == 2 && prime(rs2) && prime(rs1) && s >= 0 && s < 128 && s&3 == 0: // c.sw
+			return uint16(6<<13 | u>>3&7<<10 | (rs1-8)<<7 | u>>2&1<<6 | u>>6&1<<5 | (rs2-8)<<2), true
+		case f3 == 3 && prime(rs2) && prime(rs1) && s >= 0 && s < 256 && s&7 == 0: // c.sd
+			return uint16(7<<13 | u>>3&7<<10 | (rs1-8)<<7 | u>>6&3<<5 | (rs2-8)<<2), true
+		}
+	case rvJalr:
+		if f3 == 0 && imm == 0 && rs1 != 0 && (rd == 0 || rd == 1) { // c.jr, c.jalr
+			return uint16((8|rd)<<12 | rs1<<7 | 2), true
+		}
+	case rvSystem:
+		if w == 1<<20|rvSystem { // c.ebreak
+			return 0x9002, true
+		}
+	}
+	return 0, false
+}
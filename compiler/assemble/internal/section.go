@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"strings"
+)
+
+// 段属性: 段名决定默认的类型及标志, AT&T 风格可以通过 .section 指定:
+//
+//	.section name[, "flags"[, @type[, entsize]]]
+//	.pushsection name[, ...] / .popsection   保存、恢复当前段
+//	.previous                                 切换回上一个段
+//
+// 标志: a 分配内存, w 可写, x 可执行, M 可合并, S 字符串, T 线程局部存储
+// 类型: @progbits, @nobits, @note, @init_array, @fini_array, @preinit_array
+
+// sectionFlags 段标志字符
+var sectionFlags = map[byte]elf.SectionFlag{
+	'a': elf.SHF_ALLOC,
+	'w': elf.SHF_WRITE,
+	'x': elf.SHF_EXECINSTR,
+	'M': elf.SHF_MERGE,
+	'S': elf.SHF_STRINGS,
+	'T': elf.SHF_TLS,
+}
+
+// sectionTypes 段类型名称
+var sectionTypes = map[string]elf.SectionType{
+	"progbits":      elf.SHT_PROGBITS,
+	"nobits":        elf.SHT_NOBITS,
+	"note":          elf.SHT_NOTE,
+	"init_array":    elf.SHT_INIT_ARRAY,
+	"fini_array":    elf.SHT_FINI_ARRAY,
+	"preinit_array": elf.SHT_PREINIT_ARRAY,
+}
+
+// sectionAttr 根据段名推断段类型及标志, 返回是否为已知的段名; 其它段默认可写
+func sectionAttr(name string) (elf.SectionType, elf.SectionFlag, bool) {
+	prefix := func(s string) bool {
+		return name == s || strings.HasPrefix(name, s+".")
+	}
+	switch {
+	case prefix(".text"):
+		return elf.SHT_PROGBITS, elf.SHF_ALLOC | elf.SHF_EXECINSTR, true
+	case prefix(".data"):
+		return elf.SHT_PROGBITS, elf.SHF_ALLOC | elf.SHF_WRITE, true
+	case prefix(".bss"):
+		return elf.SHT_NOBITS, elf.SHF_ALLOC | elf.SHF_WRITE, true
+	case prefix(".rodata"):
+		return elf.SHT_PROGBITS, elf.SHF_ALLOC, true
+	case prefix(".tdata"):
+		return elf.SHT_PROGBITS, elf.SHF_ALLOC | elf.SHF_WRITE | elf.SHF_TLS, true
+	case prefix(".tbss"):
+		return elf.SHT_NOBITS, elf.SHF_ALLOC | elf.SHF_WRITE | elf.SHF_TLS, true
+	case prefix(".init_array"):
+		return elf.SHT_INIT_ARRAY, elf.SHF_ALLOC | elf.SHF_WRITE, true
+	case prefix(".fini_array"):
+		return elf.SHT_FINI_ARRAY, elf.SHF_ALLOC | elf.SHF_WRITE, true
+	case prefix(".preinit_array"):
+		return elf.SHT_PREINIT_ARRAY, elf.SHF_ALLOC | elf.SHF_WRITE, true
+	case name == ".note.GNU-stack": // 只用于标记栈不可执行
+		return elf.SHT_PROGBITS, 0, true
+	case strings.HasPrefix(name, ".note"):
+		return elf.SHT_NOTE, 0, true
+	}
+	return elf.SHT_PROGBITS, elf.SHF_ALLOC | elf.SHF_WRITE, false
+}
+
+// exec 是否为可执行段, 对齐时填充 nop
+func (sec *section) exec() bool {
+	return sec.Flags&elf.SHF_EXECINSTR != 0
+}
+
+// nobits 是否为只占用空间、不占用文件的段(.bss)
+func (sec *section) nobits() bool {
+	return sec.Type == elf.SHT_NOBITS
+}
+
+// newSection 添加新的段, 构造函数表的表项大小为指针宽度
+func (p *parser) newSection(name string, typ elf.SectionType, flags elf.SectionFlag, entsize int) *section {
+	if entsize == 0 && (typ == elf.SHT_INIT_ARRAY || typ == elf.SHT_FINI_ARRAY || typ == elf.SHT_PREINIT_ARRAY) {
+		entsize = p.bits / 8
+	}
+	sec := &section{Name: name, Type: typ, Flags: flags, Entsize: entsize}
+	p.secList = append(p.secList, sec)
+	return sec
+}
+
+// setSection 切换当前段, 记录上一个段
+func (p *parser) setSection(sec *section) {
+	if sec != p.sec {
+		p.prevSec, p.sec = p.sec, sec
+	}
+}
+
+// findSection 按名称查找段
+func (p *parser) findSection(name string) *section {
+	for _, sec := range p.secList {
+		if sec.Name == name {
+			return sec
+		}
+	}
+	return nil
+}
+
+// attSection .section/.pushsection 的参数: name[, "flags"[, @type[, entsize]]]
+func (p *parser) attSection() {
+	pos := p.pos
+	name := p.attSectionName()
+	typ, flags, known := sectionAttr(name)
+	if !known { // 与 GAS 一致, 未知的段名没有默认标志
+		flags = 0
+	}
+	given, entsize := false, 0
+	if p.got(COMMA) {
+		given, flags = true, 0
+		text := p.id
+		p.expect(STRING)
+		for i := 0; i < len(text); i++ {
+			f, ok := sectionFlags[text[i]]
+			if !ok {
+				p.errorAt(pos, fmt.Sprintf("段 %s: 不支持的段标志 %q", name, text[i]))
+			}
+			flags |= f
+		}
+		if p.got(COMMA) {
+			p.got(REM) // AArch64 的 %progbits, % 不属于符号
+			t, ok := sectionTypes[strings.TrimPrefix(p.id, "@")]
+			if p.token != IDENT && p.token != STRING || !ok {
+				p.errorAt(p.pos, fmt.Sprintf("段 %s: 不支持的段类型 %s", name, p.token.Message(p.id)))
+			}
+			typ = t
+			p.next()
+			if p.got(COMMA) {
+				entsize = int(p.number())
+			}
+		}
+		if flags&elf.SHF_MERGE != 0 && entsize <= 0 {
+			p.errorAt(pos, fmt.Sprintf("段 %s: 可合并的段(M)需要指定表项大小", name))
+		}
+	}
+
+	sec := p.findSection(name)
+	switch {
+	case sec == nil:
+		sec = p.newSection(name, typ, flags, entsize)
+	case given && (sec.Type != typ || sec.Flags != flags):
+		p.errorAt(pos, fmt.Sprintf("段 %s 的属性与之前的定义不一致", name))
+	}
+	p.setSection(sec)
+}
+
+// pushSection .pushsection: 保存当前段及上一个段, 然后切换段
+func (p *parser) pushSection() {
+	p.secStack = append(p.secStack, [2]*section{p.sec, p.prevSec})
+	p.attSection()
+}
+
+// popSection .popsection: 恢复 .pushsection 保存的段
+func (p *parser) popSection() {
+	if len(p.secStack) == 0 {
+		p.errorf(".popsection 缺少对应的 .pushsection")
+	}
+	top := p.secStack[len(p.secStack)-1]
+	p.secStack = p.secStack[:len(p.secStack)-1]
+	p.sec, p.prevSec = top[0], top[1]
+}
+
+// previous .previous: 与上一个段交换
+func (p *parser) previous() {
+	if p.prevSec == nil {
+		p.errorf(".previous 之前没有切换过段")
+	}
+	p.sec, p.prevSec = p.prevSec, p.sec
+}
@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"bytes"
+	"github.com/facelang/face/internal/os/elf"
+	"testing"
+)
+
+func TestSectionAttr(t *testing.T) {
+	// 期望结果来自 GNU as 的输出
+	src := `
+	.section .rodata.str1.1, "aMS", @progbits, 1
+	.string "hi"
+	.section .init_array, "aw"
+	.long 0
+	.section .note.foo, "a"
+	.section .note.GNU-stack, "", @progbits
+	.section .tdata, "awT"
+	.section .mycode, "ax"
+	ret
+	.section .weird
+	.section .bss.x, "aw", @nobits
+	.section .rodata
+	.section .tbss
+`
+	p := assemble(t, "amd64", SyntaxATT, src)
+	tests := []struct {
+		name    string
+		typ     elf.SectionType
+		flags   elf.SectionFlag
+		entsize int
+	}{
+		{".rodata.str1.1", elf.SHT_PROGBITS, elf.SHF_ALLOC | elf.SHF_MERGE | elf.SHF_STRINGS, 1},
+		{".init_array", elf.SHT_INIT_ARRAY, elf.SHF_ALLOC | elf.SHF_WRITE, 8},
+		{".note.foo", elf.SHT_NOTE, elf.SHF_ALLOC, 0},
+		{".note.GNU-stack", elf.SHT_PROGBITS, 0, 0},
+		{".tdata", elf.SHT_PROGBITS, elf.SHF_ALLOC | elf.SHF_WRITE | elf.SHF_TLS, 0},
+		{".mycode", elf.SHT_PROGBITS, elf.SHF_ALLOC | elf.SHF_EXECINSTR, 0},
+		{".weird", elf.SHT_PROGBITS, 0, 0},
+		{".bss.x", elf.SHT_NOBITS, elf.SHF_ALLOC | elf.SHF_WRITE, 0},
+		{".rodata", elf.SHT_PROGBITS, elf.SHF_ALLOC, 0},
+		{".tbss", elf.SHT_NOBITS, elf.SHF_ALLOC | elf.SHF_WRITE | elf.SHF_TLS, 0},
+	}
+	for _, tt := range tests {
+		sec := p.findSection(tt.name)
+		if sec == nil {
+			t.Errorf("%s: 段不存在", tt.name)
+			continue
+		}
+		if sec.Type != tt.typ || sec.Flags != tt.flags || sec.Entsize != tt.entsize {
+			t.Errorf("%s: got %s %s %d, want %s %s %d", tt.name,
+				sec.Type, sec.Flags, sec.Entsize, tt.typ, tt.flags, tt.entsize)
+		}
+	}
+	if sec := p.findSection(".mycode"); !bytes.Equal(sec.Data, []byte{0xC3}) {
+		t.Errorf(".mycode: got % X", sec.Data)
+	}
+}
+
+func TestSectionStack(t *testing.T) {
+	src := `
+	.byte 1
+	.pushsection .data
+	.byte 2
+	.pushsection .rodata
+	.byte 3
+	.popsection
+	.byte 4
+	.popsection
+	.byte 5
+	.section .rodata
+	.byte 6
+	.previous
+	.byte 7
+	.previous
+	.byte 8
+`
+	p := assemble(t, "amd64", SyntaxATT, src)
+	tests := []struct {
+		name string
+		want []byte
+	}{
+		{".text", []byte{1, 5, 7}},
+		{".data", []byte{2, 4}},
+		{".rodata", []byte{3, 6, 8}},
+	}
+	for _, tt := range tests {
+		if sec := p.findSection(tt.name); sec == nil || !bytes.Equal(sec.Data, tt.want) {
+			t.Errorf("%s: got %v, want % X", tt.name, sec, tt.want)
+		}
+	}
+
+	for _, src := range []string{
+		".popsection",
+		".previous",
+		".section .x, \"aq\"",
+		".section .x, \"a\", @bogus",
+		".section .x, \"aM\"",
+		".section .x, \"a\"\n.section .x, \"ax\"",
+	} {
+		lex := NewBytesLexer([]byte(src))
+		lex.syntax = SyntaxATT
+		if err := NewParser(lex).ParseFile(); err == nil {
+			t.Errorf("%q: 期望出错", src)
+		}
+	}
+}
+
+func TestSectionAlign(t *testing.T) {
+	// 与 GAS/llvm-mc 一致: 没有对齐要求的段按 1 字节对齐, arm64/riscv64 的指令所在的段按指令长度对齐
+	p := assemble(t, "amd64", SyntaxATT, "\t.section foo\n\t.byte 1\n\t.data\n\t.byte 2\n\t.text\n\tret\n")
+	for _, name := range []string{"foo", ".data", ".text"} {
+		if sec := p.findSection(name); sec.Align != 0 {
+			t.Errorf("%s: align %d, want 0", name, sec.Align)
+		}
+	}
+	if sec := assemble(t, "arm64", SyntaxATT, "\tret\n").findSection(".text"); sec.Align != 4 {
+		t.Errorf("arm64 .text: align %d, want 4", sec.Align)
+	}
+	if sec := assemble(t, "riscv64", SyntaxATT, "\tret\n").findSection(".text"); sec.Align != 2 {
+		t.Errorf("riscv64 .text: align %d, want 2", sec.Align)
+	}
+}
@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"github.com/facelang/face/internal/prog"
+	"strings"
+)
+
+// 符号属性伪指令(AT&T 风格), 写入 ELF 符号表的 st_info/st_other/st_size:
+//
+//	.type name, @function            符号类型: function object tls_object common notype gnu_indirect_function
+//	.size name, expr                 符号大小, 表达式在分支优化之后计算
+//	.weak name[, ...]                弱符号
+//	.hidden/.protected/.internal     可见性
+//	.local name[, ...]               局部符号, 之后的 .comm 在本文件的 .bss 中分配空间
+//	.comm name, size[, align]        公共符号, 由链接器分配空间
+//	.lcomm name, size                在 .bss 中分配空间的局部符号
+
+// symTypes .type 的类型名称, 可以带 @ 前缀, 也可以加引号或使用 STT_ 名称
+var symTypes = map[string]elf.SymType{
+	"function":              elf.STT_FUNC,
+	"object":                elf.STT_OBJECT,
+	"tls_object":            elf.STT_TLS,
+	"common":                elf.STT_COMMON,
+	"notype":                elf.STT_NOTYPE,
+	"gnu_indirect_function": elf.STT_LOOS, // STT_GNU_IFUNC, GNU 扩展使用 STT_LOOS 的值
+	"STT_FUNC":              elf.STT_FUNC,
+	"STT_OBJECT":            elf.STT_OBJECT,
+	"STT_TLS":               elf.STT_TLS,
+	"STT_COMMON":            elf.STT_COMMON,
+	"STT_NOTYPE":            elf.STT_NOTYPE,
+}
+
+// symVis 可见性伪指令
+var symVis = map[string]elf.SymVis{
+	".hidden":    elf.STV_HIDDEN,
+	".protected": elf.STV_PROTECTED,
+	".internal":  elf.STV_INTERNAL,
+}
+
+// symSize .size 指定的符号大小, 表达式可能引用当前位置, 在分支优化之后计算
+type symSize struct {
+	Sym  *label
+	Expr Express
+	Pos  prog.FilePos
+}
+
+// attSymList 读取以逗号分隔的符号列表, 对每个符号执行 f
+func (p *parser) attSymList(f func(lb *label)) {
+	f(p.attrLabel(p.ident()))
+	for p.got(COMMA) {
+		f(p.attrLabel(p.ident()))
+	}
+}
+
+// attrLabel 符号属性伪指令作用的符号: 当前函数内已经定义的同名标签优先
+func (p *parser) attrLabel(name string) *label {
+	if i, ok := p.labelNames[scopedName(p.scope, name)]; ok && p.scope != "" {
+		return p.labelList[i]
+	}
+	return p.GetLabel(name)
+}
+
+// attType .type name, @type; @function 同时开始函数, 之后定义的标签只在函数内可见, 直到 .size、下一个函数或切换段
+func (p *parser) attType() {
+	name := p.ident()
+	lb := p.attrLabel(name)
+	p.got(COMMA) // 与 GAS 一致, 逗号可以省略
+	p.got(REM)   // AArch64 的 %function, % 不属于符号
+	pos, kind := p.pos, p.id
+	if p.token != IDENT && p.token != STRING {
+		p.unexpect("symbol type")
+	}
+	typ, ok := symTypes[strings.TrimPrefix(kind, "@")]
+	if !ok {
+		p.errorAt(pos, fmt.Sprintf("不支持的符号类型: %s", kind))
+	}
+	p.next()
+	lb.Kind = typ
+	if typ == elf.STT_FUNC && lb.Orig == "" { // 函数内已经定义的标签不开始新的函数
+		p.scope = name
+	}
+}
+
+// attSize .size name, expr; 结束 .type 开始的函数
+func (p *parser) attSize() {
+	pos, name := p.pos, p.ident()
+	lb := p.attrLabel(name)
+	p.expect(COMMA)
+	p.symSizes = append(p.symSizes, &symSize{Sym: lb, Expr: p.expr(), Pos: pos})
+	if name == p.scope {
+		p.scope = ""
+	}
+}
+
+// resolveSizes 计算 .size 指定的符号大小, 结果必须是常量
+func (p *parser) resolveSizes() {
+	for _, s := range p.symSizes {
+		v, err := p.eval(s.Expr, true)
+		switch {
+		case err != nil:
+			p.report(s.Pos, fmt.Sprintf(".size %s: %s", labelName(s.Sym.Name), err))
+		case v.Sym != nil:
+			p.report(s.Pos, fmt.Sprintf(".size %s: 表达式 %s 不是常量", labelName(s.Sym.Name), exprString(s.Expr)))
+		default:
+			s.Sym.Size = int(v.Value)
+		}
+	}
+}
+
+// attComm .comm name, size[, align]; 已用 .local 申明的符号在 .bss 中分配空间
+func (p *parser) attComm() {
+	pos, name := p.pos, p.ident()
+	p.expect(COMMA)
+	size, align := p.number(), int64(0)
+	if p.got(COMMA) {
+		align = p.number()
+	}
+	if size < 0 {
+		p.errorAt(pos, fmt.Sprintf(".comm %s: 无效的大小 %d", name, size))
+	}
+	if align == 0 { // 默认按大小向上取 2 的幂, 最多 16 字节
+		for align = 1; align < min(size, 16); align <<= 1 {
+		}
+	}
+	if align < 0 || align&(align-1) != 0 {
+		p.errorAt(pos, fmt.Sprintf(".comm %s: 对齐值必须是 2 的幂: %d", name, align))
+	}
+	if p.GetLabel(name).Local {
+		p.bssAlloc(name, size, align, pos)
+		return
+	}
+	p.AddLabel(name, &label{Type: COMMON_LABEL, Addr: int(align)}, pos)
+	lb := p.GetLabel(name)
+	lb.Kind, lb.Size = elf.STT_OBJECT, int(size)
+}
+
+// attLcomm .lcomm name, size; 默认按大小向下取 2 的幂对齐, 最多 8 字节
+func (p *parser) attLcomm() {
+	pos, name := p.pos, p.ident()
+	p.expect(COMMA)
+	size := p.number()
+	if size < 0 {
+		p.errorAt(pos, fmt.Sprintf(".lcomm %s: 无效的大小 %d", name, size))
+	}
+	align := int64(1)
+	for align < 8 && align*2 <= size {
+		align <<= 1
+	}
+	p.bssAlloc(name, size, align, pos)
+}
+
+// bssAlloc 在 .bss 中为符号分配 size 字节的空间, 不改变当前段
+func (p *parser) bssAlloc(name string, size, align int64, pos prog.FilePos) {
+	cur, prev := p.sec, p.prevSec
+	defer func() {
+		p.sec, p.prevSec = cur, prev
+	}()
+	p._switch(".bss")
+	p.sec.Align = max(p.sec.Align, int(align))
+	p.emit(&instr{Opcode: K_ALIGN, Size: int(align), Times: 1, Pos: pos})
+	p.AddLabel(name, NewLabel(LOCAL_LABEL), pos)
+	p.space(size, 1, 0, pos)
+	lb := p.GetLabel(name)
+	lb.Kind, lb.Size = elf.STT_OBJECT, int(size)
+}
+
+// symInfo 符号表项的 st_info(绑定及类型) 与 st_other(可见性)
+func (lb *label) symInfo() (info, other uint8) {
+	bind := elf.STB_LOCAL
+	switch {
+	case lb.Weak:
+		bind = elf.STB_WEAK
+	case lb.Global || !lb.defined():
+		bind = elf.STB_GLOBAL
+	}
+	return elf.ST_INFO(bind, lb.Kind), uint8(lb.Vis)
+}
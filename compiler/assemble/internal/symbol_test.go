@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"github.com/facelang/face/internal/os/elf"
+	"testing"
+)
+
+func TestSymbolAttr(t *testing.T) {
+	// 期望结果来自 GNU as 的输出
+	src := `
+	.globl f
+	.type f, @function
+	.hidden f
+f:	jmp 1f
+	.byte 0x90
+1:	ret
+	.size f, .-f
+	.weak w, wu
+	.type obj, @object
+	.protected obj
+	.globl obj
+w:	call wu
+	.data
+obj:	.long 1, 2
+	.size obj, 8
+	.internal iv
+iv:	.byte 1
+	.comm cbuf, 100, 16
+	.comm c3, 3
+	.lcomm lbuf, 20
+	.lcomm lb2, 3
+	.local sbuf
+	.comm sbuf, 8, 4
+	.type tl, STT_TLS
+`
+	p := assemble(t, "amd64", SyntaxATT, src)
+	tests := []struct {
+		name  string
+		bind  elf.SymBind
+		typ   elf.SymType
+		vis   elf.SymVis
+		value int
+		size  int
+	}{
+		{"f", elf.STB_GLOBAL, elf.STT_FUNC, elf.STV_HIDDEN, 0, 4},
+		{"w", elf.STB_WEAK, elf.STT_NOTYPE, elf.STV_DEFAULT, 4, 0},
+		{"wu", elf.STB_WEAK, elf.STT_NOTYPE, elf.STV_DEFAULT, 0, 0},
+		{"obj", elf.STB_GLOBAL, elf.STT_OBJECT, elf.STV_PROTECTED, 0, 8},
+		{"iv", elf.STB_LOCAL, elf.STT_NOTYPE, elf.STV_INTERNAL, 8, 0},
+		{"cbuf", elf.STB_GLOBAL, elf.STT_OBJECT, elf.STV_DEFAULT, 16, 100},
+		{"c3", elf.STB_GLOBAL, elf.STT_OBJECT, elf.STV_DEFAULT, 4, 3},
+		{"lbuf", elf.STB_LOCAL, elf.STT_OBJECT, elf.STV_DEFAULT, 0, 20},
+		{"lb2", elf.STB_LOCAL, elf.STT_OBJECT, elf.STV_DEFAULT, 20, 3},
+		{"sbuf", elf.STB_LOCAL, elf.STT_OBJECT, elf.STV_DEFAULT, 24, 8},
+		{"tl", elf.STB_GLOBAL, elf.STT_TLS, elf.STV_DEFAULT, 0, 0},
+	}
+	for _, tt := range tests {
+		lb := p.GetLabel(tt.name)
+		info, other := lb.symInfo()
+		if elf.ST_BIND(info) != tt.bind || elf.ST_TYPE(info) != tt.typ || elf.ST_VISIBILITY(other) != tt.vis ||
+			lb.Addr != tt.value || lb.Size != tt.size {
+			t.Errorf("%s: got %s %s %s %#x %d, want %s %s %s %#x %d", tt.name,
+				elf.ST_BIND(info), elf.ST_TYPE(info), elf.ST_VISIBILITY(other), lb.Addr, lb.Size,
+				tt.bind, tt.typ, tt.vis, tt.value, tt.size)
+		}
+	}
+	if sec := p.findSection(".bss"); sec.Length != 32 || sec.Align != 8 {
+		t.Errorf(".bss: got 长度 %d 对齐 %d, want 32 8", sec.Length, sec.Align)
+	}
+	if lb := p.GetLabel("cbuf"); lb.Type != COMMON_LABEL {
+		t.Errorf("cbuf: 应为公共符号, got %s", labelTypeNames[lb.Type])
+	}
+
+	for _, src := range []string{
+		".type f, @bogus",
+		".size f, g",
+		"f: ret\n.comm f, 4",
+		".comm x, 4, 3",
+	} {
+		lex := NewBytesLexer([]byte(src))
+		lex.syntax = SyntaxATT
+		p := NewParser(lex)
+		if err := p.ParseFile(); err == nil && p.Codegen() == nil {
+			t.Errorf("%q: 期望出错", src)
+		}
+	}
+}
+
+func TestSymbolScoped(t *testing.T) {
+	// 函数内标签在定义前后使用的属性伪指令都作用于该标签, 期望结果来自 GNU as 的输出
+	src := `
+	.type f, @function
+f:
+	.type q, @object
+	.size q, 4
+	.hidden q
+	.local q
+q:	.long 0
+r:	ret
+	.type r, @function
+	.size r, .-r
+	.protected r
+	.weak s
+s:	ret
+	.size f, .-f
+`
+	file := assemble(t, "amd64", SyntaxATT, src).Object()
+	tests := []struct {
+		name  string // SymNames 中的名称, 函数内的标签以原名称写入字符串表
+		bind  elf.SymBind
+		typ   elf.SymType
+		vis   elf.SymVis
+		value uint64
+		size  uint64
+	}{
+		{"f", elf.STB_LOCAL, elf.STT_FUNC, elf.STV_DEFAULT, 0, 6},
+		{scopedName("f", "q"), elf.STB_LOCAL, elf.STT_OBJECT, elf.STV_HIDDEN, 0, 4},
+		{scopedName("f", "r"), elf.STB_LOCAL, elf.STT_FUNC, elf.STV_PROTECTED, 4, 1},
+		{"s", elf.STB_WEAK, elf.STT_NOTYPE, elf.STV_DEFAULT, 5, 0},
+	}
+	if n := len(file.SymNames); n != len(tests)+2 { // 空符号及 .text 段符号
+		t.Errorf("符号表: got %d 项 %q, want %d 项", n, file.SymNames, len(tests)+2)
+	}
+	syms := file.Symbols()
+	for _, tt := range tests {
+		i := file.GetSymIndex(tt.name)
+		if i < 0 {
+			t.Errorf("%s: 符号表中没有该符号", tt.name)
+			continue
+		}
+		sym := syms[i]
+		if elf.ST_BIND(sym.Info) != tt.bind || elf.ST_TYPE(sym.Info) != tt.typ || elf.ST_VISIBILITY(sym.Other) != tt.vis ||
+			sym.Value != tt.value || sym.Size != tt.size {
+			t.Errorf("%s: got %s %s %s %#x %d, want %s %s %s %#x %d", tt.name,
+				elf.ST_BIND(sym.Info), elf.ST_TYPE(sym.Info), elf.ST_VISIBILITY(sym.Other), sym.Value, sym.Size,
+				tt.bind, tt.typ, tt.vis, tt.value, tt.size)
+		}
+	}
+}
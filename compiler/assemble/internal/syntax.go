@@ -0,0 +1,50 @@
+package internal
+
+import "fmt"
+
+// Syntax 汇编语法风格, 不同风格的源码最终都转换为相同的 instr/operand 结构
+type Syntax uint8
+
+const (
+	SyntaxIntel Syntax = iota // Intel/NASM: 目标操作数在前, 内存引用 [base+index*scale+disp], ; 注释
+	SyntaxATT                 // AT&T/GAS: 源操作数在前, 寄存器前缀 %, 立即数前缀 $, 指令后缀表示宽度, # 注释
+	SyntaxPlan9               // Plan 9: 源操作数在前, 立即数前缀 $, 大写指令及寄存器, 伪寄存器 SB, // 注释
+)
+
+var syntaxNames = [...]string{
+	SyntaxIntel: "intel",
+	SyntaxATT:   "att",
+	SyntaxPlan9: "plan9",
+}
+
+func (s Syntax) String() string {
+	if int(s) < len(syntaxNames) {
+		return syntaxNames[s]
+	}
+	return fmt.Sprintf("syntax(%d)", s)
+}
+
+// ParseSyntax 解析语法风格名称: att, intel, plan9
+func ParseSyntax(name string) (Syntax, error) {
+	for i, s := range syntaxNames {
+		if s == name {
+			return Syntax(i), nil
+		}
+	}
+	return 0, fmt.Errorf("不支持的语法风格: %s", name)
+}
+
+// suffixSize AT&T 及 Plan 9 指令后缀对应的操作数宽度
+func suffixSize(suffix byte) int {
+	switch suffix {
+	case 'b', 'B':
+		return 1
+	case 'w', 'W':
+		return 2
+	case 'l', 'L':
+		return 4
+	case 'q', 'Q':
+		return 8
+	}
+	return 0
+}
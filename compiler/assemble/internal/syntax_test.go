@@ -0,0 +1,229 @@
+package internal
+
+import (
+	"bytes"
+	"github.com/facelang/face/internal/os/elf"
+	"testing"
+)
+
+func TestSyntax(t *testing.T) {
+	// 同一条指令的三种写法, 编码结果必须一致
+	tests := []struct {
+		intel, att, plan9 string
+	}{
+		{"mov rax, 1", "movq $1, %rax", "MOVQ $1, AX"},
+		{"mov eax, ebx", "movl %ebx, %eax", "MOVL BX, AX"},
+		{"mov al, 5", "movb $5, %al", "MOVB $5, AL"},
+		{"mov r9w, ax", "movw %ax, %r9w", "MOVW AX, R9"},
+		{"mov dword [rsp+8], eax", "movl %eax, 8(%rsp)", "MOVL AX, 8(SP)"},
+		{"mov qword [rax], 1", "movq $1, (%rax)", "MOVQ $1, (AX)"},
+		{"lea rsi, [rbx+rcx*4-16]", "leaq -16(%rbx,%rcx,4), %rsi", "LEAQ -16(BX)(CX*4), SI"},
+		{"add rax, 1000", "addq $1000, %rax", "ADDQ $1000, AX"},
+		{"sub ecx, [rbx+4]", "subl 4(%rbx), %ecx", "SUBL 4(BX), CX"},
+		{"cmp rax, 10", "cmpq $10, %rax", "CMPQ AX, $10"},
+		{"imul r9, 100", "imulq $100, %r9", "IMULQ $100, R9"},
+		{"imul eax, ecx, 3", "imull $3, %ecx, %eax", "IMUL3L $3, CX, AX"},
+		{"imul rdx, [rbx], 1000", "imulq $1000, (%rbx), %rdx", "IMUL3Q $1000, (BX), DX"},
+		{"xor eax, eax", "xorl %eax, %eax", "XORL AX, AX"},
+		{"and rdx, 0xff", "andq $0xff, %rdx", "ANDQ $0xff, DX"},
+		{"or byte [rdi+3], 0x80", "orb $0x80, 3(%rdi)", "ORB $0x80, 3(DI)"},
+		{"test eax, ecx", "testl %ecx, %eax", "TESTL CX, AX"},
+		{"test esi, [rdi]", "testl (%rdi), %esi", "TESTL (DI), SI"},
+		{"test byte [rdi+1], 2", "testb $2, 1(%rdi)", "TESTB $2, 1(DI)"},
+		{"push rbp", "pushq %rbp", "PUSHQ BP"},
+		{"call rax", "call *%rax", "CALL AX"},
+		{"int 0x80", "int $0x80", "INT $0x80"},
+		{"ret", "ret", "RET"},
+		{"mov rax, 0x123456789", "movabsq $0x123456789, %rax", "MOVQ $0x123456789, AX"},
+	}
+
+	for _, tt := range tests {
+		want := assemble(t, "amd64", SyntaxIntel, tt.intel).secList[0].Data
+		for _, src := range []struct {
+			syntax Syntax
+			text   string
+		}{{SyntaxATT, tt.att}, {SyntaxPlan9, tt.plan9}} {
+			got := assemble(t, "amd64", src.syntax, src.text).secList[0].Data
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s %q: got % X, want % X(%q)", src.syntax, src.text, got, want, tt.intel)
+			}
+		}
+	}
+}
+
+func TestSyntaxATT(t *testing.T) {
+	src := `
+# 注释
+.data
+msg:	.ascii "hi"
+	.asciz "!"
+	.short 1, 2
+.equ len, 3
+
+.text
+.globl _start
+_start: movl $len, %edx; leaq msg(%rip), %rsi /* 块注释 */
+	jmp _start
+`
+	p := assemble(t, "amd64", SyntaxATT, src)
+	data := []byte{'h', 'i', '!', 0, 1, 0, 2, 0}
+	if sec := p.secList[1]; sec.Name != ".data" || !bytes.Equal(sec.Data, data) {
+		t.Errorf("%s: got % X, want % X", sec.Name, sec.Data, data)
+	}
+	text := []byte{
+		0xBA, 0x03, 0x00, 0x00, 0x00, // mov edx, len
+		0x48, 0x8D, 0x35, 0x00, 0x00, 0x00, 0x00, // lea rsi, [rip+msg]
+		0xEB, 0xF2, // jmp _start(同一段内的全局符号不生成重定位)
+	}
+	if sec := p.secList[0]; !bytes.Equal(sec.Data, text) {
+		t.Errorf("%s: got % X, want % X", sec.Name, sec.Data, text)
+	}
+	if !p.GetLabel("_start").Global {
+		t.Errorf("_start 应为全局符号")
+	}
+}
+
+func TestSyntaxPlan9(t *testing.T) {
+	src := `
+// 注释
+DATA msg<>+0(SB)/4, $"abc"
+DATA msg<>+6(SB)/2, $0x1234
+GLOBL msg<>(SB), RODATA, $10
+GLOBL ·buf(SB), $16
+DATA ·val+0(SB)/8, $·buf(SB)
+GLOBL ·val(SB), NOPTR, $8
+
+TEXT ·start(SB), NOSPLIT, $0
+loop:
+	MOVL msg<>+2(SB), AX
+	JMP loop
+`
+	p := assemble(t, "amd64", SyntaxPlan9, src)
+	want := map[string][]byte{
+		".text":   {0x8B, 0x05, 0x00, 0x00, 0x00, 0x00, 0xEB, 0xF8},
+		".rodata": {'a', 'b', 'c', 0, 0, 0, 0x34, 0x12, 0, 0},
+		".bss":    make([]byte, 16),
+		".data":   make([]byte, 8),
+	}
+	for _, sec := range p.secList {
+		if !bytes.Equal(sec.Data, want[sec.Name]) {
+			t.Errorf("%s: got % X, want % X", sec.Name, sec.Data, want[sec.Name])
+		}
+	}
+	for name, global := range map[string]bool{"msg": false, "buf": true, "val": true, "start": true} {
+		if lb := p.GetLabel(name); !lb.defined() || lb.Global != global {
+			t.Errorf("符号 %s: defined=%v global=%v", name, lb.defined(), lb.Global)
+		}
+	}
+}
+
+func TestSyntaxIntel(t *testing.T) {
+	// NASM 的 default rel/abs, [rel sym]/[abs sym], times 重复指令及 resb/resw/resd/resq
+	src := `
+default rel
+section .text
+	lea rsi, [msg]
+	mov eax, [abs msg]
+	mov ecx, [rel cnt]
+	mov edx, [rbx+8]
+	mov rax, fs:[0x28]
+	times 3 nop
+default abs
+	mov eax, [msg]
+section .data
+msg db "hi", 10
+section .bss
+buf: resb 16
+cnt resd 2
+	resq 1
+arr: times 4 resw 2
+`
+	p := assemble(t, "amd64", SyntaxIntel, src)
+	text := []byte{
+		0x48, 0x8D, 0x35, 0, 0, 0, 0, // lea rsi, [rip+msg]
+		0x8B, 0x04, 0x25, 0, 0, 0, 0, // mov eax, [msg]
+		0x8B, 0x0D, 0, 0, 0, 0, // mov ecx, [rip+cnt]
+		0x8B, 0x53, 0x08, // 含寄存器的内存引用不使用 rip 相对寻址
+		0x64, 0x48, 0x8B, 0x04, 0x25, 0x28, 0, 0, 0, // fs 段超越前缀
+		0x90, 0x90, 0x90,
+		0x8B, 0x04, 0x25, 0, 0, 0, 0,
+	}
+	if got := p.findSection(".text").Data; !bytes.Equal(got, text) {
+		t.Errorf("got % X, want % X", got, text)
+	}
+	rels := []relocate{
+		{Label: ".data", Type: int(elf.R_X86_64_PC32), Offset: 3, Section: ".text", Addend: -4},
+		{Label: ".data", Type: int(elf.R_X86_64_32S), Offset: 10, Section: ".text"},
+		{Label: ".bss", Type: int(elf.R_X86_64_PC32), Offset: 16, Section: ".text", Addend: 12},
+		{Label: ".data", Type: int(elf.R_X86_64_32S), Offset: 38, Section: ".text"},
+	}
+	if len(p.relocateList) != len(rels) {
+		t.Fatalf("重定位数量: got %d, want %d", len(p.relocateList), len(rels))
+	}
+	for i, rel := range p.relocateList {
+		if *rel != rels[i] {
+			t.Errorf("重定位 %d: got %+v, want %+v", i, *rel, rels[i])
+		}
+	}
+	if sec := p.findSection(".bss"); sec.Length != 48 {
+		t.Errorf(".bss: 长度 got %d, want 48", sec.Length)
+	}
+	for name, addr := range map[string]int{"buf": 0, "cnt": 16, "arr": 32} {
+		if lb := p.GetLabel(name); lb.Addr != addr {
+			t.Errorf("%s: got %#x, want %#x", name, lb.Addr, addr)
+		}
+	}
+
+	// rel 只能用于符号地址, 且只在 64 位模式下可用
+	for _, tt := range []struct {
+		src  string
+		bits int
+	}{
+		{"mov eax, [rel rbx]", 64},
+		{"mov eax, [rel 0x10]", 64},
+		{"mov eax, [rel fs:x]\nx:", 64},
+		{"mov eax, gs:[rel x]\nx:", 64},
+		{"mov eax, [rel x]\nx:", 32},
+		{"default x", 64},
+		{".bss\nresb 1, 2", 64},
+	} {
+		p := NewParser(NewBytesLexer([]byte(tt.src)))
+		p.bits = tt.bits
+		if err := p.ParseFile(); err == nil {
+			t.Errorf("%q(%d 位): 期望解析错误", tt.src, tt.bits)
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	tests := []struct {
+		src    string
+		syntax Syntax
+	}{
+		{"mov eax, 1 2", SyntaxIntel},
+		{"movq %eax, %rbx", SyntaxATT},
+		{"movl $1, %rax", SyntaxATT},
+		{"foo %eax", SyntaxATT},
+		{"movl *%eax, %ebx", SyntaxATT},
+		{"addsd %xmm0, 8(%xmm1)", SyntaxATT},
+		{"movl %fs(%eax), %ebx", SyntaxATT},
+		{"imull $1, %eax, %ebx, %ecx", SyntaxATT},
+		{"addl $1, %eax, %ebx", SyntaxATT},
+		{".unknown 1", SyntaxATT},
+		{"MOVQ x+8(FP), AX", SyntaxPlan9},
+		{"MOVQ msg+8(AX), AX", SyntaxPlan9},
+		{"TEXT ·f(SB), $16", SyntaxPlan9},
+		{"DATA x+0(SB)/4, $1", SyntaxPlan9},
+		{"DATA x+0(SB)/8, $1\nGLOBL x(SB), $4", SyntaxPlan9},
+	}
+
+	for _, tt := range tests {
+		lex := NewBytesLexer([]byte(tt.src))
+		lex.syntax = tt.syntax
+		p := NewParser(lex)
+		p.bits = 64
+		if err := p.ParseFile(); err == nil {
+			t.Errorf("%s %q: 期望解析错误", tt.syntax, tt.src)
+		}
+	}
+}
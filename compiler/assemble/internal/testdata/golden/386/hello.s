@@ -0,0 +1,29 @@
+# 32 位系统调用及 REL 重定位(加数保存在段内容中)
+	.data
+msg:	.ascii	"hello, world\n"
+	.byte	0
+	.long	msg + 4, ext
+	.text
+	.globl	_start
+_start:
+	movl	$4, %eax
+	movl	$1, %ebx
+	movl	$msg, %ecx
+	movl	$13, %edx
+	int	$0x80
+	movl	msg+2, %eax
+	leal	8(%esp,%ecx,4), %esi
+	call	ext
+	call	helper
+	jmp	done
+helper:
+	pushl	%ebp
+	movl	%esp, %ebp
+	addl	$-16, %esp
+	imull	8(%ebp)
+	popl	%ebp
+	ret
+done:
+	movl	$1, %eax
+	movl	$0, %ebx
+	int	$0x80
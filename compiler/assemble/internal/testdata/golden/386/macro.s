@@ -0,0 +1,38 @@
+# 宏、重复及条件汇编
+	.macro	syscall3 nr, a, b, c
+	movl	$\nr, %eax
+	movl	\a, %ebx
+	movl	\b, %ecx
+	movl	\c, %edx
+	int	$0x80
+	.endm
+
+	.text
+	.globl	main
+	.type	main, @function
+main:
+	syscall3 4, $1, $buf, $16
+	.rept	3
+	incl	%eax
+	.endr
+	.irp	reg, %eax, %ebx, %ecx
+	pushl	\reg
+	.endr
+	.if	1
+	decl	%edx
+	.else
+	incl	%edx
+	.endif
+	ret
+	.size	main, . - main
+
+	.section .rodata
+	.align	4
+table:	.long	main, main + 8
+	.string	"abc"
+	.asciz	"de"
+	.byte	0x7f
+	.p2align 3
+	.quad	-1
+	.bss
+	.comm	buf, 16, 4
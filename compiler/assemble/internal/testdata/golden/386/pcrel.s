@@ -0,0 +1,25 @@
+# pc 相对的数据: 跳转表、sym - . 及 sym - 当前段中的标签
+	.text
+	.globl	dispatch
+dispatch:
+	leal	table, %edx
+	movl	(%edx,%ecx,4), %eax
+	addl	%edx, %eax
+	jmp	*%eax
+1:	ret
+2:	ret
+
+	.section .rodata
+	.p2align 2
+table:
+	.long	1b - table
+	.long	2b - table
+	.long	ext - table
+	.long	msg - .
+	.long	ext - .
+	.long	dispatch - . + 8
+	.value	msg - .
+
+	.data
+msg:	.asciz	"hi"
+	.long	ext - msg + 4
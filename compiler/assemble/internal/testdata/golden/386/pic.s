@@ -0,0 +1,39 @@
+# 位置无关代码: GOT 地址, PLT, GOT 及 TLS 重定位修饰
+	.text
+	.globl	get
+	.type	get, @function
+get:
+	pushl	%ebx
+	call	__x86.get_pc_thunk.bx
+	addl	$_GLOBAL_OFFSET_TABLE_, %ebx
+	call	ext@PLT
+	call	local@PLT
+	movl	ext@GOT(%ebx), %eax
+	movl	local@GOT(%ebx), %eax
+	leal	local@GOTOFF(%ebx), %eax
+	leal	get@GOTOFF+4(%ebx), %eax
+	movl	counter@GOTOFF(%ebx), %eax
+	call	*ext@GOT(%ebx)
+	addl	ext@GOT(%ebx), %eax
+	leal	ext@GOT(%ebx), %eax
+	movl	tv@INDNTPOFF, %eax
+	movl	tv@GOTNTPOFF(%ebx), %eax
+	movl	%gs:tv@NTPOFF, %eax
+	leal	tv@TLSGD(,%ebx,1), %eax
+	leal	tv@TLSLDM(%ebx), %eax
+	leal	tv@DTPOFF(%eax), %edx
+	movl	$tv@TPOFF, %eax
+	popl	%ebx
+	ret
+	.size	get, .-get
+
+local:
+	ret
+
+	.data
+counter:
+	.long	get
+	.long	get@GOTOFF
+	.long	tv@DTPOFF
+	.long	tv@NTPOFF
+	.long	ext@GOT
@@ -0,0 +1,30 @@
+# 整数运算与寻址模式
+	.text
+	.globl	sum
+	.type	sum, @function
+sum:
+	pushq	%rbp
+	movq	%rsp, %rbp
+	movl	%edi, -4(%rbp)
+	movl	%esi, -8(%rbp)
+	movl	-4(%rbp), %edx
+	movl	-8(%rbp), %eax
+	addl	%edx, %eax
+	imull	%ecx
+	subq	$8, %rsp
+	addq	$0x1000, %rsp
+	movb	$1, (%rax)
+	movw	$-2, 6(%rbx,%rcx,2)
+	leaq	8(%rdi,%rsi,4), %rax
+	movq	0x10(,%r12,8), %r9
+	cmpq	$-1, %rax
+	cmpb	$97, %r8b
+	incl	%eax
+	decq	(%r13)
+	negq	%rcx
+	idivl	%ebx
+	movq	$0x12345678, %rax
+	movabsq	$0x123456789, %rax
+	popq	%rbp
+	ret
+	.size	sum, .-sum
@@ -0,0 +1,19 @@
+# 跳转与分支优化
+	.text
+	.globl	loop
+	.type	loop, @function
+loop:
+	movl	$0, %eax
+1:	addl	$1, %eax
+	cmpl	$10, %eax
+	jl	1b
+	je	2f
+	.fill	200, 1, 0x90
+2:	jmp	3f
+	jne	1b
+	call	ext
+	call	loop
+	jmp	*%rax
+	call	*8(%rbx)
+3:	ret
+	.size	loop, .-loop
@@ -0,0 +1,23 @@
+# 数据定义与重定位
+	.section .rodata
+msg:	.ascii	"hello, world\n"
+	.byte	1, 2, 3
+	.align	8
+tab:	.quad	msg, ext+8, tab-msg
+	.long	0x12345678
+	.short	-1
+	.data
+	.globl	counter
+counter:
+	.long	0
+ptr:	.quad	counter
+	.text
+	.globl	get
+get:
+	leaq	msg(%rip), %rax
+	movq	ptr(%rip), %rcx
+	movl	counter(%rip), %edx
+	ret
+	.bss
+	.lcomm	buf, 64
+	.comm	shared, 16, 8
@@ -0,0 +1,22 @@
+# 跳转到同一段内的全局符号: 与 GAS 一致, jmp/jcc 直接计算偏移(包括分支优化), call、@PLT 及弱符号生成重定位
+	.text
+	.globl g, h
+	.weak w
+	.protected p
+	.globl p
+g:	ret
+w:	ret
+h:	ret
+p:	ret
+	jmp g
+	jne g
+	call g
+	jmp w
+	jmp g@PLT
+	call h
+	lea g(%rip), %rax
+	movl g, %eax
+	.long g
+	jmp p
+	.fill 200, 1, 0xc3
+	jmp g
@@ -0,0 +1,63 @@
+# 条件跳转及别名, 逻辑运算, test 及三操作数 imul
+	.text
+	.globl	f
+	.type	f, @function
+f:
+	jo 1f
+	jno 1f
+	jb 1f
+	jc 1f
+	jnae 1f
+	jae 1f
+	jnb 1f
+	jnc 1f
+	jz 1f
+	je 1f
+	jnz 1f
+	jbe 1f
+	ja 1f
+	jnbe 1f
+	js 1f
+	jns 1f
+	jp 1f
+	jpe 1f
+	jnp 1f
+	jpo 1f
+	jl 1f
+	jnge 1f
+	jge 1f
+	jnl 1f
+	jle 1f
+	jng 1f
+	jg 1f
+	jnle 1f
+1:	xor %eax, %eax
+	xorl $1, %ecx
+	xorq $0x1000, %rax
+	xorb %al, (%rbx)
+	xorq 8(%rsp), %r9
+	andl $0xff, %edx
+	andq %rax, %rbx
+	orw $1, %ax
+	orb $0x80, 3(%rdi)
+	orl (%rax), %ecx
+	test %eax, %eax
+	testl %ecx, %eax
+	testb $1, %al
+	testl $0x100, %eax
+	testq $4, %rdx
+	testb $2, 1(%rdi)
+	testl %esi, (%rdi)
+	testl (%rdi), %esi
+	testw %r8w, %r9w
+	imul $3, %ecx, %eax
+	imul $1000, (%rbx), %edx
+	imulq $-2, 8(%rsp), %r10
+	imul $5, %eax
+	imulw $300, %cx, %dx
+	lock xorl %eax, (%rbx)
+	lock andq $1, (%rdi)
+	js	1b
+	jnz	1b
+	ret
+	.size	f, .-f
@@ -0,0 +1,48 @@
+# syscall, nop, leave, 移位, setcc, cmovcc, 扩展传送及符号扩展
+	.text
+	.globl	f
+	.type	f, @function
+f:
+	syscall
+	nop
+	nopl 0(%rax)
+	nopw 0(%rax,%rax,1)
+	leave
+	shll %eax
+	shll $1, %eax
+	shlq $3, %rax
+	shrl %cl, %edx
+	sarq %cl, %r9
+	sarb $2, %al
+	shrw $4, 8(%rbx)
+	sall $5, %r12d
+	sete %al
+	setne %sil
+	setg (%rdi)
+	setbe %r10b
+	setl %ah
+	cmovel %ecx, %eax
+	cmovlq (%rsi), %r8
+	cmovgw %dx, %ax
+	cmovnz %rbx, %rax
+	cmovbe 8(%rsp), %edx
+	movzbl %al, %eax
+	movzbl (%rdi), %ecx
+	movzbw %sil, %ax
+	movzbq %r9b, %r10
+	movzwl 2(%rsi), %eax
+	movzwq %cx, %rdx
+	movsbl %dl, %eax
+	movsbq (%rax,%rcx), %rdi
+	movswl %ax, %eax
+	movswq %r8w, %r15
+	movsbw %al, %dx
+	movslq %ecx, %rdx
+	movslq 4(%rbp), %rax
+	movzx %al, %eax
+	movsx %cl, %r11
+	cqto
+	cltq
+	cltd
+	ret
+	.size	f, .-f
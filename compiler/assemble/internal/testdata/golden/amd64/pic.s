@@ -0,0 +1,38 @@
+# 位置无关代码: PLT, GOT 及 TLS 重定位修饰
+	.text
+	.globl	get
+	.type	get, @function
+get:
+	call	ext@PLT
+	call	get@PLT
+	call	local@PLT
+	jmp	ext@PLT
+	movq	ext@GOTPCREL(%rip), %rax
+	movq	local@GOTPCREL(%rip), %rax
+	movl	ext@GOTPCREL(%rip), %eax
+	addq	ext@GOTPCREL(%rip), %rax
+	call	*ext@GOTPCREL(%rip)
+	jmp	*ext@GOTPCREL(%rip)
+	leaq	ext@GOTPCREL(%rip), %rax
+	movq	ext@GOTPCREL+8(%rip), %rax
+	leaq	_GLOBAL_OFFSET_TABLE_(%rip), %r15
+	leaq	counter(%rip), %rax
+	movq	tv@GOTTPOFF(%rip), %rax
+	movq	%fs:0, %rdx
+	movl	%fs:tv@TPOFF, %eax
+	leaq	tv@TPOFF(%rdx), %rax
+	leaq	tv@TLSGD(%rip), %rdi
+	leaq	tv@TLSLD(%rip), %rdi
+	leaq	tv@DTPOFF(%rax), %rdx
+	ret
+	.size	get, .-get
+
+local:
+	ret
+
+	.data
+counter:
+	.quad	get
+	.quad	tv@DTPOFF
+	.long	tv@DTPOFF
+	.quad	tv@TPOFF
@@ -0,0 +1,20 @@
+# 函数内的标签: 以原名称作为局部符号, 其它函数及数据段可以引用
+	.text
+	.globl	f
+	.type	f, @function
+f:
+	movl	$3, %ecx
+loop:	decl	%ecx
+	jne	loop
+	ret
+	.size	f, .-f
+	.type	g, @function
+g:
+	jmp	loop
+far:	call	f
+	.type	h, @function
+h:
+	movq	$table, %rax
+back:	jmp	far
+	.data
+table:	.quad	loop, far, back
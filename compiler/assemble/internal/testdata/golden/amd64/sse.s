@@ -0,0 +1,59 @@
+# SSE2 浮点运算、串操作指令、lock/rep 前缀及段超越
+	.text
+	.globl	scale
+	.type	scale, @function
+scale:
+	cvtsi2sdq	%rdi, %xmm1
+	cvtsi2sdl	(%rsi), %xmm2
+	mulsd	.LC0(%rip), %xmm0
+	addsd	%xmm1, %xmm0
+	subsd	%xmm2, %xmm0
+	divsd	8(%rsp), %xmm0
+	sqrtsd	%xmm0, %xmm0
+	minsd	%xmm1, %xmm9
+	maxss	%xmm10, %xmm2
+	ucomisd	%xmm1, %xmm0
+	comiss	(%rax), %xmm3
+	cvtsd2ss	%xmm0, %xmm4
+	cvtss2sd	%xmm4, %xmm5
+	cvttsd2si	%xmm0, %rax
+	cvtsd2si	%xmm0, %ecx
+	cvttss2si	(%rdi), %r9d
+	movsd	%xmm0, -8(%rbp)
+	movss	-4(%rbp), %xmm6
+	movapd	%xmm0, %xmm15
+	movups	(%rdi,%rcx,8), %xmm7
+	movq	%xmm0, %rax
+	movq	%rdx, %xmm1
+	movq	(%rax), %xmm2
+	movq	%xmm2, 16(%rax)
+	movd	%eax, %xmm3
+	xorpd	%xmm0, %xmm0
+	andnps	%xmm1, %xmm2
+	pxor	%xmm8, %xmm8
+	ret
+	.size	scale, .-scale
+
+	.globl	copy
+	.type	copy, @function
+copy:
+	movq	%rdx, %rcx
+	rep movsb
+	rep stosq
+	repne scasb
+	repe cmpsw
+	movsl
+	lodsq
+	lock addl $1, (%rdi)
+	lock; incq 8(%rdi)
+	lock subw %ax, %fs:(%rsi)
+	movq	%fs:0x28, %rax
+	movl	%gs:8(%rbx), %ecx
+	rep ret
+	.size	copy, .-copy
+
+	.section	.rodata
+	.align 8
+.LC0:
+	.long	0
+	.long	1073741824
@@ -0,0 +1,30 @@
+// 函数调用、寻址及 ADRP/LO12 重定位
+	.text
+	.globl	main
+	.type	main, %function
+main:
+	stp	x29, x30, [sp, #-16]!
+	mov	x29, sp
+	adrp	x0, msg
+	add	x0, x0, :lo12:msg
+	ldr	w1, [x0, :lo12:count]
+	bl	puts
+	mov	x0, #0x10000
+	movk	x0, #0x1234, lsl #16
+	and	w2, w1, #0xf0f0f0f0
+	cmp	x0, #4
+	b.ne	1f
+	cbz	x1, 1f
+	tbnz	w2, #3, main
+	ldr	x3, [x1, w2, sxtw #3]
+1:	ldp	x29, x30, [sp], #16
+	mov	w0, #0
+	ret
+	.size	main, .-main
+
+	.data
+	.p2align 3
+msg:	.asciz	"hello"
+	.p2align 2
+count:	.word	3
+	.xword	main, ext+8
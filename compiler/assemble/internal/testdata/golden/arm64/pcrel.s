@@ -0,0 +1,26 @@
+// pc 相对的数据: 跳转表、sym - . 及 sym - 当前段中的标签
+	.text
+	.globl	dispatch
+dispatch:
+	adrp	x1, table
+	add	x1, x1, :lo12:table
+	ldrsw	x2, [x1, x0, lsl #2]
+	add	x1, x1, x2
+	br	x1
+1:	ret
+2:	ret
+
+	.section .rodata
+	.p2align 2
+table:
+	.word	1b - table
+	.word	2b - table
+	.word	ext - table
+	.word	msg - .
+	.xword	ext - .
+	.xword	dispatch - . + 8
+	.hword	msg - .
+
+	.data
+msg:	.asciz	"hi"
+	.word	ext - msg + 4
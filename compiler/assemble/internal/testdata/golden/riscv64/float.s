@@ -0,0 +1,33 @@
+# 浮点指令及压缩跳转: 求 n 个双精度数之和
+	.text
+	.globl	sum
+	.type	sum, @function
+sum:
+	fmv.d.x	fa0, zero
+	beqz	a1, .Ldone
+.Lloop:
+	fld	fa5, 0(a0)
+	fadd.d	fa0, fa0, fa5
+	addi	a0, a0, 8
+	addi	a1, a1, -1
+	bnez	a1, .Lloop
+.Ldone:
+	fcvt.l.d	a2, fa0, rtz
+	fcvt.d.l	fa1, a2
+	feq.d	a3, fa0, fa1
+	fsd	fa0, 8(sp)
+	fld	fs0, 8(sp)
+	fmadd.d	fa0, fa0, fa1, fs0
+	fsqrt.s	ft0, ft1
+	fcvt.s.d	ft0, fa0
+	fmv.x.d	a0, fa0
+	fld	fa2, scale, t0
+	fmul.d	fa0, fa0, fa2
+	j	.Lout
+	fneg.d	fa0, fa0
+.Lout:
+	ret
+	.size	sum, .-sum
+
+	.data
+scale:	.dword	0x3fe0000000000000	# 0.5
@@ -0,0 +1,27 @@
+# 函数调用、压缩指令及链接器松弛重定位
+	.text
+	.globl	main
+	.type	main, @function
+main:
+	addi	sp, sp, -16
+	sd	ra, 8(sp)
+	lui	a0, %hi(msg)
+	addi	a0, a0, %lo(msg)
+	call	puts
+	li	a1, 0x12345678
+	mv	a2, a1
+	mul	a3, a1, a2
+	amoadd.w.aq a4, a3, (a0)
+	csrr	a5, cycle
+	fence	rw, rw
+	bnez	a0, main
+	ld	ra, 8(sp)
+	addi	sp, sp, 16
+	ret
+	.size	main, .-main
+
+	.data
+msg:	.asciz	"hello"
+	.p2align 2
+	.word	3
+	.dword	main, ext+8
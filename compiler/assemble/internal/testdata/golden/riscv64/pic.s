@@ -0,0 +1,20 @@
+# 位置无关代码: la 通过 GOT 加载符号地址, lla 仍为 pc 相对地址
+	.option	pic
+	.text
+	.globl	get
+	.type	get, @function
+get:
+	la	a0, ext
+	la	a1, local
+	lla	a2, ext
+	.option	push
+	.option	nopic
+	la	a3, ext
+	.option	pop
+	la	a4, ext
+	ld	a0, 0(a0)
+	ret
+	.size	get, .-get
+
+local:
+	ret
@@ -22,30 +22,38 @@ const (
 	_operator    // 运算符
 	ADD          // +
 	SUB          // -
+	MUL          // *
+	DIV          // /
+	REM          // %
+	AND          // &
+	OR           // |
+	XOR          // ^
+	SHL          // <<
+	SHR          // >>
+	NOT          // ~
 	LBRACK       // [
 	COMMA        // ,
 	RBRACK       // ]
 	COLON        // :
+	LPAREN       // (
+	RPAREN       // )
+	DOLLAR       // $
+	LSS          // <
+	GTR          // >
+	EQL          // ==
+	NEQ          // !=
+	LEQ          // <=
+	GEQ          // >=
+	LAND         // &&
+	LOR          // ||
+	HASH         // # 立即数前缀(AArch64)
+	EXCL         // ! 前变址写回(AArch64)
+	SEMI         // ; 语句分隔符(AT&T, Plan 9)
+	NEWLINE      // 换行, 语句结束
 	_operatorEnd // 操作符结束
 
-	_keywords
-	BREAK    // 1
-	CHAR     // 2
-	CONTINUE // 3
-
-	ELSE   // 4
-	EXTERN // 5
-
-	IF     // 5
-	IN     // 6
-	INT    // 7
-	OUT    // 8
-	RETURN // 9
-	VOID   // 11
-	WHILE  // 12
-	_keywordsEnd
-
-	// 寄存器
+	// 寄存器, 同组内按机器编码顺序排列, r8 以上的寄存器仅在 64 位模式下可用
+	// 8 位
 	BR_AL
 	BR_CL
 	BR_DL
@@ -54,6 +62,37 @@ const (
 	BR_CH
 	BR_DH
 	BR_BH
+	BR_R8B
+	BR_R9B
+	BR_R10B
+	BR_R11B
+	BR_R12B
+	BR_R13B
+	BR_R14B
+	BR_R15B
+	// 8 位, 编码 4~7, 需要 REX 前缀
+	BR_SPL
+	BR_BPL
+	BR_SIL
+	BR_DIL
+	// 16 位
+	WR_AX
+	WR_CX
+	WR_DX
+	WR_BX
+	WR_SP
+	WR_BP
+	WR_SI
+	WR_DI
+	WR_R8W
+	WR_R9W
+	WR_R10W
+	WR_R11W
+	WR_R12W
+	WR_R13W
+	WR_R14W
+	WR_R15W
+	// 32 位
 	DR_EAX
 	DR_ECX
 	DR_EDX
@@ -62,12 +101,88 @@ const (
 	DR_EBP
 	DR_ESI
 	DR_EDI
+	DR_R8D
+	DR_R9D
+	DR_R10D
+	DR_R11D
+	DR_R12D
+	DR_R13D
+	DR_R14D
+	DR_R15D
+	// 64 位
+	QR_RAX
+	QR_RCX
+	QR_RDX
+	QR_RBX
+	QR_RSP
+	QR_RBP
+	QR_RSI
+	QR_RDI
+	QR_R8
+	QR_R9
+	QR_R10
+	QR_R11
+	QR_R12
+	QR_R13
+	QR_R14
+	QR_R15
+	// SSE 寄存器, xmm8 以上仅在 64 位模式下可用
+	XR_XMM0
+	XR_XMM1
+	XR_XMM2
+	XR_XMM3
+	XR_XMM4
+	XR_XMM5
+	XR_XMM6
+	XR_XMM7
+	XR_XMM8
+	XR_XMM9
+	XR_XMM10
+	XR_XMM11
+	XR_XMM12
+	XR_XMM13
+	XR_XMM14
+	XR_XMM15
+	REG_RIP // rip, 仅用于相对寻址
+	// 段寄存器, 仅用于内存操作数的段超越前缀, 例如 fs:[0]
+	SR_ES
+	SR_CS
+	SR_SS
+	SR_DS
+	SR_FS
+	SR_GS
 	// 双操作数指令
 	I_MOV
 	I_CMP
 	I_SUB
 	I_ADD
 	I_LEA
+	I_AND
+	I_OR
+	I_XOR
+	I_TEST
+	I_SHL
+	I_SHR
+	I_SAR
+	I_MOVZX  // 零扩展传送, 源操作数为 8 或 16 位
+	I_MOVSX  // 符号扩展传送, 源操作数为 8 或 16 位
+	I_MOVSXD // 32 位符号扩展为 64 位
+	I_CMOVO
+	I_CMOVNO
+	I_CMOVB
+	I_CMOVAE
+	I_CMOVE
+	I_CMOVNE
+	I_CMOVNA
+	I_CMOVA
+	I_CMOVS
+	I_CMOVNS
+	I_CMOVP
+	I_CMOVNP
+	I_CMOVL
+	I_CMOVGE
+	I_CMOVLE
+	I_CMOVG
 	// 单操作数指令
 	I_CALL
 	I_INT
@@ -84,21 +199,147 @@ const (
 	I_JLE
 	I_JNE
 	I_JNA
+	I_JO
+	I_JNO
+	I_JB
+	I_JAE
+	I_JA
+	I_JS
+	I_JNS
+	I_JP
+	I_JNP
 	I_PUSH
 	I_POP
+	I_SETO
+	I_SETNO
+	I_SETB
+	I_SETAE
+	I_SETE
+	I_SETNE
+	I_SETNA
+	I_SETA
+	I_SETS
+	I_SETNS
+	I_SETP
+	I_SETNP
+	I_SETL
+	I_SETGE
+	I_SETLE
+	I_SETG
 	// 零操作数指令
 	I_RET
+	I_NOP
+	I_SYSCALL
+	I_LEAVE
+	I_CDQ  // 符号扩展 eax 到 edx:eax
+	I_CQO  // 符号扩展 rax 到 rdx:rax
+	I_CDQE // 符号扩展 eax 到 rax
+	// 串操作指令, 操作数隐含为 (e/r)si, (e/r)di, 宽度由名称决定
+	I_MOVSB
+	I_MOVSW
+	I_MOVSD // 带 xmm 操作数时为 SSE2 标量双精度传送
+	I_MOVSQ
+	I_STOSB
+	I_STOSW
+	I_STOSD
+	I_STOSQ
+	I_LODSB
+	I_LODSW
+	I_LODSD
+	I_LODSQ
+	I_CMPSB
+	I_CMPSW
+	I_CMPSD
+	I_CMPSQ
+	I_SCASB
+	I_SCASW
+	I_SCASD
+	I_SCASQ
+	// SSE/SSE2 浮点指令
+	I_MOVSS
+	I_MOVAPS
+	I_MOVAPD
+	I_MOVUPS
+	I_MOVUPD
+	I_MOVD
+	I_MOVQ
+	I_ADDSS
+	I_ADDSD
+	I_ADDPS
+	I_ADDPD
+	I_SUBSS
+	I_SUBSD
+	I_SUBPS
+	I_SUBPD
+	I_MULSS
+	I_MULSD
+	I_MULPS
+	I_MULPD
+	I_DIVSS
+	I_DIVSD
+	I_DIVPS
+	I_DIVPD
+	I_SQRTSS
+	I_SQRTSD
+	I_SQRTPS
+	I_SQRTPD
+	I_MINSS
+	I_MINSD
+	I_MAXSS
+	I_MAXSD
+	I_UCOMISS
+	I_UCOMISD
+	I_COMISS
+	I_COMISD
+	I_CVTSI2SS
+	I_CVTSI2SD
+	I_CVTSS2SI
+	I_CVTSD2SI
+	I_CVTTSS2SI
+	I_CVTTSD2SI
+	I_CVTSS2SD
+	I_CVTSD2SS
+	I_ANDPS
+	I_ANDPD
+	I_ANDNPS
+	I_ANDNPD
+	I_ORPS
+	I_ORPD
+	I_XORPS
+	I_XORPD
+	I_PXOR
+	// 指令前缀, 可以单独成行, 也可以写在指令之前
+	I_LOCK
+	I_REP
+	I_REPE
+	I_REPNE
 	// 汇编指令
 	K_SEC
 	K_GLB
+	K_EXT
 	K_EQU
 	K_TIMES
 	K_DB
 	K_DW
 	K_DD
+	K_DQ
+	K_RESB // resb, 保留未初始化的空间
+	K_RESW
+	K_RESD
+	K_RESQ
+	// 操作数宽度修饰
+	K_SBYTE  // byte
+	K_SWORD  // word
+	K_SDWORD // dword
+	K_SQWORD // qword
+	K_PTR    // ptr
+	// NASM 内存寻址方式
+	K_REL     // rel, rip 相对寻址
+	K_ABS     // abs, 绝对寻址
+	K_DEFAULT // default rel|abs
 
 	// 数据段定义相关的token
-	K_BYTE = iota + _literal + 1 // .byte
+	K_BYTE // .byte
 	K_WORD
 	K_LONG
 	K_QUAD
@@ -109,81 +350,336 @@ const (
 	K_ENDR
 
 	// 段定义相关的token
-	K_DATA    = iota + _literal + 1 // .data
-	K_TEXT                          // .text
-	K_BSS                           // .bss
-	K_SECTION                       // .section
-	K_GLOBAL                        // .global
-	K_LOCAL                         // .local
-	K_ALIGN                         // .align
-	K_SKIP                          // .skip
-	K_SPACE                         // .space
+	K_DATA    // .data
+	K_TEXT    // .text
+	K_BSS     // .bss
+	K_SECTION // .section
+	K_GLOBAL  // .global
+	K_LOCAL   // .local
+	K_ALIGN   // .align
+	K_SKIP    // .skip
+	K_SPACE   // .space
+	K_INSN    // 定长指令架构(arm64)的机器指令, 解析时已编码
 )
 
 var tokens = [...]string{
-	ILLEGAL:  "ILLEGAL",
-	EOF:      "EOF",
-	COMMENT:  "COMMENT",
-	IDENT:    "IDENT",
-	INT:      "INT",
-	FLOAT:    "FLOAT",
-	STRING:   "STRING",
-	ADD:      "+",
-	SUB:      "-",
-	LBRACK:   "[",
-	COMMA:    ",",
-	RBRACK:   "]",
-	COLON:    ":",
-	BREAK:    "break",
-	CHAR:     "char",
-	CONTINUE: "continue",
-	ELSE:     "else",
-	EXTERN:   "extern",
-	IF:       "if",
-	IN:       "in",
-	INT:      "int",
-	OUT:      "out",
-	RETURN:   "return",
-	VOID:     "void",
-	WHILE:    "while",
+	ILLEGAL: "ILLEGAL",
+	EOF:     "EOF",
+	COMMENT: "COMMENT",
+	IDENT:   "IDENT",
+	INT:     "INT",
+	FLOAT:   "FLOAT",
+	STRING:  "STRING",
+	ADD:     "+",
+	SUB:     "-",
+	MUL:     "*",
+	DIV:     "/",
+	REM:     "%",
+	AND:     "&",
+	OR:      "|",
+	XOR:     "^",
+	SHL:     "<<",
+	SHR:     ">>",
+	NOT:     "~",
+	LBRACK:  "[",
+	COMMA:   ",",
+	RBRACK:  "]",
+	COLON:   ":",
+	LPAREN:  "(",
+	RPAREN:  ")",
+	DOLLAR:  "$",
+	LSS:     "<",
+	GTR:     ">",
+	EQL:     "==",
+	NEQ:     "!=",
+	LEQ:     "<=",
+	GEQ:     ">=",
+	LAND:    "&&",
+	LOR:     "||",
+	HASH:    "#",
+	EXCL:    "!",
+	SEMI:    ";",
+	NEWLINE: "newline",
 
-	//TILDE:     "~",
-}
+	BR_AL:   "al",
+	BR_CL:   "cl",
+	BR_DL:   "dl",
+	BR_BL:   "bl",
+	BR_AH:   "ah",
+	BR_CH:   "ch",
+	BR_DH:   "dh",
+	BR_BH:   "bh",
+	BR_R8B:  "r8b",
+	BR_R9B:  "r9b",
+	BR_R10B: "r10b",
+	BR_R11B: "r11b",
+	BR_R12B: "r12b",
+	BR_R13B: "r13b",
+	BR_R14B: "r14b",
+	BR_R15B: "r15b",
+	BR_SPL:  "spl",
+	BR_BPL:  "bpl",
+	BR_SIL:  "sil",
+	BR_DIL:  "dil",
+	WR_AX:   "ax",
+	WR_CX:   "cx",
+	WR_DX:   "dx",
+	WR_BX:   "bx",
+	WR_SP:   "sp",
+	WR_BP:   "bp",
+	WR_SI:   "si",
+	WR_DI:   "di",
+	WR_R8W:  "r8w",
+	WR_R9W:  "r9w",
+	WR_R10W: "r10w",
+	WR_R11W: "r11w",
+	WR_R12W: "r12w",
+	WR_R13W: "r13w",
+	WR_R14W: "r14w",
+	WR_R15W: "r15w",
+	DR_EAX:  "eax",
+	DR_ECX:  "ecx",
+	DR_EDX:  "edx",
+	DR_EBX:  "ebx",
+	DR_ESP:  "esp",
+	DR_EBP:  "ebp",
+	DR_ESI:  "esi",
+	DR_EDI:  "edi",
+	DR_R8D:  "r8d",
+	DR_R9D:  "r9d",
+	DR_R10D: "r10d",
+	DR_R11D: "r11d",
+	DR_R12D: "r12d",
+	DR_R13D: "r13d",
+	DR_R14D: "r14d",
+	DR_R15D: "r15d",
+	QR_RAX:  "rax",
+	QR_RCX:  "rcx",
+	QR_RDX:  "rdx",
+	QR_RBX:  "rbx",
+	QR_RSP:  "rsp",
+	QR_RBP:  "rbp",
+	QR_RSI:  "rsi",
+	QR_RDI:  "rdi",
+	QR_R8:   "r8",
+	QR_R9:   "r9",
+	QR_R10:  "r10",
+	QR_R11:  "r11",
+	QR_R12:  "r12",
+	QR_R13:  "r13",
+	QR_R14:  "r14",
+	QR_R15:  "r15",
+
+	XR_XMM0:  "xmm0",
+	XR_XMM1:  "xmm1",
+	XR_XMM2:  "xmm2",
+	XR_XMM3:  "xmm3",
+	XR_XMM4:  "xmm4",
+	XR_XMM5:  "xmm5",
+	XR_XMM6:  "xmm6",
+	XR_XMM7:  "xmm7",
+	XR_XMM8:  "xmm8",
+	XR_XMM9:  "xmm9",
+	XR_XMM10: "xmm10",
+	XR_XMM11: "xmm11",
+	XR_XMM12: "xmm12",
+	XR_XMM13: "xmm13",
+	XR_XMM14: "xmm14",
+	XR_XMM15: "xmm15",
+
+	REG_RIP: "rip",
+	SR_ES:   "es",
+	SR_CS:   "cs",
+	SR_SS:   "ss",
+	SR_DS:   "ds",
+	SR_FS:   "fs",
+	SR_GS:   "gs",
+	I_MOV:   "mov",
+	I_CMP:   "cmp",
+	I_SUB:   "sub",
+	I_ADD:   "add",
+	I_LEA:   "lea",
+	I_AND:   "and",
+	I_OR:    "or",
+	I_XOR:   "xor",
+	I_TEST:  "test",
+	I_CALL:  "call",
+	I_INT:   "int",
+	I_IMUL:  "imul",
+	I_IDIV:  "idiv",
+	I_NEG:   "neg",
+	I_INC:   "inc",
+	I_DEC:   "dec",
+	I_JMP:   "jmp",
+	I_JE:    "je",
+	I_JG:    "jg",
+	I_JL:    "jl",
+	I_JGE:   "jge",
+	I_JLE:   "jle",
+	I_JNE:   "jne",
+	I_JNA:   "jna",
+	I_JO:    "jo",
+	I_JNO:   "jno",
+	I_JB:    "jb",
+	I_JAE:   "jae",
+	I_JA:    "ja",
+	I_JS:    "js",
+	I_JNS:   "jns",
+	I_JP:    "jp",
+	I_JNP:   "jnp",
+	I_PUSH:  "push",
+	I_POP:   "pop",
+	I_RET:   "ret",
 
-var tokenNames = map[Token]string{
-	ILLEGAL:  "ILLEGAL",
-	EOF:      "EOF",
-	COMMENT:  "COMMENT",
-	IDENT:    "IDENT",
-	INT:      "INT",
-	FLOAT:    "FLOAT",
-	STRING:   "STRING",
-	ADD:      "+",
-	SUB:      "-",
-	LBRACK:   "[",
-	COMMA:    ",",
-	RBRACK:   "]",
-	COLON:    ":",
-	BREAK:    "break",
-	CHAR:     "char",
-	CONTINUE: "continue",
-	ELSE:     "else",
-	EXTERN:   "extern",
-	IF:       "if",
-	IN:       "in",
-	INT:      "int",
-	OUT:      "out",
-	RETURN:   "return",
-	VOID:     "void",
-	WHILE:    "while",
+	I_MOVSB:     "movsb",
+	I_MOVSW:     "movsw",
+	I_MOVSD:     "movsd",
+	I_MOVSQ:     "movsq",
+	I_STOSB:     "stosb",
+	I_STOSW:     "stosw",
+	I_STOSD:     "stosd",
+	I_STOSQ:     "stosq",
+	I_LODSB:     "lodsb",
+	I_LODSW:     "lodsw",
+	I_LODSD:     "lodsd",
+	I_LODSQ:     "lodsq",
+	I_CMPSB:     "cmpsb",
+	I_CMPSW:     "cmpsw",
+	I_CMPSD:     "cmpsd",
+	I_CMPSQ:     "cmpsq",
+	I_SCASB:     "scasb",
+	I_SCASW:     "scasw",
+	I_SCASD:     "scasd",
+	I_SCASQ:     "scasq",
+	I_MOVSS:     "movss",
+	I_MOVAPS:    "movaps",
+	I_MOVAPD:    "movapd",
+	I_MOVUPS:    "movups",
+	I_MOVUPD:    "movupd",
+	I_MOVD:      "movd",
+	I_MOVQ:      "movq",
+	I_ADDSS:     "addss",
+	I_ADDSD:     "addsd",
+	I_ADDPS:     "addps",
+	I_ADDPD:     "addpd",
+	I_SUBSS:     "subss",
+	I_SUBSD:     "subsd",
+	I_SUBPS:     "subps",
+	I_SUBPD:     "subpd",
+	I_MULSS:     "mulss",
+	I_MULSD:     "mulsd",
+	I_MULPS:     "mulps",
+	I_MULPD:     "mulpd",
+	I_DIVSS:     "divss",
+	I_DIVSD:     "divsd",
+	I_DIVPS:     "divps",
+	I_DIVPD:     "divpd",
+	I_SQRTSS:    "sqrtss",
+	I_SQRTSD:    "sqrtsd",
+	I_SQRTPS:    "sqrtps",
+	I_SQRTPD:    "sqrtpd",
+	I_MINSS:     "minss",
+	I_MINSD:     "minsd",
+	I_MAXSS:     "maxss",
+	I_MAXSD:     "maxsd",
+	I_UCOMISS:   "ucomiss",
+	I_UCOMISD:   "ucomisd",
+	I_COMISS:    "comiss",
+	I_COMISD:    "comisd",
+	I_CVTSI2SS:  "cvtsi2ss",
+	I_CVTSI2SD:  "cvtsi2sd",
+	I_CVTSS2SI:  "cvtss2si",
+	I_CVTSD2SI:  "cvtsd2si",
+	I_CVTTSS2SI: "cvttss2si",
+	I_CVTTSD2SI: "cvttsd2si",
+	I_CVTSS2SD:  "cvtss2sd",
+	I_CVTSD2SS:  "cvtsd2ss",
+	I_ANDPS:     "andps",
+	I_ANDPD:     "andpd",
+	I_ANDNPS:    "andnps",
+	I_ANDNPD:    "andnpd",
+	I_ORPS:      "orps",
+	I_ORPD:      "orpd",
+	I_XORPS:     "xorps",
+	I_XORPD:     "xorpd",
+	I_PXOR:      "pxor",
+	I_LOCK:      "lock",
+	I_REP:       "rep",
+	I_REPE:      "repe",
+	I_REPNE:     "repne",
+
+	I_SHL:     "shl",
+	I_SHR:     "shr",
+	I_SAR:     "sar",
+	I_MOVZX:   "movzx",
+	I_MOVSX:   "movsx",
+	I_MOVSXD:  "movsxd",
+	I_CMOVO:   "cmovo",
+	I_CMOVNO:  "cmovno",
+	I_CMOVB:   "cmovb",
+	I_CMOVAE:  "cmovae",
+	I_CMOVE:   "cmove",
+	I_CMOVNE:  "cmovne",
+	I_CMOVNA:  "cmovna",
+	I_CMOVA:   "cmova",
+	I_CMOVS:   "cmovs",
+	I_CMOVNS:  "cmovns",
+	I_CMOVP:   "cmovp",
+	I_CMOVNP:  "cmovnp",
+	I_CMOVL:   "cmovl",
+	I_CMOVGE:  "cmovge",
+	I_CMOVLE:  "cmovle",
+	I_CMOVG:   "cmovg",
+	I_SETO:    "seto",
+	I_SETNO:   "setno",
+	I_SETB:    "setb",
+	I_SETAE:   "setae",
+	I_SETE:    "sete",
+	I_SETNE:   "setne",
+	I_SETNA:   "setna",
+	I_SETA:    "seta",
+	I_SETS:    "sets",
+	I_SETNS:   "setns",
+	I_SETP:    "setp",
+	I_SETNP:   "setnp",
+	I_SETL:    "setl",
+	I_SETGE:   "setge",
+	I_SETLE:   "setle",
+	I_SETG:    "setg",
+	I_NOP:     "nop",
+	I_SYSCALL: "syscall",
+	I_LEAVE:   "leave",
+	I_CDQ:     "cdq",
+	I_CQO:     "cqo",
+	I_CDQE:    "cdqe",
+
+	K_SEC:    "section",
+	K_GLB:    "global",
+	K_EXT:    "extern",
+	K_EQU:    "equ",
+	K_TIMES:  "times",
+	K_DB:     "db",
+	K_DW:     "dw",
+	K_DD:     "dd",
+	K_DQ:     "dq",
+	K_RESB:   "resb",
+	K_RESW:   "resw",
+	K_RESD:   "resd",
+	K_RESQ:   "resq",
+	K_SBYTE:  "byte",
+	K_SWORD:  "word",
+	K_SDWORD: "dword",
+	K_SQWORD: "qword",
+	K_PTR:    "ptr",
+
+	K_REL:     "rel",
+	K_ABS:     "abs",
+	K_DEFAULT: "default",
 
-	//TILDE:     "~",
 	K_BYTE:    ".byte",
 	K_WORD:    ".word",
 	K_LONG:    ".long",
 	K_QUAD:    ".quad",
-	K_QUAD:    ".float",
-	K_QUAD:    ".double",
 	K_ASCII:   ".ascii",
 	K_ASCIZ:   ".asciz",
 	K_STRING:  ".string",
@@ -198,6 +694,7 @@ var tokenNames = map[Token]string{
 	K_ALIGN:   ".align",
 	K_SKIP:    ".skip",
 	K_SPACE:   ".space",
+	K_INSN:    "insn",
 }
 
 func (tok Token) String() string {
@@ -223,21 +720,82 @@ func (tok Token) Message(id string) string {
 }
 
 var keywordsList = []string{
-	"al", "cl", "dl", "bl", "ah", "ch", "dh", "bh",
-	"eax", "ecx", "edx", "ebx", "esp", "ebp", "esi", "edi",
-	"mov", "cmp", "sub", "add", "lea",
+	"al", "cl", "dl", "bl", "ah", "ch", "dh", "bh", "r8b", "r9b", "r10b", "r11b", "r12b", "r13b", "r14b", "r15b",
+	"spl", "bpl", "sil", "dil",
+	"ax", "cx", "dx", "bx", "sp", "bp", "si", "di", "r8w", "r9w", "r10w", "r11w", "r12w", "r13w", "r14w", "r15w",
+	"eax", "ecx", "edx", "ebx", "esp", "ebp", "esi", "edi", "r8d", "r9d", "r10d", "r11d", "r12d", "r13d", "r14d", "r15d",
+	"rax", "rcx", "rdx", "rbx", "rsp", "rbp", "rsi", "rdi", "r8", "r9", "r10", "r11", "r12", "r13", "r14", "r15",
+	"xmm0", "xmm1", "xmm2", "xmm3", "xmm4", "xmm5", "xmm6", "xmm7",
+	"xmm8", "xmm9", "xmm10", "xmm11", "xmm12", "xmm13", "xmm14", "xmm15",
+	"rip",
+	"es", "cs", "ss", "ds", "fs", "gs",
+	"mov", "cmp", "sub", "add", "lea", "and", "or", "xor", "test",
 	"call", "int", "imul", "idiv", "neg", "inc", "dec", "jmp", "je", "jg", "jl", "jge", "jle", "jne", "jna", "push", "pop",
+	"jo", "jno", "jb", "jae", "ja", "js", "jns", "jp", "jnp",
+	// 条件跳转的别名
+	"jz", "jnz", "jnle", "jnge", "jnl", "jng", "jbe", "jc", "jnae", "jnb", "jnc", "jnbe", "jpe", "jpo",
 	"ret",
-	"section", "global", "equ", "times", "db", "dw", "dd",
+	"shl", "sal", "shr", "sar", "movzx", "movsx", "movsxd",
+	"cmovo", "cmovno", "cmovb", "cmovae", "cmove", "cmovne", "cmovna", "cmova", "cmovs", "cmovns", "cmovp", "cmovnp", "cmovl", "cmovge", "cmovle", "cmovg",
+	"cmovz", "cmovnz", "cmovnle", "cmovnge", "cmovnl", "cmovng", "cmovbe", "cmovc", "cmovnae", "cmovnb", "cmovnc", "cmovnbe", "cmovpe", "cmovpo",
+	"seto", "setno", "setb", "setae", "sete", "setne", "setna", "seta", "sets", "setns", "setp", "setnp", "setl", "setge", "setle", "setg",
+	"setz", "setnz", "setnle", "setnge", "setnl", "setng", "setbe", "setc", "setnae", "setnb", "setnc", "setnbe", "setpe", "setpo",
+	"nop", "syscall", "leave", "cdq", "cqo", "cdqe",
+	"cltd", "cqto", "cltq", // AT&T 名称
+	"movsb", "movsw", "movsd", "movsq", "stosb", "stosw", "stosd", "stosq",
+	"lodsb", "lodsw", "lodsd", "lodsq", "cmpsb", "cmpsw", "cmpsd", "cmpsq",
+	"scasb", "scasw", "scasd", "scasq",
+	"movss", "movaps", "movapd", "movups", "movupd", "movd", "movq",
+	"addss", "addsd", "addps", "addpd", "subss", "subsd", "subps", "subpd",
+	"mulss", "mulsd", "mulps", "mulpd", "divss", "divsd", "divps", "divpd",
+	"sqrtss", "sqrtsd", "sqrtps", "sqrtpd", "minss", "minsd", "maxss", "maxsd",
+	"ucomiss", "ucomisd", "comiss", "comisd",
+	"cvtsi2ss", "cvtsi2sd", "cvtss2si", "cvtsd2si", "cvttss2si", "cvttsd2si", "cvtss2sd", "cvtsd2ss",
+	"andps", "andpd", "andnps", "andnpd", "orps", "orpd", "xorps", "xorpd", "pxor",
+	"lock", "rep", "repe", "repz", "repne", "repnz",
+	"section", "global", "extern", "equ", "times", "db", "dw", "dd", "dq",
+	"resb", "resw", "resd", "resq",
+	"byte", "word", "dword", "qword", "ptr",
+	"rel", "abs", "default",
 	"text", "data", "bss", // 添加段名
 }
 var keywordsTable = []Token{
-	BR_AL, BR_CL, BR_DL, BR_BL, BR_AH, BR_CH, BR_DH, BR_BH,
-	DR_EAX, DR_ECX, DR_EDX, DR_EBX, DR_ESP, DR_EBP, DR_ESI, DR_EDI,
-	I_MOV, I_CMP, I_SUB, I_ADD, I_LEA,
+	BR_AL, BR_CL, BR_DL, BR_BL, BR_AH, BR_CH, BR_DH, BR_BH, BR_R8B, BR_R9B, BR_R10B, BR_R11B, BR_R12B, BR_R13B, BR_R14B, BR_R15B,
+	BR_SPL, BR_BPL, BR_SIL, BR_DIL,
+	WR_AX, WR_CX, WR_DX, WR_BX, WR_SP, WR_BP, WR_SI, WR_DI, WR_R8W, WR_R9W, WR_R10W, WR_R11W, WR_R12W, WR_R13W, WR_R14W, WR_R15W,
+	DR_EAX, DR_ECX, DR_EDX, DR_EBX, DR_ESP, DR_EBP, DR_ESI, DR_EDI, DR_R8D, DR_R9D, DR_R10D, DR_R11D, DR_R12D, DR_R13D, DR_R14D, DR_R15D,
+	QR_RAX, QR_RCX, QR_RDX, QR_RBX, QR_RSP, QR_RBP, QR_RSI, QR_RDI, QR_R8, QR_R9, QR_R10, QR_R11, QR_R12, QR_R13, QR_R14, QR_R15,
+	XR_XMM0, XR_XMM1, XR_XMM2, XR_XMM3, XR_XMM4, XR_XMM5, XR_XMM6, XR_XMM7,
+	XR_XMM8, XR_XMM9, XR_XMM10, XR_XMM11, XR_XMM12, XR_XMM13, XR_XMM14, XR_XMM15,
+	REG_RIP,
+	SR_ES, SR_CS, SR_SS, SR_DS, SR_FS, SR_GS,
+	I_MOV, I_CMP, I_SUB, I_ADD, I_LEA, I_AND, I_OR, I_XOR, I_TEST,
 	I_CALL, I_INT, I_IMUL, I_IDIV, I_NEG, I_INC, I_DEC, I_JMP, I_JE, I_JG, I_JL, I_JGE, I_JLE, I_JNE, I_JNA, I_PUSH, I_POP,
+	I_JO, I_JNO, I_JB, I_JAE, I_JA, I_JS, I_JNS, I_JP, I_JNP,
+	I_JE, I_JNE, I_JG, I_JL, I_JGE, I_JLE, I_JNA, I_JB, I_JB, I_JAE, I_JAE, I_JA, I_JP, I_JNP,
 	I_RET,
-	K_SEC, K_GLB, K_EQU, K_TIMES, K_DB, K_DW, K_DD,
+	I_SHL, I_SHL, I_SHR, I_SAR, I_MOVZX, I_MOVSX, I_MOVSXD,
+	I_CMOVO, I_CMOVNO, I_CMOVB, I_CMOVAE, I_CMOVE, I_CMOVNE, I_CMOVNA, I_CMOVA, I_CMOVS, I_CMOVNS, I_CMOVP, I_CMOVNP, I_CMOVL, I_CMOVGE, I_CMOVLE, I_CMOVG,
+	I_CMOVE, I_CMOVNE, I_CMOVG, I_CMOVL, I_CMOVGE, I_CMOVLE, I_CMOVNA, I_CMOVB, I_CMOVB, I_CMOVAE, I_CMOVAE, I_CMOVA, I_CMOVP, I_CMOVNP,
+	I_SETO, I_SETNO, I_SETB, I_SETAE, I_SETE, I_SETNE, I_SETNA, I_SETA, I_SETS, I_SETNS, I_SETP, I_SETNP, I_SETL, I_SETGE, I_SETLE, I_SETG,
+	I_SETE, I_SETNE, I_SETG, I_SETL, I_SETGE, I_SETLE, I_SETNA, I_SETB, I_SETB, I_SETAE, I_SETAE, I_SETA, I_SETP, I_SETNP,
+	I_NOP, I_SYSCALL, I_LEAVE, I_CDQ, I_CQO, I_CDQE,
+	I_CDQ, I_CQO, I_CDQE,
+	I_MOVSB, I_MOVSW, I_MOVSD, I_MOVSQ, I_STOSB, I_STOSW, I_STOSD, I_STOSQ,
+	I_LODSB, I_LODSW, I_LODSD, I_LODSQ, I_CMPSB, I_CMPSW, I_CMPSD, I_CMPSQ,
+	I_SCASB, I_SCASW, I_SCASD, I_SCASQ,
+	I_MOVSS, I_MOVAPS, I_MOVAPD, I_MOVUPS, I_MOVUPD, I_MOVD, I_MOVQ,
+	I_ADDSS, I_ADDSD, I_ADDPS, I_ADDPD, I_SUBSS, I_SUBSD, I_SUBPS, I_SUBPD,
+	I_MULSS, I_MULSD, I_MULPS, I_MULPD, I_DIVSS, I_DIVSD, I_DIVPS, I_DIVPD,
+	I_SQRTSS, I_SQRTSD, I_SQRTPS, I_SQRTPD, I_MINSS, I_MINSD, I_MAXSS, I_MAXSD,
+	I_UCOMISS, I_UCOMISD, I_COMISS, I_COMISD,
+	I_CVTSI2SS, I_CVTSI2SD, I_CVTSS2SI, I_CVTSD2SI, I_CVTTSS2SI, I_CVTTSD2SI, I_CVTSS2SD, I_CVTSD2SS,
+	I_ANDPS, I_ANDPD, I_ANDNPS, I_ANDNPD, I_ORPS, I_ORPD, I_XORPS, I_XORPD, I_PXOR,
+	I_LOCK, I_REP, I_REPE, I_REPE, I_REPNE, I_REPNE,
+	K_SEC, K_GLB, K_EXT, K_EQU, K_TIMES, K_DB, K_DW, K_DD, K_DQ,
+	K_RESB, K_RESW, K_RESD, K_RESQ,
+	K_SBYTE, K_SWORD, K_SDWORD, K_SQWORD, K_PTR,
+	K_REL, K_ABS, K_DEFAULT,
 	IDENT, IDENT, IDENT, // 段名作为标识符处理
 }
 
@@ -260,3 +818,55 @@ func Lookup(ident string) Token {
 }
 
 func (tok Token) IsLiteral() bool { return _literal < tok && tok < _literalEnd }
+
+// IsRegister 通用寄存器及 xmm 寄存器
+func (tok Token) IsRegister() bool { return BR_AL <= tok && tok <= XR_XMM15 }
+
+// IsXmm SSE 寄存器 xmm0~xmm15
+func (tok Token) IsXmm() bool { return XR_XMM0 <= tok && tok <= XR_XMM15 }
+
+// IsSegment 段寄存器
+func (tok Token) IsSegment() bool { return SR_ES <= tok && tok <= SR_GS }
+
+// IsInstr 机器指令, 包括指令前缀
+func (tok Token) IsInstr() bool { return I_MOV <= tok && tok <= I_REPNE }
+
+// IsPrefix 指令前缀 lock/rep/repe/repne
+func (tok Token) IsPrefix() bool { return I_LOCK <= tok && tok <= I_REPNE }
+
+// IsBranch 跳转及调用指令, 操作数为跳转目标
+func (tok Token) IsBranch() bool {
+	return tok == I_CALL || I_JMP <= tok && tok <= I_JNP
+}
+
+// IsComparison 是否为比较运算符
+func (tok Token) IsComparison() bool {
+	return LSS <= tok && tok <= GEQ
+}
+
+// Precedence 二元运算符的优先级, 0 表示不是二元运算符
+func (tok Token) Precedence() int {
+	switch tok {
+	case LOR:
+		return 1
+	case LAND:
+		return 2
+	case OR:
+		return 3
+	case XOR:
+		return 4
+	case AND:
+		return 5
+	case EQL, NEQ:
+		return 6
+	case LSS, GTR, LEQ, GEQ:
+		return 7
+	case SHL, SHR:
+		return 8
+	case ADD, SUB:
+		return 9
+	case MUL, DIV, REM:
+		return 10
+	}
+	return 0
+}
@@ -3,9 +3,9 @@ package main
 import (
 	"flag"
 	"fmt"
-	"github.com/facelang/face/compiler/assemble/internal"
-	"github.com/facelang/face/compiler/provider/asm"
+	"github.com/facelang/face/compiler/assemble/asm"
 	"github.com/facelang/face/internal/os/elf"
+	"github.com/facelang/face/internal/prog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,9 +14,26 @@ import (
 var (
 	Debug      = flag.Bool("debug", false, "启用调试模式，默认不启用")
 	OutputFile = flag.String("o", "", "输出文件，默认跟输入文件保持一致")
-	// todo 可以指定平台信息， 支持跨平台编译
+	Listing    = flag.String("l", "", "输出列表文件(地址、机器码及源码)")
+	Arch       = flag.String("arch", "amd64", "目标架构: 386, amd64, arm64, riscv64")
+	Syntax     = flag.String("syntax", "intel", "汇编语法风格: att, intel, plan9; arm64, riscv64 只支持 att(默认)")
+	DebugInfo  = flag.Bool("g", false, "生成 DWARF 调试信息(行号表)")
+	PIC        = flag.Bool("fpic", false, "生成位置无关代码: x86 跳转到外部符号时经过 PLT, RISC-V 的 la 从 GOT 加载")
+	Includes   dirList
 )
 
+// dirList 可以重复指定的目录列表参数
+type dirList []string
+
+func (l *dirList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *dirList) Set(dir string) error {
+	*l = append(*l, dir)
+	return nil
+}
+
 func Usage() {
 	fmt.Fprintf(os.Stderr, "usage: asm [options] file.s ...\n")
 	fmt.Fprintf(os.Stderr, "Flags:\n")
@@ -24,39 +41,96 @@ func Usage() {
 	os.Exit(2)
 }
 
+// flagSet 命令行是否显式指定了参数
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
 func main() {
+	flag.Var(&Includes, "I", "包含文件的查找目录, 可以重复指定")
+	flag.Usage = Usage
+	flag.Parse()
+
 	if flag.NArg() == 0 {
 		flag.Usage()
 	}
 
-	if *OutputFile == "" {
-		if flag.NArg() != 1 {
-			flag.Usage()
-		}
-		input := filepath.Base(flag.Arg(0))
-		input = strings.TrimSuffix(input, ".s")
-		*OutputFile = fmt.Sprintf("%s.o", input)
+	if (*OutputFile != "" || *Listing != "") && flag.NArg() != 1 {
+		flag.Usage()
+	}
+
+	syntax := *Syntax
+	if !flagSet("syntax") { // 使用架构默认的语法, 非 x86 架构只有 GAS 语法
+		syntax = ""
 	}
 
+	failed := false
 	for _, f := range flag.Args() {
-		lexer := internal.NewLexer(f)
-		parser := internal.NewParser(lexer)
-		pList := new(obj.Plist)
-		pList.Firstpc, ok = parser.Parse() // p.firstProg
+		output := *OutputFile
+		if output == "" {
+			output = strings.TrimSuffix(filepath.Base(f), ".s") + ".o"
+		}
+
+		if err := assemble(f, output, syntax); err != nil {
+			if list, ok := err.(asm.ErrorList); ok { // 列出全部错误
+				prog.PrintError(os.Stderr, list)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", f, err)
+			}
+			failed = true
+			continue
+		}
+
+		if *Debug {
+			file, err := elf.ReadElf(output)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", output, err)
+				continue
+			}
+			file.Objdump()
+		}
+	}
 
-		obj.Flushplist(ctxt, pList, nil)
+	if failed {
+		os.Exit(1)
 	}
+}
 
-	buf, err := os.ReadFile("common.t")
+// assemble 汇编源文件 input, 输出目标文件 output, 同时按参数输出列表文件
+func assemble(input, output, syntax string) (err error) {
+	src, err := os.ReadFile(input)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	for _, b := range buf {
-		fmt.Printf("%d, ", b)
+	opts := asm.Options{
+		Filename: input,
+		Arch:     *Arch,
+		Syntax:   syntax,
+		Includes: Includes,
+		Debug:    *DebugInfo,
+		PIC:      *PIC,
 	}
-	_ = asm.Program("example/hello.s")
-	println("完成编译！")
-	file, _ := elf.ReadElf("common.o")
-	file.Objdump()
-
+	if *Listing != "" {
+		f, err := os.Create(*Listing)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		opts.Listing = f
+	}
+	obj, err := asm.Assemble(src, opts)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, obj.Data, 0666)
 }
@@ -1,10 +1,21 @@
 package arch
 
+import (
+	"github.com/facelang/face/internal/os/elf"
+	"strings"
+)
+
+// As 指令编号, 由各架构的指令表定义
+type As int16
+
 // Arch wraps the link architecture object with more architecture-specific information.
 type Arch struct {
-	//*obj.LinkArch
+	Name    string      // 架构名称: 386, amd64, arm64, riscv64
+	Machine elf.Machine // 目标文件的 e_machine
+	Flags   uint32      // 目标文件的 e_flags, 例如 RISC-V 的压缩指令及浮点 ABI 标志
+	PtrSize int         // 指针宽度(字节)
 	// Map of instruction names to enumeration.
-	InstrTable map[string]obj.As
+	InstrTable map[string]As
 	// Map of register names to enumeration.
 	Register map[string]int16
 	// Table of register prefix names. These are things like R for R(0) and SPR for SPR(268).
@@ -14,3 +25,40 @@ type Arch struct {
 	// Instruction is a jump.
 	IsJump func(word string) bool
 }
+
+// Set 按名称查找目标架构, 不支持时返回 nil
+func Set(name string) *Arch {
+	switch name {
+	case "386":
+		return archX86(name, elf.EM_386, 4)
+	case "amd64":
+		return archX86(name, elf.EM_X86_64, 8)
+	case "arm64":
+		return archArm64()
+	case "riscv64":
+		return archRiscv64()
+	}
+	return nil
+}
+
+// archX86 x86 的指令及寄存器由汇编器的词法分析识别, 这里只描述目标文件格式
+func archX86(name string, machine elf.Machine, ptrSize int) *Arch {
+	return &Arch{
+		Name:    name,
+		Machine: machine,
+		PtrSize: ptrSize,
+		IsJump: func(word string) bool {
+			word = strings.ToLower(word)
+			return word[0] == 'j' || word == "call" || word == "ret" || word == "loop"
+		},
+	}
+}
+
+// instrTable 按名称列表生成指令表, 指令编号从 1 开始
+func instrTable(names []string) map[string]As {
+	table := make(map[string]As, len(names))
+	for i, name := range names {
+		table[name] = As(i + 1)
+	}
+	return table
+}
@@ -0,0 +1,212 @@
+package arch
+
+import (
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"strings"
+)
+
+// AArch64 寄存器编号: 低 5 位为寄存器号, 编号 31 按 REG_A64_SP 区分栈指针与零寄存器
+const (
+	REG_A64_W  = 1 << 5 // 32 位寄存器 w0-w30, wzr, wsp
+	REG_A64_SP = 1 << 6 // 栈指针 sp/wsp, 否则编号 31 为零寄存器 xzr/wzr
+
+	REG_A64_LR  = 30
+	REG_A64_ZR  = 31
+	REG_A64_RSP = 31 | REG_A64_SP
+)
+
+// AArch64 指令编号, 与 arm64Anames 的顺序一致; 条件跳转 b.eq 等统一为 A64_BCOND
+const (
+	A64_ADD As = iota + 1
+	A64_ADDS
+	A64_SUB
+	A64_SUBS
+	A64_CMP
+	A64_CMN
+	A64_NEG
+	A64_NEGS
+	A64_AND
+	A64_ANDS
+	A64_ORR
+	A64_EOR
+	A64_BIC
+	A64_BICS
+	A64_ORN
+	A64_EON
+	A64_TST
+	A64_MVN
+	A64_MOV
+	A64_MOVZ
+	A64_MOVN
+	A64_MOVK
+	A64_LSL
+	A64_LSR
+	A64_ASR
+	A64_ROR
+	A64_MUL
+	A64_MNEG
+	A64_MADD
+	A64_MSUB
+	A64_SMULL
+	A64_UMULL
+	A64_SMULH
+	A64_UMULH
+	A64_SDIV
+	A64_UDIV
+	A64_CSEL
+	A64_CSINC
+	A64_CSINV
+	A64_CSNEG
+	A64_CSET
+	A64_CSETM
+	A64_CINC
+	A64_CNEG
+	A64_SXTB
+	A64_SXTH
+	A64_SXTW
+	A64_UXTB
+	A64_UXTH
+	A64_SBFM
+	A64_UBFM
+	A64_SBFX
+	A64_UBFX
+	A64_CLZ
+	A64_RBIT
+	A64_REV
+	A64_ADR
+	A64_ADRP
+	A64_B
+	A64_BL
+	A64_BCOND
+	A64_BR
+	A64_BLR
+	A64_RET
+	A64_CBZ
+	A64_CBNZ
+	A64_TBZ
+	A64_TBNZ
+	A64_SVC
+	A64_BRK
+	A64_NOP
+	A64_DMB
+	A64_DSB
+	A64_ISB
+	A64_LDR
+	A64_STR
+	A64_LDRB
+	A64_STRB
+	A64_LDRH
+	A64_STRH
+	A64_LDRSB
+	A64_LDRSH
+	A64_LDRSW
+	A64_LDUR
+	A64_STUR
+	A64_LDURB
+	A64_STURB
+	A64_LDURH
+	A64_STURH
+	A64_LDP
+	A64_STP
+	A64_LDXR
+	A64_STXR
+	A64_LDAXR
+	A64_STLXR
+	A64_LDAR
+	A64_STLR
+)
+
+var arm64Anames = []string{
+	"add", "adds", "sub", "subs", "cmp", "cmn", "neg", "negs",
+	"and", "ands", "orr", "eor", "bic", "bics", "orn", "eon", "tst", "mvn",
+	"mov", "movz", "movn", "movk",
+	"lsl", "lsr", "asr", "ror",
+	"mul", "mneg", "madd", "msub", "smull", "umull", "smulh", "umulh", "sdiv", "udiv",
+	"csel", "csinc", "csinv", "csneg", "cset", "csetm", "cinc", "cneg",
+	"sxtb", "sxth", "sxtw", "uxtb", "uxth", "sbfm", "ubfm", "sbfx", "ubfx",
+	"clz", "rbit", "rev",
+	"adr", "adrp",
+	"b", "bl", "b.cond", "br", "blr", "ret", "cbz", "cbnz", "tbz", "tbnz",
+	"svc", "brk", "nop", "dmb", "dsb", "isb",
+	"ldr", "str", "ldrb", "strb", "ldrh", "strh", "ldrsb", "ldrsh", "ldrsw",
+	"ldur", "stur", "ldurb", "sturb", "ldurh", "sturh",
+	"ldp", "stp", "ldxr", "stxr", "ldaxr", "stlxr", "ldar", "stlr",
+}
+
+// A64Conds 条件码名称, 下标为编码; hs/lo 为 cs/cc 的别名
+var A64Conds = map[string]uint32{
+	"eq": 0, "ne": 1, "cs": 2, "hs": 2, "cc": 3, "lo": 3, "mi": 4, "pl": 5, "vs": 6, "vc": 7,
+	"hi": 8, "ls": 9, "ge": 10, "lt": 11, "gt": 12, "le": 13, "al": 14, "nv": 15,
+}
+
+func archArm64() *Arch {
+	register := map[string]int16{
+		"sp":  REG_A64_RSP,
+		"wsp": REG_A64_RSP | REG_A64_W,
+		"xzr": REG_A64_ZR,
+		"wzr": REG_A64_ZR | REG_A64_W,
+		"lr":  REG_A64_LR,
+		"fp":  29,
+		"ip0": 16,
+		"ip1": 17,
+	}
+	for i := int16(0); i < 31; i++ {
+		register[fmt.Sprintf("x%d", i)] = i
+		register[fmt.Sprintf("w%d", i)] = i | REG_A64_W
+	}
+	instructions := instrTable(arm64Anames)
+	for cond := range A64Conds {
+		instructions["b."+cond] = A64_BCOND
+	}
+
+	return &Arch{
+		Name:           "arm64",
+		Machine:        elf.EM_AARCH64,
+		PtrSize:        8,
+		InstrTable:     instructions,
+		Register:       register,
+		RegisterPrefix: map[string]bool{"x": true, "w": true},
+		RegisterNumber: arm64RegisterNumber,
+		IsJump:         arm64IsJump,
+	}
+}
+
+// arm64RegisterNumber x(10) -> x10, w(10) -> w10
+func arm64RegisterNumber(name string, n int16) (int16, bool) {
+	if n < 0 || n > 30 {
+		return 0, false
+	}
+	switch name {
+	case "x":
+		return n, true
+	case "w":
+		return n | REG_A64_W, true
+	}
+	return 0, false
+}
+
+func arm64IsJump(word string) bool {
+	switch word = strings.ToLower(word); word {
+	case "b", "bl", "br", "blr", "ret", "cbz", "cbnz", "tbz", "tbnz":
+		return true
+	}
+	return strings.HasPrefix(word, "b.")
+}
+
+// A64RegName 寄存器名称, 用于错误信息
+func A64RegName(reg int16) string {
+	prefix := "x"
+	if reg&REG_A64_W != 0 {
+		prefix = "w"
+	}
+	switch {
+	case reg&REG_A64_SP != 0 && prefix == "x":
+		return "sp"
+	case reg&REG_A64_SP != 0:
+		return "wsp"
+	case reg&31 == REG_A64_ZR:
+		return prefix + "zr"
+	}
+	return fmt.Sprintf("%s%d", prefix, reg&31)
+}
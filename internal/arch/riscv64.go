@@ -0,0 +1,329 @@
+package arch
+
+import (
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"strings"
+)
+
+// RISC-V 目标文件的 e_flags
+const (
+	EF_RISCV_RVC              = 0x1 // 使用了压缩指令
+	EF_RISCV_FLOAT_ABI_DOUBLE = 0x4 // lp64d: 双精度浮点参数使用浮点寄存器传递
+)
+
+// RISC-V 寄存器编号
+const (
+	REG_RV_ZERO = 0
+	REG_RV_RA   = 1
+	REG_RV_SP   = 2
+	REG_RV_T1   = 6
+	REG_RV_F0   = 32 // 浮点寄存器 f0-f31 编号为 32-63, 指令中使用低 5 位
+)
+
+// RISC-V 指令编号, 与 riscv64Anames 的顺序一致; 原子指令的 .aq/.rl/.aqrl 后缀与基本指令使用相同编号
+const (
+	RV_LUI As = iota + 1
+	RV_AUIPC
+	RV_JAL
+	RV_JALR
+	RV_BEQ
+	RV_BNE
+	RV_BLT
+	RV_BGE
+	RV_BLTU
+	RV_BGEU
+	RV_LB
+	RV_LH
+	RV_LW
+	RV_LD
+	RV_LBU
+	RV_LHU
+	RV_LWU
+	RV_SB
+	RV_SH
+	RV_SW
+	RV_SD
+	RV_ADDI
+	RV_SLTI
+	RV_SLTIU
+	RV_XORI
+	RV_ORI
+	RV_ANDI
+	RV_SLLI
+	RV_SRLI
+	RV_SRAI
+	RV_ADD
+	RV_SUB
+	RV_SLL
+	RV_SLT
+	RV_SLTU
+	RV_XOR
+	RV_SRL
+	RV_SRA
+	RV_OR
+	RV_AND
+	RV_ADDIW
+	RV_SLLIW
+	RV_SRLIW
+	RV_SRAIW
+	RV_ADDW
+	RV_SUBW
+	RV_SLLW
+	RV_SRLW
+	RV_SRAW
+	RV_FENCE
+	RV_FENCEI
+	RV_ECALL
+	RV_EBREAK
+	RV_CSRRW
+	RV_CSRRS
+	RV_CSRRC
+	RV_CSRRWI
+	RV_CSRRSI
+	RV_CSRRCI
+	RV_MUL
+	RV_MULH
+	RV_MULHSU
+	RV_MULHU
+	RV_DIV
+	RV_DIVU
+	RV_REM
+	RV_REMU
+	RV_MULW
+	RV_DIVW
+	RV_DIVUW
+	RV_REMW
+	RV_REMUW
+	RV_LRW
+	RV_SCW
+	RV_AMOSWAPW
+	RV_AMOADDW
+	RV_AMOXORW
+	RV_AMOANDW
+	RV_AMOORW
+	RV_AMOMINW
+	RV_AMOMAXW
+	RV_AMOMINUW
+	RV_AMOMAXUW
+	RV_LRD
+	RV_SCD
+	RV_AMOSWAPD
+	RV_AMOADDD
+	RV_AMOXORD
+	RV_AMOANDD
+	RV_AMOORD
+	RV_AMOMIND
+	RV_AMOMAXD
+	RV_AMOMINUD
+	RV_AMOMAXUD
+	RV_FLW
+	RV_FSW
+	RV_FMADDS
+	RV_FMSUBS
+	RV_FNMSUBS
+	RV_FNMADDS
+	RV_FADDS
+	RV_FSUBS
+	RV_FMULS
+	RV_FDIVS
+	RV_FSQRTS
+	RV_FSGNJS
+	RV_FSGNJNS
+	RV_FSGNJXS
+	RV_FMINS
+	RV_FMAXS
+	RV_FCVTWS
+	RV_FCVTWUS
+	RV_FCVTLS
+	RV_FCVTLUS
+	RV_FMVXW
+	RV_FEQS
+	RV_FLTS
+	RV_FLES
+	RV_FCLASSS
+	RV_FCVTSW
+	RV_FCVTSWU
+	RV_FCVTSL
+	RV_FCVTSLU
+	RV_FMVWX
+	RV_FLD
+	RV_FSD
+	RV_FMADDD
+	RV_FMSUBD
+	RV_FNMSUBD
+	RV_FNMADDD
+	RV_FADDD
+	RV_FSUBD
+	RV_FMULD
+	RV_FDIVD
+	RV_FSQRTD
+	RV_FSGNJD
+	RV_FSGNJND
+	RV_FSGNJXD
+	RV_FMIND
+	RV_FMAXD
+	RV_FCVTSD
+	RV_FCVTDS
+	RV_FCVTWD
+	RV_FCVTWUD
+	RV_FCVTLD
+	RV_FCVTLUD
+	RV_FMVXD
+	RV_FEQD
+	RV_FLTD
+	RV_FLED
+	RV_FCLASSD
+	RV_FCVTDW
+	RV_FCVTDWU
+	RV_FCVTDL
+	RV_FCVTDLU
+	RV_FMVDX
+	RV_NOP
+	RV_LI
+	RV_LA
+	RV_LLA
+	RV_MV
+	RV_NOT
+	RV_NEG
+	RV_NEGW
+	RV_SEXTW
+	RV_SEQZ
+	RV_SNEZ
+	RV_SLTZ
+	RV_SGTZ
+	RV_BEQZ
+	RV_BNEZ
+	RV_BLEZ
+	RV_BGEZ
+	RV_BLTZ
+	RV_BGTZ
+	RV_BGT
+	RV_BLE
+	RV_BGTU
+	RV_BLEU
+	RV_J
+	RV_JR
+	RV_RET
+	RV_CALL
+	RV_TAIL
+	RV_CSRR
+	RV_CSRW
+	RV_FMVS
+	RV_FNEGS
+	RV_FABSS
+	RV_FMVD
+	RV_FNEGD
+	RV_FABSD
+)
+
+var riscv64Anames = []string{
+	"lui", "auipc", "jal", "jalr",
+	"beq", "bne", "blt", "bge", "bltu", "bgeu",
+	"lb", "lh", "lw", "ld", "lbu", "lhu", "lwu", "sb", "sh", "sw", "sd",
+	"addi", "slti", "sltiu", "xori", "ori", "andi", "slli", "srli", "srai",
+	"add", "sub", "sll", "slt", "sltu", "xor", "srl", "sra", "or", "and",
+	"addiw", "slliw", "srliw", "sraiw", "addw", "subw", "sllw", "srlw", "sraw",
+	"fence", "fence.i", "ecall", "ebreak",
+	"csrrw", "csrrs", "csrrc", "csrrwi", "csrrsi", "csrrci",
+	"mul", "mulh", "mulhsu", "mulhu", "div", "divu", "rem", "remu",
+	"mulw", "divw", "divuw", "remw", "remuw",
+	"lr.w", "sc.w", "amoswap.w", "amoadd.w", "amoxor.w", "amoand.w", "amoor.w",
+	"amomin.w", "amomax.w", "amominu.w", "amomaxu.w",
+	"lr.d", "sc.d", "amoswap.d", "amoadd.d", "amoxor.d", "amoand.d", "amoor.d",
+	"amomin.d", "amomax.d", "amominu.d", "amomaxu.d",
+	"flw", "fsw", "fmadd.s", "fmsub.s", "fnmsub.s", "fnmadd.s",
+	"fadd.s", "fsub.s", "fmul.s", "fdiv.s", "fsqrt.s", "fsgnj.s", "fsgnjn.s", "fsgnjx.s", "fmin.s", "fmax.s",
+	"fcvt.w.s", "fcvt.wu.s", "fcvt.l.s", "fcvt.lu.s", "fmv.x.w", "feq.s", "flt.s", "fle.s", "fclass.s",
+	"fcvt.s.w", "fcvt.s.wu", "fcvt.s.l", "fcvt.s.lu", "fmv.w.x",
+	"fld", "fsd", "fmadd.d", "fmsub.d", "fnmsub.d", "fnmadd.d",
+	"fadd.d", "fsub.d", "fmul.d", "fdiv.d", "fsqrt.d", "fsgnj.d", "fsgnjn.d", "fsgnjx.d", "fmin.d", "fmax.d",
+	"fcvt.s.d", "fcvt.d.s", "fcvt.w.d", "fcvt.wu.d", "fcvt.l.d", "fcvt.lu.d", "fmv.x.d", "feq.d", "flt.d", "fle.d", "fclass.d",
+	"fcvt.d.w", "fcvt.d.wu", "fcvt.d.l", "fcvt.d.lu", "fmv.d.x",
+	"nop", "li", "la", "lla", "mv", "not", "neg", "negw", "sext.w", "seqz", "snez", "sltz", "sgtz",
+	"beqz", "bnez", "blez", "bgez", "bltz", "bgtz", "bgt", "ble", "bgtu", "bleu",
+	"j", "jr", "ret", "call", "tail", "csrr", "csrw",
+	"fmv.s", "fneg.s", "fabs.s", "fmv.d", "fneg.d", "fabs.d",
+}
+
+// RVCSRs 常用控制状态寄存器的名称及编号
+var RVCSRs = map[string]uint32{
+	"fflags": 0x001, "frm": 0x002, "fcsr": 0x003,
+	"cycle": 0xC00, "time": 0xC01, "instret": 0xC02,
+	"sstatus": 0x100, "sie": 0x104, "stvec": 0x105, "sscratch": 0x140,
+	"sepc": 0x141, "scause": 0x142, "stval": 0x143, "sip": 0x144, "satp": 0x180,
+	"mstatus": 0x300, "misa": 0x301, "mie": 0x304, "mtvec": 0x305, "mscratch": 0x340,
+	"mepc": 0x341, "mcause": 0x342, "mtval": 0x343, "mip": 0x344, "mhartid": 0xF14,
+}
+
+// riscv64RegNames 整数寄存器的 ABI 名称, 下标为寄存器编号
+var riscv64RegNames = []string{
+	"zero", "ra", "sp", "gp", "tp", "t0", "t1", "t2",
+	"s0", "s1", "a0", "a1", "a2", "a3", "a4", "a5",
+	"a6", "a7", "s2", "s3", "s4", "s5", "s6", "s7",
+	"s8", "s9", "s10", "s11", "t3", "t4", "t5", "t6",
+}
+
+// riscv64FRegNames 浮点寄存器的 ABI 名称, 下标为寄存器编号减去 REG_RV_F0
+var riscv64FRegNames = []string{
+	"ft0", "ft1", "ft2", "ft3", "ft4", "ft5", "ft6", "ft7",
+	"fs0", "fs1", "fa0", "fa1", "fa2", "fa3", "fa4", "fa5",
+	"fa6", "fa7", "fs2", "fs3", "fs4", "fs5", "fs6", "fs7",
+	"fs8", "fs9", "fs10", "fs11", "ft8", "ft9", "ft10", "ft11",
+}
+
+func archRiscv64() *Arch {
+	register := map[string]int16{"fp": 8}
+	for i, name := range riscv64RegNames {
+		register[name] = int16(i)
+		register[fmt.Sprintf("x%d", i)] = int16(i)
+	}
+	for i, name := range riscv64FRegNames {
+		register[name] = REG_RV_F0 + int16(i)
+		register[fmt.Sprintf("f%d", i)] = REG_RV_F0 + int16(i)
+	}
+	instructions := instrTable(riscv64Anames)
+	for as := RV_LRW; as <= RV_AMOMAXUD; as++ {
+		name := riscv64Anames[as-1]
+		for _, suffix := range []string{".aq", ".rl", ".aqrl"} {
+			instructions[name+suffix] = as
+		}
+	}
+	instructions["fmv.x.s"], instructions["fmv.s.x"] = RV_FMVXW, RV_FMVWX // 旧的名称
+
+	return &Arch{
+		Name:           "riscv64",
+		Machine:        elf.EM_RISCV,
+		Flags:          EF_RISCV_RVC | EF_RISCV_FLOAT_ABI_DOUBLE,
+		PtrSize:        8,
+		InstrTable:     instructions,
+		Register:       register,
+		RegisterPrefix: map[string]bool{"x": true},
+		RegisterNumber: riscv64RegisterNumber,
+		IsJump:         riscv64IsJump,
+	}
+}
+
+// riscv64RegisterNumber x(10) -> x10
+func riscv64RegisterNumber(name string, n int16) (int16, bool) {
+	if name != "x" || n < 0 || n > 31 {
+		return 0, false
+	}
+	return n, true
+}
+
+func riscv64IsJump(word string) bool {
+	switch word = strings.ToLower(word); word {
+	case "jal", "jalr", "j", "jr", "ret", "call", "tail":
+		return true
+	}
+	return strings.HasPrefix(word, "b") // 条件跳转 beq, bnez ...
+}
+
+// RVRegName 寄存器的 ABI 名称, 用于错误信息
+func RVRegName(reg int16) string {
+	if reg >= REG_RV_F0 {
+		return riscv64FRegNames[reg&31]
+	}
+	return riscv64RegNames[reg&31]
+}
@@ -0,0 +1,122 @@
+package ar
+
+import (
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"path/filepath"
+)
+
+// 静态库(System V/GNU ar 格式):
+//
+//	!<arch>\n
+//	/               符号索引: 符号数(大端 4 字节), 各符号所在成员头部的文件偏移, 以 0 结尾的符号名
+//	//              长文件名表: 超过 15 个字符的成员名, 以 "/\n" 结尾; 成员头部中记为 "/偏移"
+//	name.o/         成员
+//	...
+//
+// 每个成员前有 60 字节的头部, 数据按 2 字节对齐, 以 '\n' 填充
+
+const (
+	Magic      = "!<arch>\n" // 文件标识
+	ThinMagic  = "!<thin>\n" // GNU thin 静态库, 成员数据不在文件中
+	HeaderSize = 60          // 成员头部大小
+	headerMag  = "`\n"       // 成员头部结束标识
+
+	symdefName   = "/"       // 符号索引, 偏移为 4 字节
+	symdef64Name = "/SYM64/" // 符号索引, 偏移为 8 字节
+	stringsName  = "//"      // 长文件名表
+)
+
+// Member 静态库中的成员(一般为可重定位目标文件)
+type Member struct {
+	Name string // 文件名, 不含目录
+	Date int64  // 修改时间, 秒
+	Uid  int
+	Gid  int
+	Mode uint32 // 文件权限
+	Data []byte
+}
+
+// Symbol 符号索引中的一项
+type Symbol struct {
+	Name   string
+	Member int // 定义符号的成员在 Archive.Members 中的索引
+}
+
+// Archive 静态库
+type Archive struct {
+	Members []*Member
+	Symbols []Symbol // 符号索引, 为空时不写入
+}
+
+// NewMember 创建成员, name 只保留文件名; 时间、用户及组为 0, 使输出与创建时间无关
+func NewMember(name string, data []byte) *Member {
+	return &Member{Name: filepath.Base(name), Mode: 0644, Data: data}
+}
+
+// Member 按名称查找成员
+func (a *Archive) Member(name string) *Member {
+	for _, m := range a.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// Add 添加成员, 同名的成员被替换并保持原有位置(ar r); 符号索引需要重新生成
+func (a *Archive) Add(m *Member) {
+	for i, old := range a.Members {
+		if old.Name == m.Name {
+			a.Members[i] = m
+			return
+		}
+	}
+	a.Members = append(a.Members, m)
+}
+
+// Lookup 在符号索引中查找定义符号 name 的成员, 用于链接时按需载入成员
+func (a *Archive) Lookup(name string) *Member {
+	for _, sym := range a.Symbols {
+		if sym.Name == name && sym.Member < len(a.Members) {
+			return a.Members[sym.Member]
+		}
+	}
+	return nil
+}
+
+// BuildIndex 重新生成符号索引, symbols 返回成员定义的全局符号
+func (a *Archive) BuildIndex(symbols func(m *Member) ([]string, error)) error {
+	a.Symbols = nil
+	for i, m := range a.Members {
+		names, err := symbols(m)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			a.Symbols = append(a.Symbols, Symbol{Name: name, Member: i})
+		}
+	}
+	return nil
+}
+
+// ElfSymbols 返回 ELF 目标文件成员定义的非局部符号(全局、弱符号及 STB_GNU_UNIQUE), 不是 ELF 文件的成员没有符号
+func ElfSymbols(m *Member) ([]string, error) {
+	if len(m.Data) < 4 || string(m.Data[:4]) != "\x7fELF" {
+		return nil, nil
+	}
+	f, err := elf.ParseElf(m.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", m.Name, err)
+	}
+	var names []string
+	for i, sym := range f.Symbols() {
+		bind, typ := elf.ST_BIND(sym.Info), elf.ST_TYPE(sym.Info)
+		if i == 0 || sym.Shndx == uint16(elf.SHN_UNDEF) || bind == elf.STB_LOCAL ||
+			typ == elf.STT_SECTION || typ == elf.STT_FILE {
+			continue
+		}
+		names = append(names, f.SymNames[i])
+	}
+	return names, nil
+}
@@ -0,0 +1,94 @@
+package ar
+
+import (
+	"bytes"
+	"testing"
+)
+
+func testArchive() *Archive {
+	a := &Archive{}
+	a.Add(NewMember("dir/a.o", []byte("abc")))
+	a.Add(NewMember("a_very_long_member_name.o", []byte("long")))
+	a.Add(NewMember("b.o", []byte("b")))
+	a.Symbols = []Symbol{{"foo", 0}, {"bar", 2}, {"baz", 1}}
+	return a
+}
+
+func TestArchiveBytes(t *testing.T) {
+	data, err := testArchive().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 与 GNU ar rcsD 的格式一致: 成员偏移 a.o 0xb8, a_very_long_member_name.o 0xf8, b.o 0x138
+	want := Magic +
+		"/               0           0     0     0       28        `\n" +
+		"\x00\x00\x00\x03\x00\x00\x00\xb8\x00\x00\x01\x38\x00\x00\x00\xf8foo\x00bar\x00baz\x00" +
+		"//                                              28        `\n" +
+		"a_very_long_member_name.o/\n\n" +
+		"a.o/            0           0     0     644     3         `\nabc\n" +
+		"/0              0           0     0     644     4         `\nlong" +
+		"b.o/            0           0     0     644     1         `\nb\n"
+	if string(data) != want {
+		t.Errorf("got\n%q\nwant\n%q", data, want)
+	}
+}
+
+func TestParseArchive(t *testing.T) {
+	a := testArchive()
+	data, err := a.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseArchive(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Members) != 3 {
+		t.Fatalf("members got %d", len(got.Members))
+	}
+	for i, m := range got.Members {
+		if m.Name != a.Members[i].Name || !bytes.Equal(m.Data, a.Members[i].Data) || m.Mode != 0644 {
+			t.Errorf("member %d got %+v, want %+v", i, m, a.Members[i])
+		}
+	}
+	if m := got.Lookup("baz"); m == nil || m.Name != "a_very_long_member_name.o" {
+		t.Errorf("Lookup(baz) got %+v", m)
+	}
+	if m := got.Lookup("qux"); m != nil {
+		t.Errorf("Lookup(qux) got %+v", m)
+	}
+	again, err := got.Bytes()
+	if err != nil || !bytes.Equal(again, data) {
+		t.Errorf("重新编码的结果不一致: %v", err)
+	}
+
+	// 替换同名成员, 保持原有位置
+	got.Add(NewMember("b/a.o", []byte("new")))
+	if len(got.Members) != 3 || string(got.Member("a.o").Data) != "new" || got.Members[0].Name != "a.o" {
+		t.Errorf("Add got %+v", got.Members)
+	}
+}
+
+func TestParseArchiveMalformed(t *testing.T) {
+	data, err := testArchive().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"magic", []byte("!<arc>\n")},
+		{"thin", []byte(ThinMagic)},
+		{"header", data[:len(Magic)+30]},
+		{"size", data[:len(data)-2]},
+		{"fmag", bytes.Replace(data, []byte("`\nabc"), []byte("x\nabc"), 1)},
+		{"long name", bytes.Replace(data, []byte("/0 "), []byte("/99"), 1)},
+		{"symdef", bytes.Replace(data, []byte("\x00\x00\x00\x03"), []byte("\x00\x00\x01\x00"), 1)},
+	}
+	for _, tt := range tests {
+		if _, err := ParseArchive(tt.data); err == nil {
+			t.Errorf("%s: 没有返回错误", tt.name)
+		}
+	}
+}
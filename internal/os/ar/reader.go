@@ -0,0 +1,183 @@
+package ar
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ReadArchive 读取静态库文件
+func ReadArchive(file string) (*Archive, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	a, err := ParseArchive(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return a, nil
+}
+
+// IsArchive 判断 data 是否为静态库
+func IsArchive(data []byte) bool {
+	return strings.HasPrefix(string(data[:min(len(data), len(Magic))]), Magic)
+}
+
+// header 成员头部
+type header struct {
+	name string // 原始名称, 去除末尾空格
+	date int64
+	uid  int
+	gid  int
+	mode uint32
+	size int
+}
+
+// ParseArchive 解析静态库, 成员数据引用 data
+func ParseArchive(data []byte) (*Archive, error) {
+	if len(data) >= len(ThinMagic) && string(data[:len(ThinMagic)]) == ThinMagic {
+		return nil, fmt.Errorf("不支持 thin 静态库")
+	}
+	if !IsArchive(data) {
+		return nil, fmt.Errorf("不是静态库文件")
+	}
+
+	a := &Archive{}
+	var symdef []byte // 符号索引
+	var symdef64 bool
+	var longNames []byte     // 长文件名表
+	offsets := map[int]int{} // 成员头部偏移 -> 成员索引
+	for off := len(Magic); off < len(data); {
+		h, err := readHeader(data, off)
+		if err != nil {
+			return nil, err
+		}
+		body := data[off+HeaderSize : off+HeaderSize+h.size]
+		switch {
+		case h.name == symdefName || h.name == symdef64Name:
+			symdef, symdef64 = body, h.name == symdef64Name
+		case h.name == stringsName:
+			longNames = body
+		default:
+			name, err := memberName(h.name, longNames)
+			if err != nil {
+				return nil, fmt.Errorf("成员头部(0x%x): %w", off, err)
+			}
+			offsets[off] = len(a.Members)
+			a.Members = append(a.Members, &Member{Name: name, Date: h.date, Uid: h.uid, Gid: h.gid, Mode: h.mode, Data: body})
+		}
+		off += HeaderSize + h.size + h.size%2
+	}
+
+	if symdef != nil {
+		syms, err := readSymdef(symdef, symdef64, offsets)
+		if err != nil {
+			return nil, fmt.Errorf("符号索引: %w", err)
+		}
+		a.Symbols = syms
+	}
+	return a, nil
+}
+
+// readHeader 读取 off 处的成员头部:
+//
+//	name[16] date[12] uid[6] gid[6] mode[8] size[10] "`\n"
+func readHeader(data []byte, off int) (*header, error) {
+	if len(data)-off < HeaderSize {
+		return nil, fmt.Errorf("成员头部(0x%x)超出文件范围", off)
+	}
+	b := data[off : off+HeaderSize]
+	if string(b[58:60]) != headerMag {
+		return nil, fmt.Errorf("成员头部(0x%x)结束标识错误", off)
+	}
+	field := func(begin, end int) string {
+		return strings.TrimRight(string(b[begin:end]), " ")
+	}
+	num := func(begin, end, base int) (int64, error) {
+		s := field(begin, end)
+		if s == "" {
+			return 0, nil
+		}
+		n, err := strconv.ParseInt(s, base, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("成员头部(0x%x): 数值 %q 错误", off, s)
+		}
+		return n, nil
+	}
+
+	h := &header{name: field(0, 16)}
+	var vals [5]int64
+	for i, f := range []struct{ begin, end, base int }{{16, 28, 10}, {28, 34, 10}, {34, 40, 10}, {40, 48, 8}, {48, 58, 10}} {
+		v, err := num(f.begin, f.end, f.base)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	h.date, h.uid, h.gid, h.mode = vals[0], int(vals[1]), int(vals[2]), uint32(vals[3])
+	if vals[4] > int64(len(data)-off-HeaderSize) {
+		return nil, fmt.Errorf("成员 %s(0x%x) 大小 %d 超出文件范围", h.name, off, vals[4])
+	}
+	h.size = int(vals[4])
+	return h, nil
+}
+
+// memberName 成员名: "name/" 或长文件名表中的 "/偏移"
+func memberName(name string, longNames []byte) (string, error) {
+	if !strings.HasPrefix(name, "/") {
+		return strings.TrimSuffix(name, "/"), nil
+	}
+	off, err := strconv.Atoi(name[1:])
+	if err != nil {
+		return "", fmt.Errorf("成员名 %q 错误", name)
+	}
+	if off < 0 || off >= len(longNames) {
+		return "", fmt.Errorf("成员名 %q 超出长文件名表范围", name)
+	}
+	s := string(longNames[off:])
+	end := strings.Index(s, "/\n")
+	if end < 0 {
+		return "", fmt.Errorf("成员名 %q 没有结束标识", name)
+	}
+	return s[:end], nil
+}
+
+// readSymdef 读取符号索引, 偏移为大端序
+func readSymdef(data []byte, is64 bool, offsets map[int]int) ([]Symbol, error) {
+	size := 4
+	if is64 {
+		size = 8
+	}
+	word := func(p []byte) uint64 {
+		if is64 {
+			return binary.BigEndian.Uint64(p)
+		}
+		return uint64(binary.BigEndian.Uint32(p))
+	}
+	if len(data) < size {
+		return nil, fmt.Errorf("长度 %d 错误", len(data))
+	}
+	n := word(data)
+	if n > uint64(len(data)/size-1) {
+		return nil, fmt.Errorf("符号数 %d 超出范围", n)
+	}
+	names := data[size*(int(n)+1):]
+	syms := make([]Symbol, n)
+	for i := range syms {
+		off := word(data[size*(i+1):])
+		member, ok := offsets[int(off)]
+		if !ok {
+			return nil, fmt.Errorf("偏移 0x%x 处没有成员", off)
+		}
+		end := strings.IndexByte(string(names), 0)
+		if end < 0 {
+			return nil, fmt.Errorf("符号名没有结束标识")
+		}
+		syms[i] = Symbol{Name: string(names[:end]), Member: member}
+		names = names[end+1:]
+	}
+	return syms, nil
+}
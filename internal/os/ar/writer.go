@@ -0,0 +1,122 @@
+package ar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// WriteArchive 将静态库写入文件
+func WriteArchive(a *Archive, file string) error {
+	data, err := a.Bytes()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// Bytes 编码静态库: 符号索引、长文件名表、各成员依次排列
+func (a *Archive) Bytes() ([]byte, error) {
+	// 长文件名表, 超过 15 个字符或含有 '/' 的名称
+	var longNames bytes.Buffer
+	names := make([]string, len(a.Members))
+	for i, m := range a.Members {
+		if m.Name == "" || strings.Contains(m.Name, "\n") {
+			return nil, fmt.Errorf("成员名 %q 错误", m.Name)
+		}
+		if len(m.Name) > 15 || strings.Contains(m.Name, "/") {
+			names[i] = fmt.Sprintf("/%d", longNames.Len())
+			longNames.WriteString(m.Name + "/\n")
+		} else {
+			names[i] = m.Name + "/"
+		}
+	}
+	if longNames.Len()%2 != 0 { // 与 GNU ar 一致, 填充计入大小
+		longNames.WriteByte('\n')
+	}
+
+	// 符号索引: 偏移超出 4 字节时使用 /SYM64/
+	var strtab bytes.Buffer
+	for _, sym := range a.Symbols {
+		if sym.Member < 0 || sym.Member >= len(a.Members) {
+			return nil, fmt.Errorf("符号 %s 的成员索引 %d 超出范围", sym.Name, sym.Member)
+		}
+		strtab.WriteString(sym.Name)
+		strtab.WriteByte(0)
+	}
+	is64, wordSize := false, 4
+	layout := func() (int, []int) {
+		off := len(Magic)
+		if len(a.Symbols) > 0 {
+			off += padded(HeaderSize + wordSize*(len(a.Symbols)+1) + strtab.Len())
+		}
+		if longNames.Len() > 0 {
+			off += padded(HeaderSize + longNames.Len())
+		}
+		offsets := make([]int, len(a.Members))
+		for i, m := range a.Members {
+			offsets[i] = off
+			off += padded(HeaderSize + len(m.Data))
+		}
+		return off, offsets
+	}
+	total, offsets := layout()
+	if total > math.MaxUint32 {
+		is64, wordSize = true, 8
+		total, offsets = layout()
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, total))
+	out.WriteString(Magic)
+	if len(a.Symbols) > 0 {
+		name := symdefName
+		if is64 {
+			name = symdef64Name
+		}
+		body := make([]byte, wordSize*(len(a.Symbols)+1))
+		put := func(i int, v uint64) {
+			if is64 {
+				binary.BigEndian.PutUint64(body[wordSize*i:], v)
+			} else {
+				binary.BigEndian.PutUint32(body[wordSize*i:], uint32(v))
+			}
+		}
+		put(0, uint64(len(a.Symbols)))
+		for i, sym := range a.Symbols {
+			put(i+1, uint64(offsets[sym.Member]))
+		}
+		body = append(body, strtab.Bytes()...)
+		if len(body)%2 != 0 { // 与 GNU ar 一致, 以 0 填充并计入大小
+			body = append(body, 0)
+		}
+		writeMember(out, &Member{Name: name}, name, body)
+	}
+	if longNames.Len() > 0 {
+		writeMember(out, nil, stringsName, longNames.Bytes())
+	}
+	for i, m := range a.Members {
+		writeMember(out, m, names[i], m.Data)
+	}
+	return out.Bytes(), nil
+}
+
+// padded 成员按 2 字节对齐后的大小
+func padded(size int) int {
+	return size + size%2
+}
+
+// writeMember 写入成员头部及数据; m 为空时只写入名称及大小(长文件名表)
+func writeMember(out *bytes.Buffer, m *Member, name string, data []byte) {
+	if m == nil {
+		fmt.Fprintf(out, "%-16s%-12s%-6s%-6s%-8s%-10d%s", name, "", "", "", "", len(data), headerMag)
+	} else {
+		fmt.Fprintf(out, "%-16s%-12d%-6d%-6d%-8o%-10d%s", name, m.Date, m.Uid, m.Gid, m.Mode, len(data), headerMag)
+	}
+	out.Write(data)
+	if len(data)%2 != 0 {
+		out.WriteByte('\n')
+	}
+}
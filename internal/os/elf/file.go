@@ -44,57 +44,98 @@ func (m Elf_Magic) Endian() binary.ByteOrder {
 	panic("不支持的字节序")
 }
 
-type Elf32_Phdr struct {
+// 文件头、段表项、程序头表项及重定位表项在文件中的大小, 分别对应 Header32/64, Section32/64, Prog32/64, Rel(a)32/64
+const (
+	Ehdr32Size = 52
+	Ehdr64Size = 64
+	Shdr32Size = 40
+	Shdr64Size = 64
+	Phdr32Size = 32
+	Phdr64Size = 56
+	Rel32Size  = 8
+	Rela32Size = 12
+	Rel64Size  = 16
+	Rela64Size = 24
+)
+
+// Elf_Ehdr 文件头(32bit/64bit 通用), 地址及偏移统一按 64 位记录, 读写文件时按 Magic 中的类别转换
+type Elf_Ehdr struct {
+	Magic     Elf_Magic  // 魔数和相关信息
+	Type      Elf32_Half // 文件类型: 1 可重定位, 2 可执行, 3 共享目标, 4 核心转储
+	Machine   Elf32_Half // 架构类型
+	Version   Elf32_Word // 文件版本, 一般为 1
+	Entry     Elf64_Addr // 入口点虚拟地址
+	Phoff     Elf64_Off  // 程序头表偏移
+	Shoff     Elf64_Off  // 节头表偏移
+	Flags     Elf32_Word // 处理器特定标志
+	Ehsize    Elf32_Half // ELF头部大小: 32 位 52 字节, 64 位 64 字节
+	Phentsize Elf32_Half // 程序头表项大小: 32 位 32 字节, 64 位 56 字节
+	Phnum     Elf32_Half // 程序头表项数量
+	Shentsize Elf32_Half // 节头表项大小: 32 位 40 字节, 64 位 64 字节
+	Shnum     Elf32_Half // 节头表项数量
+	Shstrndx  Elf32_Half // 节头字符串表索引
+}
+
+// Elf_Shdr 段表项(32bit/64bit 通用)
+type Elf_Shdr struct {
+	Name      Elf32_Word // 段名在 .shstrtab 中的偏移
+	Type      Elf32_Word // 段类型
+	Flags     Elf64_Xword
+	Addr      Elf64_Addr // 段虚拟地址, 可重定位文件为 0
+	Offset    Elf64_Off  // 段在文件中的偏移
+	Size      Elf64_Xword
+	Link      Elf32_Word // 相关段的索引: 符号表链接字符串表, 重定位表链接符号表
+	Info      Elf32_Word // 附加信息: 符号表为第一个全局符号的索引, 重定位表为所修正的段的索引
+	Addralign Elf64_Xword
+	Entsize   Elf64_Xword // 表项大小
+}
+
+// Elf_Phdr 程序头表项(32bit/64bit 通用)
+type Elf_Phdr struct {
 	Type   Elf32_Word
-	Offset Elf32_Off
-	VAddr  Elf32_Addr
-	Paddr  Elf32_Addr
-	Filesz Elf32_Word
-	Memsz  Elf32_Word
 	Flags  Elf32_Word
-	Align  Elf32_Word
+	Offset Elf64_Off
+	VAddr  Elf64_Addr
+	Paddr  Elf64_Addr
+	Filesz Elf64_Xword
+	Memsz  Elf64_Xword
+	Align  Elf64_Xword
+}
+
+// Elf_Sym 符号表项(32bit/64bit 通用)
+type Elf_Sym struct {
+	Name  uint32 // 符号名在 .strtab 中的偏移
+	Value uint64 // 符号值
+	Size  uint64 // 符号大小
+	Info  byte   // 符号类型和绑定信息
+	Other byte   // 可见性
+	Shndx uint16 // 符号所在节
 }
 
-// Elf32_Ehdr ELF32文件头结构
-type Elf32_Ehdr struct {
-	Magic     Elf_Magic  // (16)魔数和相关信息
-	Type      Elf32_Half // (2) 0 Unknown, 1 32-bit, 2 64-bit
-	Machine   Elf32_Half // (2) 架构类型
-	Version   Elf32_Word // (4) 0 或者 1
-	Entry     Elf32_Addr // (8) [32/64] 入口点虚拟地址(32bit 占32位 64bit占64位)
-	Phoff     Elf32_Off  // (8) [32/64] 程序头表偏移(按位占用地址宽度)
-	Shoff     Elf32_Off  // (8) [32/64] 节头表偏移(按位占用地址宽度)
-	Flags     Elf32_Word // (4) 处理器特定标志
-	Ehsize    Elf32_Half // (2) ELF头部大小
-	Phentsize Elf32_Half // (2) 程序头表项大小
-	Phnum     Elf32_Half // (2) 程序头表项数量
-	Shentsize Elf32_Half // (2) 节头表项大小
-	Shnum     Elf32_Half // (2) 节头表项数量
-	Shstrndx  Elf32_Half // (2) 节头字符串表索引
+// Elf_Rel 重定位表项(32bit/64bit 通用), 符号索引及类型分开记录, 写入文件时按类别合并为 Info
+type Elf_Rel struct {
+	Offset uint64 // 需要修正的位置
+	Sym    uint32 // 符号索引
+	Type   uint32 // 重定位类型
+	Addend int64  // 加数, 仅 RELA 格式写入重定位表项
 }
 
-// Elf32_Shdr 段表项结构
-type Elf32_Shdr struct {
-	Name      Elf32_Word // 段名（4字节，存在于字符串表中的偏移量， shstrtab 也是一个段， shstrndx ）
-	Type      Elf32_Word // 段类型 (1表示程序段.text.data 2表示符号段.symtab 3表示串表段.shstrtab 8表示内容段.bss 9表示重定位表段.rel.text.rel.data)
-	Flags     Elf32_Word // 段标志 (0表示默认 1表示可写 2表示段加载后需要为之分配空间 4表示可执行)
-	Addr      Elf32_Addr // 段虚拟地址 可重定位文件默认为零， 可执行文件由链接器计算地址
-	Offset    Elf32_Off  // 段在文件中的偏移
-	Size      Elf32_Word // 段的大小，字节单位， SHT_NOBITS 代表没有数据（此时指代加载后占用的内存大小）
-	Link      Elf32_Word // 段的链接信息，一般用于描述符号标段和重定位表段的链接信息。
-	Info      Elf32_Word // 附加信息
-	Addralign Elf32_Word // 对齐要求
-	Entsize   Elf32_Word // 表项大小
+// Elf_RelInfo 重定位信息
+type Elf_RelInfo struct {
+	SegName string   // 重定位的目标段名
+	Rel     *Elf_Rel // 重定位信息
+	RelName string   // 符号名称
+	Rela    bool     // 带加数的重定位(.rela 段), 否则加数保存在被修正的位置(.rel 段)
 }
 
-func NewShdr(Type SectionType, Flags SectionFlag, Offset, Size int) *Elf32_Shdr {
-	return &Elf32_Shdr{
+func NewShdr(Type SectionType, Flags SectionFlag, Offset, Size int) *Elf_Shdr {
+	return &Elf_Shdr{
 		Name:      0,
 		Type:      Elf32_Word(Type),
-		Flags:     Elf32_Word(Flags),
+		Flags:     Elf64_Xword(Flags),
 		Addr:      0,
-		Offset:    Elf32_Off(Offset),
-		Size:      Elf32_Word(Size),
+		Offset:    Elf64_Off(Offset),
+		Size:      Elf64_Xword(Size),
 		Link:      0,
 		Info:      0,
 		Addralign: 4,
@@ -102,49 +143,35 @@ func NewShdr(Type SectionType, Flags SectionFlag, Offset, Size int) *Elf32_Shdr
 	}
 }
 
-// Elf32_Sym ELF32符号表项结构
-type Elf32_Sym struct {
-	Name  uint32 // 符号名
-	Value uint32 // 符号值
-	Size  uint32 // 符号大小
-	Info  byte   // 符号类型和绑定信息
-	Other byte   // 保留
-	Shndx uint16 // 符号所在节
-}
-
-// Elf32_Rel ELF32重定位表项结构
-type Elf32_Rel struct {
-	Offset uint32
-	Info   uint32
-}
-
-type Elf32_RelInfo struct {
-	SegName string     // 重定位的目标段名
-	Rel     *Elf32_Rel // 重定位信息
-	RelName string     // 符号名称
-}
-
-// File elf文件类，包含elf文件的重要内容，处理elf文件
+// File elf文件类，包含elf文件的重要内容，处理elf文件; 32 位及 64 位文件使用相同的结构, 按 Ehdr.Magic 中的类别读写
 type File struct {
-	Ehdr         *Elf32_Ehdr            // ELF文件头
-	PhdrTab      []*Elf32_Phdr          // 程序头表！
-	ShdrTab      map[string]*Elf32_Shdr // 段表映射
-	ShdrNames    []string               // 段名列表,  段表名和索引的映射关系，方便符号查询自己的段信息
-	SymTab       map[string]*Elf32_Sym  // 符号表映射
-	SymNames     []string               // 符号名列表, 符号名与符号表项索引的映射关系，对于重定位表生成重要
-	RelTab       []*Elf32_RelInfo       // 重定位信息列表,// 省略 辅助数据 char *elf_dir;			   // 处理elf文件的目录
-	Name         string                 // 文件名称
-	Reader       BytesReader            // 缓存s
-	Shstrtab     []byte                 // 段表字符串表数据
-	ShstrtabSize int                    // 段表字符串表长
-	Strtab       []byte                 // 字符串表数据
-	StrtabSize   int                    // 字符串表长
-	ProgSegList  []*ProgSeg             // 程序头表缓存数据
+	Ehdr         *Elf_Ehdr            // ELF文件头
+	PhdrTab      []*Elf_Phdr          // 程序头表！
+	ShdrTab      map[string]*Elf_Shdr // 段表映射
+	ShdrNames    []string             // 段名列表,  段表名和索引的映射关系，方便符号查询自己的段信息
+	SymTab       map[string]*Elf_Sym  // 符号表映射
+	SymNames     []string             // 符号名列表, 符号名与符号表项索引的映射关系，对于重定位表生成重要
+	RelTab       []*Elf_RelInfo       // 重定位信息列表,// 省略 辅助数据 char *elf_dir;			   // 处理elf文件的目录
+	Name         string               // 文件名称
+	Reader       BytesReader          // 缓存s
+	Shstrtab     []byte               // 段表字符串表数据
+	ShstrtabSize int                  // 段表字符串表长
+	Strtab       []byte               // 字符串表数据
+	StrtabSize   int                  // 字符串表长
+	ProgSegList  []*ProgSeg           // 程序头表缓存数据
+
+	shdrs []*Elf_Shdr // 读取文件时按索引记录的段表项, 同名的段在 ShdrTab 中只保留第一个
+	syms  []*Elf_Sym  // 读取文件时按索引记录的符号表项, 同名的符号在 SymTab 中只保留第一个
 }
 
+// NewElfFile 创建 ELF 文件, 文件头及各表项的大小由 magic 中的类别(ELFCLASS32/ELFCLASS64)决定
 func NewElfFile(magic Elf_Magic, eType, eMachine Elf32_Half) *File {
+	ehsize, shentsize := Ehdr32Size, Shdr32Size
+	if magic.Bits() == int(ELFCLASS64) {
+		ehsize, shentsize = Ehdr64Size, Shdr64Size
+	}
 	file := &File{
-		Ehdr: &Elf32_Ehdr{
+		Ehdr: &Elf_Ehdr{
 			Magic:     magic,                  // 这个字段比较复杂
 			Type:      eType,                  // 文件类型： 1表示可重定位, 2表示可执行 3表示共享目标 4 表示核心转储  0 表示无效
 			Machine:   eMachine,               // 机器类型
@@ -152,18 +179,18 @@ func NewElfFile(magic Elf_Magic, eType, eMachine Elf32_Half) *File {
 			Entry:     0,                      // 程序入口的线性地址，一般用于可以执行文件， 可重定向文件该字段为 0
 			Phoff:     0,                      // 程序头表在文件内的偏移地址， 标识了程序头表在文件内的位置
 			Flags:     0,                      // 文件平台相关属性， 一般默认为 0 (x86 应该没用到)
-			Ehsize:    52,                     // 文件头的大小 (跟系统位数有关 32位52字节 64位64字节)
-			Phentsize: 0,                      // 程序头表项的大小
+			Ehsize:    Elf32_Half(ehsize),     // 文件头的大小 (跟系统位数有关 32位52字节 64位64字节)
+			Phentsize: 0,                      // 程序头表项的大小, 添加程序头表时设置
 			Phnum:     0,                      // 程序头表项的个数，确定程序头表在文件[phoff: phoff + phentsize*phnum] 的数据块中
-			Shentsize: 40,                     // 段表项的大小
+			Shentsize: Elf32_Half(shentsize),  // 段表项的大小
 			Shnum:     0,                      // 段表项的个数， 确定数据区块存在于 [shoff:shoff+shentsize*eshnum] 中
 			Shstrndx:  0,                      // .shstrtab的索引
 		},
-		ShdrTab:     make(map[string]*Elf32_Shdr),
+		ShdrTab:     make(map[string]*Elf_Shdr),
 		ShdrNames:   make([]string, 0),
-		SymTab:      make(map[string]*Elf32_Sym),
+		SymTab:      make(map[string]*Elf_Sym),
 		SymNames:    make([]string, 0),
-		RelTab:      make([]*Elf32_RelInfo, 0),
+		RelTab:      make([]*Elf_RelInfo, 0),
 		Shstrtab:    make([]byte, 0),
 		Strtab:      make([]byte, 0),
 		ProgSegList: make([]*ProgSeg, 0),
@@ -183,7 +210,7 @@ func NewElfFile(magic Elf_Magic, eType, eMachine Elf32_Half) *File {
 	// 其它字节默认为 0
 
 	// 添加空节表项(重定位文件和可执行文件都有)
-	file.AddShdr("", &Elf32_Shdr{})
+	file.AddShdr("", &Elf_Shdr{})
 
 	// 添加空符号表项
 	file.AddSym("", nil)
@@ -195,9 +222,35 @@ func (e *File) Bits() int {
 	return e.Ehdr.Magic.Bits()
 }
 
+// Is64 是否为 64 位(ELFCLASS64)文件
+func (e *File) Is64() bool {
+	return e.Bits() == int(ELFCLASS64)
+}
+
 func (e *File) Endian() binary.ByteOrder { return e.Ehdr.Magic.Endian() }
 
-func (e *File) AddShdr(shName string, shdr *Elf32_Shdr) {
+// SymSize 符号表项大小
+func (e *File) SymSize() int {
+	if e.Is64() {
+		return Sym64Size
+	}
+	return Sym32Size
+}
+
+// RelSize 重定位表项大小, rela 表示带加数的格式
+func (e *File) RelSize(rela bool) int {
+	switch {
+	case e.Is64() && rela:
+		return Rela64Size
+	case e.Is64():
+		return Rel64Size
+	case rela:
+		return Rela32Size
+	}
+	return Rel32Size
+}
+
+func (e *File) AddShdr(shName string, shdr *Elf_Shdr) {
 	if shdr != nil {
 		e.ShdrTab[shName] = shdr
 	}
@@ -226,18 +279,23 @@ func (e *File) AddShdrSec(section *Section, offset int) {
 	}
 }
 
-// AddPhdrRec 添加程序头表
-func (e *File) AddPhdr(t Elf32_Word, off Elf32_Off, vaddr Elf32_Addr, filesz, memsz, flags, align Elf32_Word) {
-	ph := &Elf32_Phdr{
+// AddPhdr 添加程序头表项, 同时按文件类别设置程序头表项大小
+func (e *File) AddPhdr(t Elf32_Word, off Elf64_Off, vaddr Elf64_Addr, filesz, memsz Elf64_Xword, flags Elf32_Word, align Elf64_Xword) {
+	ph := &Elf_Phdr{
 		Type:   t,
 		Offset: off,
 		VAddr:  vaddr,
+		Paddr:  vaddr,
 		Filesz: filesz,
 		Memsz:  memsz,
 		Flags:  flags,
 		Align:  align,
 	}
 	e.PhdrTab = append(e.PhdrTab, ph)
+	e.Ehdr.Phentsize = Phdr32Size
+	if e.Is64() {
+		e.Ehdr.Phentsize = Phdr64Size
+	}
 }
 
 // AddProgSeg 添加程序头表, 同时添加段表
@@ -253,8 +311,8 @@ func (e *File) AddProgSeg(name string, seg *ProgSeg) {
 
 	seg.Name = name
 	e.ProgSegList = append(e.ProgSegList, seg)
-	e.AddPhdr(Elf32_Word(PT_LOAD), seg.Offset, seg.BaseAddr,
-		filesz, seg.Size, Elf32_Word(flags), MemAlign)
+	e.AddPhdr(Elf32_Word(PT_LOAD), Elf64_Off(seg.Offset), Elf64_Addr(seg.BaseAddr),
+		Elf64_Xword(filesz), Elf64_Xword(seg.Size), Elf32_Word(flags), MemAlign)
 
 	shType := SHT_PROGBITS
 	shFlags := SHF_ALLOC | SHF_WRITE
@@ -269,13 +327,13 @@ func (e *File) AddProgSeg(name string, seg *ProgSeg) {
 	// 添加程序头表也要添加对应的段
 	//添加一个段表项，暂时按照4字节对齐
 	shdr := NewShdr(shType, shFlags, int(seg.Offset), int(seg.Size))
-	shdr.Addr = seg.BaseAddr
-	shdr.Addralign = Elf32_Word(shAlign)
+	shdr.Addr = Elf64_Addr(seg.BaseAddr)
+	shdr.Addralign = Elf64_Xword(shAlign)
 	e.AddShdr(name, shdr)
 }
 
-func (e *File) AddSym(name string, sym *Elf32_Sym) {
-	target := &Elf32_Sym{
+func (e *File) AddSym(name string, sym *Elf_Sym) {
+	target := &Elf_Sym{
 		Name:  0,
 		Value: 0,
 		Size:  0,
@@ -284,6 +342,7 @@ func (e *File) AddSym(name string, sym *Elf32_Sym) {
 		Shndx: 0,
 	}
 	if name != "" {
+		target.Name = sym.Name
 		target.Value = sym.Value
 		target.Size = sym.Size
 		target.Info = sym.Info
@@ -294,10 +353,34 @@ func (e *File) AddSym(name string, sym *Elf32_Sym) {
 	e.SymNames = append(e.SymNames, name)
 }
 
-func (e *File) AddRel(info *Elf32_RelInfo) {
+func (e *File) AddRel(info *Elf_RelInfo) {
 	e.RelTab = append(e.RelTab, info)
 }
 
+// Sections 按索引排列的段表项, 与 ShdrNames 一一对应
+func (e *File) Sections() []*Elf_Shdr {
+	if e.shdrs != nil {
+		return e.shdrs
+	}
+	shdrs := make([]*Elf_Shdr, len(e.ShdrNames))
+	for i, name := range e.ShdrNames {
+		shdrs[i] = e.ShdrTab[name]
+	}
+	return shdrs
+}
+
+// Symbols 按索引排列的符号表项, 与 SymNames 一一对应
+func (e *File) Symbols() []*Elf_Sym {
+	if e.syms != nil {
+		return e.syms
+	}
+	syms := make([]*Elf_Sym, len(e.SymNames))
+	for i, name := range e.SymNames {
+		syms[i] = e.SymTab[name]
+	}
+	return syms
+}
+
 func (e *File) GetSegIndex(seg string) int {
 	for i, name := range e.ShdrNames {
 		if name == seg {
@@ -316,7 +399,7 @@ func (e *File) GetSymIndex(sym string) int {
 	return -1
 }
 
-func (e *File) ReadData(offset Elf32_Off, size Elf32_Word) []byte {
+func (e *File) ReadData(offset Elf64_Off, size Elf64_Xword) []byte {
 	return e.Reader.Data(int(offset), int(size))
 }
 
@@ -383,68 +466,10 @@ func (e *File) Objdump() {
 	fmt.Printf("\t程序头表项大小：%d bytes\n", e.Ehdr.Phentsize)
 	fmt.Printf("\t程序头表项数：%d\n", e.Ehdr.Phnum)
 	fmt.Printf("\t段表项大小：%d bytes\n", e.Ehdr.Shentsize)
-	fmt.Printf("\t段表项数：%d\n", e.Ehdr.Ehsize)
+	fmt.Printf("\t段表项数：%d\n", e.Ehdr.Shnum)
 	fmt.Printf("\t节头字符串表索引：%d\n", e.Ehdr.Shstrndx)
 
-	//offset := int(e.Ehdr.Shoff)
-	//shnum := int(e.Ehdr.Shnum)
-	//shentsize := int(e.Ehdr.Shentsize)
-	//fmt.Printf("\nELF 段表信息[开始：0x%x, 长度：0x%x]：\n", offset, shnum*shentsize)
-	//w := tablewriter.NewWriter(os.Stdout)
-	//w.SetAlignment(tablewriter.ALIGN_RIGHT)
-	//w.SetHeader([]string{
-	//	"开始地址", "序号", "名称", "类型", "标志", "地址", "位置偏移", "空间大小", "链接", "附加", "对齐", "表项大小",
-	//})
-	//for i, name := range e.ShdrNames {
-	//	e := e.ShdrTab[name]
-	//	w.Append([]string{
-	//		fmt.Sprintf("0x%x", offset+i*shentsize),
-	//		fmt.Sprintf("[%d]", i),
-	//		name,
-	//		SectionTypeName(e.Type),
-	//		SectionFlagName(uint64(e.Flags)),
-	//		fmt.Sprintf("0x%x", e.Addr),
-	//		fmt.Sprintf("0x%x", e.Offset),
-	//		fmt.Sprintf("%d bytes", e.Size),
-	//		fmt.Sprintf("%d", e.Link),
-	//		fmt.Sprintf("%d", e.Info),
-	//		fmt.Sprintf("%d", e.Addralign),
-	//		fmt.Sprintf("%d bytes", e.Entsize),
-	//	})
-	//}
-	//w.Render()
-	//
-	//// 打印符号表【段】
-	//symTabInfo := e.ShdrTab[".symtab"]
-	//offset = int(symTabInfo.Offset)
-	//fmt.Printf("\nELF 符号表信息[开始：0x%x, 长度：0x%x]：\n", offset, symTabInfo.Size)
-	//w = tablewriter.NewWriter(os.Stdout)
-	//w.SetHeader([]string{
-	//	"开始地址", "序号", "名称", "地址", "空间大小", "类型和绑定", "其它", "所在节",
-	//})
-	//for i, name := range e.SymNames {
-	//	sym := e.SymTab[name]
-	//	segment := fmt.Sprintf("%d", sym.Shndx)
-	//	if sym.Shndx > 0 {
-	//		sh := e.ShdrNames[sym.Shndx]
-	//		segment = fmt.Sprintf("%s,%s", segment, sh)
-	//	}
-	//	w.Append([]string{
-	//		fmt.Sprintf("0x%x", offset+i*16),
-	//		fmt.Sprintf("[%d]", i),
-	//		name,
-	//		fmt.Sprintf("0x%x", sym.Value),
-	//		fmt.Sprintf("%d bytes", sym.Size),
-	//		fmt.Sprintf("0b%b", sym.Info),
-	//		fmt.Sprintf("0b%b", sym.Other),
-	//		segment,
-	//	})
-	//}
-	//w.Render()
-
-	// 打印重定位表【段】
-
-	// todo 循环遍历，依次打印段信息
+	// 段表、符号表、重定位表及程序头表见 face readelf
 
 }
 
@@ -0,0 +1,132 @@
+package elf
+
+import (
+	"bytes"
+	"debug/elf"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestFile 构造只有 .text 段、一个全局符号及一个重定位项的可重定位文件
+func newTestFile(class Class, machine Machine) *File {
+	var magic Elf_Magic
+	copy(magic[:], ELFMAG)
+	magic[EI_CLASS], magic[EI_DATA], magic[EI_VERSION] = byte(class), byte(ELFDATA2LSB), byte(EV_CURRENT)
+	file := NewElfFile(magic, Elf32_Half(ET_REL), Elf32_Half(machine))
+
+	text := []byte{0xe8, 0, 0, 0, 0, 0xc3}
+	offset := int(file.Ehdr.Ehsize)
+	rela := file.Is64()
+	relSec := ".rel.text"
+	if rela {
+		relSec = ".rela.text"
+	}
+	file.Shstrtab = []byte("\x00.text\x00.shstrtab\x00.symtab\x00.strtab\x00" + relSec + "\x00")
+	file.Strtab = []byte("\x00f\x00")
+	file.ShstrtabSize, file.StrtabSize = len(file.Shstrtab), len(file.Strtab)
+
+	shdr := NewShdr(SHT_PROGBITS, SHF_ALLOC|SHF_EXECINSTR, offset, len(text))
+	shdr.Name = 1
+	file.AddShdr(".text", shdr)
+	file.ProgSegList = append(file.ProgSegList, &ProgSeg{
+		Name: ".text", Offset: uint64(offset), Size: uint64(len(text)),
+		Blocks: []*Block{{Data: text, Size: uint64(len(text))}},
+	})
+	offset += len(text)
+
+	shdr = NewShdr(SHT_STRTAB, 0, offset, file.ShstrtabSize)
+	shdr.Name, shdr.Addralign = 7, 1
+	file.AddShdr(".shstrtab", shdr)
+	offset += file.ShstrtabSize
+
+	file.Ehdr.Shoff, file.Ehdr.Shnum, file.Ehdr.Shstrndx = uint64(offset), 6, 2
+	offset += 6 * int(file.Ehdr.Shentsize)
+
+	file.AddSym("f", &Elf_Sym{Name: 1, Info: ST_INFO(STB_GLOBAL, STT_FUNC), Shndx: 1})
+	shdr = NewShdr(SHT_SYMTAB, 0, offset, 2*file.SymSize())
+	shdr.Name, shdr.Entsize, shdr.Link, shdr.Info = 17, uint64(file.SymSize()), 4, 1
+	file.AddShdr(".symtab", shdr)
+	offset += 2 * file.SymSize()
+
+	shdr = NewShdr(SHT_STRTAB, 0, offset, file.StrtabSize)
+	shdr.Name, shdr.Addralign = 25, 1
+	file.AddShdr(".strtab", shdr)
+	offset += file.StrtabSize
+
+	typ := SHT_REL
+	if rela {
+		typ = SHT_RELA
+	}
+	shdr = NewShdr(typ, SHF_INFO_LINK, offset, file.RelSize(rela))
+	shdr.Name, shdr.Entsize, shdr.Link, shdr.Info = 33, uint64(file.RelSize(rela)), 3, 1
+	file.AddShdr(relSec, shdr)
+	file.AddRel(&Elf_RelInfo{
+		SegName: ".text",
+		Rel:     &Elf_Rel{Offset: 1, Sym: 1, Type: 4, Addend: -4},
+		RelName: "f",
+		Rela:    rela,
+	})
+	return file
+}
+
+func TestFileClass(t *testing.T) {
+	tests := []struct {
+		class     Class
+		machine   Machine
+		ehsize    int
+		shentsize int
+		relSec    string
+	}{
+		{ELFCLASS32, EM_386, Ehdr32Size, Shdr32Size, ".rel.text"},
+		{ELFCLASS64, EM_X86_64, Ehdr64Size, Shdr64Size, ".rela.text"},
+	}
+	for _, tt := range tests {
+		file := newTestFile(tt.class, tt.machine)
+		if int(file.Ehdr.Ehsize) != tt.ehsize || int(file.Ehdr.Shentsize) != tt.shentsize {
+			t.Errorf("%s: Ehsize %d, Shentsize %d", tt.class, file.Ehdr.Ehsize, file.Ehdr.Shentsize)
+		}
+		data := file.Bytes()
+
+		// 标准库能够解析输出的文件
+		f, err := elf.NewFile(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.class, err)
+			continue
+		}
+		if int(f.Class) != int(tt.class) || int(f.Machine) != int(tt.machine) {
+			t.Errorf("%s: class %s, machine %s", tt.class, f.Class, f.Machine)
+		}
+		syms, err := f.Symbols()
+		if err != nil || len(syms) != 1 || syms[0].Name != "f" {
+			t.Errorf("%s: symbols %v, %v", tt.class, syms, err)
+		}
+		if sec := f.Section(tt.relSec); sec == nil || int(sec.Entsize) != file.RelSize(file.Is64()) {
+			t.Errorf("%s: 缺少重定位段 %s", tt.class, tt.relSec)
+		}
+
+		// 读取后与写入的结构一致
+		name := filepath.Join(t.TempDir(), "a.o")
+		if err := os.WriteFile(name, data, 0666); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadElf(name)
+		if err != nil {
+			t.Errorf("%s: ReadElf: %v", tt.class, err)
+			continue
+		}
+		if got.Bits() != int(tt.class) || *got.Ehdr != *file.Ehdr {
+			t.Errorf("%s: Ehdr got %+v, want %+v", tt.class, got.Ehdr, file.Ehdr)
+		}
+		if sym := got.SymTab["f"]; sym == nil || *sym != *file.SymTab["f"] {
+			t.Errorf("%s: 符号 f got %+v", tt.class, sym)
+		}
+		want := *file.RelTab[0].Rel
+		if !file.RelTab[0].Rela {
+			want.Addend = 0
+		}
+		if len(got.RelTab) != 1 || *got.RelTab[0].Rel != want || got.RelTab[0].SegName != ".text" || got.RelTab[0].RelName != "f" {
+			t.Errorf("%s: 重定位 got %+v", tt.class, got.RelTab)
+		}
+	}
+}
@@ -5,23 +5,23 @@ import "encoding/binary"
 // Block 表示一个数据块
 type Block struct {
 	Data   []byte
-	Offset uint32
-	Size   uint32
+	Offset uint64
+	Size   uint64
 }
 
 // ProgSeg 表示段的列表, 还有两个方法： allocAddr, relocAddr
 type ProgSeg struct {
 	Name      string   // 段名称
-	BaseAddr  uint32   // 分配基地址
-	Offset    uint32   // 合并后的文件偏移
-	Size      uint32   // 合并后大小
-	Begin     uint32   // 对齐前开始位置偏移
+	BaseAddr  uint64   // 分配基地址
+	Offset    uint64   // 合并后的文件偏移
+	Size      uint64   // 合并后大小
+	Begin     uint64   // 对齐前开始位置偏移
 	OwnerList []*File  // 拥有该段的文件序列
 	Blocks    []*Block // 记录合并后的数据块序列
 }
 
 // AllocAddr 分配地址空间 base 是基址， off 是偏移
-func (s *ProgSeg) AllocAddr(name string, base *uint32, off *uint32) {
+func (s *ProgSeg) AllocAddr(name string, base *uint64, off *uint64) {
 	s.Begin = *off //记录对齐前偏移
 
 	// 虚拟地址对齐，让所有的段按照4KB字节对齐
@@ -30,7 +30,7 @@ func (s *ProgSeg) AllocAddr(name string, base *uint32, off *uint32) {
 	}
 
 	// 偏移地址对齐，让一般段按照4字节对齐，文本段按照16字节对齐
-	align := uint32(DiscAlign)
+	align := uint64(DiscAlign)
 	if name == ".text" {
 		align = 16
 	}
@@ -69,7 +69,7 @@ func (s *ProgSeg) AllocAddr(name string, base *uint32, off *uint32) {
 
 // RelocAddr 根据提供的重定位信息重定位地址
 func (s *ProgSeg) RelocAddr(relAddr uint32, relocType uint8, symAddr uint32) {
-	relOffset := relAddr - s.BaseAddr //同类合并段的数据偏移
+	relOffset := uint64(relAddr) - s.BaseAddr //同类合并段的数据偏移
 
 	// 查找修正地址所在位置的数据块
 	var targetBlock *Block
@@ -86,7 +86,7 @@ func (s *ProgSeg) RelocAddr(relAddr uint32, relocType uint8, symAddr uint32) {
 	//处理字节为b->data[relOffset-b->offset]
 	// 获取需要修改的地址位置
 	offset := relOffset - targetBlock.Offset
-	if offset+4 > uint32(len(targetBlock.Data)) {
+	if offset+4 > uint64(len(targetBlock.Data)) {
 		return
 	}
 
@@ -3,7 +3,9 @@ package elf
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"math"
 	"os"
 	"strings"
 )
@@ -64,6 +66,14 @@ func (r *bytesReader) Data(begin, length int) []byte {
 	return r.buf[begin : begin+length]
 }
 
+// Slice 读取 [begin, begin+length) 的数据, 超出范围时返回错误
+func (r *bytesReader) Slice(begin, length int) ([]byte, error) {
+	if begin < 0 || length < 0 || begin > r.e || length > r.e-begin {
+		return nil, fmt.Errorf("数据 [0x%x, +0x%x) 超出文件范围(0x%x)", begin, length, r.e)
+	}
+	return r.buf[begin : begin+length], nil
+}
+
 func (r *bytesReader) Party(begin, length int) BytesReader {
 	//if begin+length > r.e {
 	//	return nil, io.EOF
@@ -90,118 +100,425 @@ func ObjectRead[T any](r BytesReader) (*T, error) {
 	return ret, err
 }
 
-// ReadElf 打开 ELF 文件, 需要记录端序
+// ReadElf 读取 ELF 文件, 不修改文件本身
 func ReadElf(file string) (*File, error) {
-	elf := &File{Name: file}
-	f, err := os.Open(file)
-	d, err := io.ReadAll(f)
-	println(d)
-	os.Chmod(file, 0666)
 	data, err := os.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
+	elf, err := ParseElf(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	elf.Name = file
+	return elf, nil
+}
+
+// NewFile 从 r 中读取完整的 ELF 文件
+func NewFile(r io.ReaderAt) (*File, error) {
+	data, err := io.ReadAll(io.NewSectionReader(r, 0, math.MaxInt64))
+	if err != nil {
+		return nil, err
+	}
+	return ParseElf(data)
+}
+
+// ParseElf 解析内存中的 ELF 文件(32/64 位, 大小端), 填充文件头、程序头表、段表、符号表及全部重定位表;
+// 文件格式错误或数据超出文件范围时返回错误, 不会 panic
+func ParseElf(data []byte) (*File, error) {
+	if len(data) < EI_NIDENT || string(data[:len(ELFMAG)]) != ELFMAG {
+		return nil, fmt.Errorf("不是 ELF 文件")
+	}
 	magic := Elf_Magic(data[:EI_NIDENT])
+	if c := Class(magic[EI_CLASS]); c != ELFCLASS32 && c != ELFCLASS64 {
+		return nil, fmt.Errorf("不支持的 ELF 类别: %s", c)
+	}
+	if d := Data(magic[EI_DATA]); d != ELFDATA2LSB && d != ELFDATA2MSB {
+		return nil, fmt.Errorf("不支持的字节序: %s", d)
+	}
 
 	reader := NewReader(data, magic.Endian())
-	elf.Reader = reader
+	elf := &File{
+		Reader:      reader,
+		ShdrTab:     make(map[string]*Elf_Shdr),
+		SymTab:      make(map[string]*Elf_Sym),
+		RelTab:      make([]*Elf_RelInfo, 0),
+		ProgSegList: make([]*ProgSeg, 0),
+	}
+	is64 := magic.Bits() == int(ELFCLASS64)
+	ehsize, phentsize, shentsize := Ehdr32Size, Phdr32Size, Shdr32Size
+	if is64 {
+		ehsize, phentsize, shentsize = Ehdr64Size, Phdr64Size, Shdr64Size
+	}
 
-	elf.Ehdr, err = ObjectRead[Elf32_Ehdr](reader) // 前16位 magic 也读
+	var err error
+	if len(data) < ehsize {
+		return nil, fmt.Errorf("文件头不完整")
+	}
+	elf.Ehdr, err = readEhdr(reader, is64) // 前16位 magic 也读
 	if err != nil {
 		return nil, err
 	}
 
-	// -------------------------------------------
-	// 先解析段表字符串信息
-	// -------------------------------------------
-	offset := int(elf.Ehdr.Shoff)
-	shentsize := int(elf.Ehdr.Shentsize)
-	off := offset + int(elf.Ehdr.Shstrndx)*shentsize
-	next := reader.Party(off, shentsize) // 这里需要解析为指定数据结构
-	// 这个是表头， 记录字符串信息的
-	shstrtab, err := ObjectRead[Elf32_Shdr](next)
-	if err != nil {
-		return nil, err
+	// 程序头表
+	if phnum := int(elf.Ehdr.Phnum); phnum > 0 {
+		if int(elf.Ehdr.Phentsize) != phentsize {
+			return nil, fmt.Errorf("程序头表项大小 %d 错误, 应为 %d", elf.Ehdr.Phentsize, phentsize)
+		}
+		for i := 0; i < phnum; i++ {
+			next, err := elf.table(elf.Ehdr.Phoff, i, phentsize)
+			if err != nil {
+				return nil, fmt.Errorf("程序头表: %w", err)
+			}
+			phdr, err := readPhdr(next, is64)
+			if err != nil {
+				return nil, err
+			}
+			elf.PhdrTab = append(elf.PhdrTab, phdr)
+		}
+	}
+
+	// 段表, 段数超过 SHN_LORESERVE 时段数及 .shstrtab 的索引记录在第 0 个段表项中
+	shnum, shstrndx := int(elf.Ehdr.Shnum), int(elf.Ehdr.Shstrndx)
+	if elf.Ehdr.Shoff == 0 {
+		shnum = 0
+	} else if int(elf.Ehdr.Shentsize) != shentsize {
+		return nil, fmt.Errorf("段表项大小 %d 错误, 应为 %d", elf.Ehdr.Shentsize, shentsize)
+	}
+	if elf.Ehdr.Shoff != 0 && (shnum == 0 || shstrndx == int(SHN_XINDEX)) {
+		next, err := elf.table(elf.Ehdr.Shoff, 0, shentsize)
+		if err != nil {
+			return nil, fmt.Errorf("段表: %w", err)
+		}
+		sh0, err := readShdr(next, is64)
+		if err != nil {
+			return nil, err
+		}
+		if shnum == 0 {
+			shnum = int(sh0.Size)
+		}
+		if shstrndx == int(SHN_XINDEX) {
+			shstrndx = int(sh0.Link)
+		}
 	}
-	shstrTabData := reader.Data(int(shstrtab.Offset), int(shstrtab.Size))
-	elf.Shstrtab = shstrTabData
-	elf.ShstrtabSize = int(shstrtab.Size)
-
-	// -------------------------------------------
-	// 解析段表
-	// -------------------------------------------
-	// 读取完整段表
-	shdrTab := make(map[string]*Elf32_Shdr, int(elf.Ehdr.Shnum))
-	shdrNames := make([]string, int(elf.Ehdr.Shnum))
-	for index := 0; index < int(elf.Ehdr.Shnum); index++ {
-		begin := offset + index*shentsize
-		next = reader.Party(begin, shentsize)
-		shdr, err := ObjectRead[Elf32_Shdr](next)
+	if shnum > (len(data)-int(min(elf.Ehdr.Shoff, uint64(len(data)))))/shentsize {
+		return nil, fmt.Errorf("段表项数 %d 超出文件范围", shnum)
+	}
+	shdrs := make([]*Elf_Shdr, shnum)
+	for i := range shdrs {
+		next, err := elf.table(elf.Ehdr.Shoff, i, shentsize)
 		if err != nil {
+			return nil, fmt.Errorf("段表: %w", err)
+		}
+		if shdrs[i], err = readShdr(next, is64); err != nil {
 			return nil, err
 		}
-		name := StringTableName(shstrTabData, shdr.Name)
-		shdrTab[name] = shdr
-		shdrNames[index] = name
-		//if name == "" { //删除空段表项
-		//	shdrTab[name] = nil
-		//} else {
-		//	shdrTab[name] = shdr
-		//}
-	}
-	elf.ShdrTab = shdrTab
-	elf.ShdrNames = shdrNames
-
-	strTab := shdrTab[".strtab"]
-	strTabData := reader.Data(int(strTab.Offset), int(strTab.Size))
-	elf.Strtab = strTabData
-	elf.StrtabSize = int(strTab.Size)
-
-	symTab := shdrTab[".symtab"]
-	symTabSize := 16                           // todo 这个表达式不正确 2 ^ int(symTab.Entsize)      // 16
-	symTabLen := int(symTab.Size) / symTabSize // ➗ 16
-	symTabList := make(map[string]*Elf32_Sym, symTabLen)
-	symNames := make([]string, symTabLen)
-	for i := 0; i < symTabLen; i++ {
-		begin := int(symTab.Offset) + i*symTabSize
-		next = reader.Party(begin, symTabSize)
-		sym, err := ObjectRead[Elf32_Sym](next)
+	}
+
+	// 段表字符串表及段名
+	if shnum > 0 {
+		if shstrndx == int(SHN_UNDEF) || shstrndx >= shnum {
+			return nil, fmt.Errorf("段表字符串表索引 %d 超出范围", shstrndx)
+		}
+		if elf.Shstrtab, err = elf.sectionData(shdrs[shstrndx]); err != nil {
+			return nil, fmt.Errorf("段表字符串表: %w", err)
+		}
+		elf.ShstrtabSize = len(elf.Shstrtab)
+	}
+	elf.shdrs = shdrs
+	elf.ShdrNames = make([]string, shnum)
+	for i, shdr := range shdrs {
+		name, err := cstring(elf.Shstrtab, shdr.Name)
 		if err != nil {
+			return nil, fmt.Errorf("段 %d 的名称: %w", i, err)
+		}
+		elf.ShdrNames[i] = name
+		if _, ok := elf.ShdrTab[name]; !ok { // 同名的段只能按名称查找第一个
+			elf.ShdrTab[name] = shdr
+		}
+	}
+
+	// 符号表及字符串表
+	symtab := -1
+	for i, shdr := range shdrs {
+		if shdr.Type == Elf32_Word(SHT_SYMTAB) {
+			symtab = i
+			break
+		}
+	}
+	var syms []*Elf_Sym
+	if symtab >= 0 {
+		if syms, elf.Strtab, err = elf.readSymbols(shdrs, symtab); err != nil {
+			return nil, err
+		}
+		elf.syms = syms
+		elf.StrtabSize = len(elf.Strtab)
+		if elf.SymNames, err = elf.symbolNames(syms, elf.Strtab); err != nil {
 			return nil, err
 		}
-		name := StringTableName(strTabData, sym.Name)
-		symNames[i] = name
-		symTabList[name] = sym
-		//if name == "" { //无名符号，对于链接没有意义,按照链接器设计需要记录全局和局部符号，避免名字冲突
-		//	symTabList[name] = nil
-		//} else {
-		//	symTabList[name] = sym //加入符号表
-		//}
-	}
-	elf.SymTab = symTabList
-	elf.SymNames = symNames
-
-	elf.RelTab = make([]*Elf32_RelInfo, 0)
-	for name, relTab := range shdrTab { //所有段的重定位项整合
-		if strings.HasPrefix(name, ".rel") { // 重定位段
-			relTabLen := int(relTab.Size) / 8
-			for i := 0; i < relTabLen; i++ {
-				begin := int(relTab.Offset) + i*8
-				next = reader.Party(begin, 8)
-				rel, err := ObjectRead[Elf32_Rel](next)
-				if err != nil {
-					return nil, err
-				}
-				sym := symNames[int(rel.Info>>8)]
-				relName := StringTableName(strTabData, symTabList[sym].Name)
-				elf.RelTab = append(elf.RelTab, &Elf32_RelInfo{
-					SegName: name[4:],
-					Rel:     rel,
-					RelName: relName,
-				})
+		for i, name := range elf.SymNames {
+			if _, ok := elf.SymTab[name]; !ok { // 同名的局部符号只能按名称查找第一个
+				elf.SymTab[name] = syms[i]
 			}
 		}
+	} else if strtab := elf.ShdrTab[".strtab"]; strtab != nil {
+		if elf.Strtab, err = elf.sectionData(strtab); err != nil {
+			return nil, fmt.Errorf("字符串表: %w", err)
+		}
+		elf.StrtabSize = len(elf.Strtab)
+	}
+
+	// 重定位表: 按段表顺序读取全部 .rel 及 .rela 段, 引用的符号名取自所链接的符号表(.symtab 或 .dynsym)
+	linked := map[int][]string{symtab: elf.SymNames}
+	for i, shdr := range shdrs {
+		rela := shdr.Type == Elf32_Word(SHT_RELA)
+		if !rela && shdr.Type != Elf32_Word(SHT_REL) {
+			continue
+		}
+		name := elf.ShdrNames[i]
+		segName := strings.TrimPrefix(strings.TrimPrefix(name, ".rela"), ".rel")
+		if info := int(shdr.Info); info > 0 && info < shnum && shdr.Flags&Elf64_Xword(SHF_INFO_LINK) != 0 {
+			segName = elf.ShdrNames[info]
+		}
+		names, ok := linked[int(shdr.Link)]
+		if !ok && shdr.Link != 0 {
+			if int(shdr.Link) >= shnum {
+				return nil, fmt.Errorf("重定位段 %s 链接的符号表 %d 超出范围", name, shdr.Link)
+			}
+			dynsyms, dynstr, err := elf.readSymbols(shdrs, int(shdr.Link))
+			if err != nil {
+				return nil, err
+			}
+			if names, err = elf.symbolNames(dynsyms, dynstr); err != nil {
+				return nil, err
+			}
+			linked[int(shdr.Link)] = names
+		}
+		data, err := elf.sectionData(shdr)
+		if err != nil {
+			return nil, fmt.Errorf("重定位段 %s: %w", name, err)
+		}
+		relSize := elf.RelSize(rela)
+		for off := 0; off+relSize <= len(data); off += relSize {
+			rel, err := readRel(NewReader(data[off:off+relSize], reader.order), is64, rela)
+			if err != nil {
+				return nil, err
+			}
+			if int(rel.Sym) >= max(len(names), 1) {
+				return nil, fmt.Errorf("重定位段 %s: 符号索引 %d 超出范围", name, rel.Sym)
+			}
+			relName := ""
+			if rel.Sym != 0 {
+				relName = names[rel.Sym]
+			}
+			elf.RelTab = append(elf.RelTab, &Elf_RelInfo{
+				SegName: segName,
+				Rel:     rel,
+				RelName: relName,
+				Rela:    rela,
+			})
+		}
 	}
 
 	return elf, nil
 }
+
+// table 读取位于 off 处的表中第 index 项
+func (e *File) table(off uint64, index, entsize int) (BytesReader, error) {
+	begin := off + uint64(index)*uint64(entsize)
+	if begin > math.MaxInt32 {
+		return nil, fmt.Errorf("表项偏移 0x%x 超出文件范围", begin)
+	}
+	data, err := e.Reader.Slice(int(begin), entsize)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(data, e.Reader.order), nil
+}
+
+// sectionData 段在文件中的数据, SHT_NOBITS 段没有数据
+func (e *File) sectionData(shdr *Elf_Shdr) ([]byte, error) {
+	if shdr.Type == Elf32_Word(SHT_NOBITS) {
+		return nil, nil
+	}
+	if shdr.Offset > math.MaxInt32 || shdr.Size > math.MaxInt32 {
+		return nil, fmt.Errorf("数据 [0x%x, +0x%x) 超出文件范围", shdr.Offset, shdr.Size)
+	}
+	return e.Reader.Slice(int(shdr.Offset), int(shdr.Size))
+}
+
+// readSymbols 读取第 index 个段(符号表)中的全部符号, 同时返回其链接的字符串表
+func (e *File) readSymbols(shdrs []*Elf_Shdr, index int) ([]*Elf_Sym, []byte, error) {
+	shdr := shdrs[index]
+	if t := SectionType(shdr.Type); t != SHT_SYMTAB && t != SHT_DYNSYM {
+		return nil, nil, fmt.Errorf("段 %d 不是符号表", index)
+	}
+	if int(shdr.Link) >= len(shdrs) {
+		return nil, nil, fmt.Errorf("符号表链接的字符串表 %d 超出范围", shdr.Link)
+	}
+	strtab, err := e.sectionData(shdrs[shdr.Link])
+	if err != nil {
+		return nil, nil, fmt.Errorf("字符串表: %w", err)
+	}
+	data, err := e.sectionData(shdr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("符号表: %w", err)
+	}
+	symSize := e.SymSize()
+	syms := make([]*Elf_Sym, 0, len(data)/symSize)
+	for off := 0; off+symSize <= len(data); off += symSize {
+		sym, err := readSym(NewReader(data[off:off+symSize], e.Reader.order), e.Is64())
+		if err != nil {
+			return nil, nil, err
+		}
+		syms = append(syms, sym)
+	}
+	return syms, strtab, nil
+}
+
+// symbolNames 符号名列表; 段符号没有名称, 与 NewElfFile 构造的文件一致使用所在段的段名
+func (e *File) symbolNames(syms []*Elf_Sym, strtab []byte) ([]string, error) {
+	names := make([]string, len(syms))
+	for i, sym := range syms {
+		name, err := cstring(strtab, sym.Name)
+		if err != nil {
+			return nil, fmt.Errorf("符号 %d 的名称: %w", i, err)
+		}
+		if name == "" && ST_TYPE(sym.Info) == STT_SECTION && int(sym.Shndx) < len(e.ShdrNames) {
+			name = e.ShdrNames[sym.Shndx]
+		}
+		names[i] = name
+	}
+	return names, nil
+}
+
+// cstring 读取字符串表中 off 处以 0 结尾的字符串
+func cstring(tab []byte, off uint32) (string, error) {
+	if off == 0 && len(tab) == 0 {
+		return "", nil
+	}
+	if int(off) >= len(tab) {
+		return "", fmt.Errorf("字符串偏移 %d 超出字符串表范围(%d)", off, len(tab))
+	}
+	end := bytes.IndexByte(tab[off:], 0)
+	if end < 0 {
+		return "", fmt.Errorf("字符串表中偏移 %d 处的字符串没有结束符", off)
+	}
+	return string(tab[off : int(off)+end]), nil
+}
+
+// readEhdr 按文件类别读取文件头
+func readEhdr(r BytesReader, is64 bool) (*Elf_Ehdr, error) {
+	if is64 {
+		h, err := ObjectRead[Header64](r)
+		if err != nil {
+			return nil, err
+		}
+		return &Elf_Ehdr{
+			Magic: h.Ident, Type: h.Type, Machine: h.Machine, Version: h.Version,
+			Entry: h.Entry, Phoff: h.Phoff, Shoff: h.Shoff, Flags: h.Flags,
+			Ehsize: h.Ehsize, Phentsize: h.Phentsize, Phnum: h.Phnum,
+			Shentsize: h.Shentsize, Shnum: h.Shnum, Shstrndx: h.Shstrndx,
+		}, nil
+	}
+	h, err := ObjectRead[Header32](r)
+	if err != nil {
+		return nil, err
+	}
+	return &Elf_Ehdr{
+		Magic: h.Ident, Type: h.Type, Machine: h.Machine, Version: h.Version,
+		Entry: uint64(h.Entry), Phoff: uint64(h.Phoff), Shoff: uint64(h.Shoff), Flags: h.Flags,
+		Ehsize: h.Ehsize, Phentsize: h.Phentsize, Phnum: h.Phnum,
+		Shentsize: h.Shentsize, Shnum: h.Shnum, Shstrndx: h.Shstrndx,
+	}, nil
+}
+
+// readPhdr 按文件类别读取程序头表项
+func readPhdr(r BytesReader, is64 bool) (*Elf_Phdr, error) {
+	if is64 {
+		p, err := ObjectRead[Prog64](r)
+		if err != nil {
+			return nil, err
+		}
+		return &Elf_Phdr{
+			Type: p.Type, Flags: p.Flags, Offset: p.Off, VAddr: p.Vaddr, Paddr: p.Paddr,
+			Filesz: p.Filesz, Memsz: p.Memsz, Align: p.Align,
+		}, nil
+	}
+	p, err := ObjectRead[Prog32](r)
+	if err != nil {
+		return nil, err
+	}
+	return &Elf_Phdr{
+		Type: p.Type, Flags: p.Flags, Offset: uint64(p.Off), VAddr: uint64(p.Vaddr), Paddr: uint64(p.Paddr),
+		Filesz: uint64(p.Filesz), Memsz: uint64(p.Memsz), Align: uint64(p.Align),
+	}, nil
+}
+
+// readShdr 按文件类别读取段表项
+func readShdr(r BytesReader, is64 bool) (*Elf_Shdr, error) {
+	if is64 {
+		s, err := ObjectRead[Section64](r)
+		if err != nil {
+			return nil, err
+		}
+		return &Elf_Shdr{
+			Name: s.Name, Type: s.Type, Flags: s.Flags, Addr: s.Addr, Offset: s.Off, Size: s.Size,
+			Link: s.Link, Info: s.Info, Addralign: s.Addralign, Entsize: s.Entsize,
+		}, nil
+	}
+	s, err := ObjectRead[Section32](r)
+	if err != nil {
+		return nil, err
+	}
+	return &Elf_Shdr{
+		Name: s.Name, Type: s.Type, Flags: uint64(s.Flags), Addr: uint64(s.Addr),
+		Offset: uint64(s.Off), Size: uint64(s.Size), Link: s.Link, Info: s.Info,
+		Addralign: uint64(s.Addralign), Entsize: uint64(s.Entsize),
+	}, nil
+}
+
+// readSym 按文件类别读取符号表项
+func readSym(r BytesReader, is64 bool) (*Elf_Sym, error) {
+	if is64 {
+		s, err := ObjectRead[Sym64](r)
+		if err != nil {
+			return nil, err
+		}
+		return &Elf_Sym{Name: s.Name, Value: s.Value, Size: s.Size, Info: s.Info, Other: s.Other, Shndx: s.Shndx}, nil
+	}
+	s, err := ObjectRead[Sym32](r)
+	if err != nil {
+		return nil, err
+	}
+	return &Elf_Sym{Name: s.Name, Value: uint64(s.Value), Size: uint64(s.Size), Info: s.Info, Other: s.Other, Shndx: s.Shndx}, nil
+}
+
+// readRel 按文件类别读取重定位表项, rela 表示带加数的格式
+func readRel(r BytesReader, is64, rela bool) (*Elf_Rel, error) {
+	switch {
+	case is64 && rela:
+		rel, err := ObjectRead[Rela64](r)
+		if err != nil {
+			return nil, err
+		}
+		return &Elf_Rel{Offset: rel.Off, Sym: R_SYM64(rel.Info), Type: R_TYPE64(rel.Info), Addend: rel.Addend}, nil
+	case is64:
+		rel, err := ObjectRead[Rel64](r)
+		if err != nil {
+			return nil, err
+		}
+		return &Elf_Rel{Offset: rel.Off, Sym: R_SYM64(rel.Info), Type: R_TYPE64(rel.Info)}, nil
+	case rela:
+		rel, err := ObjectRead[Rela32](r)
+		if err != nil {
+			return nil, err
+		}
+		return &Elf_Rel{Offset: uint64(rel.Off), Sym: R_SYM32(rel.Info), Type: R_TYPE32(rel.Info), Addend: int64(rel.Addend)}, nil
+	}
+	rel, err := ObjectRead[Rel32](r)
+	if err != nil {
+		return nil, err
+	}
+	return &Elf_Rel{Offset: uint64(rel.Off), Sym: R_SYM32(rel.Info), Type: R_TYPE32(rel.Info)}, nil
+}
@@ -0,0 +1,97 @@
+package elf
+
+import (
+	"bytes"
+	"debug/elf"
+	"os"
+	"testing"
+)
+
+// TestParseElfExecutable 与标准库比较读取可执行文件(测试程序本身)的结果
+func TestParseElfExecutable(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		t.Skip(err)
+	}
+	want, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Skip("测试程序不是 ELF 文件")
+	}
+	got, err := NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.PhdrTab) != len(want.Progs) {
+		t.Fatalf("程序头表项数 got %d, want %d", len(got.PhdrTab), len(want.Progs))
+	}
+	for i, p := range want.Progs {
+		ph := got.PhdrTab[i]
+		if ph.Type != uint32(p.Type) || ph.Offset != p.Off || ph.VAddr != p.Vaddr || ph.Filesz != p.Filesz || ph.Memsz != p.Memsz {
+			t.Errorf("程序头表项 %d got %+v, want %+v", i, ph, p.ProgHeader)
+		}
+	}
+	if len(got.ShdrNames) != len(want.Sections) {
+		t.Fatalf("段数 got %d, want %d", len(got.ShdrNames), len(want.Sections))
+	}
+	for i, sec := range want.Sections {
+		if got.ShdrNames[i] != sec.Name || got.ShdrTab[sec.Name].Size != sec.Size {
+			t.Errorf("段 %d got %s, want %s", i, got.ShdrNames[i], sec.Name)
+		}
+	}
+	syms, err := want.Symbols()
+	if err != nil {
+		t.Skip(err) // 已去除符号表
+	}
+	if len(got.SymNames) != len(syms)+1 { // 标准库不返回空符号
+		t.Fatalf("符号数 got %d, want %d", len(got.SymNames), len(syms)+1)
+	}
+	for i, sym := range syms {
+		if name := got.SymNames[i+1]; name != sym.Name && elf.ST_TYPE(sym.Info) != elf.STT_SECTION {
+			t.Errorf("符号 %d got %s, want %s", i+1, name, sym.Name)
+		}
+	}
+}
+
+func TestParseElfMalformed(t *testing.T) {
+	for _, class := range []Class{ELFCLASS32, ELFCLASS64} {
+		data := newTestFile(class, EM_X86_64).Bytes()
+		if _, err := ParseElf(data); err != nil {
+			t.Fatalf("%s: %v", class, err)
+		}
+		// 截断的文件及任意位置被破坏的文件都只返回错误
+		for n := 0; n < len(data); n++ {
+			if _, err := ParseElf(data[:n]); err == nil && n < EI_NIDENT {
+				t.Errorf("%s: 截断为 %d 字节时没有返回错误", class, n)
+			}
+			for _, b := range []byte{0, 0x7f, 0xff} {
+				bad := bytes.Clone(data)
+				bad[n] = b
+				_, _ = ParseElf(bad)
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(data []byte)
+	}{
+		{"magic", func(data []byte) { data[1] = 'X' }},
+		{"class", func(data []byte) { data[EI_CLASS] = 3 }},
+		{"endian", func(data []byte) { data[EI_DATA] = 0 }},
+		{"shoff", func(data []byte) { data[0x28], data[0x2f] = 0xff, 0x7f }},
+		{"shentsize", func(data []byte) { data[0x3a] = 40 }},
+		{"shstrndx", func(data []byte) { data[0x3e] = 9 }},
+	}
+	for _, tt := range tests {
+		data := newTestFile(ELFCLASS64, EM_X86_64).Bytes()
+		tt.modify(data)
+		if _, err := ParseElf(data); err == nil {
+			t.Errorf("%s: 没有返回错误", tt.name)
+		}
+	}
+}
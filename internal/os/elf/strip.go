@@ -0,0 +1,287 @@
+package elf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Strip 去除调试信息段(.debug*)及作用于这些段的重定位段, 返回新的文件内容.
+// 可执行文件及共享库同时去除符号表(.symtab 及其字符串表); 可重定位目标文件与 strip --strip-debug 一致,
+// 保留符号表及其余段的重定位表, 只去除文件符号及定义在被去除段中的符号, 并按新的符号索引修改重定位表及段组.
+// 文件头、程序头表及各程序段覆盖的数据保持原有的文件偏移, 其余保留的段依次重新排列, 段表放在文件末尾
+func (e *File) Strip() ([]byte, error) {
+	if e.Reader == nil {
+		return nil, fmt.Errorf("只能处理读取的 ELF 文件")
+	}
+	shdrs := e.Sections()
+	n := len(shdrs)
+	shstrndx := int(e.Ehdr.Shstrndx)
+	if shstrndx == int(SHN_XINDEX) && n > 0 {
+		shstrndx = int(shdrs[0].Link)
+	}
+	relocatable := e.Ehdr.Type == Elf32_Half(ET_REL)
+	removed := make([]bool, n)
+	for i, sh := range shdrs {
+		switch {
+		case i == 0 || i == shstrndx:
+		case sh.Type == Elf32_Word(SHT_SYMTAB) && !relocatable:
+			removed[i] = true
+			if l := int(sh.Link); l > 0 && l < n && l != shstrndx {
+				removed[l] = true // 符号表的字符串表
+			}
+		case strings.HasPrefix(e.ShdrNames[i], ".debug"):
+			removed[i] = true
+		}
+	}
+	for i, sh := range shdrs {
+		link, info := int(sh.Link), int(sh.Info)
+		switch SectionType(sh.Type) {
+		case SHT_REL, SHT_RELA:
+			if info > 0 && info < n && removed[info] { // 作用于调试信息段的重定位
+				removed[i] = true
+			}
+		case SHT_SYMTAB_SHNDX:
+			if link < n && removed[link] {
+				removed[i] = true
+			}
+		}
+	}
+
+	// 段的新索引, 被去除的段映射为 0
+	index := make([]int, n)
+	var kept []*Elf_Shdr
+	for i, sh := range shdrs {
+		if removed[i] {
+			continue
+		}
+		index[i] = len(kept)
+		c := *sh
+		kept = append(kept, &c)
+	}
+	remap := func(i uint32) uint32 {
+		if int(i) < n {
+			return uint32(index[i])
+		}
+		return 0
+	}
+
+	// 可重定位目标文件中需要修改内容的段: 符号表、扩展段索引表、重定位表及段组
+	var contents map[int][]byte
+	if relocatable {
+		var err error
+		if contents, err = e.stripSymbols(shdrs, removed, kept, index); err != nil {
+			return nil, err
+		}
+	}
+
+	// 保持原有偏移的部分: 文件头、程序头表及程序段
+	fixed := uint64(e.Ehdr.Ehsize)
+	if len(e.PhdrTab) > 0 {
+		fixed = max(fixed, e.Ehdr.Phoff+uint64(len(e.PhdrTab))*uint64(e.Ehdr.Phentsize))
+	}
+	for _, ph := range e.PhdrTab {
+		fixed = max(fixed, ph.Offset+ph.Filesz)
+	}
+	data, err := e.Reader.Slice(0, int(min(fixed, math.MaxInt32)))
+	if err != nil {
+		return nil, fmt.Errorf("程序段: %w", err)
+	}
+	out := bytes.NewBuffer(bytes.Clone(data))
+
+	for i, sh := range shdrs {
+		if removed[i] || i == 0 {
+			continue
+		}
+		c := kept[index[i]]
+		c.Link = remap(sh.Link)
+		if t := SectionType(sh.Type); t == SHT_REL || t == SHT_RELA || sh.Flags&uint64(SHF_INFO_LINK) != 0 {
+			c.Info = remap(sh.Info)
+		}
+		if sh.Offset+sh.Size <= fixed && len(e.PhdrTab) > 0 || sh.Type == Elf32_Word(SHT_NOBITS) && sh.Offset <= fixed {
+			continue // 在程序段中, 偏移不变
+		}
+		sec, ok := contents[i]
+		if ok {
+			c.Size = uint64(len(sec))
+		} else if sec, err = e.sectionData(sh); err != nil {
+			return nil, fmt.Errorf("段 %s: %w", e.ShdrNames[i], err)
+		}
+		for align := max(sh.Addralign, 1); uint64(out.Len())%align != 0; {
+			out.WriteByte(0)
+		}
+		c.Offset = uint64(out.Len())
+		out.Write(sec)
+	}
+
+	// 动态符号表中的段索引
+	buf := out.Bytes()
+	symSize, shndxOff := e.SymSize(), 14
+	if e.Is64() {
+		shndxOff = 6
+	}
+	for _, c := range kept {
+		if c.Type != Elf32_Word(SHT_DYNSYM) {
+			continue
+		}
+		for off := c.Offset; off+uint64(symSize) <= c.Offset+c.Size && off+uint64(symSize) <= uint64(len(buf)); off += uint64(symSize) {
+			p := buf[off+uint64(shndxOff):]
+			if shndx := e.Reader.order.Uint16(p); shndx != 0 && shndx < uint16(SHN_LORESERVE) {
+				e.Reader.order.PutUint16(p, uint16(remap(uint32(shndx))))
+			}
+		}
+	}
+
+	// 段表及文件头
+	align := 4
+	if e.Is64() {
+		align = 8
+	}
+	for out.Len()%align != 0 {
+		out.WriteByte(0)
+	}
+	ehdr := *e.Ehdr
+	ehdr.Shoff = uint64(out.Len())
+	ehdr.Shnum, ehdr.Shstrndx = uint16(len(kept)), 0
+	if shstrndx < n {
+		ehdr.Shstrndx = uint16(index[shstrndx])
+	}
+	if len(kept) > 0 {
+		kept[0].Size, kept[0].Link = 0, 0
+	}
+	if len(kept) >= int(SHN_LORESERVE) { // 段数及段表字符串表索引记录在第 0 个段表项中
+		kept[0].Size, kept[0].Link = uint64(len(kept)), uint32(ehdr.Shstrndx)
+		ehdr.Shnum, ehdr.Shstrndx = 0, uint16(SHN_XINDEX)
+	}
+	for _, c := range kept {
+		if err := binary.Write(out, e.Reader.order, c.raw(e.Is64())); err != nil {
+			return nil, err
+		}
+	}
+	var head bytes.Buffer
+	if err := binary.Write(&head, e.Reader.order, ehdr.raw(e.Is64())); err != nil {
+		return nil, err
+	}
+	buf = out.Bytes()
+	copy(buf, head.Bytes())
+	return buf, nil
+}
+
+// stripSymbols 可重定位目标文件去除定义在被去除段中的符号(调试信息段的段符号等)及文件符号, 其余符号按原有顺序重新编号;
+// 返回按段索引记录的新内容: 符号表、扩展段索引表, 以及按新符号索引修改的重定位表和段组.
+// kept 为保留的段表项副本, 同时修改其中符号表的第一个全局符号索引及段组的签名符号
+func (e *File) stripSymbols(shdrs []*Elf_Shdr, removed []bool, kept []*Elf_Shdr, index []int) (map[int][]byte, error) {
+	n, order, is64 := len(shdrs), e.Reader.order, e.Is64()
+	contents := make(map[int][]byte)
+	for i, sh := range shdrs {
+		if removed[i] || sh.Type != Elf32_Word(SHT_SYMTAB) {
+			continue
+		}
+		syms, _, err := e.readSymbols(shdrs, i)
+		if err != nil {
+			return nil, err
+		}
+		// 扩展段索引表: 段索引为 SHN_XINDEX 的符号在其中记录实际的段索引
+		xndx := -1
+		var xtab []byte
+		for j, x := range shdrs {
+			if x.Type == Elf32_Word(SHT_SYMTAB_SHNDX) && int(x.Link) == i {
+				if xtab, err = e.sectionData(x); err != nil {
+					return nil, fmt.Errorf("段 %s: %w", e.ShdrNames[j], err)
+				}
+				xndx = j
+			}
+		}
+
+		symIndex := make([]uint32, len(syms)) // 符号的新索引, 被去除的符号为 0
+		var symtab, newXtab bytes.Buffer
+		locals := 0
+		for j, sym := range syms {
+			shndx := uint32(sym.Shndx)
+			if sym.Shndx == uint16(SHN_XINDEX) {
+				if 4*j+4 > len(xtab) {
+					return nil, fmt.Errorf("符号 %d 的扩展段索引超出范围", j)
+				}
+				shndx = order.Uint32(xtab[4*j:])
+			}
+			section := sym.Shndx == uint16(SHN_XINDEX) || shndx != 0 && shndx < uint32(SHN_LORESERVE)
+			if j > 0 && (section && int(shndx) < n && removed[shndx] || ST_TYPE(sym.Info) == STT_FILE) {
+				continue // 与 strip --strip-debug 一致, 文件符号视为调试信息
+			}
+			c, x := *sym, uint32(0)
+			if section && int(shndx) < n {
+				x = uint32(index[shndx])
+				c.Shndx = uint16(x)
+				if x >= uint32(SHN_LORESERVE) {
+					c.Shndx = uint16(SHN_XINDEX)
+				} else {
+					x = 0
+				}
+			}
+			symIndex[j] = uint32(symtab.Len() / e.SymSize())
+			if ST_BIND(c.Info) == STB_LOCAL {
+				locals = int(symIndex[j]) + 1
+			}
+			if err := binary.Write(&symtab, order, c.raw(is64)); err != nil {
+				return nil, err
+			}
+			binary.Write(&newXtab, order, x)
+		}
+		contents[i] = symtab.Bytes()
+		kept[index[i]].Info = uint32(locals)
+		if xndx >= 0 {
+			contents[xndx] = newXtab.Bytes()
+		}
+
+		// 引用该符号表的重定位表及段组
+		for j, x := range shdrs {
+			if removed[j] || int(x.Link) != i {
+				continue
+			}
+			data, err := e.sectionData(x)
+			if err != nil {
+				return nil, fmt.Errorf("段 %s: %w", e.ShdrNames[j], err)
+			}
+			switch SectionType(x.Type) {
+			case SHT_REL, SHT_RELA:
+				rela := x.Type == Elf32_Word(SHT_RELA)
+				size := e.RelSize(rela)
+				var rels bytes.Buffer
+				for off := 0; off+size <= len(data); off += size {
+					rel, err := readRel(NewReader(data[off:off+size], order), is64, rela)
+					if err != nil {
+						return nil, err
+					}
+					if int(rel.Sym) >= len(syms) || rel.Sym != 0 && symIndex[rel.Sym] == 0 {
+						return nil, fmt.Errorf("段 %s 的重定位引用了被去除的符号 %d", e.ShdrNames[j], rel.Sym)
+					}
+					rel.Sym = symIndex[rel.Sym]
+					if err := binary.Write(&rels, order, rel.raw(is64, rela)); err != nil {
+						return nil, err
+					}
+				}
+				contents[j] = rels.Bytes()
+			case SHT_GROUP: // 标志及成员段索引, 去除被去除的成员
+				if int(x.Info) >= len(syms) || symIndex[x.Info] == 0 && x.Info != 0 {
+					return nil, fmt.Errorf("段组 %s 的签名符号 %d 被去除", e.ShdrNames[j], x.Info)
+				}
+				kept[index[j]].Info = symIndex[x.Info]
+				var group bytes.Buffer
+				for off := 0; off+4 <= len(data); off += 4 {
+					member := order.Uint32(data[off:])
+					if off > 0 {
+						if int(member) >= n || removed[member] {
+							continue
+						}
+						member = uint32(index[member])
+					}
+					binary.Write(&group, order, member)
+				}
+				contents[j] = group.Bytes()
+			}
+		}
+	}
+	return contents, nil
+}
@@ -0,0 +1,124 @@
+package elf
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStripRelocatable(t *testing.T) {
+	for _, class := range []Class{ELFCLASS32, ELFCLASS64} {
+		src := newTestFile(class, EM_X86_64).Bytes()
+		file, err := ParseElf(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := file.Strip()
+		if err != nil {
+			t.Fatalf("%s: %v", class, err)
+		}
+		got, err := ParseElf(data)
+		if err != nil {
+			t.Fatalf("%s: %v", class, err)
+		}
+		// 没有调试信息时保留全部段、符号及重定位
+		if g, w := strings.Join(got.ShdrNames, ","), strings.Join(file.ShdrNames, ","); g != w {
+			t.Errorf("%s: 段 got %s, want %s", class, g, w)
+		}
+		if g, w := strings.Join(got.SymNames, ","), strings.Join(file.SymNames, ","); g != w {
+			t.Errorf("%s: 符号 got %s, want %s", class, g, w)
+		}
+		if len(got.RelTab) != 1 || *got.RelTab[0].Rel != *file.RelTab[0].Rel {
+			t.Errorf("%s: 重定位 got %+v", class, got.RelTab)
+		}
+	}
+}
+
+// TestStripDebug 与 strip --strip-debug 一致, 去除调试信息段、文件符号及调试信息段的段符号,
+// 重定位表按新的符号索引修改; testdata/debug*.o 由 GNU as -g 生成, 见 testdata/debug.s
+func TestStripDebug(t *testing.T) {
+	for _, name := range []string{"testdata/debug32.o", "testdata/debug64.o"} {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := ParseElf(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := file.Strip()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := ParseElf(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		rel := ".rel.text"
+		if got.Is64() {
+			rel = ".rela.text"
+		}
+		if g, w := strings.Join(got.ShdrNames, ","), ",.text,"+rel+",.data,.bss,.symtab,.strtab,.shstrtab"; g != w {
+			t.Errorf("%s: 段 got %s, want %s", name, g, w)
+		}
+		if g, w := strings.Join(got.SymNames, ","), ",.text,.data,local,f,g"; g != w {
+			t.Errorf("%s: 符号 got %s, want %s", name, g, w)
+		}
+		if info := got.ShdrTab[".symtab"].Info; info != 4 {
+			t.Errorf("%s: 第一个全局符号 got %d, want 4", name, info)
+		}
+		var rels []string
+		for _, r := range got.RelTab {
+			rels = append(rels, fmt.Sprintf("%s:%d:%s", r.SegName, r.Rel.Sym, r.RelName))
+		}
+		if g, w := strings.Join(rels, ","), ".text:5:g,.text:2:.data"; g != w {
+			t.Errorf("%s: 重定位 got %s, want %s", name, g, w)
+		}
+		text, _ := got.sectionData(got.ShdrTab[".text"])
+		want, _ := file.sectionData(file.ShdrTab[".text"])
+		if !bytes.Equal(text, want) {
+			t.Errorf("%s: .text got % x, want % x", name, text, want)
+		}
+	}
+}
+
+// TestStripExecutable 去除测试程序本身的符号, 程序段的内容及位置不变
+func TestStripExecutable(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		t.Skip(err)
+	}
+	file, err := ParseElf(data)
+	if err != nil {
+		t.Skip("测试程序不是 ELF 文件")
+	}
+	stripped, err := file.Strip()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := elf.NewFile(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sec := range f.Sections {
+		if sec.Type == elf.SHT_SYMTAB || strings.HasPrefix(sec.Name, ".debug") {
+			t.Errorf("没有去除段 %s", sec.Name)
+		}
+	}
+	if len(f.Progs) != len(file.PhdrTab) {
+		t.Fatalf("程序头表项数 got %d, want %d", len(f.Progs), len(file.PhdrTab))
+	}
+	for _, p := range f.Progs { // 文件头中的段表偏移及段数会改变
+		off, end := max(p.Off, uint64(file.Ehdr.Ehsize)), p.Off+p.Filesz
+		if off < end && !bytes.Equal(stripped[off:end], data[off:end]) {
+			t.Errorf("程序段 [0x%x, 0x%x) 的内容改变", off, end)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+# as --32 -g -o debug32.o debug.s && as --64 -g -o debug64.o debug.s
+	.file	"debug.s"
+	.text
+	.globl	f
+	.type	f, @function
+f:
+	call	g
+	movl	$local, %eax
+	ret
+	.size	f, .-f
+	.data
+local:	.long	1
@@ -45,14 +45,25 @@ func NewWriter(file string, order binary.ByteOrder) FileWriter {
 
 // FileWrite 输出elf 文件
 func FileWrite(file *File, target string) error {
+	return encode(file, target).Flush() // 最后一部再写入文件
+}
+
+// Bytes 文件的完整内容
+func (e *File) Bytes() []byte {
+	return encode(e, "").w.Bytes()
+}
+
+// encode 按文件布局写入缓存, 各表项按文件类别(32/64 位)写入
+func encode(file *File, target string) FileWriter {
 	w := NewWriter(target, file.Endian())
-	_ = w.Write(file.Ehdr) //elf文件头
+	is64 := file.Is64()
+	_ = w.Write(file.Ehdr.raw(is64)) //elf文件头
 
 	// 可执行文件
 	if file.Ehdr.Type == Elf32_Half(ET_EXEC) {
 		//程序头表
 		for _, phdr := range file.PhdrTab {
-			_ = w.Write(phdr)
+			_ = w.Write(phdr.raw(is64))
 		}
 		// 【数据段】最重要的部分
 		pad := [1]byte{0}
@@ -79,24 +90,18 @@ func FileWrite(file *File, target string) error {
 			}
 		}
 	} else {
-		// 【数据段】最重要的部分
-		var prev *ProgSeg = nil
-		pad := [1]byte{0}
+		// 【数据段】最重要的部分, 按段的文件偏移填充对齐(64 位文件的第一个段也可能需要填充)
 		for _, seg := range file.ProgSegList {
-			if prev != nil {
-				padnum := seg.Offset - (prev.Offset + prev.Size)
-				for ; padnum != 0; padnum-- { //填充
-					_ = w.Write(pad)
-				}
-			}
 			if seg.Name == ".bss" {
 				continue
 			}
+			for uint64(w.w.Len()) < seg.Offset { //填充
+				w.w.WriteByte(0)
+			}
 			for i := 0; i < len(seg.Blocks); i++ {
 				b := seg.Blocks[i]
 				_ = w.Write(b.Data)
 			}
-			prev = seg
 		}
 	}
 
@@ -105,12 +110,12 @@ func FileWrite(file *File, target string) error {
 
 	// 段表
 	for _, sh := range file.ShdrNames {
-		_ = w.Write(file.ShdrTab[sh])
+		_ = w.Write(file.ShdrTab[sh].raw(is64))
 	}
 
 	// 符号表
 	for _, sym := range file.SymNames {
-		_ = w.Write(file.SymTab[sym])
+		_ = w.Write(file.SymTab[sym].raw(is64))
 	}
 
 	// 字符串表
@@ -118,8 +123,76 @@ func FileWrite(file *File, target string) error {
 
 	// 重定位表
 	for _, rel := range file.RelTab {
-		_ = w.Write(rel.Rel)
+		_ = w.Write(rel.Rel.raw(is64, rel.Rela))
+	}
+
+	return w
+}
+
+// raw 文件头在文件中的结构: Header32 或 Header64
+func (h *Elf_Ehdr) raw(is64 bool) any {
+	if is64 {
+		return &Header64{
+			Ident: h.Magic, Type: h.Type, Machine: h.Machine, Version: h.Version,
+			Entry: h.Entry, Phoff: h.Phoff, Shoff: h.Shoff, Flags: h.Flags,
+			Ehsize: h.Ehsize, Phentsize: h.Phentsize, Phnum: h.Phnum,
+			Shentsize: h.Shentsize, Shnum: h.Shnum, Shstrndx: h.Shstrndx,
+		}
+	}
+	return &Header32{
+		Ident: h.Magic, Type: h.Type, Machine: h.Machine, Version: h.Version,
+		Entry: uint32(h.Entry), Phoff: uint32(h.Phoff), Shoff: uint32(h.Shoff), Flags: h.Flags,
+		Ehsize: h.Ehsize, Phentsize: h.Phentsize, Phnum: h.Phnum,
+		Shentsize: h.Shentsize, Shnum: h.Shnum, Shstrndx: h.Shstrndx,
 	}
+}
+
+// raw 段表项在文件中的结构: Section32 或 Section64
+func (s *Elf_Shdr) raw(is64 bool) any {
+	if is64 {
+		return &Section64{
+			Name: s.Name, Type: s.Type, Flags: s.Flags, Addr: s.Addr, Off: s.Offset, Size: s.Size,
+			Link: s.Link, Info: s.Info, Addralign: s.Addralign, Entsize: s.Entsize,
+		}
+	}
+	return &Section32{
+		Name: s.Name, Type: s.Type, Flags: uint32(s.Flags), Addr: uint32(s.Addr),
+		Off: uint32(s.Offset), Size: uint32(s.Size), Link: s.Link, Info: s.Info,
+		Addralign: uint32(s.Addralign), Entsize: uint32(s.Entsize),
+	}
+}
 
-	return w.Flush() // 最后一部再写入文件
+// raw 程序头表项在文件中的结构: Prog32 或 Prog64
+func (p *Elf_Phdr) raw(is64 bool) any {
+	if is64 {
+		return &Prog64{
+			Type: p.Type, Flags: p.Flags, Off: p.Offset, Vaddr: p.VAddr, Paddr: p.Paddr,
+			Filesz: p.Filesz, Memsz: p.Memsz, Align: p.Align,
+		}
+	}
+	return &Prog32{
+		Type: p.Type, Off: uint32(p.Offset), Vaddr: uint32(p.VAddr), Paddr: uint32(p.Paddr),
+		Filesz: uint32(p.Filesz), Memsz: uint32(p.Memsz), Flags: p.Flags, Align: uint32(p.Align),
+	}
+}
+
+// raw 符号表项在文件中的结构: Sym32 或 Sym64
+func (s *Elf_Sym) raw(is64 bool) any {
+	if is64 {
+		return &Sym64{Name: s.Name, Info: s.Info, Other: s.Other, Shndx: s.Shndx, Value: s.Value, Size: s.Size}
+	}
+	return &Sym32{Name: s.Name, Value: uint32(s.Value), Size: uint32(s.Size), Info: s.Info, Other: s.Other, Shndx: s.Shndx}
+}
+
+// raw 重定位表项在文件中的结构: Rel32, Rela32, Rel64 或 Rela64
+func (r *Elf_Rel) raw(is64, rela bool) any {
+	switch {
+	case is64 && rela:
+		return &Rela64{Off: r.Offset, Info: R_INFO(r.Sym, r.Type), Addend: r.Addend}
+	case is64:
+		return &Rel64{Off: r.Offset, Info: R_INFO(r.Sym, r.Type)}
+	case rela:
+		return &Rela32{Off: uint32(r.Offset), Info: R_INFO32(r.Sym, r.Type), Addend: int32(r.Addend)}
+	}
+	return &Rel32{Off: uint32(r.Offset), Info: R_INFO32(r.Sym, r.Type)}
 }
@@ -2,6 +2,7 @@ package reader
 
 import (
 	"fmt"
+	"github.com/facelang/face/internal/prog"
 	"os"
 	"unicode/utf8"
 )
@@ -24,6 +25,11 @@ func (r *Reader) Pos() int {
 	return r.off
 }
 
+// FilePos 当前字符所在的文件位置(行列号从 0 开始)
+func (r *Reader) FilePos() prog.FilePos {
+	return prog.FilePos{Filename: r.filename, Line: r.line, Col: r.col, Offset: r.off}
+}
+
 // GoBack 回退一个字符
 func (r *Reader) GoBack() {
 	r.ch = 0
@@ -58,6 +64,15 @@ func (r *Reader) ReadByte() (byte, bool) {
 	return r.ch, false
 }
 
+// Peek 查看第 n 个尚未读取的字符(从 0 开始), 不移动游标; 返回值是否为 eof
+func (r *Reader) Peek(n int) (byte, bool) {
+	i := r.r + r.chw + n
+	if i >= r.e {
+		return 0, true
+	}
+	return r.buff[i], false
+}
+
 func (r *Reader) ReadRune() (rune, int) {
 redo:
 	c, eof := r.ReadByte()
@@ -104,6 +119,11 @@ func (r *Reader) ReadText() string {
 	return string(r.buff[r.b : r.r+r.chw])
 }
 
+// Source 读取器的全部源码, 与 FilePos 中的 Offset 对应
+func (r *Reader) Source() []byte {
+	return r.buff
+}
+
 // FileReader todo 蔚来可能扩展支持 多种数据源读取模式，比如数据流
 func FileReader(file string) *Reader {
 	r := &Reader{filename: file}
@@ -124,3 +144,11 @@ func BytesReader(input []byte) *Reader {
 	r.e = len(input)
 	return r
 }
+
+// PosReader 读取一段源码片段(例如宏展开后的文本), 行列号从 pos 开始计算, 文件偏移从 0 开始
+func PosReader(input []byte, pos prog.FilePos) *Reader {
+	r := &Reader{filename: pos.Filename, line: pos.Line, col: pos.Col}
+	r.buff = input
+	r.e = len(input)
+	return r
+}
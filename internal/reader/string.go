@@ -12,15 +12,15 @@ func Char(r *Reader) string {
 
 func String(r *Reader, quote byte) (string, int) {
 	length := 0
-	ch, ok := r.ReadByte() // read character after quote
+	ch, eof := r.ReadByte() // read character after quote
 	for ch != quote {
-		if ch == '\n' || !ok {
+		if ch == '\n' || eof {
 			panic(fmt.Errorf("literal not terminated"))
 		}
 		if ch == '\\' {
 			ch = escape(r, quote)
 		} else {
-			ch, ok = r.ReadByte()
+			ch, eof = r.ReadByte()
 		}
 		length++
 	}
@@ -28,21 +28,21 @@ func String(r *Reader, quote byte) (string, int) {
 }
 
 func RawString(r *Reader) string {
-	ch, ok := r.ReadByte() // read character after '`'
+	ch, eof := r.ReadByte() // read character after '`'
 	for ch != '`' {
-		if !ok {
+		if eof {
 			panic(fmt.Errorf("literal not terminated"))
 		}
-		ch, ok = r.ReadByte()
+		ch, eof = r.ReadByte()
 	}
 	return r.ReadText()
 }
 
 // Comment 单行注释
 func Comment(r *Reader) string {
-	ch, ok := r.ReadByte() // read character after "//"
-	for ok && ch != '\n' {
-		ch, ok = r.ReadByte()
+	ch, eof := r.ReadByte() // read character after "//"
+	for !eof && ch != '\n' {
+		ch, eof = r.ReadByte()
 	}
 	r.GoBack()
 	return r.ReadText()
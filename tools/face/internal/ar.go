@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/facelang/face/internal/os/ar"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// ArOptions ar 的操作, 与 GNU ar 的操作字母一致
+type ArOptions struct {
+	Replace bool // r 添加成员, 替换同名的成员
+	Create  bool // c 创建静态库时不输出提示
+	Index   bool // s 只生成符号索引(ranlib); 与 GNU ar 一致, r 操作总是生成符号索引
+}
+
+// ParseArOptions 解析操作字母, 例如 "rcs"
+func ParseArOptions(key string) (ArOptions, error) {
+	var opts ArOptions
+	for _, c := range strings.TrimPrefix(key, "-") {
+		switch c {
+		case 'r':
+			opts.Replace = true
+		case 'c':
+			opts.Create = true
+		case 's':
+			opts.Index = true
+		default:
+			return opts, fmt.Errorf("不支持的操作 %q", c)
+		}
+	}
+	if !opts.Replace && !opts.Index {
+		return opts, fmt.Errorf("缺少操作 r 或 s")
+	}
+	return opts, nil
+}
+
+// Ar 将目标文件 files 加入静态库 name, 静态库不存在时创建, 并重新生成符号索引.
+// 成员的时间、用户及组记为 0, 相同的输入得到相同的输出
+func Ar(w io.Writer, name string, files []string, opts ArOptions) error {
+	a, err := ar.ReadArchive(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if !opts.Replace {
+			return err
+		}
+		a = &ar.Archive{}
+		if !opts.Create {
+			fmt.Fprintf(w, "face ar: 创建 %s\n", name)
+		}
+	case err != nil:
+		return err
+	}
+
+	if opts.Replace {
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				return err
+			}
+			a.Add(ar.NewMember(f, data))
+		}
+	}
+	if err := a.BuildIndex(ar.ElfSymbols); err != nil {
+		return err
+	}
+	return ar.WriteArchive(a, name)
+}
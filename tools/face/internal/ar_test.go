@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"github.com/facelang/face/internal/os/ar"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAr(t *testing.T) {
+	dir := t.TempDir()
+	objs := map[string]string{
+		"hello.o":               "\t.globl hello\nhello:\n\tret\nloc:\tret\n",
+		"a_long_object_name.o":  "\t.weak w\nw:\tret\n",
+		"another_long_object.o": "\t.globl g\n\t.data\ng:\t.long 1\n",
+	}
+	for name, src := range objs {
+		if err := os.Rename(assembleFile(t, "amd64", src), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lib := filepath.Join(dir, "libt.a")
+	add := func(opts ArOptions, names ...string) {
+		t.Helper()
+		var files []string
+		for _, name := range names {
+			files = append(files, filepath.Join(dir, name))
+		}
+		var b strings.Builder
+		if err := Ar(&b, lib, files, opts); err != nil {
+			t.Fatal(err)
+		}
+		if b.Len() != 0 {
+			t.Errorf("c 操作时输出 %q", b.String())
+		}
+	}
+	opts, err := ParseArOptions("rcs")
+	if err != nil || opts != (ArOptions{Replace: true, Create: true, Index: true}) {
+		t.Fatalf("ParseArOptions got %+v, %v", opts, err)
+	}
+	add(opts, "hello.o", "a_long_object_name.o")
+	add(opts, "another_long_object.o", "hello.o") // 替换 hello.o
+
+	a, err := ar.ReadArchive(lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range a.Members {
+		names = append(names, m.Name)
+	}
+	if got := strings.Join(names, " "); got != "hello.o a_long_object_name.o another_long_object.o" {
+		t.Errorf("members got %s", got)
+	}
+	for sym, want := range map[string]string{"hello": "hello.o", "w": "a_long_object_name.o", "g": "another_long_object.o", "loc": "", "undefined": ""} {
+		got := ""
+		if m := a.Lookup(sym); m != nil {
+			got = m.Name
+		}
+		if got != want {
+			t.Errorf("Lookup(%s) got %q, want %q", sym, got, want)
+		}
+	}
+
+	for _, key := range []string{"x", "c", ""} {
+		if _, err := ParseArOptions(key); err == nil {
+			t.Errorf("ParseArOptions(%q) 没有返回错误", key)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"io"
+	"sort"
+)
+
+// 符号列表(face nm), 与 GNU nm 的默认格式一致:
+//
+//	                 U write
+//	0000000000000000 T _start
+//	0000000000000000 d msg
+
+// NmOptions nm 的输出选项
+type NmOptions struct {
+	DefinedOnly   bool // --defined-only 只显示已定义的符号
+	UndefinedOnly bool // --undefined-only 只显示未定义的符号
+	NumericSort   bool // -n 按地址排序, 默认按名称排序
+	NoSort        bool // -p 按符号表中的顺序
+	Reverse       bool // -r 逆序排序
+	ShowFile      bool // 输出文件名, 用于多个文件
+}
+
+// nmSymbol nm 输出的一个符号
+type nmSymbol struct {
+	Name  string
+	Value uint64
+	Type  byte
+}
+
+func (s nmSymbol) undefined() bool {
+	return s.Type == 'U' || s.Type == 'w'
+}
+
+// Nm 输出 ELF 文件 name 的符号列表
+func Nm(w io.Writer, name string, opts NmOptions) error {
+	f, err := elf.ReadElf(name)
+	if err != nil {
+		return err
+	}
+	syms, err := nmSymbols(f, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	bw := bufio.NewWriter(w)
+	if opts.ShowFile {
+		fmt.Fprintf(bw, "\n%s:\n", name)
+	}
+	width := 8
+	if f.Is64() {
+		width = 16
+	}
+	for _, sym := range syms {
+		if sym.undefined() {
+			fmt.Fprintf(bw, "%*s %c %s\n", width, "", sym.Type, sym.Name)
+		} else {
+			fmt.Fprintf(bw, "%0*x %c %s\n", width, sym.Value, sym.Type, sym.Name)
+		}
+	}
+	return bw.Flush()
+}
+
+// nmSymbols 按选项筛选并排序符号表, 不包含空符号、段符号及文件符号
+func nmSymbols(f *elf.File, opts NmOptions) ([]nmSymbol, error) {
+	all := f.Symbols()
+	if len(all) == 0 {
+		return nil, fmt.Errorf("没有符号")
+	}
+	var syms []nmSymbol
+	for i, sym := range all {
+		typ := elf.ST_TYPE(sym.Info)
+		if i == 0 || typ == elf.STT_SECTION || typ == elf.STT_FILE {
+			continue
+		}
+		undef := sym.Shndx == uint16(elf.SHN_UNDEF)
+		if opts.DefinedOnly && undef || opts.UndefinedOnly && !undef {
+			continue
+		}
+		value := sym.Value
+		if sym.Shndx == uint16(elf.SHN_COMMON) { // 与 GNU nm 一致, 公共符号显示大小, 其值为对齐要求
+			value = sym.Size
+		}
+		syms = append(syms, nmSymbol{Name: f.SymNames[i], Value: value, Type: symbolType(f, sym)})
+	}
+
+	if !opts.NoSort {
+		less := func(a, b nmSymbol) bool {
+			if opts.NumericSort && a.undefined() != b.undefined() { // 未定义的符号在前
+				return a.undefined()
+			}
+			if opts.NumericSort && a.Value != b.Value {
+				return a.Value < b.Value
+			}
+			return a.Name < b.Name
+		}
+		sort.SliceStable(syms, func(i, j int) bool {
+			if opts.Reverse { // 逆序比较, 相同的符号保持原有顺序
+				return less(syms[j], syms[i])
+			}
+			return less(syms[i], syms[j])
+		})
+	}
+	return syms, nil
+}
+
+// symbolType 符号的类型字母: 由所在段的标志决定 T(代码), D(数据), B(未初始化数据), R(只读数据), N(其它);
+// U 未定义, A 绝对值, C 公共符号, W/w 已定义/未定义的弱符号; 局部符号为小写
+func symbolType(f *elf.File, sym *elf.Elf_Sym) byte {
+	bind := elf.ST_BIND(sym.Info)
+	switch {
+	case sym.Shndx == uint16(elf.SHN_UNDEF) && bind == elf.STB_WEAK:
+		return 'w'
+	case sym.Shndx == uint16(elf.SHN_UNDEF):
+		return 'U'
+	case bind == elf.STB_WEAK:
+		return 'W'
+	case sym.Shndx == uint16(elf.SHN_COMMON):
+		return 'C'
+	}
+
+	c := byte('N')
+	shdrs := f.Sections()
+	switch {
+	case sym.Shndx == uint16(elf.SHN_ABS):
+		c = 'A'
+	case int(sym.Shndx) < len(shdrs) && sym.Shndx < uint16(elf.SHN_LORESERVE):
+		sh := shdrs[sym.Shndx]
+		flags := elf.SectionFlag(sh.Flags)
+		switch {
+		case flags&elf.SHF_ALLOC == 0:
+		case flags&elf.SHF_EXECINSTR != 0:
+			c = 'T'
+		case sh.Type == uint32(elf.SHT_NOBITS):
+			c = 'B'
+		case flags&elf.SHF_WRITE != 0:
+			c = 'D'
+		default:
+			c = 'R'
+		}
+	}
+	if bind == elf.STB_LOCAL {
+		c += 'a' - 'A'
+	}
+	return c
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+const nmSrc = `
+	.globl _start, g
+	.weak w, wu
+	.text
+_start:
+	call ext
+	call wu
+loc:	ret
+w:	ret
+	.data
+g:	.long 1
+	.section .rodata, "a"
+ro:	.byte 1
+	.bss
+buf:	.zero 8
+	.comm cbuf, 64, 16
+`
+
+func TestNm(t *testing.T) {
+	name := assembleFile(t, "amd64", nmSrc)
+	tests := []struct {
+		opts NmOptions
+		want string
+	}{
+		{NmOptions{}, `
+0000000000000000 T _start
+0000000000000000 b buf
+0000000000000040 C cbuf
+                 U ext
+0000000000000000 D g
+000000000000000a t loc
+0000000000000000 r ro
+000000000000000b W w
+                 w wu
+`},
+		{NmOptions{NumericSort: true, DefinedOnly: true}, `
+0000000000000000 T _start
+0000000000000000 b buf
+0000000000000000 D g
+0000000000000000 r ro
+000000000000000a t loc
+000000000000000b W w
+0000000000000040 C cbuf
+`},
+		{NmOptions{UndefinedOnly: true, Reverse: true}, `
+                 w wu
+                 U ext
+`},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := Nm(&b, name, tt.opts); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != tt.want[1:] {
+			t.Errorf("%+v got:\n%s\nwant:\n%s", tt.opts, got, tt.want[1:])
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	name := assembleFile(t, "386", nmSrc)
+	var b strings.Builder
+	if err := Size(&b, name); err != nil {
+		t.Fatal(err)
+	}
+	// text: .text 12 字节及 .rodata 1 字节
+	if want := "     13\t      4\t      8\t     25\t     19\t" + name + "\n"; b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
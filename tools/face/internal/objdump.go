@@ -0,0 +1,312 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"golang.org/x/arch/arm64/arm64asm"
+	"golang.org/x/arch/x86/x86asm"
+	"io"
+	"sort"
+	"strings"
+)
+
+// 目标文件反汇编(face objdump), 支持 ELF32/ELF64 的 x86 及 ARM64 目标文件和可执行文件:
+//
+//	0000000000000000 <_start>:
+//	       0:	48 c7 c0 01 00 00 00 	mov $0x1,%rax
+//	       7:	e8 00 00 00 00       	call 0xc
+//	                        8: R_X86_64_PLT32	write-0x4
+//	       c:	eb f2                	jmp 0x0 <_start>
+
+// ObjdumpOptions objdump 的输出选项
+type ObjdumpOptions struct {
+	Headers bool   // -h 显示段表
+	Disasm  bool   // -d 反汇编可执行段
+	Syntax  string // -M 反汇编语法: att(默认), intel, plan9
+}
+
+// symbol 反汇编时用于标注地址的符号
+type symbol struct {
+	Name string
+	Addr uint64
+}
+
+// reloc 可重定位目标文件中的重定位项
+type reloc struct {
+	Offset uint64
+	Type   string
+	Sym    string
+	Addend int64
+}
+
+// disassembler 单个段的反汇编器
+type disassembler struct {
+	machine elf.Machine
+	syntax  string
+	exec    bool     // 可执行文件, 地址已经确定
+	width   int      // 符号地址的显示宽度, 32 位文件为 8, 64 位文件为 16
+	syms    []symbol // 按地址排序
+	rels    []reloc  // 按偏移排序
+}
+
+// Objdump 输出 ELF 文件 name 的段表及反汇编结果
+func Objdump(w io.Writer, name string, opts ObjdumpOptions) error {
+	f, err := elf.ReadElf(name)
+	if err != nil {
+		return err
+	}
+
+	switch machine := elf.Machine(f.Ehdr.Machine); machine {
+	case elf.EM_386, elf.EM_X86_64, elf.EM_AARCH64:
+	default:
+		return fmt.Errorf("%s: 不支持的架构 %s", name, machine)
+	}
+	switch opts.Syntax {
+	case "", "att", "intel", "plan9":
+	default:
+		return fmt.Errorf("不支持的反汇编语法: %s", opts.Syntax)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "\n%s:     文件格式 %s\n\n", name, fileFormat(f))
+	if opts.Headers {
+		sectionHeaders(bw, f)
+	}
+	if opts.Disasm {
+		if err := disasmFile(bw, f, opts.Syntax); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// fileFormat 与 GNU objdump 一致的文件格式名称
+func fileFormat(f *elf.File) string {
+	switch elf.Machine(f.Ehdr.Machine) {
+	case elf.EM_386:
+		return "elf32-i386"
+	case elf.EM_X86_64:
+		if !f.Is64() {
+			return "elf32-x86-64"
+		}
+		return "elf64-x86-64"
+	case elf.EM_AARCH64:
+		return "elf64-littleaarch64"
+	}
+	return "elf"
+}
+
+// sectionHeaders 输出段表
+func sectionHeaders(w io.Writer, f *elf.File) {
+	fmt.Fprintf(w, "Sections:\n")
+	fmt.Fprintf(w, "Idx %-16s %-8s %-16s %-8s %s\n", "Name", "Size", "Addr", "Off", "Flags")
+	for i, s := range f.Sections() {
+		if s.Type == uint32(elf.SHT_NULL) {
+			continue
+		}
+		fmt.Fprintf(w, "%3d %-16s %08x %016x %08x %s\n", i, f.ShdrNames[i], s.Size, s.Addr, s.Offset, elf.SectionFlag(s.Flags))
+	}
+	fmt.Fprintln(w)
+}
+
+// disasmFile 反汇编所有可执行段
+func disasmFile(w io.Writer, f *elf.File, syntax string) error {
+	machine, rel := elf.Machine(f.Ehdr.Machine), f.Ehdr.Type == uint16(elf.ET_REL)
+	syms := f.Symbols() // 没有符号表时不标注符号
+	for i, s := range f.Sections() {
+		if s.Type != uint32(elf.SHT_PROGBITS) || elf.SectionFlag(s.Flags)&elf.SHF_EXECINSTR == 0 {
+			continue
+		}
+		code, err := f.Reader.Slice(int(s.Offset), int(s.Size))
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.ShdrNames[i], err)
+		}
+		d := &disassembler{machine: machine, syntax: syntax, exec: !rel, width: 16}
+		if !f.Is64() {
+			d.width = 8
+		}
+		for j, sym := range syms {
+			typ := elf.ST_TYPE(sym.Info)
+			if j == 0 || typ == elf.STT_SECTION || typ == elf.STT_FILE || f.SymNames[j] == "" || sym.Shndx == uint16(elf.SHN_UNDEF) {
+				continue
+			}
+			// 可重定位目标文件只标注本段内的符号, 可执行文件使用全部符号的绝对地址
+			if rel && int(sym.Shndx) != i {
+				continue
+			}
+			d.syms = append(d.syms, symbol{Name: f.SymNames[j], Addr: sym.Value})
+		}
+		sort.SliceStable(d.syms, func(a, b int) bool { return d.syms[a].Addr < d.syms[b].Addr })
+		if rel {
+			d.rels = relocations(f, f.ShdrNames[i])
+		}
+
+		fmt.Fprintf(w, "\nDisassembly of section %s:\n", f.ShdrNames[i])
+		d.disasm(w, code, s.Addr)
+	}
+	return nil
+}
+
+// relocations 作用于段 sec 的重定位项, 段符号以段名显示
+func relocations(f *elf.File, sec string) []reloc {
+	var rels []reloc
+	machine := elf.Machine(f.Ehdr.Machine)
+	for _, r := range f.RelTab {
+		if r.SegName != sec {
+			continue
+		}
+		rels = append(rels, reloc{
+			Offset: r.Rel.Offset,
+			Type:   relocType(machine, r.Rel.Type),
+			Sym:    r.RelName,
+			Addend: r.Rel.Addend,
+		})
+	}
+	sort.SliceStable(rels, func(a, b int) bool { return rels[a].Offset < rels[b].Offset })
+	return rels
+}
+
+// relocType 重定位类型名称
+func relocType(machine elf.Machine, typ uint32) string {
+	switch machine {
+	case elf.EM_386:
+		return elf.R_386(typ).String()
+	case elf.EM_X86_64:
+		return elf.R_X86_64(typ).String()
+	case elf.EM_AARCH64:
+		return elf.R_AARCH64(typ).String()
+	}
+	return fmt.Sprint(typ)
+}
+
+// lookup 查找地址所在的符号: 地址不小于符号地址的最后一个符号
+func (d *disassembler) lookup(addr uint64) (symbol, bool) {
+	i := sort.Search(len(d.syms), func(i int) bool { return d.syms[i].Addr > addr })
+	if i == 0 {
+		return symbol{}, false
+	}
+	return d.syms[i-1], true
+}
+
+// annotate 地址的符号标注, 例如 <_start+0x4>
+func (d *disassembler) annotate(addr uint64) string {
+	sym, ok := d.lookup(addr)
+	if !ok {
+		return ""
+	}
+	if addr == sym.Addr {
+		return fmt.Sprintf(" <%s>", sym.Name)
+	}
+	return fmt.Sprintf(" <%s+%#x>", sym.Name, addr-sym.Addr)
+}
+
+// disasm 反汇编 code, 起始地址为 addr
+func (d *disassembler) disasm(w io.Writer, code []byte, addr uint64) {
+	next := 0 // 下一个待输出的符号
+	rel := 0  // 下一个待输出的重定位项
+	for off := 0; off < len(code); {
+		pc := addr + uint64(off)
+		for ; next < len(d.syms) && d.syms[next].Addr <= pc; next++ {
+			if d.syms[next].Addr == pc {
+				fmt.Fprintf(w, "\n%0*x <%s>:\n", d.width, pc, d.syms[next].Name)
+			}
+		}
+
+		n, text := d.decode(code[off:], pc)
+		text = strings.TrimSpace(text)
+		d.line(w, pc, code[off:off+n], text)
+		for ; rel < len(d.rels) && d.rels[rel].Offset < uint64(off+n); rel++ {
+			r := d.rels[rel]
+			if r.Addend != 0 {
+				fmt.Fprintf(w, "\t\t\t%x: %s\t%s%+#x\n", r.Offset, r.Type, r.Sym, r.Addend)
+			} else {
+				fmt.Fprintf(w, "\t\t\t%x: %s\t%s\n", r.Offset, r.Type, r.Sym)
+			}
+		}
+		off += n
+	}
+}
+
+// line 输出一条指令: 地址、机器码及汇编文本, x86 机器码每行最多 7 字节
+func (d *disassembler) line(w io.Writer, pc uint64, code []byte, text string) {
+	if d.machine == elf.EM_AARCH64 && len(code) == 4 { // 以小端序的指令字显示
+		fmt.Fprintf(w, "%8x:\t%02x%02x%02x%02x \t%s\n", pc, code[3], code[2], code[1], code[0], text)
+		return
+	}
+	for i := 0; i < len(code); i += 7 {
+		chunk := code[i:min(i+7, len(code))]
+		if i == 0 {
+			fmt.Fprintf(w, "%8x:\t%-21s\t%s\n", pc, fmt.Sprintf("% x ", chunk), text)
+		} else {
+			fmt.Fprintf(w, "%8x:\t%s\n", pc+uint64(i), fmt.Sprintf("% x", chunk))
+		}
+	}
+}
+
+// decode 解码一条指令, 返回长度及汇编文本; 无法解码时按一个字节(ARM64 为 4 字节)输出 (bad)
+func (d *disassembler) decode(code []byte, pc uint64) (int, string) {
+	if d.machine == elf.EM_AARCH64 {
+		if len(code) < 4 {
+			return len(code), "(bad)"
+		}
+		inst, err := arm64asm.Decode(code)
+		if err != nil {
+			return 4, "(bad)"
+		}
+		var text string
+		if d.syntax == "plan9" {
+			text = arm64asm.GoSyntax(inst, pc, d.symname, nil)
+		} else {
+			text = arm64asm.GNUSyntax(inst)
+		}
+		for _, arg := range inst.Args {
+			if rel, ok := arg.(arm64asm.PCRel); ok {
+				target := pc + uint64(rel)
+				if inst.Op == arm64asm.ADRP {
+					target = pc&^0xfff + uint64(rel)
+				}
+				if d.syntax != "plan9" {
+					text = strings.Replace(text, rel.String(), fmt.Sprintf("%#x", target), 1)
+				}
+				text += d.annotate(target)
+			}
+		}
+		return 4, text
+	}
+
+	mode := 64
+	if d.machine == elf.EM_386 {
+		mode = 32
+	}
+	inst, err := x86asm.Decode(code, mode)
+	if err != nil {
+		return 1, "(bad)"
+	}
+	var text string
+	switch d.syntax {
+	case "intel":
+		text = x86asm.IntelSyntax(inst, pc, d.symname)
+	case "plan9":
+		text = x86asm.GoSyntax(inst, pc, d.symname)
+	default:
+		text = x86asm.GNUSyntax(inst, pc, d.symname)
+	}
+	if rel, ok := inst.Args[0].(x86asm.Rel); ok { // 跳转目标统一显示为绝对地址及符号
+		target := pc + uint64(inst.Len) + uint64(int64(rel))
+		if i := strings.IndexByte(text, ' '); i > 0 {
+			text = text[:i]
+		}
+		text += fmt.Sprintf(" %#x%s", target, d.annotate(target))
+	}
+	return inst.Len, text
+}
+
+// symname 供 x/arch 格式化使用的符号查找, 只在地址与符号完全一致时返回符号名;
+// 可重定位目标文件中的地址尚未重定位, 不做标注
+func (d *disassembler) symname(addr uint64) (string, uint64) {
+	if sym, ok := d.lookup(addr); ok && d.exec && sym.Addr == addr {
+		return sym.Name, sym.Addr
+	}
+	return "", 0
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"github.com/facelang/face/internal/os/elf"
+	"strings"
+	"testing"
+)
+
+func TestDisasmX86(t *testing.T) {
+	d := &disassembler{
+		machine: elf.EM_X86_64,
+		width:   16,
+		syms:    []symbol{{Name: "_start", Addr: 0}, {Name: "loop", Addr: 7}},
+		rels:    []reloc{{Offset: 8, Type: "R_X86_64_PLT32", Sym: "write", Addend: -4}},
+	}
+	code := []byte{
+		0x48, 0xC7, 0xC0, 0x01, 0x00, 0x00, 0x00, // mov rax, 1
+		0xE8, 0x00, 0x00, 0x00, 0x00, // call write
+		0xEB, 0xF4, // jmp _start
+		0x0F, 0x0B, // ud2
+		0x06, // 64 位模式下无效的指令
+	}
+	var b strings.Builder
+	d.disasm(&b, code, 0)
+	want := `
+0000000000000000 <_start>:
+       0:	48 c7 c0 01 00 00 00 	mov $0x1,%rax
+
+0000000000000007 <loop>:
+       7:	e8 00 00 00 00       	callq 0xc <loop+0x5>
+			8: R_X86_64_PLT32	write-0x4
+       c:	eb f4                	jmp 0x2 <_start+0x2>
+       e:	0f 0b                	ud2
+      10:	06                   	(bad)
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDisasmARM64(t *testing.T) {
+	d := &disassembler{
+		machine: elf.EM_AARCH64,
+		width:   16,
+		syms:    []symbol{{Name: "main", Addr: 0x400000}, {Name: "done", Addr: 0x40000c}},
+	}
+	code := []byte{
+		0x1F, 0x20, 0x03, 0xD5, // nop
+		0x02, 0x00, 0x00, 0x94, // bl done
+		0xC0, 0x03, 0x5F, 0xD6, // ret
+		0x00, 0x00, 0x00, 0x90, // adrp x0, main
+	}
+	var b strings.Builder
+	d.disasm(&b, code, 0x400000)
+	want := `
+0000000000400000 <main>:
+  400000:	d503201f 	nop
+  400004:	94000002 	bl 0x40000c <done>
+  400008:	d65f03c0 	ret
+
+000000000040000c <done>:
+  40000c:	90000000 	adrp x0, 0x400000 <main>
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestObjdump(t *testing.T) {
+	name := assembleFile(t, "amd64", nmSrc)
+	var b strings.Builder
+	if err := Objdump(&b, name, ObjdumpOptions{Headers: true, Disasm: true}); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"文件格式 elf64-x86-64",
+		"  1 .text            0000000c 0000000000000000 00000040 SHF_ALLOC+SHF_EXECINSTR\n",
+		"\n0000000000000000 <_start>:\n       0:\te8 00 00 00 00       \tcallq 0x5 <_start+0x5>\n\t\t\t1: R_X86_64_PLT32\text-0x4\n",
+		"\t\t\t6: R_X86_64_PLT32\twu-0x4\n",
+		"\n000000000000000a <loc>:\n       a:\tc3                   \tretq\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("缺少 %q:\n%s", want, got)
+		}
+	}
+}
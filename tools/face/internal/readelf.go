@@ -0,0 +1,446 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"github.com/olekukonko/tablewriter"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ELF 文件信息(face readelf), 基于 internal/os/elf 读取, 以表格输出:
+//
+//	段 '.text' 的重定位(RELA) 包含 1 项:
+//	+------+---------------+-------+------+
+//	| 偏移 |     类型      | 符号  | 加数 |
+//	+------+---------------+-------+------+
+//	| 0xd  | R_X86_64_PC32 | .data | -4   |
+//	+------+---------------+-------+------+
+//
+// --json 时以 JSON 输出相同的内容, 便于脚本处理
+
+// ReadelfOptions readelf 的输出选项
+type ReadelfOptions struct {
+	Header   bool   // -h 显示文件头
+	Sections bool   // -S 显示段表
+	Symbols  bool   // -s 显示符号表
+	Relocs   bool   // -r 显示重定位表
+	Segments bool   // -l 显示程序头表
+	HexDump  string // -x 以十六进制显示指定段(段名或索引)的内容
+	JSON     bool   // --json 以 JSON 输出
+}
+
+// elfInfo readelf 的输出内容, 未选择的部分为空
+type elfInfo struct {
+	File     string       `json:"file"`
+	Header   *elfHeader   `json:"header,omitempty"`
+	Sections []elfSection `json:"sections,omitempty"`
+	Symbols  []elfSymbol  `json:"symbols,omitempty"`
+	Relocs   []elfReloc   `json:"relocations,omitempty"`
+	Segments []elfSegment `json:"segments,omitempty"`
+	HexDump  *elfHexDump  `json:"hexdump,omitempty"`
+}
+
+type elfHeader struct {
+	Class      string `json:"class"`
+	Data       string `json:"data"`
+	OSABI      string `json:"osabi"`
+	ABIVersion uint8  `json:"abiversion"`
+	Type       string `json:"type"`
+	Machine    string `json:"machine"`
+	Version    uint32 `json:"version"`
+	Entry      uint64 `json:"entry"`
+	Phoff      uint64 `json:"phoff"`
+	Shoff      uint64 `json:"shoff"`
+	Flags      uint32 `json:"flags"`
+	Ehsize     uint16 `json:"ehsize"`
+	Phentsize  uint16 `json:"phentsize"`
+	Phnum      uint16 `json:"phnum"`
+	Shentsize  uint16 `json:"shentsize"`
+	Shnum      uint16 `json:"shnum"`
+	Shstrndx   uint16 `json:"shstrndx"`
+}
+
+type elfSection struct {
+	Index   int    `json:"index"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Flags   string `json:"flags"`
+	Addr    uint64 `json:"addr"`
+	Offset  uint64 `json:"offset"`
+	Size    uint64 `json:"size"`
+	Link    uint32 `json:"link"`
+	Info    uint32 `json:"info"`
+	Align   uint64 `json:"align"`
+	Entsize uint64 `json:"entsize"`
+}
+
+type elfSymbol struct {
+	Index   int    `json:"index"`
+	Name    string `json:"name"`
+	Value   uint64 `json:"value"`
+	Size    uint64 `json:"size"`
+	Type    string `json:"type"`
+	Bind    string `json:"bind"`
+	Vis     string `json:"visibility"`
+	Section string `json:"section"`
+}
+
+type elfReloc struct {
+	Section string `json:"section"` // 被修正的段
+	Offset  uint64 `json:"offset"`
+	Type    string `json:"type"`
+	Symbol  string `json:"symbol"`
+	Addend  int64  `json:"addend"`
+	Rela    bool   `json:"rela"` // 加数记录在重定位项中(.rela), 否则在被修正的位置(.rel)
+}
+
+type elfSegment struct {
+	Type   string `json:"type"`
+	Flags  string `json:"flags"`
+	Offset uint64 `json:"offset"`
+	VAddr  uint64 `json:"vaddr"`
+	PAddr  uint64 `json:"paddr"`
+	Filesz uint64 `json:"filesz"`
+	Memsz  uint64 `json:"memsz"`
+	Align  uint64 `json:"align"`
+}
+
+type elfHexDump struct {
+	Section string `json:"section"`
+	Addr    uint64 `json:"addr"`
+	Data    string `json:"data"` // 十六进制字符串
+	raw     []byte
+}
+
+// Readelf 输出 ELF 文件 name 的信息
+func Readelf(w io.Writer, name string, opts ReadelfOptions) error {
+	f, err := elf.ReadElf(name)
+	if err != nil {
+		return err
+	}
+	info, err := readelfInfo(f, name, opts)
+	if err != nil {
+		return err
+	}
+	if opts.JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "\n%s:\n", name)
+	if info.Header != nil {
+		printHeader(bw, info.Header)
+	}
+	if opts.Sections {
+		printSections(bw, info.Sections)
+	}
+	if opts.Segments {
+		printSegments(bw, info.Segments)
+	}
+	if opts.Symbols {
+		printSymbols(bw, info.Symbols)
+	}
+	if opts.Relocs {
+		printRelocs(bw, info.Relocs)
+	}
+	if info.HexDump != nil {
+		printHexDump(bw, info.HexDump)
+	}
+	return bw.Flush()
+}
+
+// readelfInfo 收集选项指定的信息
+func readelfInfo(f *elf.File, name string, opts ReadelfOptions) (*elfInfo, error) {
+	info := &elfInfo{File: name}
+	machine := elf.Machine(f.Ehdr.Machine)
+	shdrs := f.Sections()
+	if opts.Header {
+		h := f.Ehdr
+		info.Header = &elfHeader{
+			Class:      elf.Class(h.Magic[elf.EI_CLASS]).String(),
+			Data:       elf.Data(h.Magic[elf.EI_DATA]).String(),
+			OSABI:      elf.OSABI(h.Magic[elf.EI_OSABI]).String(),
+			ABIVersion: h.Magic[elf.EI_ABIVERSION],
+			Type:       elf.Type(h.Type).String(),
+			Machine:    machine.String(),
+			Version:    h.Version,
+			Entry:      h.Entry,
+			Phoff:      h.Phoff,
+			Shoff:      h.Shoff,
+			Flags:      h.Flags,
+			Ehsize:     h.Ehsize,
+			Phentsize:  h.Phentsize,
+			Phnum:      h.Phnum,
+			Shentsize:  h.Shentsize,
+			Shnum:      h.Shnum,
+			Shstrndx:   h.Shstrndx,
+		}
+	}
+	if opts.Sections {
+		info.Sections = make([]elfSection, 0, len(shdrs))
+		for i, sh := range shdrs {
+			info.Sections = append(info.Sections, elfSection{
+				Index:   i,
+				Name:    f.ShdrNames[i],
+				Type:    elf.SectionType(sh.Type).String(),
+				Flags:   sectionFlags(sh.Flags),
+				Addr:    sh.Addr,
+				Offset:  sh.Offset,
+				Size:    sh.Size,
+				Link:    sh.Link,
+				Info:    sh.Info,
+				Align:   sh.Addralign,
+				Entsize: sh.Entsize,
+			})
+		}
+	}
+	if opts.Symbols {
+		syms := f.Symbols()
+		info.Symbols = make([]elfSymbol, 0, len(syms))
+		for i, sym := range syms {
+			name := f.SymNames[i]
+			if elf.ST_TYPE(sym.Info) == elf.STT_SECTION { // 段符号没有名称, 读取时以段名记录
+				name = ""
+			}
+			info.Symbols = append(info.Symbols, elfSymbol{
+				Index:   i,
+				Name:    name,
+				Value:   sym.Value,
+				Size:    sym.Size,
+				Type:    elf.ST_TYPE(sym.Info).String(),
+				Bind:    elf.ST_BIND(sym.Info).String(),
+				Vis:     elf.ST_VISIBILITY(sym.Other).String(),
+				Section: sectionName(f, sym.Shndx),
+			})
+		}
+	}
+	if opts.Relocs {
+		info.Relocs = make([]elfReloc, 0, len(f.RelTab))
+		for _, rel := range f.RelTab {
+			info.Relocs = append(info.Relocs, elfReloc{
+				Section: rel.SegName,
+				Offset:  rel.Rel.Offset,
+				Type:    relocName(machine, rel.Rel.Type),
+				Symbol:  rel.RelName,
+				Addend:  rel.Rel.Addend,
+				Rela:    rel.Rela,
+			})
+		}
+	}
+	if opts.Segments {
+		info.Segments = make([]elfSegment, 0, len(f.PhdrTab))
+		for _, ph := range f.PhdrTab {
+			info.Segments = append(info.Segments, elfSegment{
+				Type:   elf.ProgType(ph.Type).String(),
+				Flags:  progFlags(ph.Flags),
+				Offset: ph.Offset,
+				VAddr:  ph.VAddr,
+				PAddr:  ph.Paddr,
+				Filesz: ph.Filesz,
+				Memsz:  ph.Memsz,
+				Align:  ph.Align,
+			})
+		}
+	}
+	if opts.HexDump != "" {
+		index := f.GetSegIndex(opts.HexDump)
+		if n, err := strconv.Atoi(opts.HexDump); index < 0 && err == nil {
+			index = n
+		}
+		if index <= 0 || index >= len(shdrs) {
+			return nil, fmt.Errorf("%s: 没有段 %s", name, opts.HexDump)
+		}
+		sh := shdrs[index]
+		if sh.Type == uint32(elf.SHT_NOBITS) {
+			return nil, fmt.Errorf("%s: 段 %s 在文件中没有数据", name, f.ShdrNames[index])
+		}
+		data, err := f.Reader.Slice(int(sh.Offset), int(sh.Size))
+		if err != nil {
+			return nil, fmt.Errorf("%s: 段 %s: %w", name, f.ShdrNames[index], err)
+		}
+		info.HexDump = &elfHexDump{Section: f.ShdrNames[index], Addr: sh.Addr, Data: fmt.Sprintf("%x", data), raw: data}
+	}
+	return info, nil
+}
+
+// sectionFlags 段标志, 例如 AX; 与 GNU readelf 的缩写一致
+func sectionFlags(flags uint64) string {
+	var b strings.Builder
+	for _, f := range []struct {
+		flag elf.SectionFlag
+		c    byte
+	}{
+		{elf.SHF_WRITE, 'W'}, {elf.SHF_ALLOC, 'A'}, {elf.SHF_EXECINSTR, 'X'}, {elf.SHF_MERGE, 'M'},
+		{elf.SHF_STRINGS, 'S'}, {elf.SHF_INFO_LINK, 'I'}, {elf.SHF_LINK_ORDER, 'L'},
+		{elf.SHF_OS_NONCONFORMING, 'O'}, {elf.SHF_GROUP, 'G'}, {elf.SHF_TLS, 'T'}, {elf.SHF_COMPRESSED, 'C'},
+	} {
+		if flags&uint64(f.flag) != 0 {
+			b.WriteByte(f.c)
+		}
+	}
+	return b.String()
+}
+
+// progFlags 程序头表项的权限, 例如 R E
+func progFlags(flags uint32) string {
+	b := []byte("   ")
+	if flags&uint32(elf.PF_R) != 0 {
+		b[0] = 'R'
+	}
+	if flags&uint32(elf.PF_W) != 0 {
+		b[1] = 'W'
+	}
+	if flags&uint32(elf.PF_X) != 0 {
+		b[2] = 'E'
+	}
+	return string(b)
+}
+
+// sectionName 符号所在段的名称, 特殊的段索引显示为 SHN_ABS 等
+func sectionName(f *elf.File, shndx uint16) string {
+	if shndx == uint16(elf.SHN_UNDEF) || shndx >= uint16(elf.SHN_LORESERVE) || int(shndx) >= len(f.ShdrNames) {
+		return elf.SectionIndex(shndx).String()
+	}
+	return f.ShdrNames[shndx]
+}
+
+// relocName 按架构解码重定位类型名称
+func relocName(machine elf.Machine, typ uint32) string {
+	switch machine {
+	case elf.EM_386:
+		return elf.R_386(typ).String()
+	case elf.EM_X86_64:
+		return elf.R_X86_64(typ).String()
+	case elf.EM_AARCH64:
+		return elf.R_AARCH64(typ).String()
+	case elf.EM_RISCV:
+		return elf.R_RISCV(typ).String()
+	}
+	return strconv.Itoa(int(typ))
+}
+
+// newTable 创建左对齐、不改写表头的表格
+func newTable(w io.Writer, header ...string) *tablewriter.Table {
+	t := tablewriter.NewWriter(w)
+	t.SetHeader(header)
+	t.SetAutoFormatHeaders(false)
+	t.SetAutoWrapText(false)
+	t.SetAlignment(tablewriter.ALIGN_LEFT)
+	return t
+}
+
+func hex(v uint64) string { return fmt.Sprintf("0x%x", v) }
+
+func printHeader(w io.Writer, h *elfHeader) {
+	fmt.Fprintf(w, "\nELF 文件头:\n")
+	t := newTable(w, "字段", "值")
+	t.AppendBulk([][]string{
+		{"类别", h.Class},
+		{"字节序", h.Data},
+		{"OS/ABI", h.OSABI},
+		{"ABI 版本", strconv.Itoa(int(h.ABIVersion))},
+		{"类型", h.Type},
+		{"架构", h.Machine},
+		{"版本", strconv.Itoa(int(h.Version))},
+		{"入口地址", hex(h.Entry)},
+		{"程序头表偏移", fmt.Sprintf("%d (bytes)", h.Phoff)},
+		{"段表偏移", fmt.Sprintf("%d (bytes)", h.Shoff)},
+		{"标志", hex(uint64(h.Flags))},
+		{"文件头大小", fmt.Sprintf("%d (bytes)", h.Ehsize)},
+		{"程序头表项大小", fmt.Sprintf("%d (bytes)", h.Phentsize)},
+		{"程序头表项数", strconv.Itoa(int(h.Phnum))},
+		{"段表项大小", fmt.Sprintf("%d (bytes)", h.Shentsize)},
+		{"段表项数", strconv.Itoa(int(h.Shnum))},
+		{"段表字符串表索引", strconv.Itoa(int(h.Shstrndx))},
+	})
+	t.Render()
+}
+
+func printSections(w io.Writer, secs []elfSection) {
+	fmt.Fprintf(w, "\n段表:\n")
+	t := newTable(w, "序号", "名称", "类型", "地址", "偏移", "大小", "表项大小", "标志", "链接", "附加", "对齐")
+	for _, s := range secs {
+		t.Append([]string{
+			fmt.Sprintf("[%d]", s.Index), s.Name, s.Type, hex(s.Addr), hex(s.Offset), hex(s.Size),
+			hex(s.Entsize), s.Flags, strconv.Itoa(int(s.Link)), strconv.Itoa(int(s.Info)), strconv.FormatUint(s.Align, 10),
+		})
+	}
+	t.Render()
+	fmt.Fprintf(w, "标志: W (write), A (alloc), X (execute), M (merge), S (strings), I (info),\n")
+	fmt.Fprintf(w, "  L (link order), O (extra OS processing required), G (group), T (TLS), C (compressed)\n")
+}
+
+func printSegments(w io.Writer, segs []elfSegment) {
+	fmt.Fprintf(w, "\n程序头表:\n")
+	if len(segs) == 0 {
+		fmt.Fprintf(w, "没有程序头表\n")
+		return
+	}
+	t := newTable(w, "类型", "偏移", "虚拟地址", "物理地址", "文件大小", "内存大小", "标志", "对齐")
+	for _, s := range segs {
+		t.Append([]string{s.Type, hex(s.Offset), hex(s.VAddr), hex(s.PAddr), hex(s.Filesz), hex(s.Memsz), s.Flags, hex(s.Align)})
+	}
+	t.Render()
+}
+
+func printSymbols(w io.Writer, syms []elfSymbol) {
+	fmt.Fprintf(w, "\n符号表 '.symtab' 包含 %d 个符号:\n", len(syms))
+	t := newTable(w, "序号", "值", "大小", "类型", "绑定", "可见性", "所在段", "名称")
+	for _, s := range syms {
+		t.Append([]string{
+			fmt.Sprintf("%d", s.Index), hex(s.Value), strconv.FormatUint(s.Size, 10), s.Type, s.Bind, s.Vis, s.Section, s.Name,
+		})
+	}
+	t.Render()
+}
+
+func printRelocs(w io.Writer, rels []elfReloc) {
+	if len(rels) == 0 {
+		fmt.Fprintf(w, "\n没有重定位信息\n")
+		return
+	}
+	for i := 0; i < len(rels); {
+		j := i + 1 // 同一个段的重定位项
+		for j < len(rels) && rels[j].Section == rels[i].Section && rels[j].Rela == rels[i].Rela {
+			j++
+		}
+		kind := "REL"
+		if rels[i].Rela {
+			kind = "RELA"
+		}
+		fmt.Fprintf(w, "\n段 '%s' 的重定位(%s) 包含 %d 项:\n", rels[i].Section, kind, j-i)
+		t := newTable(w, "偏移", "类型", "符号", "加数")
+		for _, r := range rels[i:j] {
+			t.Append([]string{hex(r.Offset), r.Type, r.Symbol, strconv.FormatInt(r.Addend, 10)})
+		}
+		t.Render()
+		i = j
+	}
+}
+
+// printHexDump 与 GNU readelf -x 相同的格式: 地址, 4 组 4 字节十六进制, 可打印字符
+func printHexDump(w io.Writer, d *elfHexDump) {
+	fmt.Fprintf(w, "\n段 '%s' 的十六进制内容:\n", d.Section)
+	for off := 0; off < len(d.raw); off += 16 {
+		line := d.raw[off:min(off+16, len(d.raw))]
+		var hexs, text strings.Builder
+		for i, b := range line {
+			fmt.Fprintf(&hexs, "%02x", b)
+			if i%4 == 3 {
+				hexs.WriteByte(' ')
+			}
+			if b >= 0x20 && b < 0x7f {
+				text.WriteByte(b)
+			} else {
+				text.WriteByte('.')
+			}
+		}
+		fmt.Fprintf(w, "  0x%08x %-36s%s\n", d.Addr+uint64(off), hexs.String(), text.String())
+	}
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"encoding/json"
+	"github.com/facelang/face/compiler/assemble/asm"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// assembleFile 汇编 src 并写入临时目录, 返回目标文件路径
+func assembleFile(t *testing.T, arch, src string) string {
+	t.Helper()
+	obj, err := asm.Assemble([]byte(src), asm.Options{Arch: arch, Syntax: "att"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), arch+".o")
+	if err := os.WriteFile(name, obj.Data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+const readelfSrc = `
+	.globl _start
+	.text
+_start:
+	call write
+	movl $msg, %eax
+	ret
+	.data
+msg:	.ascii "hi!\n"
+	.bss
+buf:	.zero 16
+`
+
+func TestReadelf(t *testing.T) {
+	tests := []struct {
+		arch string
+		want []string
+	}{
+		{"386", []string{"ELFCLASS32", "EM_386", "R_386_PC32", "R_386_32", "段 '.text' 的重定位(REL) 包含 2 项"}},
+		{"amd64", []string{"ELFCLASS64", "EM_X86_64", "R_X86_64_PLT32", "R_X86_64_32", "段 '.text' 的重定位(RELA) 包含 2 项"}},
+	}
+	for _, tt := range tests {
+		name := assembleFile(t, tt.arch, readelfSrc)
+		var b strings.Builder
+		err := Readelf(&b, name, ReadelfOptions{Header: true, Sections: true, Symbols: true, Relocs: true, Segments: true, HexDump: ".data"})
+		if err != nil {
+			t.Errorf("%s: %v", tt.arch, err)
+			continue
+		}
+		out := b.String()
+		want := append(tt.want, "ET_REL", "| .bss ", "SHT_NOBITS", "| _start ", "STB_GLOBAL", "| write ", "SHN_UNDEF",
+			"没有程序头表", "  0x00000000 6869210a                            hi!.")
+		for _, s := range want {
+			if !strings.Contains(out, s) {
+				t.Errorf("%s: 输出中没有 %q:\n%s", tt.arch, s, out)
+			}
+		}
+
+		if err := Readelf(&b, name, ReadelfOptions{HexDump: ".bss"}); err == nil {
+			t.Errorf("%s: -x .bss 没有返回错误", tt.arch)
+		}
+	}
+}
+
+func TestReadelfJSON(t *testing.T) {
+	name := assembleFile(t, "arm64", "\t.globl f\nf:\n\tbl g\n\tret\n")
+	var b strings.Builder
+	if err := Readelf(&b, name, ReadelfOptions{Header: true, Relocs: true, HexDump: "1", JSON: true}); err != nil {
+		t.Fatal(err)
+	}
+	var info struct {
+		Header struct {
+			Machine   string
+			Shentsize int
+		}
+		Relocations []elfReloc
+		Hexdump     struct {
+			Section string
+			Data    string
+		}
+	}
+	if err := json.Unmarshal([]byte(b.String()), &info); err != nil {
+		t.Fatalf("%v:\n%s", err, b.String())
+	}
+	if info.Header.Machine != "EM_AARCH64" || info.Header.Shentsize != 64 {
+		t.Errorf("header got %+v", info.Header)
+	}
+	if len(info.Relocations) != 1 || info.Relocations[0].Type != "R_AARCH64_CALL26" || info.Relocations[0].Symbol != "g" || !info.Relocations[0].Rela {
+		t.Errorf("relocations got %+v", info.Relocations)
+	}
+	if info.Hexdump.Section != ".text" || info.Hexdump.Data != "00000094c0035fd6" {
+		t.Errorf("hexdump got %+v", info.Hexdump)
+	}
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/facelang/face/internal/os/elf"
+	"io"
+)
+
+// 段大小统计(face size), 与 GNU size 的 Berkeley 格式一致:
+//
+//	   text	   data	    bss	    dec	    hex	filename
+//	     49	     13	      0	     62	     3e	hello.o
+
+// SizeHeader size 输出的表头
+const SizeHeader = "   text\t   data\t    bss\t    dec\t    hex\tfilename\n"
+
+// Size 输出 ELF 文件 name 中需要加载的段的大小: text 为代码及只读数据, data 为可写数据, bss 为未初始化数据
+func Size(w io.Writer, name string) error {
+	f, err := elf.ReadElf(name)
+	if err != nil {
+		return err
+	}
+	text, data, bss := sectionSizes(f)
+	total := text + data + bss
+	_, err = fmt.Fprintf(w, "%7d\t%7d\t%7d\t%7d\t%7x\t%s\n", text, data, bss, total, total, name)
+	return err
+}
+
+// sectionSizes 按段标志统计 SHF_ALLOC 段的大小
+func sectionSizes(f *elf.File) (text, data, bss uint64) {
+	for _, sh := range f.Sections() {
+		flags := elf.SectionFlag(sh.Flags)
+		switch {
+		case flags&elf.SHF_ALLOC == 0:
+		case sh.Type == uint32(elf.SHT_NOBITS):
+			bss += sh.Size
+		case flags&elf.SHF_WRITE != 0 && flags&elf.SHF_EXECINSTR == 0:
+			data += sh.Size
+		default:
+			text += sh.Size
+		}
+	}
+	return text, data, bss
+}
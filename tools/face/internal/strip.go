@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"github.com/facelang/face/internal/os/elf"
+	"os"
+)
+
+// Strip 去除 ELF 文件 name 的调试信息, 可执行文件及共享库同时去除符号表, 写入 output; output 为空时覆盖原文件, 保留文件权限
+func Strip(name, output string) error {
+	f, err := elf.ReadElf(name)
+	if err != nil {
+		return err
+	}
+	data, err := f.Strip()
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		output = name
+	}
+	if err := os.WriteFile(output, data, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chmod(output, info.Mode().Perm()) // 覆盖已存在的文件时 WriteFile 不修改权限
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"github.com/facelang/face/compiler/assemble/asm"
+	"github.com/facelang/face/internal/os/elf"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStrip(t *testing.T) {
+	obj, err := asm.Assemble([]byte(nmSrc), asm.Options{Arch: "amd64", Syntax: "att", Debug: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	name, output := filepath.Join(dir, "a.o"), filepath.Join(dir, "b.o")
+	if err := os.WriteFile(name, obj.Data, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := elf.ReadElf(name); err != nil || !strings.Contains(strings.Join(f.ShdrNames, ","), ".debug_line") {
+		t.Fatalf("没有生成调试信息: %v", err)
+	}
+	if err := Strip(name, output); err != nil {
+		t.Fatal(err)
+	}
+
+	// 可重定位目标文件只去除调试信息, 符号表及其余重定位表保留
+	f, err := elf.ReadElf(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections := strings.Join(f.ShdrNames, ",")
+	for _, want := range []string{".symtab", ".strtab", ".rela.text"} {
+		if !strings.Contains(sections, want) {
+			t.Errorf("段 %s 被去除: %s", want, sections)
+		}
+	}
+	if strings.Contains(sections, ".debug") {
+		t.Errorf("调试信息段没有去除: %s", sections)
+	}
+	if info, err := os.Stat(output); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("文件权限 got %v, %v", info.Mode(), err)
+	}
+
+	var before, after strings.Builder
+	if err := Nm(&before, name, NmOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Nm(&after, output, NmOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if before.String() != after.String() {
+		t.Errorf("nm got\n%s\nwant\n%s", after.String(), before.String())
+	}
+}
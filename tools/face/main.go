@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/facelang/face/tools/face/internal"
+	"os"
+)
+
+// command face 的子命令
+type command struct {
+	Name  string
+	Short string                   // 简要说明
+	Run   func(args []string) bool // 执行命令, 返回是否成功
+}
+
+var commands = []*command{
+	{Name: "objdump", Short: "显示目标文件信息及反汇编结果", Run: runObjdump},
+	{Name: "readelf", Short: "显示 ELF 文件的文件头、段表、符号表、重定位表及程序头表", Run: runReadelf},
+	{Name: "nm", Short: "列出目标文件的符号", Run: runNm},
+	{Name: "size", Short: "显示目标文件的代码、数据及 bss 段大小", Run: runSize},
+	{Name: "strip", Short: "去除目标文件的调试信息, 可执行文件同时去除符号表", Run: runStrip},
+	{Name: "ar", Short: "创建及更新静态库", Run: runAr},
+}
+
+func Usage() {
+	fmt.Fprintf(os.Stderr, "usage: face <command> [arguments]\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "\t%-10s %s\n", cmd.Name, cmd.Short)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		Usage()
+	}
+	for _, cmd := range commands {
+		if cmd.Name == os.Args[1] {
+			if !cmd.Run(os.Args[2:]) {
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "face: 未知命令 %s\n", os.Args[1])
+	Usage()
+}
+
+// newFlagSet 创建子命令的参数解析器
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: face %s [options] %s\n", name, args)
+		fmt.Fprintf(os.Stderr, "Flags:\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	return fs
+}
+
+func runObjdump(args []string) bool {
+	fs := newFlagSet("objdump", "file ...")
+	var opts internal.ObjdumpOptions
+	fs.BoolVar(&opts.Disasm, "d", false, "反汇编可执行段")
+	fs.BoolVar(&opts.Headers, "h", false, "显示段表")
+	fs.StringVar(&opts.Syntax, "M", "att", "反汇编语法: att, intel, plan9")
+	fs.Parse(args)
+	if fs.NArg() == 0 || !opts.Disasm && !opts.Headers {
+		fs.Usage()
+	}
+
+	ok := true
+	for _, f := range fs.Args() {
+		if err := internal.Objdump(os.Stdout, f, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "face objdump: %s\n", err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+func runReadelf(args []string) bool {
+	fs := newFlagSet("readelf", "file ...")
+	var opts internal.ReadelfOptions
+	fs.BoolVar(&opts.Header, "h", false, "显示文件头")
+	fs.BoolVar(&opts.Sections, "S", false, "显示段表")
+	fs.BoolVar(&opts.Symbols, "s", false, "显示符号表")
+	fs.BoolVar(&opts.Relocs, "r", false, "显示重定位表")
+	fs.BoolVar(&opts.Segments, "l", false, "显示程序头表")
+	fs.StringVar(&opts.HexDump, "x", "", "以十六进制显示段的内容(段名或索引)")
+	fs.BoolVar(&opts.JSON, "json", false, "以 JSON 输出")
+	fs.Parse(args)
+	if fs.NArg() == 0 || !opts.Header && !opts.Sections && !opts.Symbols && !opts.Relocs && !opts.Segments && opts.HexDump == "" {
+		fs.Usage()
+	}
+
+	ok := true
+	for _, f := range fs.Args() {
+		if err := internal.Readelf(os.Stdout, f, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "face readelf: %s\n", err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+func runNm(args []string) bool {
+	fs := newFlagSet("nm", "file ...")
+	var opts internal.NmOptions
+	fs.BoolVar(&opts.DefinedOnly, "defined-only", false, "只显示已定义的符号")
+	fs.BoolVar(&opts.UndefinedOnly, "undefined-only", false, "只显示未定义的符号")
+	fs.BoolVar(&opts.NumericSort, "n", false, "按地址排序")
+	fs.BoolVar(&opts.NoSort, "p", false, "不排序, 按符号表中的顺序")
+	fs.BoolVar(&opts.Reverse, "r", false, "逆序")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+	}
+	opts.ShowFile = fs.NArg() > 1
+
+	ok := true
+	for _, f := range fs.Args() {
+		if err := internal.Nm(os.Stdout, f, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "face nm: %s\n", err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+func runSize(args []string) bool {
+	fs := newFlagSet("size", "file ...")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+	}
+
+	ok := true
+	fmt.Print(internal.SizeHeader)
+	for _, f := range fs.Args() {
+		if err := internal.Size(os.Stdout, f); err != nil {
+			fmt.Fprintf(os.Stderr, "face size: %s\n", err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+func runStrip(args []string) bool {
+	fs := newFlagSet("strip", "file ...")
+	output := fs.String("o", "", "输出文件, 默认覆盖原文件(只能用于单个文件)")
+	fs.Parse(args)
+	if fs.NArg() == 0 || *output != "" && fs.NArg() > 1 {
+		fs.Usage()
+	}
+
+	ok := true
+	for _, f := range fs.Args() {
+		if err := internal.Strip(f, *output); err != nil {
+			fmt.Fprintf(os.Stderr, "face strip: %s: %s\n", f, err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+func runAr(args []string) bool {
+	fs := newFlagSet("ar", "rcs archive file ...")
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+	}
+	opts, err := internal.ParseArOptions(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "face ar: %s\n", err)
+		fs.Usage()
+	}
+
+	if err := internal.Ar(os.Stderr, fs.Arg(1), fs.Args()[2:], opts); err != nil {
+		fmt.Fprintf(os.Stderr, "face ar: %s\n", err)
+		return false
+	}
+	return true
+}